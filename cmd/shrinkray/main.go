@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
@@ -20,9 +21,17 @@ import (
 	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
 	"github.com/gwlsn/shrinkray/internal/jobs"
 	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/notify"
+	"github.com/gwlsn/shrinkray/internal/rules"
 	"github.com/gwlsn/shrinkray/internal/store"
+	"github.com/gwlsn/shrinkray/internal/trash"
+	"github.com/gwlsn/shrinkray/internal/util"
+	"github.com/gwlsn/shrinkray/internal/watch"
 )
 
+// trashPurgeInterval is how often expired originals are purged from the trash
+const trashPurgeInterval = time.Hour
+
 func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "", "Path to config file (default: ./config/shrinkray.yaml)")
@@ -67,6 +76,11 @@ func main() {
 		cfg.TempPath = envTemp
 	}
 
+	// Override trash path with environment variable
+	if envTrash := os.Getenv("TRASH_PATH"); envTrash != "" {
+		cfg.TrashPath = envTrash
+	}
+
 	// Auto-detect /temp mount if temp_path is still not configured
 	if cfg.TempPath == "" {
 		if info, err := os.Stat("/temp"); err == nil && info.IsDir() {
@@ -74,9 +88,18 @@ func main() {
 		}
 	}
 
-	// Validate media path exists
-	if _, err := os.Stat(cfg.MediaPath); os.IsNotExist(err) {
-		logger.Error("Media path does not exist", "path", cfg.MediaPath)
+	// Validate media paths exist. With several roots, one can be missing
+	// (e.g. an unmounted share) as long as another is there.
+	mediaFound := false
+	for _, root := range cfg.Roots() {
+		if _, err := os.Stat(root.Path); os.IsNotExist(err) {
+			logger.Warn("Media path does not exist", "name", root.Name, "path", root.Path)
+			continue
+		}
+		mediaFound = true
+	}
+	if !mediaFound {
+		logger.Error("No media path exists", "paths", strings.Join(cfg.MediaPaths(), ", "))
 		os.Exit(1)
 	}
 
@@ -91,6 +114,18 @@ func main() {
 		logger.Warn("Could not create config directory", "error", err)
 	}
 
+	// Load user-defined presets (optional, merged with built-in presets at InitPresets)
+	userPresetsPath := filepath.Join(configDir, ffmpeg.UserPresetsFile)
+	userPresets, err := ffmpeg.LoadUserPresets(userPresetsPath)
+	if err != nil {
+		logger.Error("Failed to load user presets", "error", err)
+		os.Exit(1)
+	}
+	ffmpeg.SetUserPresets(userPresets)
+	if len(userPresets) > 0 {
+		logger.Info("Loaded user presets", "count", len(userPresets), "path", userPresetsPath)
+	}
+
 	// Initialize SQLite store (handles migration from JSON if needed)
 	jobStore, err := store.InitStore(configDir)
 	if err != nil {
@@ -99,6 +134,26 @@ func main() {
 	}
 	defer jobStore.Close()
 
+	// Set up the trash for originals (original_handling: trash).
+	// Created whenever trash_path is set so originals already in it keep being purged.
+	var originalTrash *trash.Trash
+	if cfg.TrashPath != "" {
+		originalTrash, err = newTrash(cfg, jobStore)
+		if err != nil {
+			logger.Warn("Trash disabled", "path", cfg.TrashPath, "error", err)
+		}
+	}
+	if cfg.OriginalHandling == "trash" && originalTrash == nil {
+		logger.Warn("original_handling is 'trash' but no usable trash_path is configured, keeping originals as .old instead")
+		cfg.OriginalHandling = "keep"
+	}
+	if cfg.DiskSpaceReserve != "" {
+		if _, err := util.ParseBytes(cfg.DiskSpaceReserve); err != nil {
+			logger.Warn("Ignoring disk_space_reserve", "error", err)
+			cfg.DiskSpaceReserve = ""
+		}
+	}
+
 	fmt.Println("╔═══════════════════════════════════════════════════════════╗")
 	fmt.Println("║                         SHRINKRAY                         ║")
 	fmt.Println("║          Simple, user-friendly video transcoding          ║")
@@ -107,7 +162,14 @@ func main() {
 	fmt.Printf("║%*s%s%*s║\n", padding/2, "", versionLine, (padding+1)/2, "")
 	fmt.Println("╚═══════════════════════════════════════════════════════════╝")
 	fmt.Println()
-	fmt.Printf("  Media path:   %s\n", cfg.MediaPath)
+	if len(cfg.MediaRoots) > 0 {
+		fmt.Printf("  Media roots:\n")
+		for _, root := range cfg.MediaRoots {
+			fmt.Printf("    %s: %s\n", root.Name, root.Path)
+		}
+	} else {
+		fmt.Printf("  Media path:   %s\n", cfg.MediaPath)
+	}
 	fmt.Printf("  Config:       %s\n", cfgPath)
 	fmt.Printf("  Database:     %s\n", jobStore.Path())
 	if cfg.TempPath != "" {
@@ -117,6 +179,12 @@ func main() {
 	}
 	fmt.Printf("  Workers:      %d\n", cfg.Workers)
 	fmt.Printf("  Original:     %s\n", cfg.OriginalHandling)
+	if originalTrash != nil {
+		fmt.Printf("  Trash:        %s\n", originalTrash.Dir())
+	}
+	for _, folder := range cfg.WatchFolders {
+		fmt.Printf("  Watching:     %s (%s)\n", folder.Path, folder.PresetID)
+	}
 	fmt.Printf("  FFmpeg:       %s\n", cfg.FFmpegPath)
 	fmt.Printf("  FFprobe:      %s\n", cfg.FFprobePath)
 	fmt.Println()
@@ -155,7 +223,13 @@ func main() {
 
 	// Initialize components
 	prober := ffmpeg.NewProber(cfg.FFprobePath)
-	browser := browse.NewBrowser(prober, cfg.MediaPath)
+	var browseRoots []browse.Root
+	for _, root := range cfg.Roots() {
+		browseRoots = append(browseRoots, browse.Root{Name: root.Name, Path: root.Path})
+	}
+	browser := browse.NewBrowserWithRoots(prober, browseRoots)
+	browser.SetExcludes(cfg.Exclude)
+	browser.SetIndex(jobStore) // Persists probe results across restarts and backs library search
 
 	queue, err := jobs.NewQueueWithStore(jobStore)
 	if err != nil {
@@ -164,14 +238,37 @@ func main() {
 		os.Exit(1) //nolint:gocritic // store closed explicitly above
 	}
 	queue.SetAllowSameCodec(cfg.AllowSameCodec)
+	queue.SetConfig(cfg)
 
 	workerPool := jobs.NewWorkerPool(queue, cfg, browser.InvalidateCache)
+	if originalTrash != nil {
+		workerPool.SetTrash(originalTrash)
+	}
 
 	// Create API handler
 	handler := api.NewHandler(browser, queue, workerPool, cfg, cfgPath)
 	handler.SetStore(jobStore) // Enable session/lifetime stats
+
+	// Watch folders are scanned even when none are configured yet, so folders
+	// added from the UI take effect without a restart
+	watcher := watch.New(queue, browser, jobStore, validWatchFolders(cfg),
+		time.Duration(cfg.WatchStableMinutes)*time.Minute)
+	handler.SetWatcher(watcher)
+
+	queueRules, err := rules.Compile(cfg.QueueRules, cfg.MediaPaths())
+	if err != nil {
+		logger.Warn("Queue rules disabled", "error", err)
+	} else if len(queueRules) > 0 {
+		logger.Info("Loaded queue rules", "count", len(queueRules), "interval_hours", cfg.QueueRulesIntervalHours)
+	}
+	ruleRunner := rules.New(queue, browser, cfg.MediaPaths(), queueRules)
+	handler.SetRules(ruleRunner)
 	router := api.NewRouter(handler, shrinkray.WebFS)
 
+	// Send notifications from the server, independent of connected browsers
+	dispatcher := notify.NewDispatcher(queue, cfg)
+	dispatcher.Start()
+
 	// Start worker pool
 	workerPool.Start()
 
@@ -186,6 +283,18 @@ func main() {
 	fmt.Println("─────────────────────────────────────────────────────────────")
 	logger.Info("Shrinkray started", "version", shrinkray.Version, "encoder", best.Name, "workers", cfg.Workers, "port", *port)
 	go browser.WarmCountCache(context.Background())
+	go func() {
+		if _, err := browser.PruneIndex(context.Background()); err != nil {
+			logger.Warn("Failed to prune library index", "error", err)
+		}
+	}()
+	if originalTrash != nil {
+		go originalTrash.Run(context.Background(), trashPurgeInterval)
+	}
+	go watcher.Run(context.Background(), time.Duration(cfg.WatchIntervalMinutes)*time.Minute)
+	if len(queueRules) > 0 && cfg.QueueRulesIntervalHours > 0 {
+		go ruleRunner.Run(context.Background(), time.Duration(cfg.QueueRulesIntervalHours)*time.Hour)
+	}
 	if vmaf.IsAvailable() {
 		logger.Info("VMAF support detected", "models", vmaf.GetModels())
 		logger.Info("VMAF scoring configured", "max_score_workers", vmaf.MaxScoreWorkers, "gomaxprocs", runtime.GOMAXPROCS(0))
@@ -209,6 +318,7 @@ func main() {
 		fmt.Println("\n  Shutting down...")
 		logger.Info("Shutdown signal received")
 		workerPool.Stop()
+		dispatcher.Stop()
 		server.Close()
 	}()
 
@@ -222,3 +332,45 @@ func main() {
 	logger.Info("Server stopped")
 	fmt.Println("  Goodbye!")
 }
+
+// newTrash validates the trash settings and creates the trash directory
+func newTrash(cfg *config.Config, jobStore *store.SQLiteStore) (*trash.Trash, error) {
+	if err := config.ValidateTrashPath(cfg.TrashPath, cfg.MediaPaths()...); err != nil {
+		return nil, err
+	}
+
+	var maxSize int64
+	if cfg.TrashMaxSize != "" {
+		size, err := util.ParseBytes(cfg.TrashMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("trash_max_size: %w", err)
+		}
+		maxSize = size
+	}
+
+	if err := os.MkdirAll(cfg.TrashPath, 0755); err != nil {
+		return nil, err
+	}
+
+	retention := time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+	return trash.New(cfg.TrashPath, cfg.MediaPaths(), retention, maxSize, jobStore), nil
+}
+
+// validWatchFolders returns the configured watch folders that exist under a
+// media path, warning about the rest
+func validWatchFolders(cfg *config.Config) []config.WatchFolder {
+	var folders []config.WatchFolder
+	for _, folder := range cfg.WatchFolders {
+		if err := config.ValidateWatchPath(folder.Path, cfg.MediaPaths()...); err != nil {
+			logger.Warn("Watch folder disabled", "path", folder.Path, "error", err)
+			continue
+		}
+		if ffmpeg.GetPreset(folder.PresetID) == nil {
+			logger.Warn("Watch folder disabled", "path", folder.Path, "error", "unknown preset "+folder.PresetID)
+			continue
+		}
+		folder.Path, _ = filepath.Abs(folder.Path)
+		folders = append(folders, folder)
+	}
+	return folders
+}
@@ -3,8 +3,14 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +22,12 @@ import (
 	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
 	"github.com/gwlsn/shrinkray/internal/jobs"
 	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/notify"
 	"github.com/gwlsn/shrinkray/internal/pushover"
+	"github.com/gwlsn/shrinkray/internal/rules"
+	"github.com/gwlsn/shrinkray/internal/trash"
+	"github.com/gwlsn/shrinkray/internal/util"
+	"github.com/gwlsn/shrinkray/internal/watch"
 )
 
 // StatsStore defines the interface for stats-related store operations.
@@ -31,9 +42,10 @@ type Handler struct {
 	workerPool *jobs.WorkerPool
 	cfg        *config.Config
 	cfgPath    string
-	pushover   *pushover.Client
-	notifyMu   sync.Mutex // Protects notification sending to prevent duplicates
-	store      StatsStore // For stats operations (may be nil)
+	restoreMu  sync.Mutex     // Serializes job restores so one original isn't moved twice
+	store      StatsStore     // For stats operations (may be nil)
+	watcher    *watch.Watcher // Watch folder scanner (may be nil)
+	rules      *rules.Runner  // Queue rules (may be nil)
 }
 
 // NewHandler creates a new API handler
@@ -44,7 +56,6 @@ func NewHandler(browser *browse.Browser, queue *jobs.Queue, workerPool *jobs.Wor
 		workerPool: workerPool,
 		cfg:        cfg,
 		cfgPath:    cfgPath,
-		pushover:   pushover.NewClient(cfg.PushoverUserKey, cfg.PushoverAppToken),
 	}
 }
 
@@ -53,6 +64,16 @@ func (h *Handler) SetStore(store StatsStore) {
 	h.store = store
 }
 
+// SetWatcher sets the watch folder scanner.
+func (h *Handler) SetWatcher(watcher *watch.Watcher) {
+	h.watcher = watcher
+}
+
+// SetRules sets the queue rules runner.
+func (h *Handler) SetRules(runner *rules.Runner) {
+	h.rules = runner
+}
+
 // response helpers
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -85,7 +106,8 @@ func validateQuality(value int, codec string) string {
 	return ""
 }
 
-// validateScheduleHour validates a schedule hour value (0-23).
+// validateScheduleHour validates an hour-of-day value (0-23) for the schedule,
+// digest or quiet hours.
 // Returns an error message if invalid, empty string if valid.
 func validateScheduleHour(value int, field string) string {
 	if value < 0 || value > 23 {
@@ -96,10 +118,8 @@ func validateScheduleHour(value int, field string) string {
 
 // Browse handles GET /api/browse?path=...
 func (h *Handler) Browse(w http.ResponseWriter, r *http.Request) {
+	// An empty path browses the media root, or lists the roots if there are several
 	path := r.URL.Query().Get("path")
-	if path == "" {
-		path = h.cfg.MediaPath
-	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
@@ -109,6 +129,9 @@ func (h *Handler) Browse(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if root := h.cfg.RootFor(result.Path); root != nil && result.Path != "" {
+		result.DefaultPresetID = root.PresetID
+	}
 
 	writeJSON(w, http.StatusOK, result)
 }
@@ -135,8 +158,9 @@ func (h *Handler) Encoders(w http.ResponseWriter, r *http.Request) {
 // CreateJobsRequest is the request body for creating jobs
 type CreateJobsRequest struct {
 	Paths              []string `json:"paths"`
-	PresetID           string   `json:"preset_id"`
+	PresetID           string   `json:"preset_id"` // Empty = each path's media root default
 	SmartShrinkQuality string   `json:"smartshrink_quality,omitempty"`
+	jobs.Overrides              // Optional quality, output_format, tonemap and original_handling
 }
 
 // CreateJobs handles POST /api/jobs
@@ -153,17 +177,33 @@ func (h *Handler) CreateJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	preset := ffmpeg.GetPreset(req.PresetID)
-	if preset == nil {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown preset: %s", req.PresetID))
-		return
+	// Without a preset, each path uses its media root's default preset
+	presetIDs := []string{req.PresetID}
+	pathsByPreset := map[string][]string{req.PresetID: req.Paths}
+	if req.PresetID == "" {
+		presetIDs, pathsByPreset = nil, make(map[string][]string)
+		for _, path := range req.Paths {
+			root := h.cfg.RootFor(path)
+			if root == nil || root.PresetID == "" {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("no preset given and %s has no default preset", path))
+				return
+			}
+			if _, ok := pathsByPreset[root.PresetID]; !ok {
+				presetIDs = append(presetIDs, root.PresetID)
+			}
+			pathsByPreset[root.PresetID] = append(pathsByPreset[root.PresetID], path)
+		}
 	}
 
-	// Validate SmartShrink quality if provided
-	smartShrinkQuality := req.SmartShrinkQuality
-	if smartShrinkQuality != "" && !jobs.IsValidSmartShrinkQuality(smartShrinkQuality) {
-		writeError(w, http.StatusBadRequest, "smartshrink_quality must be 'acceptable', 'good', or 'excellent'")
-		return
+	for _, presetID := range presetIDs {
+		if msg := validateJobOptions(presetID, req.SmartShrinkQuality); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if msg := h.validateOverrides(presetID, req.Overrides); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
 	}
 
 	// Respond immediately - jobs will be added in background and appear via SSE
@@ -172,6 +212,58 @@ func (h *Handler) CreateJobs(w http.ResponseWriter, r *http.Request) {
 		"message": fmt.Sprintf("Processing %d paths in background...", len(req.Paths)),
 	})
 
+	for _, presetID := range presetIDs {
+		h.addJobsInBackground(pathsByPreset[presetID], presetID, req.SmartShrinkQuality, req.Overrides)
+	}
+}
+
+// validateJobOptions validates the preset and SmartShrink quality of new jobs.
+// Returns an error message if invalid, empty string if valid.
+func validateJobOptions(presetID, smartShrinkQuality string) string {
+	if ffmpeg.GetPreset(presetID) == nil {
+		return fmt.Sprintf("unknown preset: %s", presetID)
+	}
+	if smartShrinkQuality != "" && !jobs.IsValidSmartShrinkQuality(smartShrinkQuality) {
+		return "smartshrink_quality must be 'acceptable', 'good', or 'excellent'"
+	}
+	return ""
+}
+
+// validateOverrides validates the per-job overrides of new jobs for a preset.
+// Returns an error message if invalid, empty string if valid.
+func (h *Handler) validateOverrides(presetID string, o jobs.Overrides) string {
+	if o.Quality != 0 {
+		preset := ffmpeg.GetPreset(presetID)
+		if preset.IsSmartShrink {
+			return "quality can't be set for SmartShrink presets (use smartshrink_quality)"
+		}
+		if preset.HasTarget() {
+			return "quality can't be set for presets with a target size or bitrate"
+		}
+		if err := ffmpeg.ValidateQuality(preset.Encoder, preset.Codec, o.Quality); err != nil {
+			return err.Error()
+		}
+	}
+	if o.OutputFormat != "" && !config.IsValidOutputFormat(o.OutputFormat) {
+		return "output_format must be 'mkv', 'mp4' or 'webm'"
+	}
+	if o.Tonemap != "" && o.Tonemap != "on" && o.Tonemap != "off" {
+		return "tonemap must be 'on' or 'off'"
+	}
+	if o.OriginalHandling != "" {
+		if !config.IsValidOriginalHandling(o.OriginalHandling) {
+			return "original_handling must be 'replace', 'keep' or 'trash'"
+		}
+		if o.OriginalHandling == "trash" && h.workerPool.Trash() == nil {
+			return "original_handling 'trash' requires trash_path to be set in the config file"
+		}
+	}
+	return ""
+}
+
+// addJobsInBackground probes paths and queues the video files found, reporting
+// progress over SSE. Jobs appear via SSE as they are added.
+func (h *Handler) addJobsInBackground(paths []string, presetID, smartShrinkQuality string, overrides jobs.Overrides) {
 	// Auto-unpause when adding new jobs (prevents accidental blocking)
 	h.workerPool.Unpause()
 
@@ -193,7 +285,7 @@ func (h *Handler) CreateJobs(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Get all video files with progress reporting
-		probes, err := h.browser.GetVideoFilesWithProgress(ctx, req.Paths, onProgress)
+		probes, err := h.browser.GetVideoFilesWithProgress(ctx, paths, onProgress)
 		if err != nil {
 			logger.Error("Error getting video files", "error", err)
 			return
@@ -204,7 +296,7 @@ func (h *Handler) CreateJobs(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Add jobs to queue - SSE will notify frontend of new jobs
-		_, _ = h.queue.AddMultiple(probes, req.PresetID, smartShrinkQuality)
+		_, _ = h.queue.AddMultipleWithOverrides(probes, presetID, smartShrinkQuality, overrides)
 	}()
 }
 
@@ -315,13 +407,41 @@ func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"version":                 shrinkray.Version,
 		"media_path":              h.cfg.MediaPath,
+		"media_roots":             h.cfg.Roots(),
 		"original_handling":       h.cfg.OriginalHandling,
 		"workers":                 h.cfg.Workers,
 		"has_temp_path":           h.cfg.TempPath != "",
+		"has_trash":               h.workerPool.Trash() != nil,
+		"trash_retention_days":    h.cfg.TrashRetentionDays,
+		"trash_max_size":          h.cfg.TrashMaxSize,
+		"disk_space_action":       h.cfg.DiskSpaceAction,
+		"disk_space_reserve":      h.cfg.DiskSpaceReserve,
 		"pushover_user_key":       h.cfg.PushoverUserKey,
 		"pushover_app_token":      h.cfg.PushoverAppToken,
-		"pushover_configured":     h.pushover.IsConfigured(),
+		"pushover_configured":     pushover.NewClient(h.cfg.PushoverUserKey, h.cfg.PushoverAppToken).IsConfigured(),
+		"discord_webhook_url":     h.cfg.DiscordWebhookURL,
+		"slack_webhook_url":       h.cfg.SlackWebhookURL,
+		"ntfy_url":                h.cfg.NtfyURL,
+		"ntfy_token":              h.cfg.NtfyToken,
+		"gotify_url":              h.cfg.GotifyURL,
+		"gotify_token":            h.cfg.GotifyToken,
+		"webhook_url":             h.cfg.WebhookURL,
+		"smtp_host":               h.cfg.SMTPHost,
+		"smtp_port":               h.cfg.SMTPPort,
+		"smtp_username":           h.cfg.SMTPUsername,
+		"has_smtp_password":       h.cfg.SMTPPassword != "",
+		"smtp_from":               h.cfg.SMTPFrom,
+		"smtp_to":                 h.cfg.SMTPTo,
+		"notifiers":               notifierStatus(h.cfg),
+		"notify_configured":       len(notify.Configured(h.cfg)) > 0,
 		"notify_on_complete":      h.cfg.NotifyOnComplete,
+		"notify_on_failure":       h.cfg.NotifyOnFailure,
+		"notify_on_skip":          h.cfg.NotifyOnSkip,
+		"notify_digest":           h.cfg.NotifyDigest,
+		"notify_digest_hour":      h.cfg.NotifyDigestHour,
+		"quiet_hours_enabled":     h.cfg.QuietHoursEnabled,
+		"quiet_hours_start":       h.cfg.QuietHoursStart,
+		"quiet_hours_end":         h.cfg.QuietHoursEnd,
 		"quality_hevc":            h.cfg.QualityHEVC,
 		"quality_av1":             h.cfg.QualityAV1,
 		"default_quality_hevc":    defaultHEVC,
@@ -330,11 +450,13 @@ func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
 		"schedule_start_hour":     h.cfg.ScheduleStartHour,
 		"schedule_end_hour":       h.cfg.ScheduleEndHour,
 		"output_format":           h.cfg.OutputFormat,
+		"mp4_image_subtitles":     h.cfg.MP4ImageSubtitles,
 		"tonemap_hdr":             h.cfg.TonemapHDR,
 		"tonemap_algorithm":       h.cfg.TonemapAlgorithm,
 		"max_concurrent_analyses": h.cfg.MaxConcurrentAnalyses,
 		"log_level":               h.cfg.LogLevel,
 		"allow_same_codec":        h.cfg.AllowSameCodec,
+		"verify_decode":           h.cfg.VerifyDecode,
 	})
 }
 
@@ -344,18 +466,40 @@ type UpdateConfigRequest struct {
 	Workers               *int    `json:"workers,omitempty"`
 	PushoverUserKey       *string `json:"pushover_user_key,omitempty"`
 	PushoverAppToken      *string `json:"pushover_app_token,omitempty"`
+	DiscordWebhookURL     *string `json:"discord_webhook_url,omitempty"`
+	SlackWebhookURL       *string `json:"slack_webhook_url,omitempty"`
+	NtfyURL               *string `json:"ntfy_url,omitempty"`
+	NtfyToken             *string `json:"ntfy_token,omitempty"`
+	GotifyURL             *string `json:"gotify_url,omitempty"`
+	GotifyToken           *string `json:"gotify_token,omitempty"`
+	WebhookURL            *string `json:"webhook_url,omitempty"`
+	SMTPHost              *string `json:"smtp_host,omitempty"`
+	SMTPPort              *int    `json:"smtp_port,omitempty"`
+	SMTPUsername          *string `json:"smtp_username,omitempty"`
+	SMTPPassword          *string `json:"smtp_password,omitempty"`
+	SMTPFrom              *string `json:"smtp_from,omitempty"`
+	SMTPTo                *string `json:"smtp_to,omitempty"`
 	NotifyOnComplete      *bool   `json:"notify_on_complete,omitempty"`
+	NotifyOnFailure       *bool   `json:"notify_on_failure,omitempty"`
+	NotifyOnSkip          *bool   `json:"notify_on_skip,omitempty"`
+	NotifyDigest          *string `json:"notify_digest,omitempty"`
+	NotifyDigestHour      *int    `json:"notify_digest_hour,omitempty"`
+	QuietHoursEnabled     *bool   `json:"quiet_hours_enabled,omitempty"`
+	QuietHoursStart       *int    `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd         *int    `json:"quiet_hours_end,omitempty"`
 	QualityHEVC           *int    `json:"quality_hevc,omitempty"`
 	QualityAV1            *int    `json:"quality_av1,omitempty"`
 	ScheduleEnabled       *bool   `json:"schedule_enabled,omitempty"`
 	ScheduleStartHour     *int    `json:"schedule_start_hour,omitempty"`
 	ScheduleEndHour       *int    `json:"schedule_end_hour,omitempty"`
 	OutputFormat          *string `json:"output_format,omitempty"`
+	MP4ImageSubtitles     *string `json:"mp4_image_subtitles,omitempty"`
 	TonemapHDR            *bool   `json:"tonemap_hdr,omitempty"`
 	TonemapAlgorithm      *string `json:"tonemap_algorithm,omitempty"`
 	MaxConcurrentAnalyses *int    `json:"max_concurrent_analyses,omitempty"`
 	LogLevel              *string `json:"log_level,omitempty"`
 	AllowSameCodec        *bool   `json:"allow_same_codec,omitempty"`
+	VerifyDecode          *bool   `json:"verify_decode,omitempty"`
 }
 
 // UpdateConfig handles PUT /api/config
@@ -368,8 +512,15 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow updating certain fields
 	if req.OriginalHandling != nil {
-		if *req.OriginalHandling != "replace" && *req.OriginalHandling != "keep" {
-			writeError(w, http.StatusBadRequest, "original_handling must be 'replace' or 'keep'")
+		switch *req.OriginalHandling {
+		case "replace", "keep":
+		case "trash":
+			if h.workerPool.Trash() == nil {
+				writeError(w, http.StatusBadRequest, "original_handling 'trash' requires trash_path to be set in the config file")
+				return
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "original_handling must be 'replace', 'keep' or 'trash'")
 			return
 		}
 		h.cfg.OriginalHandling = *req.OriginalHandling
@@ -381,18 +532,113 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 		h.workerPool.Resize(workers)
 	}
 
-	// Handle Pushover settings
+	// Handle notification backend settings
 	if req.PushoverUserKey != nil {
 		h.cfg.PushoverUserKey = *req.PushoverUserKey
-		h.pushover.UserKey = *req.PushoverUserKey
 	}
 	if req.PushoverAppToken != nil {
 		h.cfg.PushoverAppToken = *req.PushoverAppToken
-		h.pushover.AppToken = *req.PushoverAppToken
 	}
+	for _, u := range []struct {
+		value *string
+		name  string
+	}{
+		{req.DiscordWebhookURL, "discord_webhook_url"},
+		{req.SlackWebhookURL, "slack_webhook_url"},
+		{req.NtfyURL, "ntfy_url"},
+		{req.GotifyURL, "gotify_url"},
+		{req.WebhookURL, "webhook_url"},
+	} {
+		if u.value != nil && *u.value != "" && !isHTTPURL(*u.value) {
+			writeError(w, http.StatusBadRequest, u.name+" must be an http:// or https:// URL")
+			return
+		}
+	}
+	if req.DiscordWebhookURL != nil {
+		h.cfg.DiscordWebhookURL = *req.DiscordWebhookURL
+	}
+	if req.SlackWebhookURL != nil {
+		h.cfg.SlackWebhookURL = *req.SlackWebhookURL
+	}
+	if req.NtfyURL != nil {
+		h.cfg.NtfyURL = *req.NtfyURL
+	}
+	if req.NtfyToken != nil {
+		h.cfg.NtfyToken = *req.NtfyToken
+	}
+	if req.GotifyURL != nil {
+		h.cfg.GotifyURL = *req.GotifyURL
+	}
+	if req.GotifyToken != nil {
+		h.cfg.GotifyToken = *req.GotifyToken
+	}
+	if req.WebhookURL != nil {
+		h.cfg.WebhookURL = *req.WebhookURL
+	}
+	if req.SMTPHost != nil {
+		h.cfg.SMTPHost = *req.SMTPHost
+	}
+	if req.SMTPPort != nil {
+		if *req.SMTPPort < 0 || *req.SMTPPort > 65535 {
+			writeError(w, http.StatusBadRequest, "smtp_port must be between 0 and 65535")
+			return
+		}
+		h.cfg.SMTPPort = *req.SMTPPort
+	}
+	if req.SMTPUsername != nil {
+		h.cfg.SMTPUsername = *req.SMTPUsername
+	}
+	if req.SMTPPassword != nil {
+		h.cfg.SMTPPassword = *req.SMTPPassword
+	}
+	if req.SMTPFrom != nil {
+		h.cfg.SMTPFrom = *req.SMTPFrom
+	}
+	if req.SMTPTo != nil {
+		h.cfg.SMTPTo = *req.SMTPTo
+	}
+
+	// Handle notification rules
 	if req.NotifyOnComplete != nil {
 		h.cfg.NotifyOnComplete = *req.NotifyOnComplete
 	}
+	if req.NotifyOnFailure != nil {
+		h.cfg.NotifyOnFailure = *req.NotifyOnFailure
+	}
+	if req.NotifyOnSkip != nil {
+		h.cfg.NotifyOnSkip = *req.NotifyOnSkip
+	}
+	if req.NotifyDigest != nil {
+		if *req.NotifyDigest != "off" && *req.NotifyDigest != "daily" && *req.NotifyDigest != "weekly" {
+			writeError(w, http.StatusBadRequest, "notify_digest must be 'off', 'daily' or 'weekly'")
+			return
+		}
+		h.cfg.NotifyDigest = *req.NotifyDigest
+	}
+	if req.NotifyDigestHour != nil {
+		if errMsg := validateScheduleHour(*req.NotifyDigestHour, "notify_digest_hour"); errMsg != "" {
+			writeError(w, http.StatusBadRequest, errMsg)
+			return
+		}
+		h.cfg.NotifyDigestHour = *req.NotifyDigestHour
+	}
+	if req.QuietHoursEnabled != nil {
+		h.cfg.QuietHoursEnabled = *req.QuietHoursEnabled
+	}
+	if req.QuietHoursStart != nil {
+		if errMsg := validateScheduleHour(*req.QuietHoursStart, "quiet_hours_start"); errMsg != "" {
+			writeError(w, http.StatusBadRequest, errMsg)
+			return
+		}
+		h.cfg.QuietHoursStart = *req.QuietHoursStart
+	}
+	if req.QuietHoursEnd != nil {
+		if errMsg := validateScheduleHour(*req.QuietHoursEnd, "quiet_hours_end"); errMsg != "" {
+			writeError(w, http.StatusBadRequest, errMsg)
+			return
+		}
+		h.cfg.QuietHoursEnd = *req.QuietHoursEnd
+	}
 
 	// Handle quality settings
 	if req.QualityHEVC != nil {
@@ -431,12 +677,19 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Handle output format
 	if req.OutputFormat != nil {
-		if *req.OutputFormat != "mkv" && *req.OutputFormat != "mp4" {
-			writeError(w, http.StatusBadRequest, "output_format must be 'mkv' or 'mp4'")
+		if !config.IsValidOutputFormat(*req.OutputFormat) {
+			writeError(w, http.StatusBadRequest, "output_format must be 'mkv', 'mp4' or 'webm'")
 			return
 		}
 		h.cfg.OutputFormat = *req.OutputFormat
 	}
+	if req.MP4ImageSubtitles != nil {
+		if *req.MP4ImageSubtitles != "drop" && *req.MP4ImageSubtitles != "extract" {
+			writeError(w, http.StatusBadRequest, "mp4_image_subtitles must be 'drop' or 'extract'")
+			return
+		}
+		h.cfg.MP4ImageSubtitles = *req.MP4ImageSubtitles
+	}
 
 	// Handle HDR tonemapping settings
 	if req.TonemapHDR != nil {
@@ -468,6 +721,11 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 		h.queue.SetAllowSameCodec(*req.AllowSameCodec)
 	}
 
+	// Handle full decode verification of outputs
+	if req.VerifyDecode != nil {
+		h.cfg.VerifyDecode = *req.VerifyDecode
+	}
+
 	// Handle log level
 	if req.LogLevel != nil {
 		val := strings.ToLower(*req.LogLevel)
@@ -519,12 +777,29 @@ func (h *Handler) ClearCache(w http.ResponseWriter, r *http.Request) {
 
 // TestPushover handles POST /api/pushover/test
 func (h *Handler) TestPushover(w http.ResponseWriter, r *http.Request) {
-	if !h.pushover.IsConfigured() {
+	client := pushover.NewClient(h.cfg.PushoverUserKey, h.cfg.PushoverAppToken)
+	if !client.IsConfigured() {
 		writeError(w, http.StatusBadRequest, "Pushover credentials not configured")
 		return
 	}
 
-	if err := h.pushover.Test(); err != nil {
+	if err := client.Test(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Test notification sent"})
+}
+
+// TestNotifier handles POST /api/notify/{backend}/test
+func (h *Handler) TestNotifier(w http.ResponseWriter, r *http.Request) {
+	n, err := notify.Backend(h.cfg, r.PathValue("backend"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := notify.Test(n); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -532,6 +807,24 @@ func (h *Handler) TestPushover(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "Test notification sent"})
 }
 
+// notifierStatus lists each notification backend and whether it is configured
+func notifierStatus(cfg *config.Config) []map[string]interface{} {
+	var status []map[string]interface{}
+	for _, n := range notify.Backends(cfg) {
+		status = append(status, map[string]interface{}{
+			"name":       n.Name(),
+			"configured": n.IsConfigured(),
+		})
+	}
+	return status
+}
+
+// isHTTPURL reports whether s is an absolute http(s) URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // RetryJob handles POST /api/jobs/:id/retry
 func (h *Handler) RetryJob(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -561,15 +854,682 @@ func (h *Handler) RetryJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add new job with same preset and quality tier
-	newJob, err := h.queue.Add(job.InputPath, job.PresetID, probe, job.SmartShrinkQuality)
+	// Add new job with same preset, quality tier and overrides
+	newJob, err := h.queue.AddWithOverrides(job.InputPath, job.PresetID, probe, job.SmartShrinkQuality, job.Overrides)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to create job: %v", err))
 		return
 	}
 
+	if job.Priority != 0 {
+		_ = h.queue.SetPriority(newJob.ID, job.Priority)
+		newJob.Priority = job.Priority
+	}
+
 	// Remove the failed job
 	h.queue.Remove(id)
 
 	writeJSON(w, http.StatusOK, newJob)
 }
+
+// ReorderJobsRequest is the request body for reordering the queue: either
+// the new order of some or all jobs, or one job to move
+type ReorderJobsRequest struct {
+	Order  []string `json:"order,omitempty"`  // Job IDs in their new order
+	ID     string   `json:"id,omitempty"`     // Job to move...
+	Before string   `json:"before,omitempty"` // ...just before this job
+	After  string   `json:"after,omitempty"`  // ...just after this job
+	To     string   `json:"to,omitempty"`     // ...or to the "top" or "bottom" of the queue
+}
+
+// ReorderJobs handles POST /api/jobs/reorder
+// Jobs in "order" take the queue positions they held between them; a job
+// given by "id" (must be pending) moves before/after another or to the top/bottom.
+func (h *Handler) ReorderJobs(w http.ResponseWriter, r *http.Request) {
+	var req ReorderJobsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var err error
+	switch {
+	case len(req.Order) > 0:
+		err = h.queue.Reorder(req.Order)
+	case req.ID == "":
+		writeError(w, http.StatusBadRequest, "order or id required")
+		return
+	case req.Before != "":
+		err = h.queue.MoveJob(req.ID, req.Before, false)
+	case req.After != "":
+		err = h.queue.MoveJob(req.ID, req.After, true)
+	case req.To == "top":
+		err = h.queue.MoveToTop(req.ID)
+	case req.To == "bottom":
+		err = h.queue.MoveToBottom(req.ID)
+	default:
+		writeError(w, http.StatusBadRequest, "before, after or to (top or bottom) required")
+		return
+	}
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reordered"})
+}
+
+// SetJobPriority handles PUT /api/jobs/:id/priority
+// Higher priority pending jobs run first; equal priorities run in queue order.
+func (h *Handler) SetJobPriority(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "job ID required")
+		return
+	}
+
+	var req struct {
+		Priority *int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Priority == nil {
+		writeError(w, http.StatusBadRequest, "priority required")
+		return
+	}
+
+	if err := h.queue.SetPriority(id, *req.Priority); err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.queue.Get(id))
+}
+
+// writeJobError writes a queue error: 404 for a missing job, 409 for a job
+// in the wrong state, 400 otherwise
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, jobs.ErrJobNotPending), errors.Is(err, jobs.ErrJobTerminal):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// RestoreJob handles POST /api/jobs/:id/restore
+// Puts a completed job's kept original (.old or trash) back in place, removes
+// the transcoded output and marks the job as reverted.
+func (h *Handler) RestoreJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "job ID required")
+		return
+	}
+
+	h.restoreMu.Lock()
+	defer h.restoreMu.Unlock()
+
+	h.restoreJob(w, id)
+}
+
+// restoreJob restores a completed job's original and writes the reverted job.
+// Called with restoreMu held.
+func (h *Handler) restoreJob(w http.ResponseWriter, id string) {
+	job := h.queue.Get(id)
+	if job == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	if job.Status != jobs.StatusComplete {
+		writeError(w, http.StatusConflict, "can only restore completed jobs")
+		return
+	}
+	if job.OriginalPath == "" {
+		writeError(w, http.StatusConflict, "the original was not kept (original_handling: replace)")
+		return
+	}
+	if _, err := os.Stat(job.OriginalPath); err != nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("the original no longer exists: %s", job.OriginalPath))
+		return
+	}
+
+	// Originals in the trash go back through it so its entry is removed too
+	restore := func() error { return os.Rename(job.OriginalPath, job.InputPath) }
+	if t := h.workerPool.Trash(); t != nil {
+		if entry, err := t.Get(id); err == nil && entry.TrashPath == job.OriginalPath {
+			restore = func() error {
+				_, err := t.Restore(id)
+				return err
+			}
+		}
+	}
+
+	if err := ffmpeg.RevertTranscode(job.InputPath, job.OutputPath, restore); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Subtitle sidecars belonged to the output
+	for _, path := range job.SidecarPaths() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to remove subtitle sidecar", "job_id", id, "path", path, "error", err)
+		}
+	}
+
+	h.browser.InvalidateCache(job.InputPath)
+	if job.OutputPath != job.InputPath {
+		h.browser.InvalidateCache(job.OutputPath)
+	}
+
+	if err := h.queue.RevertJob(id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.queue.Get(id))
+}
+
+// ListTrash handles GET /api/trash
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	t := h.workerPool.Trash()
+	if t == nil {
+		writeError(w, http.StatusNotFound, "trash is not configured")
+		return
+	}
+
+	entries, err := t.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list trash: %v", err))
+		return
+	}
+	if entries == nil {
+		entries = []*trash.Entry{}
+	}
+
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entries":        entries,
+		"total_size":     totalSize,
+		"retention_days": h.cfg.TrashRetentionDays,
+	})
+}
+
+// RestoreTrash handles POST /api/trash/:job_id/restore
+// Restores the job like POST /api/jobs/:id/restore while it's still in the
+// queue. For cleared jobs it only moves the original back to where it came
+// from, and fails with 409 if a file (such as the transcoded output) already
+// occupies that path.
+func (h *Handler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("job_id")
+	if jobID == "" {
+		writeError(w, http.StatusBadRequest, "job ID required")
+		return
+	}
+
+	t := h.workerPool.Trash()
+	if t == nil {
+		writeError(w, http.StatusNotFound, "trash is not configured")
+		return
+	}
+
+	h.restoreMu.Lock()
+	defer h.restoreMu.Unlock()
+
+	if h.queue.Get(jobID) != nil {
+		h.restoreJob(w, jobID)
+		return
+	}
+
+	entry, err := t.Restore(jobID)
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, trash.ErrDestinationExists):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.browser.InvalidateCache(entry.OriginalPath)
+
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// WatchFoldersRequest is the body for PUT /api/watch
+type WatchFoldersRequest struct {
+	Folders []config.WatchFolder `json:"folders"`
+}
+
+// GetWatch handles GET /api/watch
+func (h *Handler) GetWatch(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		writeError(w, http.StatusNotFound, "watch folders are not configured")
+		return
+	}
+
+	var lastScan *time.Time
+	if t := h.watcher.LastScan(); !t.IsZero() {
+		lastScan = &t
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"folders":          h.watcher.Folders(),
+		"last_scan":        lastScan,
+		"interval_minutes": h.cfg.WatchIntervalMinutes,
+		"stable_minutes":   h.cfg.WatchStableMinutes,
+	})
+}
+
+// UpdateWatch handles PUT /api/watch
+// Replaces the watch folders. Each folder must be an existing directory under
+// the media path, with a known preset.
+func (h *Handler) UpdateWatch(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		writeError(w, http.StatusNotFound, "watch folders are not configured")
+		return
+	}
+
+	var req WatchFoldersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	folders := make([]config.WatchFolder, 0, len(req.Folders))
+	for _, folder := range req.Folders {
+		if err := config.ValidateWatchPath(folder.Path, h.cfg.MediaPaths()...); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if ffmpeg.GetPreset(folder.PresetID) == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown preset: %s", folder.PresetID))
+			return
+		}
+		if folder.SmartShrinkQuality != "" && !jobs.IsValidSmartShrinkQuality(folder.SmartShrinkQuality) {
+			writeError(w, http.StatusBadRequest, "smartshrink_quality must be 'acceptable', 'good', or 'excellent'")
+			return
+		}
+		folder.Path, _ = filepath.Abs(folder.Path)
+		folders = append(folders, folder)
+	}
+
+	h.cfg.WatchFolders = folders
+	h.watcher.SetFolders(folders)
+
+	if h.cfgPath != "" {
+		if err := h.cfg.Save(h.cfgPath); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save config: %v", err))
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"folders": folders})
+}
+
+// ScanWatch handles POST /api/watch/scan
+// Scans the watch folders now instead of waiting for the next interval.
+func (h *Handler) ScanWatch(w http.ResponseWriter, r *http.Request) {
+	if h.watcher == nil {
+		writeError(w, http.StatusNotFound, "watch folders are not configured")
+		return
+	}
+
+	queued, err := h.watcher.Scan(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("scan failed: %v", err))
+		return
+	}
+	if queued > 0 {
+		// Same as adding jobs by hand
+		h.workerPool.Unpause()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"queued": queued})
+}
+
+// GetProcessed handles GET /api/processed?path=...
+// Returns the processed-file ledger record for a file.
+func (h *Handler) GetProcessed(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "path required")
+		return
+	}
+
+	file, err := h.queue.GetProcessedFile(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read ledger: %v", err))
+		return
+	}
+	if file == nil {
+		writeError(w, http.StatusNotFound, "file has not been processed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, file)
+}
+
+// ForgetProcessed handles DELETE /api/processed?path=...
+// Removes a file from the processed-file ledger so it can be queued again.
+func (h *Handler) ForgetProcessed(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, "path required")
+		return
+	}
+
+	if err := h.queue.ForgetProcessedFile(path); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to update ledger: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "forgotten"})
+}
+
+// ListRules handles GET /api/rules
+func (h *Handler) ListRules(w http.ResponseWriter, r *http.Request) {
+	if h.rules == nil {
+		writeError(w, http.StatusNotFound, "queue rules are not configured")
+		return
+	}
+
+	queueRules := make([]config.QueueRule, 0, len(h.rules.Rules()))
+	for _, rule := range h.rules.Rules() {
+		queueRules = append(queueRules, rule.QueueRule)
+	}
+
+	var lastRun *time.Time
+	if t := h.rules.LastRun(); !t.IsZero() {
+		lastRun = &t
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules":          queueRules,
+		"interval_hours": h.cfg.QueueRulesIntervalHours,
+		"last_run":       lastRun,
+	})
+}
+
+// DryRunRules handles POST /api/rules/dry-run
+// Lists the files the queue rules would queue, without queueing them.
+func (h *Handler) DryRunRules(w http.ResponseWriter, r *http.Request) {
+	h.runRules(w, r, false)
+}
+
+// RunRules handles POST /api/rules/run
+func (h *Handler) RunRules(w http.ResponseWriter, r *http.Request) {
+	h.runRules(w, r, true)
+}
+
+func (h *Handler) runRules(w http.ResponseWriter, r *http.Request, apply bool) {
+	if h.rules == nil {
+		writeError(w, http.StatusNotFound, "queue rules are not configured")
+		return
+	}
+
+	run := h.rules.Plan
+	if apply {
+		run = h.rules.Apply
+	}
+	matches, err := run(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("queue rules failed: %v", err))
+		return
+	}
+	if matches == nil {
+		matches = []rules.Match{}
+	}
+	if apply && len(matches) > 0 {
+		// Same as adding jobs by hand
+		h.workerPool.Unpause()
+	}
+
+	var totalSize int64
+	for _, m := range matches {
+		totalSize += m.Size
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"matches":    matches,
+		"count":      len(matches),
+		"total_size": totalSize,
+	})
+}
+
+// Library search pagination
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// parseSearchQuery reads library search filters from query parameters.
+// List filters (codec, container, resolution) take comma-separated values.
+func parseSearchQuery(values url.Values) (browse.SearchQuery, error) {
+	q := browse.SearchQuery{
+		Codecs:     splitList(values.Get("codec")),
+		Containers: splitList(values.Get("container")),
+		Path:       values.Get("path"),
+		Sort:       values.Get("sort"),
+		Limit:      defaultSearchLimit,
+	}
+
+	for _, res := range splitList(values.Get("resolution")) {
+		res = strings.ToLower(res)
+		if res == "4k" {
+			res = browse.Resolution2160p
+		}
+		if !slices.Contains(browse.Resolutions, res) {
+			return q, fmt.Errorf("resolution must be one of %s", strings.Join(browse.Resolutions, ", "))
+		}
+		q.Resolutions = append(q.Resolutions, res)
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"min_height", &q.MinHeight},
+		{"max_height", &q.MaxHeight},
+		{"bit_depth", &q.BitDepth},
+		{"limit", &q.Limit},
+		{"offset", &q.Offset},
+	}
+	for _, p := range ints {
+		if v := values.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s: %q", p.name, v)
+			}
+			*p.dst = n
+		}
+	}
+	if q.Limit == 0 || q.Limit > maxSearchLimit {
+		q.Limit = maxSearchLimit
+	}
+
+	bitrates := []struct {
+		name string
+		dst  *int64
+	}{
+		{"min_bitrate", &q.MinBitrate},
+		{"max_bitrate", &q.MaxBitrate},
+	}
+	for _, p := range bitrates {
+		if v := values.Get(p.name); v != "" {
+			n, err := ffmpeg.ParseBitrate(v)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: %q (expected e.g. \"8M\")", p.name, v)
+			}
+			*p.dst = n
+		}
+	}
+
+	sizes := []struct {
+		name string
+		dst  *int64
+	}{
+		{"min_size", &q.MinSize},
+		{"max_size", &q.MaxSize},
+	}
+	for _, p := range sizes {
+		if v := values.Get(p.name); v != "" {
+			n, err := util.ParseBytes(v)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: %q (expected e.g. \"10G\")", p.name, v)
+			}
+			*p.dst = n
+		}
+	}
+
+	if v := values.Get("hdr"); v != "" {
+		hdr, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid hdr: %q (expected true or false)", v)
+		}
+		q.HDR = &hdr
+	}
+
+	if q.Sort != "" && !slices.Contains(browse.SearchSorts, q.Sort) {
+		return q, fmt.Errorf("sort must be one of %s", strings.Join(browse.SearchSorts, ", "))
+	}
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+	return q, nil
+}
+
+// splitList splits a comma-separated query value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SearchLibrary handles GET /api/library/search
+// Queries every probed file under the media root by codec, resolution,
+// bitrate, HDR, bit depth, size, container and path.
+func (h *Handler) SearchLibrary(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.browser.Search(q)
+	if errors.Is(err, browse.ErrNoIndex) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("search failed: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"files":      result.Files,
+		"total":      result.Total,
+		"total_size": result.TotalSize,
+		"limit":      q.Limit,
+		"offset":     q.Offset,
+		"indexing":   h.browser.Indexing(),
+	})
+}
+
+// IndexLibrary handles POST /api/library/index
+// Probes the whole media root in the background so every file is searchable,
+// and drops index entries for files that no longer exist.
+func (h *Handler) IndexLibrary(w http.ResponseWriter, r *http.Request) {
+	if !h.browser.HasIndex() {
+		writeError(w, http.StatusNotFound, browse.ErrNoIndex.Error())
+		return
+	}
+	if h.browser.Indexing() {
+		writeError(w, http.StatusConflict, browse.ErrIndexing.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "indexing"})
+
+	go func() {
+		if _, _, err := h.browser.IndexLibrary(context.Background()); err != nil {
+			logger.Warn("Library indexing failed", "error", err.Error())
+		}
+	}()
+}
+
+// LibraryQueueRequest is the request body for queueing library search results
+type LibraryQueueRequest struct {
+	PresetID           string `json:"preset_id"`
+	SmartShrinkQuality string `json:"smartshrink_quality,omitempty"`
+	jobs.Overrides            // Same as CreateJobsRequest
+}
+
+// QueueLibrary handles POST /api/library/queue
+// Queues every file matching the search filters in the query string
+// (all pages, not just one).
+func (h *Handler) QueueLibrary(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.Limit, q.Offset = 0, 0
+
+	var req LibraryQueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateJobOptions(req.PresetID, req.SmartShrinkQuality); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := h.validateOverrides(req.PresetID, req.Overrides); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	result, err := h.browser.Search(q)
+	if errors.Is(err, browse.ErrNoIndex) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("search failed: %v", err))
+		return
+	}
+	if result.Total == 0 {
+		writeError(w, http.StatusBadRequest, "no files match")
+		return
+	}
+
+	paths := make([]string, len(result.Files))
+	for i, f := range result.Files {
+		paths[i] = f.Path
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status":  "processing",
+		"count":   len(paths),
+		"message": fmt.Sprintf("Processing %d files in background...", len(paths)),
+	})
+
+	h.addJobsInBackground(paths, req.PresetID, req.SmartShrinkQuality, req.Overrides)
+}
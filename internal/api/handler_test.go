@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -16,6 +18,10 @@ import (
 	"github.com/gwlsn/shrinkray/internal/config"
 	"github.com/gwlsn/shrinkray/internal/ffmpeg"
 	"github.com/gwlsn/shrinkray/internal/jobs"
+	"github.com/gwlsn/shrinkray/internal/rules"
+	"github.com/gwlsn/shrinkray/internal/store"
+	"github.com/gwlsn/shrinkray/internal/trash"
+	"github.com/gwlsn/shrinkray/internal/watch"
 )
 
 func setupTestHandler(t *testing.T) (*Handler, string) {
@@ -177,6 +183,44 @@ func TestCreateJobsEndpoint(t *testing.T) {
 	t.Logf("Create jobs response: %d - %s", w.Code, w.Body.String())
 }
 
+func TestCreateJobsRootDefaultPreset(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+	handler.cfg.MediaRoots = []config.MediaRoot{{Name: "TV", Path: tmpDir, PresetID: "compress-hevc"}}
+	showDir := filepath.Join(tmpDir, "TV Shows", "Test Show", "Season 1")
+
+	// Browsing inside the root reports its default preset
+	w := httptest.NewRecorder()
+	handler.Browse(w, httptest.NewRequest("GET", "/api/browse?path="+url.QueryEscape(showDir), nil))
+	var result browse.BrowseResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if result.DefaultPresetID != "compress-hevc" {
+		t.Errorf("expected the root's default preset, got %q", result.DefaultPresetID)
+	}
+
+	create := func(paths ...string) int {
+		t.Helper()
+		body, _ := json.Marshal(CreateJobsRequest{Paths: paths})
+		w := httptest.NewRecorder()
+		handler.CreateJobs(w, httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// Without a preset, paths use their root's default
+	if code := create(showDir); code != http.StatusAccepted {
+		t.Errorf("expected 202 for a path with a default preset, got %d", code)
+	}
+	if code := create(showDir, "/elsewhere/movie.mkv"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a path outside every root, got %d", code)
+	}
+
+	handler.cfg.MediaRoots[0].PresetID = ""
+	if code := create(showDir); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a root without a default preset, got %d", code)
+	}
+}
+
 func TestConfigEndpoint(t *testing.T) {
 	handler, _ := setupTestHandler(t)
 
@@ -356,3 +400,588 @@ func TestJobStreamEndpoint(t *testing.T) {
 	t.Logf("SSE response: %s", w.Body.String()[:min(200, len(w.Body.String()))])
 }
 
+func TestRestoreJobEndpoint(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+
+	// A completed keep-mode job: mkv output at the input path, original as .old
+	inputPath := filepath.Join(tmpDir, "movie.mkv")
+	oldPath := ffmpeg.KeptOriginalPath(inputPath)
+	if err := os.WriteFile(oldPath, []byte("original content"), 0644); err != nil {
+		t.Fatalf("failed to create original: %v", err)
+	}
+	if err := os.WriteFile(inputPath, []byte("output"), 0644); err != nil {
+		t.Fatalf("failed to create output: %v", err)
+	}
+
+	sidecar := filepath.Join(tmpDir, "movie.eng.sup")
+	if err := os.WriteFile(sidecar, []byte("subtitles"), 0644); err != nil {
+		t.Fatalf("failed to create sidecar: %v", err)
+	}
+
+	probe := &ffmpeg.ProbeResult{Path: inputPath, Size: 1000, Duration: 10 * time.Second}
+	job, _ := handler.queue.Add(inputPath, "compress", probe, "")
+	_ = handler.queue.StartJob(job.ID, inputPath+".tmp")
+	_ = handler.queue.UpdateJobSidecars(job.ID, []string{sidecar})
+	_ = handler.queue.UpdateJobOriginalPath(job.ID, oldPath)
+	_ = handler.queue.CompleteJob(job.ID, inputPath, 400)
+
+	restore := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/jobs/"+job.ID+"/restore", nil)
+		req.SetPathValue("id", job.ID)
+		w := httptest.NewRecorder()
+		handler.RestoreJob(w, req)
+		return w
+	}
+
+	w := restore()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if content, _ := os.ReadFile(inputPath); string(content) != "original content" {
+		t.Errorf("expected original restored, got %q", content)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Error(".old file still exists after restore")
+	}
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Error("subtitle sidecar still exists after restore")
+	}
+
+	got := handler.queue.Get(job.ID)
+	if got.Status != jobs.StatusReverted || got.OriginalPath != "" {
+		t.Errorf("expected reverted job with no original path, got %s %q", got.Status, got.OriginalPath)
+	}
+
+	// A second restore is refused
+	if w := restore(); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for reverted job, got %d", w.Code)
+	}
+}
+
+func TestRestoreTrashEndpoint(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+	bin := trash.New(t.TempDir(), []string{tmpDir}, 0, 0, s)
+	handler.workerPool.SetTrash(bin)
+
+	// A completed trash-mode job: mkv output at the input path, original in the trash
+	inputPath := filepath.Join(tmpDir, "movie.mkv")
+	if err := os.WriteFile(inputPath, []byte("original content"), 0644); err != nil {
+		t.Fatalf("failed to create original: %v", err)
+	}
+	probe := &ffmpeg.ProbeResult{Path: inputPath, Size: 1000, Duration: 10 * time.Second}
+	job, _ := handler.queue.Add(inputPath, "compress", probe, "")
+	entry, err := bin.Move(job.ID, inputPath, inputPath)
+	if err != nil {
+		t.Fatalf("failed to trash original: %v", err)
+	}
+	if err := os.WriteFile(inputPath, []byte("output"), 0644); err != nil {
+		t.Fatalf("failed to create output: %v", err)
+	}
+	_ = handler.queue.StartJob(job.ID, inputPath+".tmp")
+	_ = handler.queue.UpdateJobOriginalPath(job.ID, entry.TrashPath)
+	_ = handler.queue.CompleteJob(job.ID, inputPath, 400)
+
+	// The output at the original path doesn't block a restore of a queued job
+	req := httptest.NewRequest("POST", "/api/trash/"+job.ID+"/restore", nil)
+	req.SetPathValue("job_id", job.ID)
+	w := httptest.NewRecorder()
+	handler.RestoreTrash(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if content, _ := os.ReadFile(inputPath); string(content) != "original content" {
+		t.Errorf("expected original restored, got %q", content)
+	}
+	if got := handler.queue.Get(job.ID); got.Status != jobs.StatusReverted {
+		t.Errorf("expected reverted job, got %s", got.Status)
+	}
+	if _, err := bin.Get(job.ID); err == nil {
+		t.Error("expected trash entry removed after restore")
+	}
+}
+
+func TestReorderJobsEndpoint(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	probe := &ffmpeg.ProbeResult{Path: "/media/video.mkv", Size: 1000, Duration: 10 * time.Second}
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		job, _ := handler.queue.Add("/media/"+name+".mkv", "compress", probe, "")
+		ids = append(ids, job.ID)
+	}
+
+	reorder := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ReorderJobs(w, httptest.NewRequest("POST", "/api/jobs/reorder", bytes.NewBufferString(body)))
+		return w
+	}
+	order := func() []string {
+		var got []string
+		for _, job := range handler.queue.GetAll() {
+			got = append(got, job.ID)
+		}
+		return got
+	}
+
+	if w := reorder(fmt.Sprintf(`{"order": [%q, %q, %q]}`, ids[2], ids[1], ids[0])); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := order(); !slices.Equal(got, []string{ids[2], ids[1], ids[0]}) {
+		t.Errorf("unexpected order after full reorder: %v", got)
+	}
+
+	if w := reorder(fmt.Sprintf(`{"id": %q, "to": "top"}`, ids[0])); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := reorder(fmt.Sprintf(`{"id": %q, "after": %q}`, ids[2], ids[1])); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := order(); !slices.Equal(got, ids) {
+		t.Errorf("unexpected order after moves: %v", got)
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{}`, http.StatusBadRequest},
+		{fmt.Sprintf(`{"id": %q}`, ids[0]), http.StatusBadRequest},
+		{fmt.Sprintf(`{"id": %q, "to": "middle"}`, ids[0]), http.StatusBadRequest},
+		{`{"id": "missing", "to": "top"}`, http.StatusNotFound},
+		{fmt.Sprintf(`{"id": %q, "before": "missing"}`, ids[0]), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := reorder(tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.want, w.Code)
+		}
+	}
+
+	// Only pending jobs move
+	_ = handler.queue.StartJob(ids[1], "/tmp/b.tmp.mkv")
+	if w := reorder(fmt.Sprintf(`{"id": %q, "to": "bottom"}`, ids[1])); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a running job, got %d", w.Code)
+	}
+}
+
+func TestSetJobPriorityEndpoint(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	probe := &ffmpeg.ProbeResult{Path: "/media/video.mkv", Size: 1000, Duration: 10 * time.Second}
+	first, _ := handler.queue.Add("/media/first.mkv", "compress", probe, "")
+	urgent, _ := handler.queue.Add("/media/urgent.mkv", "compress", probe, "")
+
+	setPriority := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/jobs/"+id+"/priority", bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.SetJobPriority(w, req)
+		return w
+	}
+
+	w := setPriority(urgent.ID, `{"priority": 10}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Priority != 10 {
+		t.Errorf("expected priority 10 in response, got %+v (%v)", got, err)
+	}
+	if next := handler.queue.GetNext(); next == nil || next.ID != urgent.ID {
+		t.Errorf("expected the urgent job next, got %+v", next)
+	}
+
+	if w := setPriority(first.ID, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a priority, got %d", w.Code)
+	}
+	if w := setPriority("missing", `{"priority": 1}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	_ = handler.queue.CancelJob(first.ID)
+	if w := setPriority(first.ID, `{"priority": 1}`); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a cancelled job, got %d", w.Code)
+	}
+}
+
+func TestCreateJobsOverrides(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+	showDir := filepath.Join(tmpDir, "TV Shows", "Test Show", "Season 1")
+
+	create := func(presetID string, overrides jobs.Overrides) int {
+		t.Helper()
+		body, _ := json.Marshal(CreateJobsRequest{Paths: []string{showDir}, PresetID: presetID, Overrides: overrides})
+		w := httptest.NewRecorder()
+		handler.CreateJobs(w, httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	tests := []struct {
+		name      string
+		presetID  string
+		overrides jobs.Overrides
+		want      int
+	}{
+		{"none", "compress-hevc", jobs.Overrides{}, http.StatusAccepted},
+		{"all", "compress-hevc", jobs.Overrides{Quality: 24, OutputFormat: "mp4", Tonemap: "off", OriginalHandling: "keep"}, http.StatusAccepted},
+		{"quality out of range", "compress-hevc", jobs.Overrides{Quality: 64}, http.StatusBadRequest},
+		{"quality outside encoder range", "compress-hevc", jobs.Overrides{Quality: 40}, http.StatusBadRequest},
+		{"av1 quality", "compress-av1", jobs.Overrides{Quality: 40}, http.StatusAccepted},
+		{"negative quality", "compress-hevc", jobs.Overrides{Quality: -1}, http.StatusBadRequest},
+		{"unknown format", "compress-hevc", jobs.Overrides{OutputFormat: "avi"}, http.StatusBadRequest},
+		{"unknown tonemap", "compress-hevc", jobs.Overrides{Tonemap: "yes"}, http.StatusBadRequest},
+		{"unknown original handling", "compress-hevc", jobs.Overrides{OriginalHandling: "delete"}, http.StatusBadRequest},
+		{"trash without trash_path", "compress-hevc", jobs.Overrides{OriginalHandling: "trash"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := create(tt.presetID, tt.overrides); code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, code)
+		}
+	}
+
+	// SmartShrink picks its own quality (its presets need VMAF)
+	if ffmpeg.GetPreset("smartshrink-hevc") != nil {
+		if code := create("smartshrink-hevc", jobs.Overrides{Quality: 24}); code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for quality with SmartShrink, got %d", code)
+		}
+	}
+
+	// Overrides are flattened into the request body
+	var req CreateJobsRequest
+	if err := json.Unmarshal([]byte(`{"paths": ["/media"], "output_format": "mp4", "quality": 22}`), &req); err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+	if req.OutputFormat != "mp4" || req.Quality != 22 {
+		t.Errorf("expected overrides parsed from the body, got %+v", req.Overrides)
+	}
+}
+
+func TestNotifierTestEndpoint(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	test := func(backend string) int {
+		req := httptest.NewRequest("POST", "/api/notify/"+backend+"/test", nil)
+		req.SetPathValue("backend", backend)
+		w := httptest.NewRecorder()
+		handler.TestNotifier(w, req)
+		return w.Code
+	}
+
+	if code := test("slack"); code != http.StatusBadRequest {
+		t.Errorf("unconfigured backend: expected 400, got %d", code)
+	}
+	if code := test("fax"); code != http.StatusNotFound {
+		t.Errorf("unknown backend: expected 404, got %d", code)
+	}
+
+	// Configure through the API, then test
+	hookURL := srv.URL + "/slack"
+	body, _ := json.Marshal(UpdateConfigRequest{SlackWebhookURL: &hookURL})
+	req := httptest.NewRequest("PUT", "/api/config", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.UpdateConfig(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected config update to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if code := test("slack"); code != http.StatusOK {
+		t.Errorf("configured backend: expected 200, got %d", code)
+	}
+	if path := <-received; path != "/slack" {
+		t.Errorf("expected test sent to /slack, got %s", path)
+	}
+
+	// Webhook URLs must be http(s)
+	badURL := "ftp://example.com/hook"
+	body, _ = json.Marshal(UpdateConfigRequest{DiscordWebhookURL: &badURL})
+	req = httptest.NewRequest("PUT", "/api/config", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.UpdateConfig(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for non-http webhook URL, got %d", w.Code)
+	}
+}
+
+func TestUpdateConfigNotificationRules(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	update := func(req UpdateConfigRequest) int {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest("PUT", "/api/config", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.UpdateConfig(w, r)
+		return w.Code
+	}
+
+	weekly := "weekly"
+	failure := true
+	quietStart := 23
+	if code := update(UpdateConfigRequest{NotifyDigest: &weekly, NotifyOnFailure: &failure, QuietHoursStart: &quietStart}); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if handler.cfg.NotifyDigest != "weekly" || !handler.cfg.NotifyOnFailure || handler.cfg.QuietHoursStart != 23 {
+		t.Errorf("rules not applied: %+v", handler.cfg)
+	}
+
+	hourly := "hourly"
+	if code := update(UpdateConfigRequest{NotifyDigest: &hourly}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid notify_digest, got %d", code)
+	}
+	badHour := 24
+	if code := update(UpdateConfigRequest{QuietHoursEnd: &badHour}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid quiet_hours_end, got %d", code)
+	}
+}
+
+func TestWatchEndpoints(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+
+	// Without a watcher the endpoints are unavailable
+	w := httptest.NewRecorder()
+	handler.GetWatch(w, httptest.NewRequest("GET", "/api/watch", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without a watcher, got %d", w.Code)
+	}
+
+	handler.SetWatcher(watch.New(handler.queue, handler.browser, nil, nil, 0))
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/watch", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.UpdateWatch(w, req)
+		return w
+	}
+
+	watchDir := filepath.Join(tmpDir, "TV Shows")
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"outside media path", `{"folders":[{"path":"` + t.TempDir() + `","preset_id":"compress-hevc"}]}`},
+		{"missing directory", `{"folders":[{"path":"` + filepath.Join(tmpDir, "missing") + `","preset_id":"compress-hevc"}]}`},
+		{"unknown preset", `{"folders":[{"path":"` + watchDir + `","preset_id":"nope"}]}`},
+		{"bad quality", `{"folders":[{"path":"` + watchDir + `","preset_id":"compress-hevc","smartshrink_quality":"best"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := update(tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if w := update(`{"folders":[{"path":"` + watchDir + `","preset_id":"compress-hevc"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(handler.cfg.WatchFolders) != 1 || handler.cfg.WatchFolders[0].Path != watchDir {
+		t.Errorf("expected config updated, got %+v", handler.cfg.WatchFolders)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetWatch(w, httptest.NewRequest("GET", "/api/watch", nil))
+	var result struct {
+		Folders  []config.WatchFolder `json:"folders"`
+		LastScan *time.Time           `json:"last_scan"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(result.Folders) != 1 || result.Folders[0].PresetID != "compress-hevc" || result.LastScan != nil {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestProcessedEndpoints(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+	handler.queue, _ = jobs.NewQueueWithStore(s)
+
+	path := filepath.Join(tmpDir, "TV Shows", "Test Show", "Season 1", "episode1.mkv")
+	query := "/api/processed?path=" + url.QueryEscape(path)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.GetProcessed(w, httptest.NewRequest("GET", query, nil))
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 before processing, got %d", w.Code)
+	}
+
+	job, _ := handler.queue.Add(path, "compress-hevc", &ffmpeg.ProbeResult{Path: path, Size: 1000}, "")
+	_ = handler.queue.StartJob(job.ID, "")
+	_ = handler.queue.CompleteJob(job.ID, path, 10)
+
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var file jobs.ProcessedFile
+	if err := json.Unmarshal(w.Body.Bytes(), &file); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if file.Outcome != jobs.ProcessedTranscoded || file.PresetID != "compress-hevc" {
+		t.Errorf("unexpected record: %+v", file)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ForgetProcessed(w, httptest.NewRequest("DELETE", query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after forgetting, got %d", w.Code)
+	}
+}
+
+// fakeProber returns fixed probe results instead of running ffprobe
+type fakeProber struct {
+	probes []*ffmpeg.ProbeResult
+}
+
+func (p *fakeProber) GetVideoFilesWithProgress(ctx context.Context, paths []string, onProgress browse.ProgressCallback) ([]*ffmpeg.ProbeResult, error) {
+	return p.probes, nil
+}
+
+func TestRulesEndpoints(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+
+	queueRules, err := rules.Compile([]config.QueueRule{
+		{Name: "tv h264", Path: "TV Shows", Codecs: []string{"h264"}, PresetID: "compress-hevc"},
+	}, []string{tmpDir})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	path := filepath.Join(tmpDir, "TV Shows", "Test Show", "Season 1", "episode1.mkv")
+	prober := &fakeProber{probes: []*ffmpeg.ProbeResult{
+		{Path: path, Size: 1000, VideoCodec: "h264"},
+		{Path: filepath.Join(tmpDir, "TV Shows", "Test Show", "Season 1", "episode2.mkv"), Size: 1000, VideoCodec: "hevc"},
+	}}
+	handler.SetRules(rules.New(handler.queue, prober, []string{tmpDir}, queueRules))
+
+	post := func(run func(http.ResponseWriter, *http.Request)) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		run(w, httptest.NewRequest("POST", "/api/rules", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	result := post(handler.DryRunRules)
+	if result["count"] != float64(1) {
+		t.Fatalf("expected 1 match, got %v", result)
+	}
+	if match := result["matches"].([]interface{})[0].(map[string]interface{}); match["path"] != path || match["rule"] != "tv h264" {
+		t.Errorf("unexpected match: %v", match)
+	}
+	if n := len(handler.queue.GetAll()); n != 0 {
+		t.Fatalf("expected dry run not to queue, got %d jobs", n)
+	}
+
+	post(handler.RunRules)
+	all := handler.queue.GetAll()
+	if len(all) != 1 || all[0].InputPath != path || all[0].PresetID != "compress-hevc" {
+		t.Errorf("unexpected jobs after run: %+v", all)
+	}
+}
+
+func TestLibraryEndpoints(t *testing.T) {
+	handler, tmpDir := setupTestHandler(t)
+
+	search := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.SearchLibrary(w, httptest.NewRequest("GET", "/api/library/search?"+query, nil))
+		return w
+	}
+
+	if w := search(""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without an index, got %d", w.Code)
+	}
+
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+	handler.browser.SetIndex(s)
+
+	big := filepath.Join(tmpDir, "Movies", "Big.mkv")
+	err = s.SaveProbes([]*ffmpeg.ProbeResult{
+		{Path: big, Size: 12 << 30, VideoCodec: "h264", Width: 1920, Height: 1080},
+		{Path: filepath.Join(tmpDir, "Movies", "Small.mkv"), Size: 1 << 30, VideoCodec: "h264", Width: 1920, Height: 1080},
+		{Path: filepath.Join(tmpDir, "Movies", "UHD.mkv"), Size: 40 << 30, VideoCodec: "hevc", Width: 3840, Height: 2160},
+	})
+	if err != nil {
+		t.Fatalf("SaveProbes failed: %v", err)
+	}
+
+	w := search("codec=h264&resolution=1080p&min_size=10G")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Files []ffmpeg.ProbeResult `json:"files"`
+		Total int                  `json:"total"`
+		Limit int                  `json:"limit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Files) != 1 || resp.Files[0].Path != big || resp.Limit != defaultSearchLimit {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	for _, query := range []string{"resolution=8k", "min_size=big", "min_bitrate=fast", "hdr=maybe", "sort=name", "order=up", "limit=-1"} {
+		if w := search(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+
+	queue := func(query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.QueueLibrary(w, httptest.NewRequest("POST", "/api/library/queue?"+query, bytes.NewBufferString(body)))
+		return w
+	}
+	if w := queue("codec=h264", `{"preset_id":"nope"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown preset, got %d", w.Code)
+	}
+	if w := queue("codec=vp9", `{"preset_id":"compress-hevc"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 when nothing matches, got %d", w.Code)
+	}
+	w = queue("codec=h264&limit=1", `{"preset_id":"compress-hevc"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var queued struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queued)
+	if queued.Count != 2 {
+		t.Errorf("expected every match queued regardless of limit, got %d", queued.Count)
+	}
+}
@@ -18,14 +18,40 @@ func registerAPIRoutes(mux *http.ServeMux, h *Handler) {
 	mux.HandleFunc("POST /api/jobs", h.CreateJobs)
 	mux.HandleFunc("GET /api/jobs/stream", h.JobStream)
 	mux.HandleFunc("POST /api/jobs/clear", h.ClearQueue)
+	mux.HandleFunc("POST /api/jobs/reorder", h.ReorderJobs)
 	mux.HandleFunc("GET /api/jobs/{id}", h.GetJob)
 	mux.HandleFunc("DELETE /api/jobs/{id}", h.CancelJob)
 	mux.HandleFunc("POST /api/jobs/{id}/retry", h.RetryJob)
+	mux.HandleFunc("POST /api/jobs/{id}/restore", h.RestoreJob)
+	mux.HandleFunc("PUT /api/jobs/{id}/priority", h.SetJobPriority)
 
 	// Queue control (stop/resume)
 	mux.HandleFunc("POST /api/queue/pause", h.PauseQueue)
 	mux.HandleFunc("POST /api/queue/resume", h.ResumeQueue)
 
+	// Trash (originals kept by original_handling: trash)
+	mux.HandleFunc("GET /api/trash", h.ListTrash)
+	mux.HandleFunc("POST /api/trash/{job_id}/restore", h.RestoreTrash)
+
+	// Processed-file ledger (files already transcoded or optimized)
+	mux.HandleFunc("GET /api/processed", h.GetProcessed)
+	mux.HandleFunc("DELETE /api/processed", h.ForgetProcessed)
+
+	// Watch folders (new files queued automatically)
+	mux.HandleFunc("GET /api/watch", h.GetWatch)
+	mux.HandleFunc("PUT /api/watch", h.UpdateWatch)
+	mux.HandleFunc("POST /api/watch/scan", h.ScanWatch)
+
+	// Library search (persistent index of probed files)
+	mux.HandleFunc("GET /api/library/search", h.SearchLibrary)
+	mux.HandleFunc("POST /api/library/index", h.IndexLibrary)
+	mux.HandleFunc("POST /api/library/queue", h.QueueLibrary)
+
+	// Queue rules (library-wide auto-queue policies)
+	mux.HandleFunc("GET /api/rules", h.ListRules)
+	mux.HandleFunc("POST /api/rules/dry-run", h.DryRunRules)
+	mux.HandleFunc("POST /api/rules/run", h.RunRules)
+
 	// Configuration
 	mux.HandleFunc("GET /api/config", h.GetConfig)
 	mux.HandleFunc("PUT /api/config", h.UpdateConfig)
@@ -35,6 +61,7 @@ func registerAPIRoutes(mux *http.ServeMux, h *Handler) {
 	mux.HandleFunc("POST /api/stats/reset-session", h.ResetSession)
 	mux.HandleFunc("POST /api/cache/clear", h.ClearCache)
 	mux.HandleFunc("POST /api/pushover/test", h.TestPushover)
+	mux.HandleFunc("POST /api/notify/{backend}/test", h.TestNotifier)
 }
 
 // NewRouter creates a new HTTP router with all API endpoints
@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/gwlsn/shrinkray/internal/ffmpeg"
 	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -39,6 +41,20 @@ type BrowseResult struct {
 	Entries    []*Entry `json:"entries"`
 	VideoCount int      `json:"video_count"` // Total video files in this directory and subdirs
 	TotalSize  int64    `json:"total_size"`  // Total size of video files
+
+	// The media root being browsed, set when there are several roots
+	// (with several roots, the top level lists them and has an empty Path)
+	RootName string `json:"root_name,omitempty"`
+	RootPath string `json:"root_path,omitempty"`
+
+	// DefaultPresetID is the root's default preset, if it has one (set by the API)
+	DefaultPresetID string `json:"default_preset_id,omitempty"`
+}
+
+// Root is a top-level media directory
+type Root struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
 }
 
 // dirCount holds cached recursive video counts for a directory
@@ -49,8 +65,8 @@ type dirCount struct {
 
 // Browser handles file system browsing with video metadata
 type Browser struct {
-	prober    *ffmpeg.Prober
-	mediaRoot string
+	prober *ffmpeg.Prober
+	roots  []Root
 
 	// Cache for probe results (path -> result)
 	cacheMu sync.RWMutex
@@ -65,40 +81,98 @@ type Browser struct {
 
 	// Limits concurrent directory walks to avoid overwhelming network shares
 	countSem chan struct{}
+
+	// Persistent index of probed files for library search (may be nil)
+	index    LibraryIndex
+	indexing atomic.Bool
+
+	// Exclusions: global globs, and .shrinkrayignore files by directory
+	excludes    []ignorePattern
+	ignoreMu    sync.RWMutex
+	ignoreCache map[string][]ignorePattern
 }
 
 // NewBrowser creates a new Browser with the given prober and media root
 func NewBrowser(prober *ffmpeg.Prober, mediaRoot string) *Browser {
-	// Convert to absolute path for consistent comparisons
-	absRoot, err := filepath.Abs(mediaRoot)
+	return NewBrowserWithRoots(prober, []Root{{Name: filepath.Base(mediaRoot), Path: mediaRoot}})
+}
+
+// NewBrowserWithRoots creates a new Browser over several media roots.
+// With more than one root, the top level lists the roots as directories.
+func NewBrowserWithRoots(prober *ffmpeg.Prober, roots []Root) *Browser {
+	b := &Browser{
+		prober:      prober,
+		cache:       make(map[string]*ffmpeg.ProbeResult),
+		countCache:  make(map[string]*dirCount),
+		countSem:    make(chan struct{}, 8),
+		ignoreCache: make(map[string][]ignorePattern),
+	}
+	for _, root := range roots {
+		// Convert to absolute path for consistent comparisons
+		root.Path = absPath(root.Path)
+		b.roots = append(b.roots, root)
+	}
+	return b
+}
+
+// Roots returns the media roots
+func (b *Browser) Roots() []Root {
+	return slices.Clone(b.roots)
+}
+
+// absPath converts a path to a clean absolute path
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		absRoot = mediaRoot
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// rootOf returns the path of the media root containing path (the deepest one
+// if roots are nested), or "" if path is outside every root
+func (b *Browser) rootOf(path string) string {
+	var found string
+	for _, root := range b.roots {
+		if util.IsWithin(path, root.Path) && len(root.Path) > len(found) {
+			found = root.Path
+		}
 	}
-	return &Browser{
-		prober:     prober,
-		mediaRoot:  absRoot,
-		cache:      make(map[string]*ffmpeg.ProbeResult),
-		countCache: make(map[string]*dirCount),
-		countSem:   make(chan struct{}, 8),
+	return found
+}
+
+// topRoots returns the paths of the media roots that aren't inside another
+// root, so walking them visits every file once
+func (b *Browser) topRoots() []string {
+	var paths []string
+	for _, root := range b.roots {
+		if b.rootOf(filepath.Dir(root.Path)) == "" || root.Path == filepath.Dir(root.Path) {
+			paths = append(paths, root.Path)
+		}
 	}
+	return paths
 }
 
-// normalizePath converts a path to an absolute path and ensures it's within the media root.
-// If the path is outside the media root, it returns the media root instead.
+// normalizePath converts a path to an absolute path and ensures it's within a media root.
+// If the path is outside every root, it returns the media root instead, or ""
+// (the top level listing the roots) when there are several.
 func (b *Browser) normalizePath(path string) string {
-	cleanPath, err := filepath.Abs(path)
-	if err != nil {
-		cleanPath = filepath.Clean(path)
+	cleanPath := absPath(path)
+	if path != "" && b.rootOf(cleanPath) != "" {
+		return cleanPath
 	}
-	if !strings.HasPrefix(cleanPath, b.mediaRoot) {
-		return b.mediaRoot
+	if len(b.roots) == 1 {
+		return b.roots[0].Path
 	}
-	return cleanPath
+	return ""
 }
 
 // Browse returns the contents of a directory
 func (b *Browser) Browse(ctx context.Context, path string) (*BrowseResult, error) {
 	cleanPath := b.normalizePath(path)
+	if cleanPath == "" {
+		return b.browseRoots(), nil
+	}
 
 	entries, err := os.ReadDir(cleanPath)
 	if err != nil {
@@ -111,9 +185,17 @@ func (b *Browser) Browse(ctx context.Context, path string) (*BrowseResult, error
 	}
 
 	// Set parent path (if not at root)
-	if cleanPath != b.mediaRoot {
+	root := b.rootOf(cleanPath)
+	if cleanPath != root {
 		result.Parent = filepath.Dir(cleanPath)
 	}
+	if len(b.roots) > 1 {
+		for _, r := range b.roots {
+			if r.Path == root {
+				result.RootName, result.RootPath = r.Name, r.Path
+			}
+		}
+	}
 
 	// Process entries
 	var wg sync.WaitGroup
@@ -126,6 +208,9 @@ func (b *Browser) Browse(ctx context.Context, path string) (*BrowseResult, error
 		}
 
 		entryPath := filepath.Join(cleanPath, e.Name())
+		if b.matchIgnore(entryPath, e.IsDir()) {
+			continue
+		}
 		info, err := e.Info()
 		if err != nil {
 			continue
@@ -140,20 +225,7 @@ func (b *Browser) Browse(ctx context.Context, path string) (*BrowseResult, error
 		}
 
 		if e.IsDir() {
-			// Non-blocking: return cached counts instantly, or fire background
-			// computation so counts appear on the next browse.
-			b.countCacheMu.RLock()
-			cached, isCached := b.countCache[entryPath]
-			b.countCacheMu.RUnlock()
-
-			if isCached {
-				entry.FileCount = cached.fileCount
-				entry.TotalSize = cached.totalSize
-			} else {
-				// Populate cache in background (detached context so it completes
-				// even after this HTTP response is sent)
-				go b.countVideos(context.Background(), entryPath)
-			}
+			b.setDirCounts(entry)
 		} else if ffmpeg.IsVideoFile(e.Name()) {
 			// For video files, get probe info (with caching)
 			wg.Add(1)
@@ -191,6 +263,46 @@ func (b *Browser) Browse(ctx context.Context, path string) (*BrowseResult, error
 	return result, nil
 }
 
+// browseRoots returns the top level when there are several media roots:
+// one directory entry per root, in configured order. Roots that can't be
+// read (e.g. an unmounted share) are left out.
+func (b *Browser) browseRoots() *BrowseResult {
+	result := &BrowseResult{Entries: make([]*Entry, 0, len(b.roots))}
+	for _, root := range b.roots {
+		info, err := os.Stat(root.Path)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		entry := &Entry{
+			Name:    root.Name,
+			Path:    root.Path,
+			IsDir:   true,
+			ModTime: info.ModTime(),
+		}
+		b.setDirCounts(entry)
+		result.Entries = append(result.Entries, entry)
+	}
+	return result
+}
+
+// setDirCounts fills in a directory entry's recursive video counts.
+// Non-blocking: uses cached counts instantly, or fires background
+// computation so counts appear on the next browse.
+func (b *Browser) setDirCounts(entry *Entry) {
+	b.countCacheMu.RLock()
+	cached, isCached := b.countCache[entry.Path]
+	b.countCacheMu.RUnlock()
+
+	if isCached {
+		entry.FileCount = cached.fileCount
+		entry.TotalSize = cached.totalSize
+	} else {
+		// Populate cache in background (detached context so it completes
+		// even after this HTTP response is sent)
+		go b.countVideos(context.Background(), entry.Path)
+	}
+}
+
 // countVideos counts video files in a directory recursively.
 // Uses three layers of optimization:
 //   - Cache: instant return for previously-walked directories
@@ -227,10 +339,16 @@ func (b *Browser) countVideos(ctx context.Context, dirPath string) (int, int64)
 			if ctx.Err() != nil {
 				return filepath.SkipAll
 			}
-			if err != nil || d.IsDir() {
+			if err != nil {
 				return nil
 			}
-			if ffmpeg.IsVideoFile(d.Name()) {
+			if d.IsDir() {
+				if path != dirPath && b.matchIgnore(path, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ffmpeg.IsVideoFile(d.Name()) && !b.matchIgnore(path, false) {
 				if info, infoErr := d.Info(); infoErr == nil {
 					count++
 					totalSize += info.Size()
@@ -256,34 +374,40 @@ func (b *Browser) countVideos(ctx context.Context, dirPath string) (int, int64)
 }
 
 // getProbeResult returns a cached or fresh probe result.
-// Validates cached entries using inode + size signature to detect file replacement.
-// We use inode + size instead of mtime because mtime is deliberately preserved
-// after transcoding (via os.Chtimes).
+// Cache misses are looked up in the library index, which persists probe
+// results across restarts, before probing the file.
+// Cached entries are validated against the file's signature to detect file
+// replacement. The inode and size matter most: mtime alone isn't enough
+// because it is deliberately preserved after transcoding (via os.Chtimes).
 func (b *Browser) getProbeResult(ctx context.Context, path string) *ffmpeg.ProbeResult {
-	// Check cache
+	info, err := os.Stat(path)
+	if err != nil {
+		if isGone(path) {
+			_ = b.forgetProbes([]string{path})
+		}
+		return nil
+	}
+
+	// Check the memory cache, then the library index
 	b.cacheMu.RLock()
 	cached, ok := b.cache[path]
 	b.cacheMu.RUnlock()
 
 	if ok {
-		// Validate cached signature against current file
-		if info, err := os.Stat(path); err == nil {
-			currentSize := info.Size()
-			var currentInode uint64
-			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-				currentInode = stat.Ino
-			}
-
-			// Signature match = cache hit
-			if cached.Inode == currentInode && cached.Size == currentSize {
-				return cached
-			}
-
-			// Signature mismatch = invalidate and re-probe
-			b.cacheMu.Lock()
-			delete(b.cache, path)
-			b.cacheMu.Unlock()
+		// Signature match = cache hit
+		if sameFile(cached, info) {
+			return cached
 		}
+
+		// Signature mismatch = invalidate and re-probe
+		b.cacheMu.Lock()
+		delete(b.cache, path)
+		b.cacheMu.Unlock()
+	} else if stored := b.loadProbe(path); stored != nil && sameFile(stored, info) {
+		b.cacheMu.Lock()
+		b.cache[path] = stored
+		b.cacheMu.Unlock()
+		return stored
 	}
 
 	// Cache miss or invalidated: probe and cache
@@ -296,42 +420,69 @@ func (b *Browser) getProbeResult(ctx context.Context, path string) *ffmpeg.Probe
 	b.cache[path] = result
 	b.cacheMu.Unlock()
 
+	b.indexProbes([]*ffmpeg.ProbeResult{result})
+
 	return result
 }
 
+// sameFile reports whether a probe result still describes the file on disk
+func sameFile(probe *ffmpeg.ProbeResult, info os.FileInfo) bool {
+	var inode uint64
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		inode = stat.Ino
+	}
+	return probe.Inode == inode && probe.Size == info.Size() && probe.ModTime.Equal(info.ModTime())
+}
+
 // GetVideoFilesWithProgress returns all video files with progress reporting
 // The onProgress callback is called periodically with (probed, total) counts
 func (b *Browser) GetVideoFilesWithProgress(ctx context.Context, paths []string, onProgress ProgressCallback) ([]*ffmpeg.ProbeResult, error) {
 	// First pass: count total video files (fast, no probing)
 	var videoPaths []string
+	seen := make(map[string]bool) // Overlapping paths (a folder and a file in it) list files once
+	addVideo := func(filePath string) {
+		if !seen[filePath] {
+			seen[filePath] = true
+			videoPaths = append(videoPaths, filePath)
+		}
+	}
 	for _, path := range paths {
-		cleanPath := b.normalizePath(path)
-		// Skip if path was outside media root (normalizePath returns mediaRoot in that case)
-		if cleanPath == b.mediaRoot && path != b.mediaRoot && path != "" {
-			// Check if original path was actually trying to access something outside
-			absPath, _ := filepath.Abs(path)
-			if !strings.HasPrefix(absPath, b.mediaRoot) {
+		// An empty path means every media root
+		targets := b.topRoots()
+		if path != "" {
+			cleanPath := absPath(path)
+			// Skip paths outside the media roots
+			if b.rootOf(cleanPath) == "" {
 				continue
 			}
+			targets = []string{cleanPath}
 		}
 
-		info, err := os.Stat(cleanPath)
-		if err != nil {
-			continue
-		}
+		for _, target := range targets {
+			info, err := os.Stat(target)
+			if err != nil || b.isExcluded(target, info.IsDir()) {
+				continue
+			}
 
-		if info.IsDir() {
-			_ = filepath.Walk(cleanPath, func(filePath string, info os.FileInfo, err error) error {
-				if err != nil || info.IsDir() {
+			if info.IsDir() {
+				_ = filepath.Walk(target, func(filePath string, info os.FileInfo, err error) error {
+					if err != nil {
+						return nil
+					}
+					if info.IsDir() {
+						if filePath != target && b.matchIgnore(filePath, true) {
+							return filepath.SkipDir
+						}
+						return nil
+					}
+					if ffmpeg.IsVideoFile(filePath) && !b.matchIgnore(filePath, false) {
+						addVideo(filePath)
+					}
 					return nil
-				}
-				if ffmpeg.IsVideoFile(filePath) {
-					videoPaths = append(videoPaths, filePath)
-				}
-				return nil
-			})
-		} else if ffmpeg.IsVideoFile(cleanPath) {
-			videoPaths = append(videoPaths, cleanPath)
+				})
+			} else if ffmpeg.IsVideoFile(target) {
+				addVideo(target)
+			}
 		}
 	}
 
@@ -385,28 +536,37 @@ func (b *Browser) GetVideoFilesWithProgress(ctx context.Context, paths []string,
 	return results, nil
 }
 
-// ClearCache clears the probe cache (useful after transcoding completes).
-// Directory count cache is preserved since file counts don't change after transcoding.
+// ClearCache clears the in-memory probe cache (useful after transcoding completes).
+// The library index is kept: its entries are validated against each file again
+// as they are reloaded, so only files that changed are probed again.
+// Ignore files are re-read, and since they change what is counted, directory
+// counts are cleared too (they are recomputed in the background as folders are browsed).
 func (b *Browser) ClearCache() {
 	b.cacheMu.Lock()
 	b.cache = make(map[string]*ffmpeg.ProbeResult)
 	b.cacheMu.Unlock()
+
+	b.ignoreMu.Lock()
+	b.ignoreCache = make(map[string][]ignorePattern)
+	b.ignoreMu.Unlock()
+
+	b.countCacheMu.Lock()
+	b.countCache = make(map[string]*dirCount)
+	b.countCacheMu.Unlock()
 }
 
-// InvalidateCache removes a specific path from the probe cache and clears
-// directory count caches for all ancestor directories (since their recursive
-// counts include this file).
+// InvalidateCache removes a specific path from the probe cache and library
+// index, and clears directory count caches for all ancestor directories
+// (since their recursive counts include this file).
 func (b *Browser) InvalidateCache(path string) {
-	b.cacheMu.Lock()
-	delete(b.cache, path)
-	b.cacheMu.Unlock()
+	if err := b.forgetProbes([]string{path}); err != nil {
+		logger.Warn("Failed to update library index", "error", err.Error())
+	}
 
-	// Invalidate count cache for every ancestor directory up to media root.
-	// Use path-boundary check to avoid matching e.g. /mnt/mediastuff when root is /mnt/media.
-	rootPrefix := b.mediaRoot + string(os.PathSeparator)
+	// Invalidate count cache for every ancestor directory up to the media root
 	b.countCacheMu.Lock()
 	dir := filepath.Dir(path)
-	for dir == b.mediaRoot || strings.HasPrefix(dir, rootPrefix) {
+	for b.rootOf(dir) != "" {
 		delete(b.countCache, dir)
 		parent := filepath.Dir(dir)
 		if parent == dir {
@@ -418,68 +578,70 @@ func (b *Browser) InvalidateCache(path string) {
 }
 
 // WarmCountCache pre-computes recursive video counts for all directories
-// under the media root in a single pass. Call this in a background goroutine
+// under the media roots in a single pass. Call this in a background goroutine
 // at startup so counts are ready by the time the user opens the UI.
 func (b *Browser) WarmCountCache(ctx context.Context) {
 	start := time.Now()
-	logger.Info("Warming directory count cache", "media_root", b.mediaRoot)
+	roots := b.topRoots()
+	logger.Info("Warming directory count cache", "media_roots", strings.Join(roots, ", "))
 
 	dirCounts := make(map[string]*dirCount)
-	rootPrefix := b.mediaRoot + string(os.PathSeparator)
 	var videoCount int
 
-	_ = filepath.WalkDir(b.mediaRoot, func(path string, d fs.DirEntry, err error) error {
-		if ctx.Err() != nil {
-			return filepath.SkipAll
-		}
-		if err != nil {
-			return nil
-		}
-		// Skip hidden entries
-		if strings.HasPrefix(d.Name(), ".") {
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return filepath.SkipAll
+			}
+			if err != nil {
+				return nil
+			}
+			// Skip hidden and excluded entries
+			if strings.HasPrefix(d.Name(), ".") || b.matchIgnore(path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			if d.IsDir() {
-				return filepath.SkipDir
+				// Ensure every directory gets a cache entry (even if 0 videos)
+				if _, ok := dirCounts[path]; !ok {
+					dirCounts[path] = &dirCount{}
+				}
+				return nil
 			}
-			return nil
-		}
-		if d.IsDir() {
-			// Ensure every directory gets a cache entry (even if 0 videos)
-			if _, ok := dirCounts[path]; !ok {
-				dirCounts[path] = &dirCount{}
+			if !ffmpeg.IsVideoFile(d.Name()) {
+				return nil
 			}
-			return nil
-		}
-		if !ffmpeg.IsVideoFile(d.Name()) {
-			return nil
-		}
 
-		// Only stat video files (WalkDir skips stat for non-video entries)
-		info, infoErr := d.Info()
-		if infoErr != nil {
-			return nil
-		}
+			// Only stat video files (WalkDir skips stat for non-video entries)
+			info, infoErr := d.Info()
+			if infoErr != nil {
+				return nil
+			}
 
-		videoCount++
+			videoCount++
 
-		// Propagate this file's count and size to every ancestor directory
-		dir := filepath.Dir(path)
-		for dir == b.mediaRoot || strings.HasPrefix(dir, rootPrefix) {
-			dc, ok := dirCounts[dir]
-			if !ok {
-				dc = &dirCount{}
-				dirCounts[dir] = dc
-			}
-			dc.fileCount++
-			dc.totalSize += info.Size()
+			// Propagate this file's count and size to every ancestor directory
+			dir := filepath.Dir(path)
+			for b.rootOf(dir) != "" {
+				dc, ok := dirCounts[dir]
+				if !ok {
+					dc = &dirCount{}
+					dirCounts[dir] = dc
+				}
+				dc.fileCount++
+				dc.totalSize += info.Size()
 
-			parent := filepath.Dir(dir)
-			if parent == dir {
-				break
+				parent := filepath.Dir(dir)
+				if parent == dir {
+					break
+				}
+				dir = parent
 			}
-			dir = parent
-		}
-		return nil
-	})
+			return nil
+		})
+	}
 
 	// Populate cache in chunks to avoid holding the lock for too long
 	// (Browse calls need the read lock to return cached counts)
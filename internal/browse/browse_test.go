@@ -171,6 +171,68 @@ func TestBrowserSecurity(t *testing.T) {
 	}
 }
 
+func TestBrowseMultipleRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	movies := filepath.Join(tmpDir, "movies")
+	tv := filepath.Join(tmpDir, "tv")
+	show := filepath.Join(tv, "Show")
+	for _, dir := range []string{movies, show, filepath.Join(tmpDir, "movies2")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(show, "episode.mkv"), []byte("fake video content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	browser := NewBrowserWithRoots(ffmpeg.NewProber("ffprobe"), []Root{
+		{Name: "Movies", Path: movies},
+		{Name: "TV", Path: tv},
+	})
+	browser.WarmCountCache(context.Background())
+	ctx := context.Background()
+
+	// The top level lists the roots in order
+	result, err := browser.Browse(ctx, "")
+	if err != nil {
+		t.Fatalf("Browse failed: %v", err)
+	}
+	if result.Path != "" || result.Parent != "" || len(result.Entries) != 2 {
+		t.Fatalf("expected top level with 2 roots, got %+v", result)
+	}
+	if result.Entries[0].Name != "Movies" || result.Entries[1].Path != tv || result.Entries[1].FileCount != 1 {
+		t.Errorf("unexpected root entries: %+v, %+v", result.Entries[0], result.Entries[1])
+	}
+
+	// A root has no parent; its subdirectories do
+	result, err = browser.Browse(ctx, tv)
+	if err != nil {
+		t.Fatalf("Browse failed: %v", err)
+	}
+	if result.Parent != "" || result.RootName != "TV" || result.RootPath != tv {
+		t.Errorf("unexpected root result: %+v", result)
+	}
+	result, err = browser.Browse(ctx, show)
+	if err != nil {
+		t.Fatalf("Browse failed: %v", err)
+	}
+	if result.Parent != tv || result.RootName != "TV" {
+		t.Errorf("unexpected subdirectory result: %+v", result)
+	}
+
+	// Paths outside every root go to the top level, including a sibling
+	// whose name starts with a root's name
+	for _, path := range []string{"/etc", tmpDir, filepath.Join(tmpDir, "movies2")} {
+		result, err = browser.Browse(ctx, path)
+		if err != nil {
+			t.Fatalf("Browse failed: %v", err)
+		}
+		if result.Path != "" {
+			t.Errorf("%s: expected the top level, got %s", path, result.Path)
+		}
+	}
+}
+
 func TestGetVideoFilesWithProgress(t *testing.T) {
 	// Use the real test file
 	testFile := filepath.Join("..", "..", "testdata", "test_x264.mkv")
@@ -0,0 +1,181 @@
+package browse
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
+)
+
+// IgnoreFile is the name of the per-directory exclusion file. It uses
+// gitignore syntax, with patterns relative to the directory it is in.
+const IgnoreFile = ".shrinkrayignore"
+
+// ignorePattern is one compiled line of an ignore file or exclude glob
+type ignorePattern struct {
+	re      *regexp.Regexp // Matches the path relative to the pattern's base directory
+	negate  bool           // "!pattern" re-includes a path
+	dirOnly bool           // "pattern/" only matches directories
+}
+
+// parseIgnore compiles gitignore-style pattern lines. Blank lines and
+// comments are skipped, as are patterns that don't compile.
+func parseIgnore(lines []string) []ignorePattern {
+	var patterns []ignorePattern
+	for _, line := range lines {
+		if p, ok := compileIgnore(line); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// compileIgnore compiles one gitignore-style pattern:
+//   - a pattern without a slash matches a name at any depth ("*-sample.mkv")
+//   - a pattern with a slash is anchored to the base directory ("TV/Extras")
+//   - "*" and "?" don't match "/", "**" does ("**/Extras")
+//   - a trailing "/**" also matches the directory itself, so it is hidden
+//   - a trailing "/" only matches directories, and "!" re-includes a path
+func compileIgnore(line string) (ignorePattern, bool) {
+	var p ignorePattern
+	line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " ")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	contents := strings.HasSuffix(line, "/**")
+	line = strings.TrimSuffix(line, "/**")
+	if line == "" {
+		return p, false
+	}
+
+	expr := util.GlobRegexp(line, true)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	if contents {
+		expr += "(/.*)?"
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return p, false
+	}
+	p.re = re
+	return p, true
+}
+
+// SetExcludes sets global exclude globs. They use the same syntax as
+// .shrinkrayignore files, relative to each media root.
+func (b *Browser) SetExcludes(globs []string) {
+	b.excludes = parseIgnore(globs)
+}
+
+// ignorePatterns returns the patterns of the ignore file in dir (nil if it
+// has none). Files are read once and cached until ClearCache.
+func (b *Browser) ignorePatterns(dir string) []ignorePattern {
+	b.ignoreMu.RLock()
+	patterns, ok := b.ignoreCache[dir]
+	b.ignoreMu.RUnlock()
+	if ok {
+		return patterns
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, IgnoreFile))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to read ignore file", "dir", dir, "error", err.Error())
+	}
+	if err == nil {
+		patterns = parseIgnore(strings.Split(string(data), "\n"))
+	}
+
+	b.ignoreMu.Lock()
+	b.ignoreCache[dir] = patterns
+	b.ignoreMu.Unlock()
+	return patterns
+}
+
+// matchIgnore reports whether the exclude globs or the ignore files of its
+// parent directories exclude path. Like gitignore, the last matching pattern
+// wins and deeper ignore files take precedence. Excluded parent directories
+// aren't checked (walks skip them, see isExcluded).
+func (b *Browser) matchIgnore(path string, isDir bool) bool {
+	root := b.rootOf(path)
+	if root == "" || path == root {
+		return false
+	}
+
+	excluded := false
+	check := func(base string, patterns []ignorePattern) {
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return
+		}
+		rel = filepath.ToSlash(rel)
+		for _, p := range patterns {
+			if (!p.dirOnly || isDir) && p.re.MatchString(rel) {
+				excluded = !p.negate
+			}
+		}
+	}
+
+	check(root, b.excludes)
+	for _, dir := range dirsBetween(root, filepath.Dir(path)) {
+		check(dir, b.ignorePatterns(dir))
+	}
+	return excluded
+}
+
+// isExcluded reports whether path or any of its parent directories below
+// the media root is excluded
+func (b *Browser) isExcluded(path string, isDir bool) bool {
+	root := b.rootOf(path)
+	if root == "" {
+		return false
+	}
+	for _, dir := range dirsBetween(root, filepath.Dir(path)) {
+		if dir != root && b.matchIgnore(dir, true) {
+			return true
+		}
+	}
+	return b.matchIgnore(path, isDir)
+}
+
+// IsExcluded reports whether a path is hidden by an exclude glob or a
+// .shrinkrayignore file, directly or through a parent directory
+func (b *Browser) IsExcluded(path string) bool {
+	path = absPath(path)
+	info, err := os.Stat(path)
+	return b.isExcluded(path, err == nil && info.IsDir())
+}
+
+// dirsBetween returns root and each directory below it down to dir, in
+// that order. Returns nil if dir isn't root or inside it.
+func dirsBetween(root, dir string) []string {
+	if !util.IsWithin(dir, root) {
+		return nil
+	}
+	var dirs []string
+	for ; dir != root; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, root)
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs
+}
@@ -0,0 +1,164 @@
+package browse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+)
+
+func TestCompileIgnore(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*-sample.mkv", "Movie-sample.mkv", true},
+		{"*-sample.mkv", "Movies/Film/Film-sample.mkv", true},
+		{"*-sample.mkv", "Movies/Film/Film.mkv", false},
+		{"**/Extras/**", "Movies/Film/Extras", true},
+		{"**/Extras/**", "Movies/Film/Extras/Trailer.mkv", true},
+		{"**/Extras/**", "Extras/Trailer.mkv", true},
+		{"**/Extras/**", "Movies/Film/Extras.mkv", false},
+		{"**/4K Remux/**", "Movies/4K Remux/Film.mkv", true},
+		{"TV/Extras", "TV/Extras", true},
+		{"TV/Extras", "Movies/TV/Extras", false},
+		{"/Featurettes", "Featurettes", true},
+		{"/Featurettes", "Film/Featurettes", false},
+		{"Season ?", "Show/Season 1", true},
+		{"Season ?", "Show/Season 10", false},
+		{"*.[ao]vi", "Show/E01.avi", true},
+		{"*.[!ao]vi", "Show/E01.avi", false},
+		{`\#1.mkv`, "#1.mkv", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			p, ok := compileIgnore(tt.pattern)
+			if !ok {
+				t.Fatal("expected pattern to compile")
+			}
+			if got := p.re.MatchString(tt.path); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if _, ok := compileIgnore(line); ok {
+			t.Errorf("expected %q to be skipped", line)
+		}
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"Movies/Film/Film.mkv",
+		"Movies/Film/Film-sample.mkv",
+		"Movies/Film/Extras/Trailer.mkv",
+		"Movies/Other/Other.mkv",
+		"Movies/Other/Keep-sample.mkv",
+		"TV/Show/S01E01.mkv",
+		"TV/Show/Specials/Special.mkv",
+	}
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("fake video content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ignore := func(dir, content string) {
+		if err := os.WriteFile(filepath.Join(root, dir, IgnoreFile), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ignore("TV/Show", "# Not worth transcoding\nSpecials/\n")
+	ignore("Movies/Other", "!Keep-sample.mkv\n")
+
+	browser := NewBrowser(ffmpeg.NewProber("ffprobe"), root)
+	browser.SetExcludes([]string{"**/Extras/**", "*-sample.mkv"})
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"Movies/Film/Film.mkv", false},
+		{"Movies/Film/Film-sample.mkv", true},
+		{"Movies/Film/Extras", true},
+		{"Movies/Film/Extras/Trailer.mkv", true},
+		{"Movies/Other/Keep-sample.mkv", false}, // Re-included by a deeper ignore file
+		{"TV/Show/Specials", true},
+		{"TV/Show/Specials/Special.mkv", true}, // Inside an excluded directory
+		{"TV/Show/S01E01.mkv", false},
+	}
+	for _, tt := range tests {
+		if got := browser.IsExcluded(filepath.Join(root, tt.path)); got != tt.want {
+			t.Errorf("IsExcluded(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Excluded entries are hidden from browsing
+	result, err := browser.Browse(context.Background(), filepath.Join(root, "Movies", "Film"))
+	if err != nil {
+		t.Fatalf("Browse failed: %v", err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].Name != "Film.mkv" {
+		t.Errorf("expected only Film.mkv, got %d entries", len(result.Entries))
+	}
+
+	// ... and from counts
+	browser.WarmCountCache(context.Background())
+	for dir, want := range map[string]int{"": 4, "Movies": 3, "TV": 1} {
+		if got, _ := browser.countVideos(context.Background(), filepath.Join(root, dir)); got != want {
+			t.Errorf("count for %q: got %d, want %d", dir, got, want)
+		}
+	}
+
+	// Walking a directory without the count cache skips them too
+	browser.ClearCache()
+	if got, _ := browser.countVideos(context.Background(), filepath.Join(root, "TV")); got != 1 {
+		t.Errorf("expected 1 video in TV after clearing the cache, got %d", got)
+	}
+
+	// Selections never include them, even when picked directly.
+	// Probe results come from the index, since the files aren't real videos.
+	index := &memIndex{probes: make(map[string]*ffmpeg.ProbeResult)}
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := &ffmpeg.ProbeResult{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			entry.Inode = stat.Ino
+		}
+		index.probes[path] = entry
+	}
+	browser.SetIndex(index)
+
+	probes, err := browser.GetVideoFilesWithProgress(context.Background(), []string{
+		filepath.Join(root, "Movies"),
+		filepath.Join(root, "TV", "Show", "Specials", "Special.mkv"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("GetVideoFilesWithProgress failed: %v", err)
+	}
+	var got []string
+	for _, p := range probes {
+		rel, _ := filepath.Rel(root, p.Path)
+		got = append(got, rel)
+	}
+	want := []string{"Movies/Film/Film.mkv", "Movies/Other/Keep-sample.mkv", "Movies/Other/Other.mkv"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
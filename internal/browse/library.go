@@ -0,0 +1,261 @@
+package browse
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/logger"
+)
+
+// Resolution classes for library search
+const (
+	ResolutionSD    = "sd"
+	Resolution720p  = "720p"
+	Resolution1080p = "1080p"
+	Resolution2160p = "2160p"
+)
+
+// Resolutions lists the resolution classes, smallest first
+var Resolutions = []string{ResolutionSD, Resolution720p, Resolution1080p, Resolution2160p}
+
+// SearchSorts lists the fields library search results can be sorted by
+var SearchSorts = []string{"path", "size", "bitrate", "height", "duration", "codec"}
+
+var (
+	// ErrNoIndex is returned when the browser has no library index
+	ErrNoIndex = errors.New("library index is not configured")
+
+	// ErrIndexing is returned when the library is already being indexed
+	ErrIndexing = errors.New("library is already being indexed")
+)
+
+// ResolutionClass returns the resolution class of a video. Width counts as
+// well as height, so cropped video (e.g. 1920x800 scope) is classed as 1080p.
+func ResolutionClass(width, height int) string {
+	switch {
+	case width >= 3200 || height >= 1800:
+		return Resolution2160p
+	case width >= 1700 || height >= 1000:
+		return Resolution1080p
+	case width >= 1200 || height >= 700:
+		return Resolution720p
+	default:
+		return ResolutionSD
+	}
+}
+
+// Container returns a file's container, taken from its extension (e.g. "mkv")
+func Container(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// SearchQuery filters library search results. Zero values don't filter.
+type SearchQuery struct {
+	Codecs      []string // Video codecs, e.g. "h264" (any of)
+	Containers  []string // Containers, e.g. "mkv" (any of)
+	Resolutions []string // Resolution classes (any of)
+	MinHeight   int
+	MaxHeight   int
+	MinBitrate  int64 // bits per second
+	MaxBitrate  int64
+	HDR         *bool
+	BitDepth    int
+	MinSize     int64 // bytes
+	MaxSize     int64
+	Path        string // Case-insensitive substring of the path
+
+	Sort   string // One of SearchSorts (default "path")
+	Desc   bool
+	Limit  int // 0 = no limit
+	Offset int
+}
+
+// SearchResult is a page of library search results
+type SearchResult struct {
+	Files     []*ffmpeg.ProbeResult `json:"files"`
+	Total     int                   `json:"total"`      // Matching files across all pages
+	TotalSize int64                 `json:"total_size"` // Size of all matching files
+}
+
+// LibraryIndex persists probe results. It backs library search and is the
+// browser's probe cache across restarts, so files aren't probed again.
+// Implementations must be safe for concurrent use.
+type LibraryIndex interface {
+	// GetProbe returns the entry for a path, or nil if there is none.
+	GetProbe(path string) (*ffmpeg.ProbeResult, error)
+
+	// SaveProbes records probe results, replacing existing entries for the same paths.
+	SaveProbes(probes []*ffmpeg.ProbeResult) error
+
+	// DeleteProbes removes entries for paths (no error for paths without one).
+	DeleteProbes(paths []string) error
+
+	// ProbePaths returns the paths of all entries under root.
+	ProbePaths(root string) ([]string, error)
+
+	// SearchProbes returns the entries matching a query.
+	SearchProbes(q SearchQuery) (*SearchResult, error)
+}
+
+// SetIndex sets the library index. Every file the browser probes from then on
+// is added to it, and probe cache misses are looked up in it before probing.
+func (b *Browser) SetIndex(index LibraryIndex) {
+	b.index = index
+}
+
+// HasIndex reports whether the browser has a library index
+func (b *Browser) HasIndex() bool {
+	return b.index != nil
+}
+
+// loadProbe returns the library index entry for a path, or nil if there is
+// no index or no entry
+func (b *Browser) loadProbe(path string) *ffmpeg.ProbeResult {
+	if b.index == nil {
+		return nil
+	}
+	probe, err := b.index.GetProbe(path)
+	if err != nil {
+		logger.Warn("Failed to read library index", "path", path, "error", err.Error())
+		return nil
+	}
+	return probe
+}
+
+// forgetProbes removes paths from the probe cache and the library index
+func (b *Browser) forgetProbes(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	b.cacheMu.Lock()
+	for _, path := range paths {
+		delete(b.cache, path)
+	}
+	b.cacheMu.Unlock()
+
+	if b.index == nil {
+		return nil
+	}
+	return b.index.DeleteProbes(paths)
+}
+
+// isGone reports whether a file no longer exists. A file whose directory is
+// also missing doesn't count, so an unmounted share doesn't empty the index.
+func isGone(path string) bool {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return false
+	}
+	_, err := os.Stat(filepath.Dir(path))
+	return err == nil
+}
+
+// indexProbes adds fresh probe results to the library index, if there is one
+func (b *Browser) indexProbes(probes []*ffmpeg.ProbeResult) {
+	if b.index == nil || len(probes) == 0 {
+		return
+	}
+	if err := b.index.SaveProbes(probes); err != nil {
+		logger.Warn("Failed to update library index", "error", err.Error())
+	}
+}
+
+// Search queries the library index
+func (b *Browser) Search(q SearchQuery) (*SearchResult, error) {
+	if b.index == nil {
+		return nil, ErrNoIndex
+	}
+	return b.index.SearchProbes(q)
+}
+
+// Indexing reports whether IndexLibrary is running
+func (b *Browser) Indexing() bool {
+	return b.indexing.Load()
+}
+
+// IndexLibrary probes every video file under the media roots, adds them to the
+// library index and removes entries for files that no longer exist (see
+// PruneIndex) or are now excluded.
+// Returns the number of files indexed and removed.
+func (b *Browser) IndexLibrary(ctx context.Context) (indexed, removed int, err error) {
+	if b.index == nil {
+		return 0, 0, ErrNoIndex
+	}
+	if !b.indexing.CompareAndSwap(false, true) {
+		return 0, 0, ErrIndexing
+	}
+	defer b.indexing.Store(false)
+
+	probes, err := b.GetVideoFilesWithProgress(ctx, b.topRoots(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err // A partial walk would prune files that still exist
+	}
+
+	// Cached results may predate the index, so save them all
+	if err := b.index.SaveProbes(probes); err != nil {
+		return 0, 0, err
+	}
+
+	found := make(map[string]bool, len(probes))
+	for _, probe := range probes {
+		found[probe.Path] = true
+	}
+	removed, err = b.prune(ctx, func(path string) bool { return !found[path] && (isGone(path) || b.isExcluded(path, false)) })
+	if err != nil {
+		return len(probes), removed, err
+	}
+
+	logger.Info("Library indexed", "files", len(probes), "removed", removed)
+	return len(probes), removed, nil
+}
+
+// PruneIndex removes library index entries for files under the media roots that
+// no longer exist or are excluded. Entries are only pruned while their directory
+// exists, so an unmounted share keeps its entries. Returns the number of entries removed.
+func (b *Browser) PruneIndex(ctx context.Context) (int, error) {
+	if b.index == nil {
+		return 0, ErrNoIndex
+	}
+
+	removed, err := b.prune(ctx, func(path string) bool { return isGone(path) || b.isExcluded(path, false) })
+	if err != nil {
+		return removed, err
+	}
+	if removed > 0 {
+		logger.Info("Pruned library index", "removed", removed)
+	}
+	return removed, nil
+}
+
+// prune removes the index entries under the media roots for which gone returns true
+func (b *Browser) prune(ctx context.Context, gone func(path string) bool) (int, error) {
+	var paths []string
+	for _, root := range b.topRoots() {
+		rootPaths, err := b.index.ProbePaths(root)
+		if err != nil {
+			return 0, err
+		}
+		paths = append(paths, rootPaths...)
+	}
+
+	var stale []string
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if gone(path) {
+			stale = append(stale, path)
+		}
+	}
+	if err := b.forgetProbes(stale); err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
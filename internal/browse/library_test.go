@@ -0,0 +1,234 @@
+package browse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+)
+
+// memIndex is an in-memory LibraryIndex for tests
+type memIndex struct {
+	mu     sync.Mutex
+	probes map[string]*ffmpeg.ProbeResult
+}
+
+func (m *memIndex) GetProbe(path string) (*ffmpeg.ProbeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.probes[path], nil
+}
+
+func (m *memIndex) SaveProbes(probes []*ffmpeg.ProbeResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range probes {
+		m.probes[p.Path] = p
+	}
+	return nil
+}
+
+func (m *memIndex) DeleteProbes(paths []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, path := range paths {
+		delete(m.probes, path)
+	}
+	return nil
+}
+
+func (m *memIndex) ProbePaths(root string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var paths []string
+	for path := range m.probes {
+		if strings.HasPrefix(path, root+"/") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (m *memIndex) SearchProbes(q SearchQuery) (*SearchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := &SearchResult{}
+	for _, p := range m.probes {
+		result.Files = append(result.Files, p)
+		result.Total++
+	}
+	return result, nil
+}
+
+func TestResolutionClass(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          string
+	}{
+		{720, 480, ResolutionSD},
+		{1280, 720, Resolution720p},
+		{1280, 536, Resolution720p},
+		{1920, 1080, Resolution1080p},
+		{1920, 800, Resolution1080p},
+		{1440, 1080, Resolution1080p},
+		{3840, 2160, Resolution2160p},
+		{3840, 1600, Resolution2160p},
+	}
+	for _, tt := range tests {
+		if got := ResolutionClass(tt.width, tt.height); got != tt.want {
+			t.Errorf("ResolutionClass(%d, %d) = %s, want %s", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestContainer(t *testing.T) {
+	if got := Container("/media/Movie.MKV"); got != "mkv" {
+		t.Errorf("expected mkv, got %q", got)
+	}
+	if got := Container("/media/noext"); got != "" {
+		t.Errorf("expected empty container, got %q", got)
+	}
+}
+
+func TestIndexLibraryPrunesMissingFiles(t *testing.T) {
+	root := t.TempDir()
+	browser := NewBrowser(ffmpeg.NewProber("ffprobe"), root)
+
+	if _, _, err := browser.IndexLibrary(context.Background()); err != ErrNoIndex {
+		t.Fatalf("expected ErrNoIndex without an index, got %v", err)
+	}
+	if _, err := browser.Search(SearchQuery{}); err != ErrNoIndex {
+		t.Fatalf("expected ErrNoIndex without an index, got %v", err)
+	}
+
+	// Entries for a deleted file under the root and a file elsewhere
+	gone := filepath.Join(root, "deleted.mkv")
+	elsewhere := filepath.Join(t.TempDir(), "other.mkv")
+	if err := os.WriteFile(elsewhere, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := &memIndex{probes: map[string]*ffmpeg.ProbeResult{
+		gone:      {Path: gone},
+		elsewhere: {Path: elsewhere},
+	}}
+	browser.SetIndex(index)
+
+	indexed, removed, err := browser.IndexLibrary(context.Background())
+	if err != nil {
+		t.Fatalf("IndexLibrary failed: %v", err)
+	}
+	if indexed != 0 || removed != 1 {
+		t.Errorf("expected 0 indexed and 1 removed, got %d and %d", indexed, removed)
+	}
+	if _, ok := index.probes[gone]; ok {
+		t.Error("expected entry for deleted file to be pruned")
+	}
+	if _, ok := index.probes[elsewhere]; !ok {
+		t.Error("expected entry outside the media root to be kept")
+	}
+	if browser.Indexing() {
+		t.Error("expected indexing to be finished")
+	}
+
+	// InvalidateCache drops the index entry too
+	browser.InvalidateCache(elsewhere)
+	if _, ok := index.probes[elsewhere]; ok {
+		t.Error("expected invalidated path to be removed from the index")
+	}
+}
+
+func TestIndexServesAsProbeCache(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "movie.mkv")
+	if err := os.WriteFile(path, []byte("not really a video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A prober that always fails, so any result must come from the index
+	browser := NewBrowser(ffmpeg.NewProber(filepath.Join(root, "no-ffprobe")), root)
+
+	// Build the entry a previous run would have saved
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &ffmpeg.ProbeResult{Path: path, Size: info.Size(), ModTime: info.ModTime(), VideoCodec: "h264"}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.Inode = stat.Ino
+	}
+	index := &memIndex{probes: map[string]*ffmpeg.ProbeResult{path: entry}}
+	browser.SetIndex(index)
+
+	if got := browser.getProbeResult(context.Background(), path); got != entry {
+		t.Fatalf("expected the indexed result without probing, got %+v", got)
+	}
+
+	// Clearing the memory cache keeps the index, so the file still isn't probed
+	browser.ClearCache()
+	if got := browser.getProbeResult(context.Background(), path); got != entry {
+		t.Fatalf("expected the indexed result after ClearCache, got %+v", got)
+	}
+
+	// A file replaced in place doesn't match its entry
+	if err := os.WriteFile(path, []byte("a different, longer file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := browser.getProbeResult(context.Background(), path); got != nil {
+		t.Errorf("expected a stale entry to be re-probed, got %+v", got)
+	}
+
+	// A deleted file is dropped from the index
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	browser.getProbeResult(context.Background(), path)
+	if _, ok := index.probes[path]; ok {
+		t.Error("expected entry for deleted file to be removed")
+	}
+}
+
+func TestPruneIndex(t *testing.T) {
+	root := t.TempDir()
+	browser := NewBrowser(ffmpeg.NewProber("ffprobe"), root)
+
+	if _, err := browser.PruneIndex(context.Background()); err != ErrNoIndex {
+		t.Fatalf("expected ErrNoIndex without an index, got %v", err)
+	}
+
+	kept := filepath.Join(root, "kept.mkv")
+	if err := os.WriteFile(kept, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deleted := filepath.Join(root, "deleted.mkv")
+	unmounted := filepath.Join(root, "share", "movie.mkv") // Directory missing
+	index := &memIndex{probes: map[string]*ffmpeg.ProbeResult{
+		kept:      {Path: kept},
+		deleted:   {Path: deleted},
+		unmounted: {Path: unmounted},
+	}}
+	browser.SetIndex(index)
+
+	removed, err := browser.PruneIndex(context.Background())
+	if err != nil {
+		t.Fatalf("PruneIndex failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 entry removed, got %d", removed)
+	}
+	if _, ok := index.probes[deleted]; ok {
+		t.Error("expected entry for deleted file to be pruned")
+	}
+	if _, ok := index.probes[kept]; !ok {
+		t.Error("expected entry for existing file to be kept")
+	}
+	if _, ok := index.probes[unmounted]; !ok {
+		t.Error("expected entry in a missing directory to be kept")
+	}
+}
@@ -4,22 +4,74 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/gwlsn/shrinkray/internal/util"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	// MediaPath is the root directory to browse for media files
+	// (ignored when MediaRoots is set)
 	MediaPath string `yaml:"media_path"`
 
+	// MediaRoots are named media directories, e.g. separate mounts for movies
+	// and TV, each shown as a top-level folder. Each root can override the
+	// temp path, default preset, original handling and output format.
+	MediaRoots []MediaRoot `yaml:"media_roots"`
+
+	// Exclude lists globs for files and folders to hide and never queue, in
+	// .shrinkrayignore (gitignore) syntax relative to each media root,
+	// e.g. "**/Extras/**" or "*-sample.mkv"
+	Exclude []string `yaml:"exclude"`
+
 	// TempPath is where temp files are written during transcoding
 	// If empty, temp files go in the same directory as the source
 	TempPath string `yaml:"temp_path"`
 
 	// OriginalHandling determines what happens to original files after transcoding
-	// Options: "replace" (rename original to .old), "keep" (keep original, new file replaces)
+	// Options: "replace" (delete original), "keep" (rename original to .old),
+	// "trash" (move original to TrashPath, purged after the retention period)
 	OriginalHandling string `yaml:"original_handling"`
 
+	// TrashPath is where originals go when OriginalHandling is "trash".
+	// Must be outside MediaPath; relative paths under MediaPath are preserved inside it.
+	TrashPath string `yaml:"trash_path"`
+
+	// TrashRetentionDays is how long trashed originals are kept (default 30, 0 = no time limit)
+	TrashRetentionDays int `yaml:"trash_retention_days"`
+
+	// TrashMaxSize caps the total size of the trash, e.g. "500G" (empty = no cap).
+	// The oldest originals are purged first when the cap is exceeded.
+	TrashMaxSize string `yaml:"trash_max_size"`
+
+	// DiskSpaceAction is what happens when a job's estimated output doesn't fit on the
+	// temp or destination filesystem: "wait" (default, hold the job until space frees up),
+	// "skip", or "off" (no check)
+	DiskSpaceAction string `yaml:"disk_space_action"`
+
+	// DiskSpaceReserve is free space the disk check leaves untouched, e.g. "10G" (empty = none)
+	DiskSpaceReserve string `yaml:"disk_space_reserve"`
+
+	// WatchFolders are directories under MediaPath scanned for new files,
+	// which are queued automatically with the folder's preset
+	WatchFolders []WatchFolder `yaml:"watch_folders"`
+
+	// WatchIntervalMinutes is how often watch folders are scanned (default 5)
+	WatchIntervalMinutes int `yaml:"watch_interval_minutes"`
+
+	// WatchStableMinutes is how long a new file must go unchanged before it is
+	// queued, so files still being copied or downloaded are left alone (default 10)
+	WatchStableMinutes int `yaml:"watch_stable_minutes"`
+
+	// QueueRules queue library files matching their conditions, on demand
+	// or every QueueRulesIntervalHours. The first matching rule wins.
+	QueueRules []QueueRule `yaml:"queue_rules"`
+
+	// QueueRulesIntervalHours is how often queue rules run over the media path
+	// (0 = only on demand)
+	QueueRulesIntervalHours int `yaml:"queue_rules_interval_hours"`
+
 	// Workers is the number of concurrent transcode jobs (default 1)
 	Workers int `yaml:"workers"`
 
@@ -38,9 +90,67 @@ type Config struct {
 	// PushoverAppToken is the Pushover application token for notifications
 	PushoverAppToken string `yaml:"pushover_app_token"`
 
-	// NotifyOnComplete triggers a Pushover notification when all jobs finish
+	// DiscordWebhookURL is a Discord channel webhook for notifications
+	DiscordWebhookURL string `yaml:"discord_webhook_url"`
+
+	// SlackWebhookURL is a Slack incoming webhook for notifications
+	SlackWebhookURL string `yaml:"slack_webhook_url"`
+
+	// NtfyURL is the full ntfy topic URL, e.g. https://ntfy.sh/my-shrinkray
+	NtfyURL string `yaml:"ntfy_url"`
+
+	// NtfyToken is an access token for protected ntfy topics (optional)
+	NtfyToken string `yaml:"ntfy_token"`
+
+	// GotifyURL is the base URL of a Gotify server
+	GotifyURL string `yaml:"gotify_url"`
+
+	// GotifyToken is the Gotify application token
+	GotifyToken string `yaml:"gotify_token"`
+
+	// WebhookURL receives notifications as a JSON POST of {title, message, timestamp}
+	WebhookURL string `yaml:"webhook_url"`
+
+	// SMTPHost enables email notifications through this SMTP server
+	SMTPHost string `yaml:"smtp_host"`
+
+	// SMTPPort is the SMTP server port (default 587). STARTTLS is used when offered.
+	SMTPPort int `yaml:"smtp_port"`
+
+	// SMTPUsername and SMTPPassword authenticate with the SMTP server (optional)
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+
+	// SMTPFrom is the sender address for email notifications
+	SMTPFrom string `yaml:"smtp_from"`
+
+	// SMTPTo is a comma-separated list of recipients for email notifications
+	SMTPTo string `yaml:"smtp_to"`
+
+	// NotifyOnComplete sends a summary to every configured backend each time the queue drains
 	NotifyOnComplete bool `yaml:"notify_on_complete"`
 
+	// NotifyOnFailure sends a notification for every failed job, with its file and error
+	NotifyOnFailure bool `yaml:"notify_on_failure"`
+
+	// NotifyOnSkip sends a notification when SmartShrink skips a file as already optimized
+	NotifyOnSkip bool `yaml:"notify_on_skip"`
+
+	// NotifyDigest sends a scheduled summary of space saved and failures: "off", "daily" or "weekly" (Mondays)
+	NotifyDigest string `yaml:"notify_digest"`
+
+	// NotifyDigestHour is the hour the digest is sent (0-23, default 9 = 9 AM)
+	NotifyDigestHour int `yaml:"notify_digest_hour"`
+
+	// QuietHoursEnabled holds notifications during quiet hours and sends them when quiet hours end
+	QuietHoursEnabled bool `yaml:"quiet_hours_enabled"`
+
+	// QuietHoursStart is when quiet hours begin (0-23, default 22 = 10 PM)
+	QuietHoursStart int `yaml:"quiet_hours_start"`
+
+	// QuietHoursEnd is when quiet hours end (0-23, default 7 = 7 AM)
+	QuietHoursEnd int `yaml:"quiet_hours_end"`
+
 	// QualityHEVC is the CRF value for HEVC encoding (lower = higher quality, default 26)
 	QualityHEVC int `yaml:"quality_hevc"`
 
@@ -63,14 +173,24 @@ type Config struct {
 	// Useful for users who want codec consistency across their library
 	KeepLargerFiles bool `yaml:"keep_larger_files"`
 
+	// VerifyDecode fully decodes each output before it replaces the original,
+	// catching truncated or corrupt encodes that still probe correctly (default: false).
+	// Duration and stream counts are always checked.
+	VerifyDecode bool `yaml:"verify_decode"`
+
 	// AllowSameCodec allows transcoding files that are already in the target codec
 	// Useful for re-encoding at different bitrates or quality settings
 	AllowSameCodec bool `yaml:"allow_same_codec"`
 
-	// OutputFormat is the container format for transcoded files: "mkv" or "mp4"
-	// MKV preserves all streams; MP4 transcodes audio to AAC and strips subtitles
+	// OutputFormat is the container format for transcoded files: "mkv", "mp4" or "webm"
+	// MKV preserves all streams; MP4 transcodes audio to AAC and converts text subtitles to mov_text;
+	// WebM (VP9/AV1 presets only, others fall back to MKV) uses Opus audio and WebVTT subtitles
 	OutputFormat string `yaml:"output_format"`
 
+	// MP4ImageSubtitles controls image-based subtitles (PGS, VobSub) for MP4 and WebM output,
+	// which can't be stored in either: "drop" (default) or "extract" to sidecar files
+	MP4ImageSubtitles string `yaml:"mp4_image_subtitles"`
+
 	// TonemapHDR enables automatic HDR to SDR conversion (default: false)
 	// When enabled, HDR content (HDR10, HLG) is tonemapped to SDR using CPU.
 	// When disabled, HDR metadata is preserved for HDR-capable displays.
@@ -87,6 +207,46 @@ type Config struct {
 	MaxConcurrentAnalyses int `yaml:"max_concurrent_analyses"`
 }
 
+// MediaRoot is a named media directory. Empty settings fall back to the global ones.
+type MediaRoot struct {
+	Name             string `yaml:"name" json:"name"`
+	Path             string `yaml:"path" json:"path"`
+	TempPath         string `yaml:"temp_path,omitempty" json:"-"`                   // Not exposed by the API
+	PresetID         string `yaml:"preset_id,omitempty" json:"preset_id,omitempty"` // Used when jobs are added without a preset
+	OriginalHandling string `yaml:"original_handling,omitempty" json:"original_handling,omitempty"`
+	OutputFormat     string `yaml:"output_format,omitempty" json:"output_format,omitempty"`
+}
+
+// WatchFolder is a directory scanned for new media to queue automatically
+type WatchFolder struct {
+	Path               string `yaml:"path" json:"path"`
+	PresetID           string `yaml:"preset_id" json:"preset_id"`
+	SmartShrinkQuality string `yaml:"smartshrink_quality,omitempty" json:"smartshrink_quality,omitempty"`
+	QueueExisting      bool   `yaml:"queue_existing,omitempty" json:"queue_existing,omitempty"` // Queue the files already in the folder too
+}
+
+// QueueRule queues files that match all of its conditions with a preset.
+// Unset conditions match any file.
+type QueueRule struct {
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Path is a directory or glob, relative to MediaPath or absolute
+	// ("*" matches within a directory, "**" across directories)
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	Codecs     []string `yaml:"codecs,omitempty" json:"codecs,omitempty"`           // Video codecs as reported by ffprobe, e.g. h264, hevc
+	MinBitrate string   `yaml:"min_bitrate,omitempty" json:"min_bitrate,omitempty"` // e.g. "8M"
+	MinHeight  int      `yaml:"min_height,omitempty" json:"min_height,omitempty"`
+	MaxHeight  int      `yaml:"max_height,omitempty" json:"max_height,omitempty"`
+	HDR        *bool    `yaml:"hdr,omitempty" json:"hdr,omitempty"`
+	BitDepth   int      `yaml:"bit_depth,omitempty" json:"bit_depth,omitempty"`
+	MinSize    string   `yaml:"min_size,omitempty" json:"min_size,omitempty"` // e.g. "2G"
+	MaxSize    string   `yaml:"max_size,omitempty" json:"max_size,omitempty"`
+
+	PresetID           string `yaml:"preset_id" json:"preset_id"`
+	SmartShrinkQuality string `yaml:"smartshrink_quality,omitempty" json:"smartshrink_quality,omitempty"`
+}
+
 // DefaultConfig returns a config with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
@@ -104,9 +264,18 @@ func DefaultConfig() *Config {
 		ScheduleEndHour:   6,  // 6 AM
 		LogLevel:          "info",
 		OutputFormat:      "mkv",
+		MP4ImageSubtitles: "drop",
 		TonemapHDR:            false,   // HDR passthrough by default; enable for SDR conversion (uses CPU)
 		TonemapAlgorithm:      "hable", // Filmic tonemapping, good for movies
 		MaxConcurrentAnalyses: 1,       // Conservative default for media servers
+		TrashRetentionDays:    30,
+		DiskSpaceAction:       "wait",
+		NotifyDigest:          "off",
+		NotifyDigestHour:      9,  // 9 AM
+		QuietHoursStart:       22, // 10 PM
+		QuietHoursEnd:         7,  // 7 AM
+		WatchIntervalMinutes:  5,
+		WatchStableMinutes:    10,
 	}
 }
 
@@ -140,6 +309,19 @@ func Load(path string) (*Config, error) {
 	if cfg.Workers < 1 {
 		cfg.Workers = 1
 	}
+	if cfg.TrashRetentionDays < 0 {
+		cfg.TrashRetentionDays = 0
+	}
+	if cfg.WatchIntervalMinutes < 1 {
+		cfg.WatchIntervalMinutes = 5
+	}
+	if cfg.WatchStableMinutes < 0 {
+		cfg.WatchStableMinutes = 0
+	}
+	if cfg.QueueRulesIntervalHours < 0 {
+		cfg.QueueRulesIntervalHours = 0
+	}
+	cfg.MediaRoots = normalizeMediaRoots(cfg.MediaRoots)
 	// Note: QualityHEVC/QualityAV1 of 0 means "use encoder-specific default"
 	// The API handler will determine the actual default based on detected encoder
 
@@ -151,6 +333,18 @@ func Load(path string) (*Config, error) {
 		cfg.OutputFormat = "mkv"
 	}
 
+	if cfg.MP4ImageSubtitles != "extract" {
+		cfg.MP4ImageSubtitles = "drop"
+	}
+
+	if cfg.NotifyDigest != "daily" && cfg.NotifyDigest != "weekly" {
+		cfg.NotifyDigest = "off"
+	}
+
+	if cfg.DiskSpaceAction != "skip" && cfg.DiskSpaceAction != "off" {
+		cfg.DiskSpaceAction = "wait"
+	}
+
 	// Validate tonemapping algorithm (use shared validation)
 	cfg.TonemapAlgorithm = ValidateTonemapAlgorithm(cfg.TonemapAlgorithm)
 
@@ -181,11 +375,153 @@ func (c *Config) Save(path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// normalizeMediaRoots cleans root paths, names unnamed roots after their
+// directory and drops settings with unknown values (so the global ones apply).
+// Roots without a path are removed.
+func normalizeMediaRoots(roots []MediaRoot) []MediaRoot {
+	var valid []MediaRoot
+	for _, root := range roots {
+		if root.Path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(root.Path); err == nil {
+			root.Path = abs
+		}
+		if root.Name == "" {
+			root.Name = filepath.Base(root.Path)
+		}
+		if !IsValidOriginalHandling(root.OriginalHandling) {
+			root.OriginalHandling = ""
+		}
+		if !IsValidOutputFormat(root.OutputFormat) {
+			root.OutputFormat = ""
+		}
+		valid = append(valid, root)
+	}
+	return valid
+}
+
+// IsValidOriginalHandling returns true if value is a known original_handling mode
+func IsValidOriginalHandling(value string) bool {
+	return value == "replace" || value == "keep" || value == "trash"
+}
+
+// IsValidOutputFormat returns true if value is a known output_format
+func IsValidOutputFormat(value string) bool {
+	return value == "mkv" || value == "mp4" || value == "webm"
+}
+
+// Roots returns the media roots. Without media_roots, media_path is the only root.
+func (c *Config) Roots() []MediaRoot {
+	if len(c.MediaRoots) > 0 {
+		return c.MediaRoots
+	}
+	return []MediaRoot{{Name: filepath.Base(c.MediaPath), Path: c.MediaPath}}
+}
+
+// MediaPaths returns the paths of the media roots
+func (c *Config) MediaPaths() []string {
+	roots := c.Roots()
+	paths := make([]string, len(roots))
+	for i, root := range roots {
+		paths[i] = root.Path
+	}
+	return paths
+}
+
+// RootFor returns the media root containing path, or nil if it is outside
+// every root. The deepest root wins if roots are nested.
+func (c *Config) RootFor(path string) *MediaRoot {
+	var found *MediaRoot
+	roots := c.Roots()
+	for i := range roots {
+		if util.IsWithin(path, roots[i].Path) && (found == nil || len(roots[i].Path) > len(found.Path)) {
+			found = &roots[i]
+		}
+	}
+	return found
+}
+
 // GetTempDir returns the directory for temp files
-// If TempPath is set, returns that; otherwise returns the directory of the source file
+// If the source's media root or the config sets a temp path, returns that;
+// otherwise returns the directory of the source file
 func (c *Config) GetTempDir(sourcePath string) string {
+	if root := c.RootFor(sourcePath); root != nil && root.TempPath != "" {
+		return root.TempPath
+	}
 	if c.TempPath != "" {
 		return c.TempPath
 	}
 	return filepath.Dir(sourcePath)
 }
+
+// OriginalHandlingFor returns the original handling mode for a source file,
+// from its media root or the global setting
+func (c *Config) OriginalHandlingFor(sourcePath string) string {
+	if root := c.RootFor(sourcePath); root != nil && root.OriginalHandling != "" {
+		return root.OriginalHandling
+	}
+	return c.OriginalHandling
+}
+
+// OutputFormatFor returns the output format for a source file,
+// from its media root or the global setting
+func (c *Config) OutputFormatFor(sourcePath string) string {
+	if root := c.RootFor(sourcePath); root != nil && root.OutputFormat != "" {
+		return root.OutputFormat
+	}
+	return c.OutputFormat
+}
+
+// ValidateTrashPath checks that trashPath can hold originals moved out of the
+// media paths. The trash must not live inside a media tree, or trashed originals
+// would be browsed and queued like any other file.
+func ValidateTrashPath(trashPath string, mediaPaths ...string) error {
+	if trashPath == "" {
+		return fmt.Errorf("trash_path is not set")
+	}
+	trashAbs, err := filepath.Abs(trashPath)
+	if err != nil {
+		return err
+	}
+	for _, mediaPath := range mediaPaths {
+		mediaAbs, err := filepath.Abs(mediaPath)
+		if err != nil {
+			return err
+		}
+		if util.IsWithin(trashAbs, mediaAbs) {
+			return fmt.Errorf("trash_path %s is inside media path %s", trashPath, mediaPath)
+		}
+	}
+	return nil
+}
+
+// ValidateWatchPath returns an error if watchPath is not a directory inside
+// one of the media paths
+func ValidateWatchPath(watchPath string, mediaPaths ...string) error {
+	if watchPath == "" {
+		return fmt.Errorf("watch folder path is not set")
+	}
+	watchAbs, err := filepath.Abs(watchPath)
+	if err != nil {
+		return err
+	}
+	inside := false
+	for _, mediaPath := range mediaPaths {
+		if mediaAbs, err := filepath.Abs(mediaPath); err == nil && util.IsWithin(watchAbs, mediaAbs) {
+			inside = true
+			break
+		}
+	}
+	if !inside {
+		return fmt.Errorf("watch folder %s is outside the media paths (%s)", watchPath, strings.Join(mediaPaths, ", "))
+	}
+	info, err := os.Stat(watchAbs)
+	if err != nil {
+		return fmt.Errorf("watch folder %s: %w", watchPath, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("watch folder %s is not a directory", watchPath)
+	}
+	return nil
+}
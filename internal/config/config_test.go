@@ -21,6 +21,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.FFmpegPath != "ffmpeg" {
 		t.Errorf("expected FFmpegPath ffmpeg, got %s", cfg.FFmpegPath)
 	}
+	if cfg.MP4ImageSubtitles != "drop" {
+		t.Errorf("expected MP4ImageSubtitles drop, got %s", cfg.MP4ImageSubtitles)
+	}
 }
 
 func TestLoadNonExistent(t *testing.T) {
@@ -114,3 +117,205 @@ workers: 4`
 		t.Errorf("expected default ffmpeg path, got %s", cfg.FFmpegPath)
 	}
 }
+
+func TestLoadInvalidMP4ImageSubtitles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte("mp4_image_subtitles: burn"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.MP4ImageSubtitles != "drop" {
+		t.Errorf("expected invalid value to fall back to drop, got %s", cfg.MP4ImageSubtitles)
+	}
+}
+
+func TestLoadDiskSpaceAction(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	for value, want := range map[string]string{"skip": "skip", "off": "off", "pause": "wait", "": "wait"} {
+		if err := os.WriteFile(configPath, []byte("disk_space_action: "+value), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		cfg, err := Load(configPath)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if cfg.DiskSpaceAction != want {
+			t.Errorf("disk_space_action %q: got %s, want %s", value, cfg.DiskSpaceAction, want)
+		}
+	}
+}
+
+func TestLoadNotificationRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte("notify_digest: monthly\nquiet_hours_enabled: true"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.NotifyDigest != "off" {
+		t.Errorf("expected invalid digest to fall back to off, got %s", cfg.NotifyDigest)
+	}
+	// Unset hours keep their defaults
+	if cfg.NotifyDigestHour != 9 || cfg.QuietHoursStart != 22 || cfg.QuietHoursEnd != 7 {
+		t.Errorf("expected default hours, got digest %d quiet %d-%d", cfg.NotifyDigestHour, cfg.QuietHoursStart, cfg.QuietHoursEnd)
+	}
+}
+
+func TestLoadQueueRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	data := `queue_rules:
+  - name: sdr tv
+    path: TV Shows
+    codecs: [h264, mpeg2video]
+    hdr: false
+    preset_id: compress-hevc
+  - preset_id: 1080p
+    min_height: 2160
+queue_rules_interval_hours: -1
+`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.QueueRules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(cfg.QueueRules))
+	}
+	first := cfg.QueueRules[0]
+	if first.Name != "sdr tv" || len(first.Codecs) != 2 || first.HDR == nil || *first.HDR {
+		t.Errorf("unexpected first rule: %+v", first)
+	}
+	if cfg.QueueRules[1].HDR != nil {
+		t.Error("expected unset hdr condition to stay nil")
+	}
+	if cfg.QueueRulesIntervalHours != 0 {
+		t.Errorf("expected negative interval clamped to 0, got %d", cfg.QueueRulesIntervalHours)
+	}
+}
+
+func TestValidateTrashPath(t *testing.T) {
+	tests := []struct {
+		trashPath string
+		wantErr   bool
+	}{
+		{"/trash", false},
+		{"/media-trash", false}, // Sibling with a shared prefix is fine
+		{"", true},
+		{"/media", true},
+		{"/media/.trash", true},
+		{"/media/../media/trash", true},
+		{"/tv/.trash", true}, // Inside the second media path
+	}
+
+	for _, tt := range tests {
+		err := ValidateTrashPath(tt.trashPath, "/media", "/tv")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTrashPath(%q): got error %v, want error %v", tt.trashPath, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadMediaRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	data := `media_roots:
+  - name: Movies
+    path: /mnt/movies
+    preset_id: compress-hevc
+    original_handling: trash
+  - path: /mnt/tv/
+    temp_path: /fast/tmp
+    output_format: avi
+  - name: no path
+exclude:
+  - "**/Extras/**"
+  - "*-sample.mkv"
+`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	roots := cfg.Roots()
+	if len(roots) != 2 {
+		t.Fatalf("expected root without a path to be dropped, got %+v", roots)
+	}
+	if roots[1].Name != "tv" || roots[1].Path != "/mnt/tv" {
+		t.Errorf("expected unnamed root named after its directory, got %+v", roots[1])
+	}
+	if roots[1].OutputFormat != "" {
+		t.Errorf("expected invalid output_format to be cleared, got %q", roots[1].OutputFormat)
+	}
+	if len(cfg.Exclude) != 2 || cfg.Exclude[0] != "**/Extras/**" {
+		t.Errorf("unexpected exclude globs: %v", cfg.Exclude)
+	}
+}
+
+func TestRootSettings(t *testing.T) {
+	cfg := &Config{MediaPath: "/media", OriginalHandling: "replace", OutputFormat: "mkv"}
+	if roots := cfg.Roots(); len(roots) != 1 || roots[0].Path != "/media" || roots[0].Name != "media" {
+		t.Errorf("expected media_path as the only root, got %+v", roots)
+	}
+
+	cfg.MediaRoots = []MediaRoot{
+		{Name: "Movies", Path: "/mnt/movies", OriginalHandling: "keep", TempPath: "/fast/tmp"},
+		{Name: "4K", Path: "/mnt/movies/4k", OutputFormat: "mp4"},
+		{Name: "TV", Path: "/mnt/tv"},
+	}
+	tests := []struct {
+		path     string
+		root     string
+		handling string
+		format   string
+		tempDir  string
+	}{
+		{"/mnt/movies/Film.mkv", "Movies", "keep", "mkv", "/fast/tmp"},
+		{"/mnt/movies/4k/Film.mkv", "4K", "replace", "mp4", "/mnt/movies/4k"}, // Deepest root wins
+		{"/mnt/tv/Show/E01.mkv", "TV", "replace", "mkv", "/mnt/tv/Show"},
+		{"/mnt/tv2/Show/E01.mkv", "", "replace", "mkv", "/mnt/tv2/Show"},
+	}
+	for _, tt := range tests {
+		var name string
+		if root := cfg.RootFor(tt.path); root != nil {
+			name = root.Name
+		}
+		if name != tt.root {
+			t.Errorf("RootFor(%s) = %q, want %q", tt.path, name, tt.root)
+		}
+		if got := cfg.OriginalHandlingFor(tt.path); got != tt.handling {
+			t.Errorf("OriginalHandlingFor(%s) = %s, want %s", tt.path, got, tt.handling)
+		}
+		if got := cfg.OutputFormatFor(tt.path); got != tt.format {
+			t.Errorf("OutputFormatFor(%s) = %s, want %s", tt.path, got, tt.format)
+		}
+		if got := cfg.GetTempDir(tt.path); got != tt.tempDir {
+			t.Errorf("GetTempDir(%s) = %s, want %s", tt.path, got, tt.tempDir)
+		}
+	}
+
+	if err := ValidateWatchPath("/mnt/elsewhere", cfg.MediaPaths()...); err == nil {
+		t.Error("expected watch folder outside every root to be rejected")
+	}
+}
@@ -0,0 +1,407 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AudioStream contains metadata about an audio stream.
+// Index is the absolute stream index (used with -map 0:N), not audio-relative.
+type AudioStream struct {
+	Index         int    `json:"index"`                    // Absolute stream index in the file (for -map 0:N)
+	CodecName     string `json:"codec_name"`               // e.g., "truehd", "dts", "eac3", "aac"
+	Profile       string `json:"profile,omitempty"`        // e.g., "DTS-HD MA", "LC"
+	Channels      int    `json:"channels"`                 // 2, 6, 8
+	ChannelLayout string `json:"channel_layout,omitempty"` // e.g., "5.1(side)", "stereo"
+	Bitrate       int64  `json:"bitrate,omitempty"`        // bits per second (0 if unknown, common for lossless)
+	Language      string `json:"language,omitempty"`       // ISO 639-2 tag, e.g., "eng"
+	Title         string `json:"title,omitempty"`          // Track title from container tags
+	Default       bool   `json:"default"`                  // Default disposition
+	Commentary    bool   `json:"commentary"`               // Comment disposition or "commentary" in title
+}
+
+// AudioPolicy defines per-preset audio stream rules.
+// A nil policy keeps the legacy behavior (copy all for MKV, AAC stereo for MP4).
+type AudioPolicy struct {
+	// Languages to keep (ISO 639-2, e.g. "eng", "jpn"). Empty = keep all.
+	// Untagged streams ("und" or no language) are always kept.
+	Languages []string `yaml:"languages" json:"languages,omitempty"`
+
+	// DropCommentary removes commentary tracks.
+	DropCommentary bool `yaml:"drop_commentary" json:"drop_commentary,omitempty"`
+
+	// KeepBestLossless keeps only the best lossless track plus one lossy
+	// compatibility track per language. If a language has no lossy track,
+	// one is created from the lossless track using CompatCodec.
+	KeepBestLossless bool   `yaml:"keep_best_lossless" json:"keep_best_lossless,omitempty"`
+	CompatCodec      string `yaml:"compat_codec" json:"compat_codec,omitempty"`     // eac3 (default), ac3, aac, opus
+	CompatBitrate    string `yaml:"compat_bitrate" json:"compat_bitrate,omitempty"` // e.g. "640k" (empty = codec default)
+
+	// TranscodeCodecs lists source codecs (ffprobe names, e.g. "dts", "truehd")
+	// that are re-encoded to TranscodeTo instead of copied.
+	TranscodeCodecs  []string `yaml:"transcode_codecs" json:"transcode_codecs,omitempty"`
+	TranscodeTo      string   `yaml:"transcode_to" json:"transcode_to,omitempty"`           // opus, eac3, ac3, aac
+	TranscodeBitrate string   `yaml:"transcode_bitrate" json:"transcode_bitrate,omitempty"` // e.g. "384k" (empty = codec default)
+}
+
+// AudioTrack is a single output audio track.
+type AudioTrack struct {
+	SourceIndex int    // Absolute source stream index (for -map 0:N)
+	Codec       string // FFmpeg encoder name, or "copy"
+	Bitrate     string // e.g. "640k" (empty for copy)
+	Channels    int    // Output channel count (0 = keep source layout)
+}
+
+// AudioPlan is the result of applying an AudioPolicy to a file's audio streams.
+type AudioPlan struct {
+	Tracks  []AudioTrack
+	Dropped []string // Human-readable descriptions of dropped streams
+}
+
+// audioEncoders maps policy codec names to FFmpeg encoders
+var audioEncoders = map[string]string{
+	"opus": "libopus",
+	"eac3": "eac3",
+	"ac3":  "ac3",
+	"aac":  "aac",
+}
+
+// defaultAudioBitrates are used when a policy doesn't specify a bitrate
+var defaultAudioBitrates = map[string]string{
+	"opus": "384k",
+	"eac3": "640k",
+	"ac3":  "640k",
+	"aac":  "256k",
+}
+
+// maxAudioChannels caps channel count for encoders that can't go beyond 5.1
+var maxAudioChannels = map[string]int{
+	"eac3": 6,
+	"ac3":  6,
+}
+
+// mp4AudioCodecs are source codecs that can be stream-copied into MP4
+var mp4AudioCodecs = map[string]bool{
+	"aac":  true,
+	"ac3":  true,
+	"eac3": true,
+	"mp3":  true,
+	"alac": true,
+	"flac": true,
+	"opus": true,
+}
+
+// webmAudioCodecs are source codecs that can be stream-copied into WebM.
+// Everything else is encoded to Opus.
+var webmAudioCodecs = map[string]bool{
+	"opus":   true,
+	"vorbis": true,
+}
+
+// DefaultCompatCodec is used for synthesized compatibility tracks
+const DefaultCompatCodec = "eac3"
+
+// IsValidAudioCodec returns true if codec can be used as a policy target.
+func IsValidAudioCodec(codec string) bool {
+	_, ok := audioEncoders[codec]
+	return ok
+}
+
+// isLosslessAudio returns true for lossless audio formats
+func isLosslessAudio(s AudioStream) bool {
+	codec := strings.ToLower(s.CodecName)
+	switch {
+	case codec == "truehd", codec == "mlp", codec == "flac", codec == "alac":
+		return true
+	case strings.HasPrefix(codec, "pcm_"):
+		return true
+	case codec == "dts" && s.Profile == "DTS-HD MA":
+		return true
+	}
+	return false
+}
+
+// isCommentaryTitle returns true if a track title suggests commentary
+func isCommentaryTitle(title string) bool {
+	return strings.Contains(strings.ToLower(title), "commentary")
+}
+
+// normalizeLanguage lowercases a language tag, treating empty as "und"
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		return "und"
+	}
+	return lang
+}
+
+// matchesLanguage returns true if the stream language passes the policy filter
+func (p *AudioPolicy) matchesLanguage(lang string) bool {
+	return matchesLanguages(p.Languages, lang)
+}
+
+// matchesLanguages returns true if lang is in the list, the list is empty,
+// or the stream is untagged (we can't tell what it is, so keep it)
+func matchesLanguages(languages []string, lang string) bool {
+	if len(languages) == 0 {
+		return true
+	}
+	lang = normalizeLanguage(lang)
+	if lang == "und" {
+		return true
+	}
+	for _, l := range languages {
+		if normalizeLanguage(l) == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// transcodes returns true if the policy re-encodes this source codec
+func (p *AudioPolicy) transcodes(codec string) bool {
+	if p.TranscodeTo == "" {
+		return false
+	}
+	for _, c := range p.TranscodeCodecs {
+		if strings.EqualFold(c, codec) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEncodedTrack builds a re-encoded output track for the given policy codec
+func newEncodedTrack(s AudioStream, codec, bitrate string) AudioTrack {
+	if bitrate == "" {
+		bitrate = defaultAudioBitrates[codec]
+	}
+	track := AudioTrack{
+		SourceIndex: s.Index,
+		Codec:       audioEncoders[codec],
+		Bitrate:     bitrate,
+	}
+	if limit, ok := maxAudioChannels[codec]; ok && s.Channels > limit {
+		track.Channels = limit
+	}
+	return track
+}
+
+// describeAudioStream returns a short description for logs and skip reasons
+func describeAudioStream(s AudioStream) string {
+	desc := fmt.Sprintf("#%d %s", s.Index, s.CodecName)
+	if s.Language != "" {
+		desc += " (" + s.Language + ")"
+	}
+	return desc
+}
+
+// PlanAudio applies an audio policy to the source streams and returns the
+// output tracks. Returns nil when policy is nil or there are no streams,
+// which keeps the legacy audio handling in BuildPresetArgs.
+// The plan never drops every stream: if the rules would leave the output
+// silent, the default (or first) stream is kept.
+func PlanAudio(streams []AudioStream, policy *AudioPolicy, outputFormat string) *AudioPlan {
+	if policy == nil || len(streams) == 0 {
+		return nil
+	}
+
+	reasons := make(map[int]string)
+
+	// Step 1: language and commentary filters
+	var candidates []AudioStream
+	for _, s := range streams {
+		switch {
+		case policy.DropCommentary && s.Commentary:
+			reasons[s.Index] = "commentary"
+		case !policy.matchesLanguage(s.Language):
+			reasons[s.Index] = "language not kept"
+		default:
+			candidates = append(candidates, s)
+		}
+	}
+
+	// Never produce silent output
+	if len(candidates) == 0 {
+		fallback := streams[0]
+		for _, s := range streams {
+			if s.Default {
+				fallback = s
+				break
+			}
+		}
+		delete(reasons, fallback.Index)
+		candidates = []AudioStream{fallback}
+	}
+
+	// Step 2: best lossless + one compatibility track per language
+	selected := candidates
+	compatFor := make(map[int]bool) // lossless stream index -> synthesize compat track
+	if policy.KeepBestLossless {
+		selected = nil
+		keep := make(map[int]bool)
+
+		var langs []string
+		groups := make(map[string][]AudioStream)
+		for _, s := range candidates {
+			lang := normalizeLanguage(s.Language)
+			if _, ok := groups[lang]; !ok {
+				langs = append(langs, lang)
+			}
+			groups[lang] = append(groups[lang], s)
+		}
+
+		for _, lang := range langs {
+			var best, compat *AudioStream
+			for i := range groups[lang] {
+				s := &groups[lang][i]
+				if isLosslessAudio(*s) {
+					if best == nil || s.Channels > best.Channels {
+						best = s
+					}
+				} else if compat == nil {
+					compat = s
+				}
+			}
+			if best != nil {
+				keep[best.Index] = true
+			}
+			if compat != nil {
+				keep[compat.Index] = true
+			} else if best != nil {
+				compatFor[best.Index] = true
+			}
+		}
+
+		for _, s := range candidates {
+			if keep[s.Index] {
+				selected = append(selected, s)
+			} else {
+				reasons[s.Index] = "redundant track"
+			}
+		}
+	}
+
+	compatCodec := policy.CompatCodec
+	if compatCodec == "" {
+		compatCodec = DefaultCompatCodec
+	}
+	transcodeTo := policy.TranscodeTo
+	if outputFormat == "webm" {
+		// WebM only holds Opus and Vorbis
+		compatCodec = "opus"
+		transcodeTo = "opus"
+	}
+
+	// Step 3: build output tracks in source order
+	plan := &AudioPlan{}
+	for _, s := range selected {
+		switch {
+		case policy.transcodes(s.CodecName):
+			plan.Tracks = append(plan.Tracks, newEncodedTrack(s, transcodeTo, policy.TranscodeBitrate))
+		case outputFormat == "mp4" && !mp4AudioCodecs[strings.ToLower(s.CodecName)]:
+			// Codec can't be copied into MP4 - use the legacy AAC stereo fallback
+			plan.Tracks = append(plan.Tracks, AudioTrack{SourceIndex: s.Index, Codec: "aac", Bitrate: "192k", Channels: 2})
+		case outputFormat == "webm" && !webmAudioCodecs[strings.ToLower(s.CodecName)]:
+			// Codec can't be copied into WebM - encode to Opus, keeping the channel layout
+			plan.Tracks = append(plan.Tracks, newEncodedTrack(s, "opus", ""))
+		default:
+			plan.Tracks = append(plan.Tracks, AudioTrack{SourceIndex: s.Index, Codec: "copy"})
+		}
+
+		if compatFor[s.Index] {
+			plan.Tracks = append(plan.Tracks, newEncodedTrack(s, compatCodec, policy.CompatBitrate))
+		}
+	}
+
+	for _, s := range streams {
+		if reason, ok := reasons[s.Index]; ok {
+			plan.Dropped = append(plan.Dropped, describeAudioStream(s)+": "+reason)
+		}
+	}
+
+	return plan
+}
+
+// Estimated bitrates for streams whose container doesn't report one
+// (common in MKV). Erring high keeps target-size encodes under the target.
+const (
+	unknownLosslessBitrate = 4_000_000 // TrueHD/DTS-HD MA, including Atmos/DTS:X
+	unknownLossyBitrate    = 640_000   // AC3/E-AC3/DTS core maximum
+)
+
+// Bitrates of the stereo fallback used without an audio plan
+const (
+	mp4AudioBitrate  = 192_000 // AAC for MP4
+	webmAudioBitrate = 128_000 // Opus for WebM
+)
+
+// AudioBitrate estimates the total audio bitrate (bits/s) of the output, so
+// target size/bitrate encodes can leave room for it. Copied streams count at
+// their source bitrate; converted streams at their target bitrate.
+func AudioBitrate(streams []AudioStream, plan *AudioPlan, outputFormat string) int64 {
+	var total int64
+	if plan == nil {
+		for _, s := range streams {
+			switch outputFormat {
+			case "mp4":
+				total += mp4AudioBitrate
+			case "webm":
+				total += webmAudioBitrate
+			default:
+				total += streamBitrate(s)
+			}
+		}
+		return total
+	}
+
+	byIndex := make(map[int]AudioStream, len(streams))
+	for _, s := range streams {
+		byIndex[s.Index] = s
+	}
+	for _, t := range plan.Tracks {
+		if t.Codec == "copy" {
+			total += streamBitrate(byIndex[t.SourceIndex])
+		} else if bitrate, err := ParseBitrate(t.Bitrate); err == nil {
+			total += bitrate
+		} else {
+			total += unknownLossyBitrate
+		}
+	}
+	return total
+}
+
+// streamBitrate returns the stream's bitrate, or an estimate if it's unknown
+func streamBitrate(s AudioStream) int64 {
+	switch {
+	case s.Bitrate > 0:
+		return s.Bitrate
+	case isLosslessAudio(s):
+		return unknownLosslessBitrate
+	default:
+		return unknownLossyBitrate
+	}
+}
+
+// args returns the FFmpeg mapping and codec args for the plan.
+// Output track N gets per-stream options (-c:a:N, -b:a:N, -ac:a:N).
+func (p *AudioPlan) args() []string {
+	var args []string
+	for _, t := range p.Tracks {
+		args = append(args, "-map", fmt.Sprintf("0:%d", t.SourceIndex))
+	}
+	for i, t := range p.Tracks {
+		args = append(args, fmt.Sprintf("-c:a:%d", i), t.Codec)
+		if t.Bitrate != "" {
+			args = append(args, fmt.Sprintf("-b:a:%d", i), t.Bitrate)
+		}
+		if t.Channels > 0 {
+			args = append(args, fmt.Sprintf("-ac:a:%d", i), fmt.Sprintf("%d", t.Channels))
+		}
+		if t.Codec == "libopus" {
+			// Mapping family 1 allows surround layouts (default family 0 is mono/stereo only)
+			args = append(args, fmt.Sprintf("-mapping_family:a:%d", i), "1")
+		}
+	}
+	return args
+}
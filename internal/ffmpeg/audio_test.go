@@ -0,0 +1,251 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// remuxAudio is a typical Blu-ray remux audio layout
+var remuxAudio = []AudioStream{
+	{Index: 1, CodecName: "truehd", Channels: 8, Language: "eng", Default: true},
+	{Index: 2, CodecName: "ac3", Channels: 6, Language: "eng"},
+	{Index: 3, CodecName: "dts", Profile: "DTS-HD MA", Channels: 6, Language: "eng"},
+	{Index: 4, CodecName: "dts", Profile: "DTS", Channels: 6, Language: "fre"},
+	{Index: 5, CodecName: "ac3", Channels: 2, Language: "eng", Title: "Director's Commentary", Commentary: true},
+}
+
+func trackIndices(plan *AudioPlan) []int {
+	var indices []int
+	for _, t := range plan.Tracks {
+		indices = append(indices, t.SourceIndex)
+	}
+	return indices
+}
+
+func TestPlanAudioNilPolicy(t *testing.T) {
+	if plan := PlanAudio(remuxAudio, nil, "mkv"); plan != nil {
+		t.Errorf("expected nil plan for nil policy, got %+v", plan)
+	}
+	if plan := PlanAudio(nil, &AudioPolicy{}, "mkv"); plan != nil {
+		t.Errorf("expected nil plan for no streams, got %+v", plan)
+	}
+}
+
+func TestPlanAudio(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *AudioPolicy
+		wantIndices []int
+		wantCodecs  []string
+	}{
+		{
+			name:        "empty policy copies all",
+			policy:      &AudioPolicy{},
+			wantIndices: []int{1, 2, 3, 4, 5},
+			wantCodecs:  []string{"copy", "copy", "copy", "copy", "copy"},
+		},
+		{
+			name:        "language filter",
+			policy:      &AudioPolicy{Languages: []string{"eng"}},
+			wantIndices: []int{1, 2, 3, 5},
+			wantCodecs:  []string{"copy", "copy", "copy", "copy"},
+		},
+		{
+			name:        "drop commentary",
+			policy:      &AudioPolicy{DropCommentary: true},
+			wantIndices: []int{1, 2, 3, 4},
+			wantCodecs:  []string{"copy", "copy", "copy", "copy"},
+		},
+		{
+			name:        "best lossless plus compatibility track",
+			policy:      &AudioPolicy{Languages: []string{"eng"}, DropCommentary: true, KeepBestLossless: true},
+			wantIndices: []int{1, 2},
+			wantCodecs:  []string{"copy", "copy"},
+		},
+		{
+			name:        "transcode dts to opus",
+			policy:      &AudioPolicy{TranscodeCodecs: []string{"dts", "truehd"}, TranscodeTo: "opus", TranscodeBitrate: "448k"},
+			wantIndices: []int{1, 2, 3, 4, 5},
+			wantCodecs:  []string{"libopus", "copy", "libopus", "libopus", "copy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := PlanAudio(remuxAudio, tt.policy, "mkv")
+			if got := trackIndices(plan); !reflect.DeepEqual(got, tt.wantIndices) {
+				t.Errorf("indices: got %v, want %v", got, tt.wantIndices)
+			}
+			var codecs []string
+			for _, track := range plan.Tracks {
+				codecs = append(codecs, track.Codec)
+			}
+			if !reflect.DeepEqual(codecs, tt.wantCodecs) {
+				t.Errorf("codecs: got %v, want %v", codecs, tt.wantCodecs)
+			}
+		})
+	}
+}
+
+func TestPlanAudioSynthesizesCompatTrack(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 1, CodecName: "truehd", Channels: 8, Language: "eng"},
+	}
+	plan := PlanAudio(streams, &AudioPolicy{KeepBestLossless: true}, "mkv")
+
+	if len(plan.Tracks) != 2 {
+		t.Fatalf("expected lossless + compat track, got %+v", plan.Tracks)
+	}
+	compat := plan.Tracks[1]
+	if compat.SourceIndex != 1 || compat.Codec != "eac3" || compat.Bitrate != "640k" || compat.Channels != 6 {
+		t.Errorf("unexpected compat track: %+v", compat)
+	}
+}
+
+func TestPlanAudioNeverSilent(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 1, CodecName: "aac", Channels: 2, Language: "jpn"},
+		{Index: 2, CodecName: "aac", Channels: 2, Language: "ger", Default: true},
+	}
+	plan := PlanAudio(streams, &AudioPolicy{Languages: []string{"eng"}}, "mkv")
+
+	if got := trackIndices(plan); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("expected fallback to default track, got %v", got)
+	}
+	if len(plan.Dropped) != 1 {
+		t.Errorf("expected 1 dropped stream, got %v", plan.Dropped)
+	}
+}
+
+func TestPlanAudioUntaggedKept(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 1, CodecName: "aac", Channels: 2},
+		{Index: 2, CodecName: "aac", Channels: 2, Language: "und"},
+		{Index: 3, CodecName: "aac", Channels: 2, Language: "spa"},
+	}
+	plan := PlanAudio(streams, &AudioPolicy{Languages: []string{"eng"}}, "mkv")
+
+	if got := trackIndices(plan); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("indices: got %v, want [1 2]", got)
+	}
+}
+
+func TestPlanAudioMP4(t *testing.T) {
+	plan := PlanAudio(remuxAudio[:2], &AudioPolicy{}, "mp4")
+
+	// TrueHD can't be copied into MP4 - falls back to AAC stereo; AC3 is copied
+	if plan.Tracks[0].Codec != "aac" || plan.Tracks[0].Channels != 2 {
+		t.Errorf("expected AAC stereo for truehd in mp4, got %+v", plan.Tracks[0])
+	}
+	if plan.Tracks[1].Codec != "copy" {
+		t.Errorf("expected ac3 copy in mp4, got %+v", plan.Tracks[1])
+	}
+}
+
+func TestPlanAudioWebM(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 1, CodecName: "truehd", Channels: 8, Language: "eng"},
+		{Index: 2, CodecName: "opus", Channels: 2, Language: "eng"},
+	}
+	plan := PlanAudio(streams, &AudioPolicy{TranscodeCodecs: []string{"opus"}, TranscodeTo: "eac3"}, "webm")
+
+	// Only Opus/Vorbis fit in WebM: truehd is encoded, transcode_to is forced to opus
+	for _, track := range plan.Tracks {
+		if track.Codec != "libopus" {
+			t.Errorf("expected libopus in webm, got %+v", track)
+		}
+	}
+	if plan.Tracks[0].Channels != 0 {
+		t.Errorf("expected source channel layout kept, got %+v", plan.Tracks[0])
+	}
+}
+
+func TestBuildPresetArgsAudioPlan(t *testing.T) {
+	preset := &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecHEVC}
+	plan := &AudioPlan{Tracks: []AudioTrack{
+		{SourceIndex: 1, Codec: "copy"},
+		{SourceIndex: 3, Codec: "libopus", Bitrate: "384k"},
+	}}
+
+	_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mkv", nil, nil, plan, nil, "")
+	args := strings.Join(outputArgs, " ")
+
+	for _, want := range []string{"-map 0:1", "-map 0:3", "-c:a:0 copy", "-c:a:1 libopus", "-b:a:1 384k", "-mapping_family:a:1 1"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in args: %s", want, args)
+		}
+	}
+	for _, unwanted := range []string{"0:a?", "-c:a copy"} {
+		if strings.Contains(args, unwanted) {
+			t.Errorf("unexpected %q in args: %s", unwanted, args)
+		}
+	}
+
+	// MP4 with a plan: no legacy AAC stereo args, subtitles still stripped
+	_, outputArgs = BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mp4", nil, nil, plan, nil, "")
+	args = strings.Join(outputArgs, " ")
+	if strings.Contains(args, "-ac 2") || !strings.Contains(args, "-sn") {
+		t.Errorf("unexpected mp4 args: %s", args)
+	}
+}
+
+func TestNewAudioStream(t *testing.T) {
+	data := `{"streams": [{
+		"index": 2, "codec_type": "audio", "codec_name": "dts", "profile": "DTS-HD MA",
+		"channels": 6, "channel_layout": "5.1(side)", "bit_rate": "1509000",
+		"tags": {"language": "eng", "title": "Commentary with cast"},
+		"disposition": {"default": 0, "comment": 0}
+	}]}`
+
+	var out ffprobeOutput
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	got := newAudioStream(&out.Streams[0])
+	want := AudioStream{
+		Index:         2,
+		CodecName:     "dts",
+		Profile:       "DTS-HD MA",
+		Channels:      6,
+		ChannelLayout: "5.1(side)",
+		Bitrate:       1509000,
+		Language:      "eng",
+		Title:         "Commentary with cast",
+		Commentary:    true,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !isLosslessAudio(got) {
+		t.Error("expected DTS-HD MA to be lossless")
+	}
+}
+
+func TestAudioBitrate(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 1, CodecName: "truehd", Channels: 8},                  // No bitrate reported: lossless estimate
+		{Index: 2, CodecName: "ac3", Channels: 6, Bitrate: 448_000},   // Copied at source bitrate
+		{Index: 3, CodecName: "dts", Channels: 6, Bitrate: 1_509_000}, // Transcoded to opus below
+	}
+
+	if got := AudioBitrate(streams, nil, "mkv"); got != 4_000_000+448_000+1_509_000 {
+		t.Errorf("mkv copy: got %d", got)
+	}
+	if got := AudioBitrate(streams, nil, "mp4"); got != 3*192_000 {
+		t.Errorf("mp4 aac: got %d", got)
+	}
+	if got := AudioBitrate(streams, nil, "webm"); got != 3*128_000 {
+		t.Errorf("webm opus: got %d", got)
+	}
+
+	plan := &AudioPlan{Tracks: []AudioTrack{
+		{SourceIndex: 2, Codec: "copy"},
+		{SourceIndex: 3, Codec: "libopus", Bitrate: "384k"},
+	}}
+	if got := AudioBitrate(streams, plan, "mkv"); got != 448_000+384_000 {
+		t.Errorf("plan: got %d", got)
+	}
+}
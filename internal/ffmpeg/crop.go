@@ -0,0 +1,128 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
+)
+
+// CropRect is a crop area in source pixels (FFmpeg crop=w:h:x:y)
+type CropRect struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+// String returns the crop in FFmpeg's w:h:x:y notation
+func (c CropRect) String() string {
+	return fmt.Sprintf("%d:%d:%d:%d", c.Width, c.Height, c.X, c.Y)
+}
+
+// filter returns the crop filter for the FFmpeg filter chain
+func (c CropRect) filter() string {
+	return "crop=" + c.String()
+}
+
+// cropDetectFrames is the number of frames analyzed at each sample position.
+// ~5 seconds at 24fps is enough to see past fades without a slow decode.
+const cropDetectFrames = 120
+
+// cropDetectLimit is the black threshold as a fraction of the pixel range,
+// so 8-bit and 10-bit sources use the same setting (24/255 ≈ 0.094)
+const cropDetectLimit = "0.094"
+
+// minCropPixels ignores crops that remove less than this from each dimension.
+// Avoids re-encoding over a few rows of encoder padding or noisy edges.
+const minCropPixels = 8
+
+// cropDetectPattern matches the crop suggestion in cropdetect log lines.
+// Negative values (entirely black frames) don't match and are ignored.
+var cropDetectPattern = regexp.MustCompile(`crop=(\d+):(\d+):(\d+):(\d+)`)
+
+// DetectCrop runs cropdetect at the VMAF sample positions and returns a crop
+// that is safe for every position, or nil if there are no black bars to remove.
+// Positions that are entirely black (fades, credits) are ignored.
+func (t *Transcoder) DetectCrop(ctx context.Context, inputPath string, duration time.Duration, width, height int) (*CropRect, error) {
+	var crops []CropRect
+	for _, pos := range vmaf.SamplePositions(duration) {
+		start := duration.Seconds() * pos
+
+		cmd := exec.CommandContext(ctx, t.ffmpegPath,
+			"-hide_banner",
+			"-nostats",
+			"-ss", fmt.Sprintf("%.3f", start),
+			"-i", inputPath,
+			"-map", "0:v:0",
+			"-frames:v", strconv.Itoa(cropDetectFrames),
+			"-vf", "cropdetect=limit="+cropDetectLimit+":round=2:reset=0",
+			"-an", "-sn",
+			"-f", "null", "-",
+		)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("cropdetect failed at %.0f%%: %w", pos*100, err)
+		}
+
+		if crop, ok := parseCropDetect(string(output)); ok {
+			crops = append(crops, crop)
+		}
+	}
+
+	return chooseCrop(crops, width, height), nil
+}
+
+// parseCropDetect returns the last crop suggestion in cropdetect output.
+// With reset=0 the last line covers every analyzed frame.
+func parseCropDetect(output string) (CropRect, bool) {
+	matches := cropDetectPattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return CropRect{}, false
+	}
+
+	last := matches[len(matches)-1]
+	var values [4]int
+	for i := range values {
+		values[i], _ = strconv.Atoi(last[i+1])
+	}
+	crop := CropRect{Width: values[0], Height: values[1], X: values[2], Y: values[3]}
+	if crop.Width <= 0 || crop.Height <= 0 {
+		return CropRect{}, false
+	}
+	return crop, true
+}
+
+// chooseCrop merges per-position crops into one stable crop: the smallest
+// area containing every detected picture, so no position loses image content.
+// Returns nil when nothing was detected or the crop is too small to matter.
+func chooseCrop(crops []CropRect, width, height int) *CropRect {
+	if len(crops) == 0 || width <= 0 || height <= 0 {
+		return nil
+	}
+
+	left, top := crops[0].X, crops[0].Y
+	right, bottom := crops[0].X+crops[0].Width, crops[0].Y+crops[0].Height
+	for _, c := range crops[1:] {
+		left = min(left, c.X)
+		top = min(top, c.Y)
+		right = max(right, c.X+c.Width)
+		bottom = max(bottom, c.Y+c.Height)
+	}
+	right = min(right, width)
+	bottom = min(bottom, height)
+
+	crop := CropRect{X: left, Y: top, Width: right - left, Height: bottom - top}
+	// Keep dimensions even for 4:2:0 chroma subsampling
+	crop.Width -= crop.Width % 2
+	crop.Height -= crop.Height % 2
+
+	if width-crop.Width < minCropPixels && height-crop.Height < minCropPixels {
+		return nil
+	}
+	return &crop
+}
@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCropDetect(t *testing.T) {
+	output := `[Parsed_cropdetect_0 @ 0x5581] x1:0 x2:1919 y1:138 y2:941 w:1920 h:800 x:0 y:140 pts:1001 t:0.041708 limit:0.094000 crop=1920:800:0:140
+[Parsed_cropdetect_0 @ 0x5581] x1:0 x2:1919 y1:136 y2:943 w:1920 h:804 x:0 y:138 pts:2002 t:0.083417 limit:0.094000 crop=1920:804:0:138
+`
+	crop, ok := parseCropDetect(output)
+	if !ok {
+		t.Fatal("expected crop to be parsed")
+	}
+	if crop.String() != "1920:804:0:138" {
+		t.Errorf("got %s, want last suggestion 1920:804:0:138", crop)
+	}
+
+	// Entirely black frames produce negative sizes, which are ignored
+	if _, ok := parseCropDetect("crop=-1920:-1072:1926:1080"); ok {
+		t.Error("expected black-frame output to be ignored")
+	}
+	if _, ok := parseCropDetect("no cropdetect output"); ok {
+		t.Error("expected no crop for empty output")
+	}
+}
+
+func TestChooseCrop(t *testing.T) {
+	tests := []struct {
+		name  string
+		crops []CropRect
+		want  string // "" = no crop
+	}{
+		{"no detections", nil, ""},
+		{"letterboxed 2.39:1", []CropRect{{1920, 800, 0, 140}, {1920, 800, 0, 140}}, "1920:800:0:140"},
+		{"positions disagree uses union", []CropRect{{1920, 800, 0, 140}, {1920, 1080, 0, 0}}, ""},
+		{"partial disagreement", []CropRect{{1920, 800, 0, 140}, {1920, 816, 0, 132}}, "1920:816:0:132"},
+		{"pillarboxed 4:3", []CropRect{{1440, 1080, 240, 0}}, "1440:1080:240:0"},
+		{"negligible crop ignored", []CropRect{{1920, 1076, 0, 2}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chooseCrop(tt.crops, 1920, 1080)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no crop, got %s", got)
+				}
+				return
+			}
+			if got == nil || got.String() != tt.want {
+				t.Errorf("got %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPresetArgsCrop(t *testing.T) {
+	crop := &CropRect{Width: 1920, Height: 800, X: 0, Y: 140}
+
+	// VAAPI: crop runs on CPU frames before hwupload, decode falls back to software
+	preset := &Preset{ID: "test", Encoder: HWAccelVAAPI, Codec: CodecHEVC, MaxHeight: 720}
+	inputArgs, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, crop, "")
+	args := strings.Join(outputArgs, " ")
+
+	if !strings.Contains(args, "-vf crop=1920:800:0:140,format=nv12,hwupload,scale_vaapi") {
+		t.Errorf("expected crop before hwupload and scale, got: %s", args)
+	}
+	if strings.Contains(strings.Join(inputArgs, " "), "-hwaccel_output_format") {
+		t.Errorf("expected software decode with crop, got input args: %v", inputArgs)
+	}
+
+	// Cropped height is used for the scaling decision: 800 <= 1080 means no scale
+	preset = &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecHEVC, MaxHeight: 1080}
+	_, outputArgs = BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, crop, "")
+	args = strings.Join(outputArgs, " ")
+	if !strings.Contains(args, "-vf crop=1920:800:0:140 ") || strings.Contains(args, "scale") {
+		t.Errorf("expected crop without scaling, got: %s", args)
+	}
+}
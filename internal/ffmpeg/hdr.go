@@ -0,0 +1,308 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Transfer functions for HDR content (ffprobe color_transfer values)
+const (
+	TransferPQ  = "smpte2084"    // HDR10, HDR10+, most Dolby Vision
+	TransferHLG = "arib-std-b67" // Hybrid Log-Gamma (broadcast HDR)
+)
+
+// MasteringDisplay is the SMPTE ST 2086 mastering display color volume.
+// Chromaticities are CIE 1931 xy coordinates, luminance is in cd/m².
+type MasteringDisplay struct {
+	RedX         float64 `json:"red_x"`
+	RedY         float64 `json:"red_y"`
+	GreenX       float64 `json:"green_x"`
+	GreenY       float64 `json:"green_y"`
+	BlueX        float64 `json:"blue_x"`
+	BlueY        float64 `json:"blue_y"`
+	WhiteX       float64 `json:"white_x"`
+	WhiteY       float64 `json:"white_y"`
+	MinLuminance float64 `json:"min_luminance"`
+	MaxLuminance float64 `json:"max_luminance"`
+}
+
+// ContentLightLevel is the CTA-861.3 content light level (cd/m²)
+type ContentLightLevel struct {
+	MaxCLL  int `json:"max_cll"`  // Maximum content light level
+	MaxFALL int `json:"max_fall"` // Maximum frame-average light level
+}
+
+// HDRMetadata contains HDR signaling read from stream and frame side data.
+type HDRMetadata struct {
+	DolbyVisionProfile  int                `json:"dv_profile,omitempty"`          // 0 = not Dolby Vision, otherwise 5, 7, 8...
+	DolbyVisionCompatID int                `json:"dv_bl_compat_id,omitempty"`     // Base layer compatibility: 1 = HDR10, 2 = SDR, 4 = HLG, 0 = none
+	HDR10Plus           bool               `json:"hdr10_plus,omitempty"`          // SMPTE 2094-40 dynamic metadata present
+	MasteringDisplay    *MasteringDisplay  `json:"mastering_display,omitempty"`   // Static mastering display metadata
+	ContentLightLevel   *ContentLightLevel `json:"content_light_level,omitempty"` // Static content light level
+}
+
+// ffprobeSideData is an entry of a stream or frame side_data_list.
+// Fields are populated depending on side_data_type.
+type ffprobeSideData struct {
+	SideDataType string `json:"side_data_type"`
+	// DOVI configuration record
+	DVProfile    int `json:"dv_profile"`
+	DVBLCompatID int `json:"dv_bl_signal_compatibility_id"`
+	// Mastering display metadata (rationals, e.g. "34000/50000")
+	RedX         string `json:"red_x"`
+	RedY         string `json:"red_y"`
+	GreenX       string `json:"green_x"`
+	GreenY       string `json:"green_y"`
+	BlueX        string `json:"blue_x"`
+	BlueY        string `json:"blue_y"`
+	WhitePointX  string `json:"white_point_x"`
+	WhitePointY  string `json:"white_point_y"`
+	MinLuminance string `json:"min_luminance"`
+	MaxLuminance string `json:"max_luminance"`
+	// Content light level metadata
+	MaxContent int `json:"max_content"`
+	MaxAverage int `json:"max_average"`
+}
+
+type ffprobeFrame struct {
+	SideDataList []ffprobeSideData `json:"side_data_list"`
+}
+
+// HasDynamicMetadata returns true if the source carries per-scene HDR metadata
+// (Dolby Vision RPUs or HDR10+) that a plain HDR10 encode would drop.
+func (m *HDRMetadata) HasDynamicMetadata() bool {
+	return m != nil && (m.DolbyVisionProfile > 0 || m.HDR10Plus)
+}
+
+// applySideData merges HDR-related side data entries into the metadata.
+func (m *HDRMetadata) applySideData(list []ffprobeSideData) {
+	for _, sd := range list {
+		switch strings.ToLower(sd.SideDataType) {
+		case "dovi configuration record":
+			m.DolbyVisionProfile = sd.DVProfile
+			m.DolbyVisionCompatID = sd.DVBLCompatID
+		case "hdr dynamic metadata smpte2094-40 (hdr10+)":
+			m.HDR10Plus = true
+		case "mastering display metadata":
+			if sd.RedX == "" || sd.MaxLuminance == "" {
+				continue
+			}
+			m.MasteringDisplay = &MasteringDisplay{
+				RedX:         parseRational(sd.RedX),
+				RedY:         parseRational(sd.RedY),
+				GreenX:       parseRational(sd.GreenX),
+				GreenY:       parseRational(sd.GreenY),
+				BlueX:        parseRational(sd.BlueX),
+				BlueY:        parseRational(sd.BlueY),
+				WhiteX:       parseRational(sd.WhitePointX),
+				WhiteY:       parseRational(sd.WhitePointY),
+				MinLuminance: parseRational(sd.MinLuminance),
+				MaxLuminance: parseRational(sd.MaxLuminance),
+			}
+		case "content light level metadata":
+			m.ContentLightLevel = &ContentLightLevel{
+				MaxCLL:  sd.MaxContent,
+				MaxFALL: sd.MaxAverage,
+			}
+		}
+	}
+}
+
+// parseRational parses an ffprobe rational ("34000/50000") or decimal value
+func parseRational(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil {
+		return 0
+	}
+	if !ok {
+		return n
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// HDRFormat returns a short display name for the source's HDR format:
+// "Dolby Vision", "HDR10+", "HLG" or "HDR10". Returns "" for SDR.
+func HDRFormat(isHDR bool, colorTransfer string, meta *HDRMetadata) string {
+	switch {
+	case meta != nil && meta.DolbyVisionProfile > 0:
+		return "Dolby Vision"
+	case meta != nil && meta.HDR10Plus:
+		return "HDR10+"
+	case !isHDR:
+		return ""
+	case strings.EqualFold(colorTransfer, TransferHLG):
+		return "HLG"
+	default:
+		return "HDR10"
+	}
+}
+
+// HDRTransfer returns the transfer function to signal on HDR output.
+// HLG sources keep HLG; everything else (including untagged HDR) is PQ.
+func HDRTransfer(colorTransfer string) string {
+	if strings.EqualFold(colorTransfer, TransferHLG) {
+		return TransferHLG
+	}
+	return TransferPQ
+}
+
+// x265 returns the mastering display in x265 master-display syntax
+// (chromaticity in 0.00002 units, luminance in 0.0001 cd/m² units).
+func (md *MasteringDisplay) x265() string {
+	c := func(v float64) int64 { return int64(math.Round(v * 50000)) }
+	l := func(v float64) int64 { return int64(math.Round(v * 10000)) }
+	return fmt.Sprintf("G(%d,%d)B(%d,%d)R(%d,%d)WP(%d,%d)L(%d,%d)",
+		c(md.GreenX), c(md.GreenY), c(md.BlueX), c(md.BlueY), c(md.RedX), c(md.RedY),
+		c(md.WhiteX), c(md.WhiteY), l(md.MaxLuminance), l(md.MinLuminance))
+}
+
+// svtav1 returns the mastering display in SVT-AV1 mastering-display syntax
+func (md *MasteringDisplay) svtav1() string {
+	return fmt.Sprintf("G(%.4f,%.4f)B(%.4f,%.4f)R(%.4f,%.4f)WP(%.4f,%.4f)L(%.4f,%.4f)",
+		md.GreenX, md.GreenY, md.BlueX, md.BlueY, md.RedX, md.RedY,
+		md.WhiteX, md.WhiteY, md.MaxLuminance, md.MinLuminance)
+}
+
+// dolbyVisionEncoders can carry Dolby Vision RPUs through a re-encode (-dolbyvision)
+var dolbyVisionEncoders = map[string]bool{
+	"libx265":   true,
+	"libsvtav1": true,
+}
+
+// hdr10PlusEncoders can carry HDR10+ dynamic metadata through a re-encode
+var hdr10PlusEncoders = map[string]bool{
+	"libx265": true,
+}
+
+// hdrEncoderArgs returns encoder args that carry static and dynamic HDR
+// metadata through the encode. Hardware encoders pick up static metadata
+// from frame side data, so only software encoders need explicit params.
+func hdrEncoderArgs(encoder, transfer string, meta *HDRMetadata, args []string) []string {
+	if meta == nil {
+		meta = &HDRMetadata{}
+	}
+
+	switch encoder {
+	case "libx265":
+		params := []string{"repeat-headers=1"}
+		if transfer == TransferPQ {
+			params = append(params, "hdr-opt=1")
+		}
+		if meta.MasteringDisplay != nil {
+			params = append(params, "master-display="+meta.MasteringDisplay.x265())
+		}
+		if meta.ContentLightLevel != nil {
+			params = append(params, fmt.Sprintf("max-cll=%d,%d", meta.ContentLightLevel.MaxCLL, meta.ContentLightLevel.MaxFALL))
+		}
+		args = mergeEncoderParams(args, "-x265-params", strings.Join(params, ":"))
+	case "libsvtav1":
+		var params []string
+		if meta.MasteringDisplay != nil {
+			params = append(params, "mastering-display="+meta.MasteringDisplay.svtav1())
+		}
+		if meta.ContentLightLevel != nil {
+			params = append(params, fmt.Sprintf("content-light=%d,%d", meta.ContentLightLevel.MaxCLL, meta.ContentLightLevel.MaxFALL))
+		}
+		if len(params) > 0 {
+			args = mergeEncoderParams(args, "-svtav1-params", strings.Join(params, ":"))
+		}
+	}
+
+	if meta.DolbyVisionProfile > 0 && dolbyVisionEncoders[encoder] {
+		args = append(args, "-dolbyvision", "1")
+	}
+	return args
+}
+
+// mergeEncoderParams appends key=value params to an existing encoder params
+// option (e.g. -x265-params from a user preset), or adds the option.
+// FFmpeg only honors the last occurrence, so two separate options would drop one.
+func mergeEncoderParams(args []string, flag, params string) []string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			args[i+1] = args[i+1] + ":" + params
+			return args
+		}
+	}
+	return append(args, flag, params)
+}
+
+// DynamicHDRSkipReason returns why a source with dynamic HDR metadata can't be
+// encoded by the preset, or "" if it can. Dynamic metadata is either preserved
+// or the job is skipped - it is never silently dropped.
+// tonemap is true when the output is tonemapped to SDR (dynamic metadata is moot).
+func DynamicHDRSkipReason(preset *Preset, meta *HDRMetadata, tonemap bool) string {
+	if !meta.HasDynamicMetadata() {
+		return ""
+	}
+
+	// Profile 5 has no HDR10/SDR-compatible base layer (IPTPQc2 color):
+	// without the RPU the picture has wrong colors
+	if meta.DolbyVisionProfile == 5 && tonemap {
+		return "Dolby Vision profile 5 can't be tonemapped (no HDR10-compatible base layer)"
+	}
+	if tonemap {
+		return ""
+	}
+
+	encoder := encoderConfigs[EncoderKey{preset.Encoder, preset.Codec}].encoder
+	if meta.DolbyVisionProfile > 0 && !dolbyVisionEncoders[encoder] {
+		return fmt.Sprintf("Dolby Vision metadata can't be preserved by %s (use a software preset or enable HDR tonemapping)", encoder)
+	}
+	if meta.HDR10Plus && !hdr10PlusEncoders[encoder] {
+		return fmt.Sprintf("HDR10+ metadata can't be preserved by %s (use a software HEVC preset or enable HDR tonemapping)", encoder)
+	}
+	return ""
+}
+
+// ProbeHDR reads HDR side data for the first video stream: the Dolby Vision
+// configuration record from the stream, and mastering display, content light
+// level and HDR10+ metadata from the first frame.
+func (p *Prober) ProbeHDR(ctx context.Context, path string) (*HDRMetadata, error) {
+	cmd := exec.CommandContext(ctx, p.ffprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "v:0",
+		"-show_streams",
+		"-show_frames",
+		"-read_intervals", "%+#1", // First frame only
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("ffprobe failed: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probeOutput ffprobeOutput
+	if err := json.Unmarshal(output, &probeOutput); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	return parseHDRMetadata(&probeOutput), nil
+}
+
+// parseHDRMetadata collects HDR side data from ffprobe stream and frame output
+func parseHDRMetadata(out *ffprobeOutput) *HDRMetadata {
+	meta := &HDRMetadata{}
+	for i := range out.Streams {
+		meta.applySideData(out.Streams[i].SideDataList)
+	}
+	for i := range out.Frames {
+		meta.applySideData(out.Frames[i].SideDataList)
+	}
+	return meta
+}
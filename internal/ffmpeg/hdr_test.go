@@ -0,0 +1,213 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// dvProbeOutput is trimmed ffprobe output for a Dolby Vision profile 8.1 file
+// with HDR10 static metadata and HDR10+ on the first frame
+const dvProbeOutput = `{
+	"streams": [{
+		"index": 0, "codec_type": "video", "codec_name": "hevc",
+		"color_transfer": "smpte2084", "color_primaries": "bt2020",
+		"side_data_list": [{
+			"side_data_type": "DOVI configuration record",
+			"dv_version_major": 1, "dv_profile": 8, "dv_level": 6,
+			"rpu_present_flag": 1, "el_present_flag": 0, "bl_present_flag": 1,
+			"dv_bl_signal_compatibility_id": 1
+		}]
+	}],
+	"frames": [{
+		"side_data_list": [
+			{
+				"side_data_type": "Mastering display metadata",
+				"red_x": "34000/50000", "red_y": "16000/50000",
+				"green_x": "13250/50000", "green_y": "34500/50000",
+				"blue_x": "7500/50000", "blue_y": "3000/50000",
+				"white_point_x": "15635/50000", "white_point_y": "16450/50000",
+				"min_luminance": "50/10000", "max_luminance": "40000000/10000"
+			},
+			{"side_data_type": "Content light level metadata", "max_content": 1000, "max_average": 400},
+			{"side_data_type": "HDR Dynamic Metadata SMPTE2094-40 (HDR10+)", "application version": 1}
+		]
+	}]
+}`
+
+func TestParseHDRMetadata(t *testing.T) {
+	var out ffprobeOutput
+	if err := json.Unmarshal([]byte(dvProbeOutput), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := parseHDRMetadata(&out)
+	if meta.DolbyVisionProfile != 8 || meta.DolbyVisionCompatID != 1 {
+		t.Errorf("DV: got profile %d compat %d, want 8/1", meta.DolbyVisionProfile, meta.DolbyVisionCompatID)
+	}
+	if !meta.HDR10Plus {
+		t.Error("expected HDR10+ to be detected")
+	}
+	if meta.ContentLightLevel == nil || meta.ContentLightLevel.MaxCLL != 1000 || meta.ContentLightLevel.MaxFALL != 400 {
+		t.Errorf("unexpected content light level: %+v", meta.ContentLightLevel)
+	}
+	if meta.MasteringDisplay == nil {
+		t.Fatal("expected mastering display metadata")
+	}
+
+	want := "G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(40000000,50)"
+	if got := meta.MasteringDisplay.x265(); got != want {
+		t.Errorf("x265 master-display: got %q, want %q", got, want)
+	}
+	want = "G(0.2650,0.6900)B(0.1500,0.0600)R(0.6800,0.3200)WP(0.3127,0.3290)L(4000.0000,0.0050)"
+	if got := meta.MasteringDisplay.svtav1(); got != want {
+		t.Errorf("svtav1 mastering-display: got %q, want %q", got, want)
+	}
+
+	if got := HDRFormat(true, TransferPQ, meta); got != "Dolby Vision" {
+		t.Errorf("HDRFormat: got %q, want Dolby Vision", got)
+	}
+}
+
+func TestHDRFormatAndTransfer(t *testing.T) {
+	tests := []struct {
+		isHDR        bool
+		transfer     string
+		meta         *HDRMetadata
+		wantFormat   string
+		wantTransfer string
+	}{
+		{false, "bt709", nil, "", TransferPQ},
+		{true, "smpte2084", &HDRMetadata{}, "HDR10", TransferPQ},
+		{true, "arib-std-b67", nil, "HLG", TransferHLG},
+		{true, "smpte2084", &HDRMetadata{HDR10Plus: true}, "HDR10+", TransferPQ},
+		{true, "", nil, "HDR10", TransferPQ}, // Poorly tagged HDR
+	}
+
+	for _, tt := range tests {
+		if got := HDRFormat(tt.isHDR, tt.transfer, tt.meta); got != tt.wantFormat {
+			t.Errorf("HDRFormat(%v, %q): got %q, want %q", tt.isHDR, tt.transfer, got, tt.wantFormat)
+		}
+		if got := HDRTransfer(tt.transfer); got != tt.wantTransfer {
+			t.Errorf("HDRTransfer(%q): got %q, want %q", tt.transfer, got, tt.wantTransfer)
+		}
+	}
+}
+
+func TestDynamicHDRSkipReason(t *testing.T) {
+	swHEVC := &Preset{Encoder: HWAccelNone, Codec: CodecHEVC}
+	swAV1 := &Preset{Encoder: HWAccelNone, Codec: CodecAV1}
+	nvenc := &Preset{Encoder: HWAccelNVENC, Codec: CodecHEVC}
+
+	dv8 := &HDRMetadata{DolbyVisionProfile: 8, DolbyVisionCompatID: 1}
+	dv5 := &HDRMetadata{DolbyVisionProfile: 5}
+	hdr10Plus := &HDRMetadata{HDR10Plus: true}
+
+	tests := []struct {
+		name     string
+		preset   *Preset
+		meta     *HDRMetadata
+		tonemap  bool
+		wantSkip string
+	}{
+		{"no metadata", nvenc, nil, false, ""},
+		{"static only", nvenc, &HDRMetadata{MasteringDisplay: &MasteringDisplay{}}, false, ""},
+		{"DV preserved by libx265", swHEVC, dv8, false, ""},
+		{"DV preserved by libsvtav1", swAV1, dv8, false, ""},
+		{"DV dropped by hardware", nvenc, dv8, false, "Dolby Vision"},
+		{"DV tonemapped", nvenc, dv8, true, ""},
+		{"DV profile 5 tonemapped", swHEVC, dv5, true, "profile 5"},
+		{"HDR10+ preserved by libx265", swHEVC, hdr10Plus, false, ""},
+		{"HDR10+ dropped by libsvtav1", swAV1, hdr10Plus, false, "HDR10+"},
+		{"HDR10+ tonemapped", nvenc, hdr10Plus, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DynamicHDRSkipReason(tt.preset, tt.meta, tt.tonemap)
+			if tt.wantSkip == "" && got != "" {
+				t.Errorf("expected no skip, got %q", got)
+			}
+			if tt.wantSkip != "" && !strings.Contains(got, tt.wantSkip) {
+				t.Errorf("skip reason: got %q, want containing %q", got, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestBuildPresetArgsHDRMetadata(t *testing.T) {
+	meta := &HDRMetadata{
+		DolbyVisionProfile: 8,
+		MasteringDisplay:   &MasteringDisplay{RedX: 0.68, RedY: 0.32, MaxLuminance: 1000, MinLuminance: 0.0001},
+		ContentLightLevel:  &ContentLightLevel{MaxCLL: 1000, MaxFALL: 400},
+	}
+	tonemap := &TonemapParams{IsHDR: true, Transfer: TransferPQ, Metadata: meta}
+
+	// libx265: static metadata merged into user -x265-params, DV RPU passthrough
+	preset := &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecHEVC, ExtraArgs: []string{"-x265-params", "aq-mode=3"}}
+	_, outputArgs := BuildPresetArgs(preset, 0, 3840, 2160, 0, 0, false, "mkv", tonemap, nil, nil, nil, "")
+	args := strings.Join(outputArgs, " ")
+
+	if strings.Count(args, "-x265-params") != 1 {
+		t.Errorf("expected a single -x265-params option, got: %s", args)
+	}
+	for _, want := range []string{"aq-mode=3:repeat-headers=1:hdr-opt=1:master-display=", "max-cll=1000,400", "-dolbyvision 1", "-color_trc smpte2084"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in args: %s", want, args)
+		}
+	}
+
+	// libsvtav1 uses its own parameter syntax
+	preset = &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecAV1}
+	_, outputArgs = BuildPresetArgs(preset, 0, 3840, 2160, 0, 0, false, "mkv", tonemap, nil, nil, nil, "")
+	args = strings.Join(outputArgs, " ")
+	if !strings.Contains(args, "-svtav1-params mastering-display=") || !strings.Contains(args, "content-light=1000,400") {
+		t.Errorf("expected SVT-AV1 HDR params, got: %s", args)
+	}
+
+	// HLG keeps its transfer and skips the PQ-only hdr-opt
+	preset = &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecHEVC}
+	_, outputArgs = BuildPresetArgs(preset, 0, 3840, 2160, 0, 0, false, "mkv", &TonemapParams{IsHDR: true, Transfer: TransferHLG}, nil, nil, nil, "")
+	args = strings.Join(outputArgs, " ")
+	if !strings.Contains(args, "-color_trc arib-std-b67") || strings.Contains(args, "smpte2084") || strings.Contains(args, "hdr-opt") {
+		t.Errorf("expected HLG signaling, got: %s", args)
+	}
+}
+
+func TestProbeDetectsHDR10Plus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as ffprobe")
+	}
+
+	// Fake ffprobe: stream info for the main probe, HDR10+ frame side data
+	// for the one-frame pass
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$*" in
+*-show_frames*)
+	echo '{"frames": [{"side_data_list": [{"side_data_type": "HDR Dynamic Metadata SMPTE2094-40 (HDR10+)"}]}]}' ;;
+*)
+	echo '{"format": {"format_name": "matroska"}, "streams": [{"codec_type": "video", "codec_name": "hevc", "pix_fmt": "yuv420p10le", "color_transfer": "smpte2084", "color_primaries": "bt2020"}]}' ;;
+esac
+`
+	ffprobePath := filepath.Join(dir, "ffprobe")
+	if err := os.WriteFile(ffprobePath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewProber(ffprobePath).Probe(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if result.HDR == nil || !result.HDR.HDR10Plus || result.HDRFormat != "HDR10+" {
+		t.Errorf("expected HDR10+, got format %q, metadata %+v", result.HDRFormat, result.HDR)
+	}
+}
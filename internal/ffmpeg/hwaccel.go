@@ -29,8 +29,19 @@ type Codec string
 const (
 	CodecHEVC Codec = "hevc"
 	CodecAV1  Codec = "av1"
+	CodecH264 Codec = "h264" // For devices without HEVC support (larger than HEVC at equal quality)
+	CodecVP9  Codec = "vp9"  // For WebM / browser playback
 )
 
+// allCodecs lists the target codecs in display order
+var allCodecs = []Codec{CodecHEVC, CodecAV1, CodecH264, CodecVP9}
+
+// SupportsHDR returns false for codecs whose output is always SDR.
+// H.264 HDR is rarely supported by players, so HDR sources are tonemapped.
+func (c Codec) SupportsHDR() bool {
+	return c != CodecH264
+}
+
 // HWEncoder contains info about a hardware encoder
 type HWEncoder struct {
 	Accel       HWAccel `json:"accel"`
@@ -78,7 +89,7 @@ var availableEncoders = &AvailableEncoders{
 	encoders: make(map[EncoderKey]*HWEncoder),
 }
 
-// allEncoderDefs defines all possible encoders (HEVC and AV1 variants)
+// allEncoderDefs defines all possible encoders (HEVC, AV1, H.264 and VP9 variants)
 var allEncoderDefs = []*HWEncoder{
 	// HEVC encoders
 	{
@@ -154,6 +165,79 @@ var allEncoderDefs = []*HWEncoder{
 		Encoder:     "libsvtav1",
 		Available:   true, // Software is always available (if ffmpeg has it)
 	},
+	// H.264 encoders
+	{
+		Accel:       HWAccelVideoToolbox,
+		Codec:       CodecH264,
+		Name:        "VideoToolbox H.264",
+		Description: "Apple Silicon / Intel Mac hardware H.264 encoding",
+		Encoder:     "h264_videotoolbox",
+	},
+	{
+		Accel:       HWAccelNVENC,
+		Codec:       CodecH264,
+		Name:        "NVENC H.264",
+		Description: "NVIDIA GPU hardware H.264 encoding",
+		Encoder:     "h264_nvenc",
+	},
+	{
+		Accel:       HWAccelQSV,
+		Codec:       CodecH264,
+		Name:        "Quick Sync H.264",
+		Description: "Intel Quick Sync hardware H.264 encoding",
+		Encoder:     "h264_qsv",
+	},
+	{
+		Accel:       HWAccelVAAPI,
+		Codec:       CodecH264,
+		Name:        "VAAPI H.264",
+		Description: "Linux VA-API hardware H.264 encoding (Intel/AMD)",
+		Encoder:     "h264_vaapi",
+	},
+	{
+		Accel:       HWAccelNone,
+		Codec:       CodecH264,
+		Name:        "Software H.264",
+		Description: "CPU-based H.264 encoding (libx264)",
+		Encoder:     "libx264",
+		Available:   true, // Software is always available (if ffmpeg has it)
+	},
+	// VP9 encoders
+	{
+		Accel:       HWAccelQSV,
+		Codec:       CodecVP9,
+		Name:        "Quick Sync VP9",
+		Description: "Intel (Ice Lake+) hardware VP9 encoding",
+		Encoder:     "vp9_qsv",
+	},
+	{
+		Accel:       HWAccelVAAPI,
+		Codec:       CodecVP9,
+		Name:        "VAAPI VP9",
+		Description: "Linux VA-API hardware VP9 encoding (Intel)",
+		Encoder:     "vp9_vaapi",
+	},
+	{
+		Accel:       HWAccelNone,
+		Codec:       CodecVP9,
+		Name:        "Software VP9",
+		Description: "CPU-based VP9 encoding (libvpx)",
+		Encoder:     "libvpx-vp9",
+		Available:   true, // Software is always available (if ffmpeg has it)
+	},
+}
+
+// softwareEncoder returns the software encoder definition for a codec.
+// Used as the last-resort fallback, even before DetectEncoders has run.
+func softwareEncoder(codec Codec) *HWEncoder {
+	for _, enc := range allEncoderDefs {
+		if enc.Accel == HWAccelNone && enc.Codec == codec {
+			encCopy := *enc
+			encCopy.Available = true
+			return &encCopy
+		}
+	}
+	return softwareEncoder(CodecHEVC)
 }
 
 // DetectEncoders probes FFmpeg to detect available hardware encoders
@@ -424,24 +508,7 @@ func GetBestEncoderForCodec(codec Codec) *HWEncoder {
 	}
 
 	// Fallback to software
-	if codec == CodecAV1 {
-		return &HWEncoder{
-			Accel:       HWAccelNone,
-			Codec:       CodecAV1,
-			Name:        "Software AV1",
-			Description: "CPU-based AV1 encoding",
-			Encoder:     "libsvtav1",
-			Available:   true,
-		}
-	}
-	return &HWEncoder{
-		Accel:       HWAccelNone,
-		Codec:       CodecHEVC,
-		Name:        "Software HEVC",
-		Description: "CPU-based HEVC encoding",
-		Encoder:     "libx265",
-		Available:   true,
-	}
+	return softwareEncoder(codec)
 }
 
 // GetBestEncoder returns the best available HEVC encoder (for backward compatibility)
@@ -483,24 +550,7 @@ func GetFallbackEncoder(current HWAccel, codec Codec) *HWEncoder {
 		// even if DetectEncoders wasn't called or cache is stale
 		if priority[i] == HWAccelNone {
 			// Return software encoder as ultimate fallback
-			if codec == CodecAV1 {
-				return &HWEncoder{
-					Accel:       HWAccelNone,
-					Codec:       CodecAV1,
-					Name:        "Software AV1",
-					Description: "CPU-based AV1 encoding (SVT-AV1)",
-					Encoder:     "libsvtav1",
-					Available:   true,
-				}
-			}
-			return &HWEncoder{
-				Accel:       HWAccelNone,
-				Codec:       CodecHEVC,
-				Name:        "Software HEVC",
-				Description: "CPU-based HEVC encoding (libx265)",
-				Encoder:     "libx265",
-				Available:   true,
-			}
+			return softwareEncoder(codec)
 		}
 	}
 
@@ -513,11 +563,10 @@ func ListAvailableEncoders() []*HWEncoder {
 	defer availableEncoders.mu.RUnlock()
 
 	var result []*HWEncoder
-	// Return in priority order (HEVC first, then AV1)
+	// Return in priority order (HEVC first, then AV1, H.264, VP9)
 	priority := []HWAccel{HWAccelVideoToolbox, HWAccelNVENC, HWAccelQSV, HWAccelVAAPI, HWAccelNone}
-	codecs := []Codec{CodecHEVC, CodecAV1}
 
-	for _, codec := range codecs {
+	for _, codec := range allCodecs {
 		for _, accel := range priority {
 			key := EncoderKey{accel, codec}
 			if enc, ok := availableEncoders.encoders[key]; ok && enc.Available {
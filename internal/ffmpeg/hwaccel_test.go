@@ -151,11 +151,15 @@ func TestGetFallbackEncoder(t *testing.T) {
 		// Software has no fallback
 		{"Software_HEVC_NoFallback", HWAccelNone, CodecHEVC, true, HWAccelNone},
 		{"Software_AV1_NoFallback", HWAccelNone, CodecAV1, true, HWAccelNone},
+		{"Software_H264_NoFallback", HWAccelNone, CodecH264, true, HWAccelNone},
+		{"Software_VP9_NoFallback", HWAccelNone, CodecVP9, true, HWAccelNone},
 
 		// Each encoder should return something lower in priority (or nil if nothing available)
 		// We can't guarantee what's available, but we can check it's not the same or higher priority
 		{"QSV_NotSameOrHigher", HWAccelQSV, CodecHEVC, false, HWAccelQSV},
 		{"VAAPI_NotSameOrHigher", HWAccelVAAPI, CodecHEVC, false, HWAccelVAAPI},
+		{"NVENC_H264_NotSameOrHigher", HWAccelNVENC, CodecH264, false, HWAccelNVENC},
+		{"QSV_VP9_NotSameOrHigher", HWAccelQSV, CodecVP9, false, HWAccelQSV},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,176 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
+)
+
+// ScanType describes how the frames of a video are stored
+type ScanType string
+
+const (
+	ScanUnknown     ScanType = ""            // Not analyzed
+	ScanProgressive ScanType = "progressive" // Full frames, no deinterlacing needed
+	ScanInterlaced  ScanType = "interlaced"  // Alternating fields (broadcast, DVD video content)
+	ScanTelecined   ScanType = "telecined"   // Film with 3:2 pulldown (repeated fields)
+)
+
+// NeedsDeinterlace returns true if the scan type produces combing when encoded as-is
+func (s ScanType) NeedsDeinterlace() bool {
+	return s == ScanInterlaced || s == ScanTelecined
+}
+
+// Software deinterlace filters.
+// bwdif in send_frame mode keeps the source frame rate. Telecined content is
+// field-matched back to progressive frames and the duplicates decimated
+// (inverse telecine), with yadif cleaning up any frames left combed.
+const (
+	deinterlaceFilter     = "bwdif=mode=send_frame"
+	inverseTelecineFilter = "fieldmatch=order=auto,yadif=deint=interlaced,decimate"
+)
+
+// idetFrames is the number of frames analyzed at each sample position
+const idetFrames = 200
+
+// Thresholds for classifying idet results.
+// Interlaced sources still show progressive frames in static scenes, so a
+// minority of combed frames is enough. Telecine repeats a field in 2 of every
+// 5 frames, while true interlaced and progressive content rarely does.
+const (
+	interlacedThreshold = 0.25 // Fraction of decided frames detected as TFF/BFF
+	telecineThreshold   = 0.15 // Fraction of frames with a repeated field
+)
+
+// idetMultiPattern matches idet's multi frame detection summary
+var idetMultiPattern = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)`)
+
+// idetRepeatedPattern matches idet's repeated field summary
+var idetRepeatedPattern = regexp.MustCompile(`Repeated Fields:\s*Neither:\s*(\d+)\s*Top:\s*(\d+)\s*Bottom:\s*(\d+)`)
+
+// idetCounts holds idet frame counts, summed across sample positions
+type idetCounts struct {
+	TFF         int
+	BFF         int
+	Progressive int
+	Neither     int // Frames without a repeated field
+	Repeated    int // Frames repeating the top or bottom field
+}
+
+// IsInterlacedFieldOrder returns true if an ffprobe field_order marks the
+// stream as interlaced. The flag is only a hint - progressive content is
+// often stored as interlaced, so DetectInterlace has the final say.
+func IsInterlacedFieldOrder(fieldOrder string) bool {
+	switch fieldOrder {
+	case "tt", "bb", "tb", "bt":
+		return true
+	}
+	return false
+}
+
+// maxSDHeight is the tallest standard-definition frame (PAL 576 lines)
+const maxSDHeight = 576
+
+// NeedsInterlaceCheck returns true if a source should be checked with
+// DetectInterlace: when its field order marks it as interlaced, or when the
+// field order is missing or unknown for codecs that are commonly interlaced
+// (MPEG-2, VC-1, DV, and SD H.264 from DVDs and broadcast recordings).
+// Sources flagged progressive are trusted.
+func NeedsInterlaceCheck(fieldOrder, videoCodec string, height int) bool {
+	if IsInterlacedFieldOrder(fieldOrder) {
+		return true
+	}
+	if fieldOrder != "" && fieldOrder != "unknown" {
+		return false
+	}
+	switch videoCodec {
+	case "mpeg2video", "vc1", "dvvideo":
+		return true
+	case "h264":
+		return height > 0 && height <= maxSDHeight
+	}
+	return false
+}
+
+// DetectInterlace runs the idet filter at the VMAF sample positions and
+// classifies the source as progressive, interlaced or telecined.
+// Returns ScanUnknown if idet could not decide on any frames.
+func (t *Transcoder) DetectInterlace(ctx context.Context, inputPath string, duration time.Duration) (ScanType, error) {
+	var total idetCounts
+	for _, pos := range vmaf.SamplePositions(duration) {
+		start := duration.Seconds() * pos
+
+		cmd := exec.CommandContext(ctx, t.ffmpegPath,
+			"-hide_banner",
+			"-nostats",
+			"-ss", fmt.Sprintf("%.3f", start),
+			"-i", inputPath,
+			"-map", "0:v:0",
+			"-frames:v", strconv.Itoa(idetFrames),
+			"-vf", "idet",
+			"-an", "-sn",
+			"-f", "null", "-",
+		)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return ScanUnknown, fmt.Errorf("idet failed at %.0f%%: %w", pos*100, err)
+		}
+
+		if counts, ok := parseIdet(string(output)); ok {
+			total.TFF += counts.TFF
+			total.BFF += counts.BFF
+			total.Progressive += counts.Progressive
+			total.Neither += counts.Neither
+			total.Repeated += counts.Repeated
+		}
+	}
+
+	return classifyScan(total), nil
+}
+
+// parseIdet extracts the frame counts from idet's end-of-stream summary
+func parseIdet(output string) (idetCounts, bool) {
+	multi := idetMultiPattern.FindAllStringSubmatch(output, -1)
+	if len(multi) == 0 {
+		return idetCounts{}, false
+	}
+
+	// idet prints one summary per filter instance; use the last
+	last := multi[len(multi)-1]
+	var counts idetCounts
+	counts.TFF, _ = strconv.Atoi(last[1])
+	counts.BFF, _ = strconv.Atoi(last[2])
+	counts.Progressive, _ = strconv.Atoi(last[3])
+
+	if repeated := idetRepeatedPattern.FindAllStringSubmatch(output, -1); len(repeated) > 0 {
+		last := repeated[len(repeated)-1]
+		top, _ := strconv.Atoi(last[2])
+		bottom, _ := strconv.Atoi(last[3])
+		counts.Neither, _ = strconv.Atoi(last[1])
+		counts.Repeated = top + bottom
+	}
+
+	return counts, true
+}
+
+// classifyScan decides the scan type from idet frame counts.
+// Telecine is checked first since pulldown frames are also detected as interlaced.
+func classifyScan(c idetCounts) ScanType {
+	decided := c.TFF + c.BFF + c.Progressive
+	if decided == 0 {
+		return ScanUnknown
+	}
+
+	if fields := c.Neither + c.Repeated; fields > 0 && float64(c.Repeated)/float64(fields) >= telecineThreshold {
+		return ScanTelecined
+	}
+	if float64(c.TFF+c.BFF)/float64(decided) >= interlacedThreshold {
+		return ScanInterlaced
+	}
+	return ScanProgressive
+}
@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIdet(t *testing.T) {
+	output := `[Parsed_idet_0 @ 0x55d1] Repeated Fields: Neither:   150 Top:    25 Bottom:    26
+[Parsed_idet_0 @ 0x55d1] Single frame detection: TFF:    60 BFF:     0 Progressive:   120 Undetermined:    21
+[Parsed_idet_0 @ 0x55d1] Multi frame detection: TFF:    78 BFF:     0 Progressive:   121 Undetermined:     2
+`
+	counts, ok := parseIdet(output)
+	if !ok {
+		t.Fatal("expected idet output to be parsed")
+	}
+	want := idetCounts{TFF: 78, BFF: 0, Progressive: 121, Neither: 150, Repeated: 51}
+	if counts != want {
+		t.Errorf("got %+v, want %+v", counts, want)
+	}
+
+	if _, ok := parseIdet("no idet output"); ok {
+		t.Error("expected no counts for empty output")
+	}
+}
+
+func TestClassifyScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts idetCounts
+		want   ScanType
+	}{
+		{"nothing decided", idetCounts{}, ScanUnknown},
+		{"progressive", idetCounts{TFF: 2, Progressive: 598, Neither: 600}, ScanProgressive},
+		{"interlaced", idetCounts{TFF: 480, Progressive: 120, Neither: 598, Repeated: 2}, ScanInterlaced},
+		{"interlaced with static scenes", idetCounts{BFF: 200, Progressive: 400, Neither: 600}, ScanInterlaced},
+		{"telecined", idetCounts{TFF: 240, Progressive: 360, Neither: 360, Repeated: 240}, ScanTelecined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyScan(tt.counts); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsInterlacedFieldOrder(t *testing.T) {
+	for _, order := range []string{"tt", "bb", "tb", "bt"} {
+		if !IsInterlacedFieldOrder(order) {
+			t.Errorf("expected %q to be interlaced", order)
+		}
+	}
+	for _, order := range []string{"", "progressive", "unknown"} {
+		if IsInterlacedFieldOrder(order) {
+			t.Errorf("expected %q not to be interlaced", order)
+		}
+	}
+}
+
+func TestNeedsInterlaceCheck(t *testing.T) {
+	tests := []struct {
+		fieldOrder string
+		codec      string
+		height     int
+		want       bool
+	}{
+		{"tt", "hevc", 1080, true},
+		{"progressive", "mpeg2video", 480, false},
+		{"", "mpeg2video", 480, true},
+		{"unknown", "vc1", 1080, true},
+		{"", "h264", 576, true},
+		{"", "h264", 1080, false},
+		{"unknown", "hevc", 480, false},
+		{"", "av1", 2160, false},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsInterlaceCheck(tt.fieldOrder, tt.codec, tt.height); got != tt.want {
+			t.Errorf("NeedsInterlaceCheck(%q, %q, %d) = %v, want %v", tt.fieldOrder, tt.codec, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPresetArgsDeinterlace(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder HWAccel
+		swDec   bool
+		scan    ScanType
+		want    string // expected -vf value
+	}{
+		{"software", HWAccelNone, false, ScanInterlaced, "bwdif=mode=send_frame"},
+		{"vaapi hw decode", HWAccelVAAPI, false, ScanInterlaced, "format=nv12|vaapi,hwupload,scale_vaapi=format=nv12,deinterlace_vaapi"},
+		{"nvenc hw decode", HWAccelNVENC, false, ScanInterlaced, "scale_cuda=format=nv12,yadif_cuda"},
+		{"qsv hw decode", HWAccelQSV, false, ScanInterlaced, "format=nv12|qsv,hwupload=extra_hw_frames=64,scale_qsv=format=nv12,deinterlace_qsv"},
+		{"vaapi sw decode", HWAccelVAAPI, true, ScanInterlaced, "bwdif=mode=send_frame,format=nv12,hwupload"},
+		{"videotoolbox", HWAccelVideoToolbox, false, ScanInterlaced, "bwdif=mode=send_frame"},
+		{"telecined", HWAccelNone, false, ScanTelecined, "fieldmatch=order=auto,yadif=deint=interlaced,decimate"},
+		{"telecined forces sw decode", HWAccelVAAPI, false, ScanTelecined, "fieldmatch=order=auto,yadif=deint=interlaced,decimate,format=nv12,hwupload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset := &Preset{ID: "test", Encoder: tt.encoder, Codec: CodecHEVC}
+			_, outputArgs := BuildPresetArgs(preset, 0, 720, 576, 0, 0, tt.swDec, "mkv", nil, nil, nil, nil, tt.scan)
+			args := strings.Join(outputArgs, " ")
+			if !strings.Contains(args, "-vf "+tt.want+" ") {
+				t.Errorf("expected -vf %s, got: %s", tt.want, args)
+			}
+		})
+	}
+
+	// Progressive sources get no deinterlace filter
+	preset := &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecHEVC}
+	_, outputArgs := BuildPresetArgs(preset, 0, 720, 576, 0, 0, false, "mkv", nil, nil, nil, nil, ScanProgressive)
+	if args := strings.Join(outputArgs, " "); strings.Contains(args, "-vf") {
+		t.Errorf("expected no filters for progressive source, got: %s", args)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
+	"github.com/gwlsn/shrinkray/internal/logger"
 )
 
 // Preset defines a transcoding preset with its FFmpeg parameters
@@ -16,6 +17,18 @@ type Preset struct {
 	Codec         Codec   `json:"codec"`           // Target codec (HEVC or AV1)
 	MaxHeight     int     `json:"max_height"`      // 0 = no scaling, 1080, 720, etc.
 	IsSmartShrink bool    `json:"is_smart_shrink"` // True for VMAF-based presets
+
+	// User-defined preset settings (see userpresets.go)
+	Quality   int             `json:"quality,omitempty"`    // CRF override for this preset (0 = use config/encoder default)
+	ExtraArgs []string        `json:"extra_args,omitempty"` // Extra encoder args appended after the built-in ones
+	IsCustom  bool            `json:"is_custom"`            // True for presets loaded from presets.yaml
+	Audio     *AudioPolicy    `json:"audio,omitempty"`      // Audio stream rules (nil = copy all / AAC for MP4)
+	Subtitles *SubtitlePolicy `json:"subtitles,omitempty"`  // Subtitle stream rules (nil = keep all compatible)
+	AutoCrop  bool            `json:"auto_crop,omitempty"`  // Detect and crop black bars before encoding
+
+	// Target rate encoding (see target.go) - replaces the quality value when set
+	TargetSize    int64 `json:"target_size,omitempty"`    // Max output size in bytes
+	TargetBitrate int64 `json:"target_bitrate,omitempty"` // Average overall bitrate in bits/s
 }
 
 // WithEncoder returns a copy of the preset with a different encoder.
@@ -36,6 +49,7 @@ type encoderSettings struct {
 	hwaccelArgs []string // Args to prepend before -i for hardware decoding
 	scaleFilter string   // Hardware-specific scale filter (e.g., "scale_qsv", "scale_cuda")
 	baseFilter  string   // Filter to prepend before scale (e.g., "format=nv12,hwupload" for VAAPI)
+	deintFilter string   // Hardware deinterlace filter for the HW decode path (empty = CPU bwdif)
 	qualityMin  int      // Minimum quality (best quality, lowest compression)
 	qualityMax  int      // Maximum quality (most compression)
 	modMin      float64  // Min bitrate modifier (for VideoToolbox)
@@ -94,6 +108,7 @@ var encoderConfigs = map[EncoderKey]encoderSettings{
 		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
 		scaleFilter: "scale_cuda",
 		baseFilter:  "scale_cuda=format=nv12", // Explicit format for compatibility
+		deintFilter: "yadif_cuda",
 		qualityMin:  18,
 		qualityMax:  35,
 	},
@@ -105,6 +120,7 @@ var encoderConfigs = map[EncoderKey]encoderSettings{
 		// hwaccelArgs generated dynamically by getHwaccelInputArgs() - QSV derived from VAAPI on Linux
 		scaleFilter: "scale_qsv",
 		baseFilter:  "format=nv12|qsv,hwupload=extra_hw_frames=64,scale_qsv=format=nv12", // Added scale_qsv for format compatibility
+		deintFilter: "deinterlace_qsv",
 		qualityMin:  18,
 		qualityMax:  35,
 	},
@@ -116,6 +132,7 @@ var encoderConfigs = map[EncoderKey]encoderSettings{
 		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
 		scaleFilter: "scale_vaapi",
 		baseFilter:  "format=nv12|vaapi,hwupload,scale_vaapi=format=nv12", // Added scale_vaapi for format compatibility
+		deintFilter: "deinterlace_vaapi",
 		qualityMin:  18,
 		qualityMax:  35,
 	},
@@ -156,6 +173,7 @@ var encoderConfigs = map[EncoderKey]encoderSettings{
 		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
 		scaleFilter: "scale_cuda",
 		baseFilter:  "scale_cuda=format=nv12", // Explicit format for compatibility
+		deintFilter: "yadif_cuda",
 		qualityMin:  20,
 		qualityMax:  40,
 	},
@@ -167,6 +185,7 @@ var encoderConfigs = map[EncoderKey]encoderSettings{
 		// hwaccelArgs generated dynamically by getHwaccelInputArgs() - QSV derived from VAAPI on Linux
 		scaleFilter: "scale_qsv",
 		baseFilter:  "format=nv12|qsv,hwupload=extra_hw_frames=64,scale_qsv=format=nv12", // Added scale_qsv for format compatibility
+		deintFilter: "deinterlace_qsv",
 		qualityMin:  20,
 		qualityMax:  40,
 	},
@@ -178,9 +197,107 @@ var encoderConfigs = map[EncoderKey]encoderSettings{
 		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
 		scaleFilter: "scale_vaapi",
 		baseFilter:  "format=nv12|vaapi,hwupload,scale_vaapi=format=nv12", // Added scale_vaapi for format compatibility
+		deintFilter: "deinterlace_vaapi",
 		qualityMin:  20,
 		qualityMax:  40,
 	},
+
+	// H.264 encoders
+	// For compatibility with older players - needs lower CRF than HEVC for similar quality
+	{HWAccelNone, CodecH264}: {
+		encoder:     "libx264",
+		qualityFlag: "-crf",
+		quality:     "23",
+		extraArgs:   []string{"-preset", "medium"},
+		scaleFilter: "scale",
+		qualityMin:  16,
+		qualityMax:  30,
+	},
+	{HWAccelVideoToolbox, CodecH264}: {
+		// H.264 is less efficient than HEVC, so it keeps more of the source bitrate
+		encoder:     "h264_videotoolbox",
+		qualityFlag: "-b:v",
+		quality:     "0.50",
+		extraArgs:   []string{"-allow_sw", "1"},
+		usesBitrate: true,
+		hwaccelArgs: []string{"-hwaccel", "videotoolbox"},
+		scaleFilter: "scale", // VideoToolbox doesn't have a HW scaler, use CPU
+		modMin:      0.10,
+		modMax:      0.90,
+	},
+	{HWAccelNVENC, CodecH264}: {
+		encoder:     "h264_nvenc",
+		qualityFlag: "-cq",
+		quality:     "25",
+		extraArgs:   []string{"-preset", "p4", "-tune", "hq", "-rc", "vbr"},
+		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
+		scaleFilter: "scale_cuda",
+		baseFilter:  "scale_cuda=format=nv12", // Explicit format for compatibility
+		deintFilter: "yadif_cuda",
+		qualityMin:  16,
+		qualityMax:  32,
+	},
+	{HWAccelQSV, CodecH264}: {
+		encoder:     "h264_qsv",
+		qualityFlag: "-global_quality",
+		quality:     "24",
+		extraArgs:   []string{"-preset", "medium"},
+		// hwaccelArgs generated dynamically by getHwaccelInputArgs() - QSV derived from VAAPI on Linux
+		scaleFilter: "scale_qsv",
+		baseFilter:  "format=nv12|qsv,hwupload=extra_hw_frames=64,scale_qsv=format=nv12", // Added scale_qsv for format compatibility
+		deintFilter: "deinterlace_qsv",
+		qualityMin:  16,
+		qualityMax:  32,
+	},
+	{HWAccelVAAPI, CodecH264}: {
+		encoder:     "h264_vaapi",
+		qualityFlag: "-qp",
+		quality:     "24",
+		extraArgs:   []string{},
+		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
+		scaleFilter: "scale_vaapi",
+		baseFilter:  "format=nv12|vaapi,hwupload,scale_vaapi=format=nv12", // Added scale_vaapi for format compatibility
+		deintFilter: "deinterlace_vaapi",
+		qualityMin:  16,
+		qualityMax:  32,
+	},
+
+	// VP9 encoders
+	// For WebM output. libvpx only runs in constant quality mode with -b:v 0.
+	{HWAccelNone, CodecVP9}: {
+		encoder:     "libvpx-vp9",
+		qualityFlag: "-crf",
+		quality:     "33",
+		extraArgs:   []string{"-b:v", "0", "-deadline", "good", "-cpu-used", "2", "-row-mt", "1"},
+		scaleFilter: "scale",
+		qualityMin:  20,
+		qualityMax:  45,
+	},
+	{HWAccelQSV, CodecVP9}: {
+		encoder:     "vp9_qsv",
+		qualityFlag: "-global_quality",
+		quality:     "32",
+		extraArgs:   []string{"-preset", "medium"},
+		// hwaccelArgs generated dynamically by getHwaccelInputArgs() - QSV derived from VAAPI on Linux
+		scaleFilter: "scale_qsv",
+		baseFilter:  "format=nv12|qsv,hwupload=extra_hw_frames=64,scale_qsv=format=nv12", // Added scale_qsv for format compatibility
+		deintFilter: "deinterlace_qsv",
+		qualityMin:  20,
+		qualityMax:  40,
+	},
+	{HWAccelVAAPI, CodecVP9}: {
+		// vp9_vaapi has no -qp option; -global_quality is the VP9 quantizer index (0-255)
+		encoder:     "vp9_vaapi",
+		qualityFlag: "-global_quality",
+		quality:     "120",
+		extraArgs:   []string{},
+		// hwaccelArgs generated dynamically by getHwaccelInputArgs()
+		scaleFilter: "scale_vaapi",
+		baseFilter:  "format=nv12|vaapi,hwupload,scale_vaapi=format=nv12", // Added scale_vaapi for format compatibility
+		deintFilter: "deinterlace_vaapi",
+		qualityMin:  60,
+		qualityMax:  180,
+	},
 }
 
 // BasePresets defines the core presets
@@ -222,12 +339,13 @@ func (p *Preset) Meta() *BasePresetMeta {
 
 // GetBasePresetMeta returns core metadata for a preset ID without VMAF gating.
 // Use this when GetPreset returns nil but you still need skip check info.
+// User-defined presets are included.
 func GetBasePresetMeta(id string) *BasePresetMeta {
-	for _, base := range BasePresets {
-		if base.ID == id {
+	for _, def := range presetDefinitions() {
+		if def.ID == id {
 			return &BasePresetMeta{
-				Codec:     base.Codec,
-				MaxHeight: base.MaxHeight,
+				Codec:     def.Codec,
+				MaxHeight: def.MaxHeight,
 			}
 		}
 	}
@@ -271,6 +389,23 @@ func GetQualityRange(hwaccel HWAccel, codec Codec) vmaf.QualityRange {
 	}
 }
 
+// ValidateQuality checks that quality is within the range the encoder for
+// codec accepts (the same range SmartShrink searches). Bitrate-based
+// encoders (VideoToolbox) take a CRF that is converted to a bitrate
+// modifier, so the software encoder's range applies to them.
+func ValidateQuality(hwaccel HWAccel, codec Codec, quality int) error {
+	config, ok := encoderConfigs[EncoderKey{hwaccel, codec}]
+	if !ok || config.usesBitrate {
+		if config, ok = encoderConfigs[EncoderKey{HWAccelNone, codec}]; !ok {
+			return fmt.Errorf("unknown codec %q", codec)
+		}
+	}
+	if quality < config.qualityMin || quality > config.qualityMax {
+		return fmt.Errorf("quality must be between %d and %d for %s", config.qualityMin, config.qualityMax, config.encoder)
+	}
+	return nil
+}
+
 // crfToBitrateModifier converts a CRF value to a VideoToolbox bitrate modifier.
 // This allows users to set CRF values (like Handbrake) even when using VideoToolbox,
 // which only supports bitrate-based encoding.
@@ -376,26 +511,37 @@ func getHwaccelInputArgs(encoder HWAccel, softwareDecode bool) []string {
 
 // TonemapParams holds parameters for HDR to SDR tonemapping
 type TonemapParams struct {
-	IsHDR          bool   // True if source is HDR content
-	EnableTonemap  bool   // True if tonemapping should be applied
-	Algorithm      string // Tonemapping algorithm: hable, bt2390, reinhard, etc.
+	IsHDR         bool         // True if source is HDR content
+	EnableTonemap bool         // True if tonemapping should be applied
+	Algorithm     string       // Tonemapping algorithm: hable, bt2390, reinhard, etc.
+	Transfer      string       // Source transfer function (arib-std-b67 keeps HLG, anything else is PQ)
+	Metadata      *HDRMetadata // Source HDR side data to carry through when preserving (nil = HDR10 signaling only)
 }
 
 // BuildPresetArgs builds FFmpeg arguments for a preset with the specified encoder
 // sourceBitrate is the source video bitrate in bits/second (used for dynamic bitrate calculation)
 // sourceWidth/sourceHeight are the source video dimensions (for calculating scaled output)
-// qualityHEVC/qualityAV1 are optional CRF overrides (0 = use default)
+// quality is an optional CRF override for the preset's codec (0 = use preset.Quality, then encoder default)
 // qualityMod is an optional bitrate modifier for VideoToolbox (0 = use default)
 // softwareDecode: if true, skip hardware decode args and use software decode filter
-// outputFormat: "mkv" preserves audio/subs, "mp4" transcodes to AAC and strips subtitles
+// outputFormat: "mkv" preserves audio/subs, "mp4" transcodes to AAC and converts text subtitles to mov_text,
+// "webm" transcodes to Opus and converts text subtitles to WebVTT
 // tonemap: optional tonemapping parameters (nil = no tonemapping)
-// subtitleIndices controls subtitle mapping for MKV output:
-//   - nil: map all subtitles (-map 0:s?)
+// subtitleIndices controls subtitle mapping:
+//   - nil: MKV maps all subtitles (-map 0:s?), MP4/WebM strip them (-sn)
 //   - empty slice: map no subtitles (all incompatible)
-//   - populated slice: map specific stream indices (-map 0:2 -map 0:4)
+//   - populated slice: map specific stream indices (-map 0:2 -map 0:4);
+//     MP4/WebM convert them to mov_text/WebVTT, so only pass text-based streams
+//
+// audio: optional audio plan from PlanAudio (nil = copy all for MKV, AAC/Opus stereo for MP4/WebM)
+// crop: optional black-bar crop from DetectCrop (nil = no crop). Cropping forces
+// software decode so the crop runs on CPU frames before scaling/hwupload.
+// scan: scan type from DetectInterlace. Interlaced sources are deinterlaced with the
+// encoder's hardware deinterlacer on the HW decode path, bwdif otherwise; telecined
+// sources get a CPU inverse telecine (forces software decode).
 //
 // Returns (inputArgs, outputArgs) - inputArgs go before -i, outputArgs go after
-func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHeight int, qualityHEVC, qualityAV1 int, qualityMod float64, softwareDecode bool, outputFormat string, tonemap *TonemapParams, subtitleIndices []int) (inputArgs []string, outputArgs []string) {
+func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHeight int, quality int, qualityMod float64, softwareDecode bool, outputFormat string, tonemap *TonemapParams, subtitleIndices []int, audio *AudioPlan, crop *CropRect, scan ScanType) (inputArgs []string, outputArgs []string) {
 	key := EncoderKey{preset.Encoder, preset.Codec}
 	config, ok := encoderConfigs[key]
 	if !ok {
@@ -418,6 +564,17 @@ func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHei
 		softwareDecode = true
 	}
 
+	// Cropping needs CPU frames, and scaling decisions use the cropped size
+	if crop != nil {
+		softwareDecode = true
+		sourceWidth, sourceHeight = crop.Width, crop.Height
+	}
+
+	// Inverse telecine (fieldmatch/decimate) only exists as a CPU filter
+	if scan == ScanTelecined {
+		softwareDecode = true
+	}
+
 	// Input args: hardware acceleration for decoding
 	// Generated dynamically based on encoder type
 	inputArgs = getHwaccelInputArgs(preset.Encoder, softwareDecode)
@@ -447,6 +604,13 @@ func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHei
 		filterParts = append(filterParts, baseFilter)
 	}
 
+	// Hardware deinterlace runs on the decoded surfaces, before scaling.
+	// Without a hardware deinterlacer (or with CPU frames), bwdif is added below.
+	hwDeinterlace := scan == ScanInterlaced && !softwareDecode && config.deintFilter != ""
+	if hwDeinterlace {
+		filterParts = append(filterParts, config.deintFilter)
+	}
+
 	// Add tonemapping filter if needed
 	// Software tonemap (zscale) goes before hwupload since it requires CPU frames
 	if needsTonemap && tonemapFilter != "" {
@@ -479,6 +643,21 @@ func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHei
 		filterParts = append(filterParts, fmt.Sprintf("%s=-2:'min(ih,%d)'", scaleFilter, preset.MaxHeight))
 	}
 
+	// Crop goes first, before tonemap, scaling and hwupload
+	if crop != nil {
+		filterParts = append([]string{crop.filter()}, filterParts...)
+	}
+
+	// CPU deinterlace goes before everything else, including crop, so fields
+	// are still intact when it runs
+	if scan.NeedsDeinterlace() && !hwDeinterlace {
+		filter := deinterlaceFilter
+		if scan == ScanTelecined {
+			filter = inverseTelecineFilter
+		}
+		filterParts = append([]string{filter}, filterParts...)
+	}
+
 	// Apply filter chain if we have any filters
 	if len(filterParts) > 0 {
 		outputArgs = append(outputArgs, "-vf", strings.Join(filterParts, ","))
@@ -490,10 +669,11 @@ func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHei
 	// Determine quality value - use override if provided, otherwise use default
 	var qualityStr string
 	qualityOverride := 0
-	if preset.Codec == CodecHEVC && qualityHEVC > 0 {
-		qualityOverride = qualityHEVC
-	} else if preset.Codec == CodecAV1 && qualityAV1 > 0 {
-		qualityOverride = qualityAV1
+	if quality > 0 {
+		qualityOverride = quality
+	} else if preset.Quality > 0 {
+		// User-defined preset quality acts as the default when no override is given
+		qualityOverride = preset.Quality
 	}
 
 	// For encoders that use dynamic bitrate calculation (VideoToolbox)
@@ -552,10 +732,14 @@ func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHei
 	// Add encoder-specific extra args
 	outputArgs = append(outputArgs, config.extraArgs...)
 
+	// Add user-defined preset args last so they override the built-in ones
+	outputArgs = append(outputArgs, preset.ExtraArgs...)
+
 	// Add HDR preservation flags when preserving HDR content
 	// Per FFmpeg docs and Jellyfin implementation:
 	// - Main10 profile for 10-bit HEVC/AV1
-	// - Color metadata for HDR10 (BT.2020 colorspace, PQ transfer)
+	// - Color metadata for HDR10 (BT.2020 colorspace, PQ transfer) or HLG
+	// - Mastering display, content light level and dynamic metadata (software encoders)
 	if preserveHDR && !needsTonemap {
 		// Set 10-bit profile for HEVC encoders
 		// Most HW encoders auto-detect, but explicit is safer
@@ -572,33 +756,61 @@ func BuildPresetArgs(preset *Preset, sourceBitrate int64, sourceWidth, sourceHei
 				outputArgs = append(outputArgs, "-profile:v", "main10")
 			}
 		}
-		// Add HDR10 color metadata to preserve HDR signaling
+		// Add color metadata to preserve HDR signaling, keeping the source transfer
 		outputArgs = append(outputArgs,
 			"-color_primaries", "bt2020",
-			"-color_trc", "smpte2084",
+			"-color_trc", HDRTransfer(tonemap.Transfer),
 			"-colorspace", "bt2020nc",
 		)
+		outputArgs = hdrEncoderArgs(config.encoder, HDRTransfer(tonemap.Transfer), tonemap.Metadata, outputArgs)
 	}
 
 	// Add stream mapping and handle audio/subtitles based on output format
 	// Use explicit stream selection to skip attached pictures (cover art)
 	// that cause hardware encoders to fail (issue #40)
-	outputArgs = append(outputArgs,
-		"-map", "0:v:0", // First video stream only
-		"-map", "0:a?",  // All audio streams (optional)
-	)
+	outputArgs = append(outputArgs, "-map", "0:v:0") // First video stream only
 
-	if outputFormat == "mp4" {
-		// MP4: Transcode audio to AAC for web compatibility, strip subtitles (PGS breaks MP4)
-		outputArgs = append(outputArgs,
-			"-c:a", "aac",
-			"-b:a", "192k",
-			"-ac", "2", // Stereo for wide compatibility
-			"-sn",      // Strip subtitles
-		)
+	if audio != nil {
+		// Audio policy: map and encode each planned track individually
+		outputArgs = append(outputArgs, audio.args()...)
 	} else {
-		// MKV: Copy audio, handle subtitles based on subtitleIndices
-		outputArgs = append(outputArgs, "-c:a", "copy")
+		outputArgs = append(outputArgs, "-map", "0:a?") // All audio streams (optional)
+	}
+
+	if outputFormat == "mp4" || outputFormat == "webm" {
+		// MP4: Transcode audio to AAC for web compatibility
+		// WebM: Only Opus/Vorbis allowed, transcode to Opus
+		if audio == nil {
+			audioCodec, audioBitrate := "aac", "192k"
+			if outputFormat == "webm" {
+				audioCodec, audioBitrate = "libopus", "128k"
+			}
+			outputArgs = append(outputArgs,
+				"-c:a", audioCodec,
+				"-b:a", audioBitrate,
+				"-ac", "2", // Stereo for wide compatibility
+			)
+		}
+
+		// Subtitles: only text streams can be converted to mov_text/WebVTT (PGS breaks MP4/WebM).
+		// nil means subtitles weren't probed, so strip them to be safe.
+		if len(subtitleIndices) == 0 {
+			outputArgs = append(outputArgs, "-sn") // Strip subtitles
+		} else {
+			subtitleCodec := "mov_text"
+			if outputFormat == "webm" {
+				subtitleCodec = "webvtt"
+			}
+			for _, idx := range subtitleIndices {
+				outputArgs = append(outputArgs, "-map", fmt.Sprintf("0:%d?", idx))
+			}
+			outputArgs = append(outputArgs, "-c:s", subtitleCodec)
+		}
+	} else {
+		// MKV: Copy audio (unless planned), handle subtitles based on subtitleIndices
+		if audio == nil {
+			outputArgs = append(outputArgs, "-c:a", "copy")
+		}
 
 		switch {
 		case subtitleIndices == nil:
@@ -635,10 +847,11 @@ func BuildSampleEncodeArgs(preset *Preset, sourceWidth, sourceHeight int,
 	// We pass modifierOverride as qualityMod. For bitrate-based encoders (VideoToolbox),
 	// BuildPresetArgs uses a 10Mbps reference when sourceBitrate=0 and applies the modifier.
 	// When modifierOverride > 0, we also replace -b:v below for explicit control.
-	// Pass nil for subtitleIndices (samples don't use subtitles anyway - we strip them below)
+	// Pass nil for subtitleIndices and audio (samples strip audio/subtitles below)
+	// Pass nil for crop and no scan type - VMAF compares samples against the untouched reference
 	// Pass nil for tonemap - samples stay in native format, tonemapping happens in VMAF scoring
 	inputArgs, outputArgs = BuildPresetArgs(preset, 0, sourceWidth, sourceHeight,
-		qualityOverride, qualityOverride, modifierOverride, softwareDecode, "mkv", nil, nil)
+		qualityOverride, modifierOverride, softwareDecode, "mkv", nil, nil, nil, nil, ScanUnknown)
 
 	// Remove audio/subtitle mapping and replace with video-only
 	filteredArgs := make([]string, 0, len(outputArgs))
@@ -705,34 +918,95 @@ func BuildSampleEncodeArgs(preset *Preset, sourceWidth, sourceHeight int,
 	return inputArgs, filteredArgs
 }
 
+// presetDefinition describes a preset before an encoder has been chosen.
+// Built-in and user-defined presets share this shape.
+type presetDefinition struct {
+	ID            string
+	Name          string
+	Description   string
+	Codec         Codec
+	MaxHeight     int
+	IsSmartShrink bool
+	Quality       int
+	ExtraArgs     []string
+	IsCustom      bool
+	Audio         *AudioPolicy
+	Subtitles     *SubtitlePolicy
+	AutoCrop      bool
+	TargetSize    int64
+	TargetBitrate int64
+}
+
+// presetDefinitions returns BasePresets followed by any user-defined presets.
+func presetDefinitions() []presetDefinition {
+	defs := make([]presetDefinition, 0, len(BasePresets)+len(userPresets))
+	for _, base := range BasePresets {
+		defs = append(defs, presetDefinition{
+			ID:            base.ID,
+			Name:          base.Name,
+			Description:   base.Description,
+			Codec:         base.Codec,
+			MaxHeight:     base.MaxHeight,
+			IsSmartShrink: base.IsSmartShrink,
+		})
+	}
+	for _, up := range userPresets {
+		defs = append(defs, up.definition())
+	}
+	return defs
+}
+
+// newPreset builds a Preset from a definition for the given encoder.
+func newPreset(def presetDefinition, encoder HWAccel) *Preset {
+	// Add HW/SW suffix to name
+	suffix := " [SW]"
+	if encoder != HWAccelNone {
+		suffix = " [HW]"
+	}
+
+	return &Preset{
+		ID:            def.ID,
+		Name:          def.Name + suffix,
+		Description:   def.Description,
+		Encoder:       encoder,
+		Codec:         def.Codec,
+		MaxHeight:     def.MaxHeight,
+		IsSmartShrink: def.IsSmartShrink,
+		Quality:       def.Quality,
+		ExtraArgs:     def.ExtraArgs,
+		IsCustom:      def.IsCustom,
+		Audio:         def.Audio,
+		Subtitles:     def.Subtitles,
+		AutoCrop:      def.AutoCrop,
+		TargetSize:    def.TargetSize,
+		TargetBitrate: def.TargetBitrate,
+	}
+}
+
 // GeneratePresets creates presets using the best available encoder for each codec
 func GeneratePresets() map[string]*Preset {
 	presets := make(map[string]*Preset)
 
-	for _, base := range BasePresets {
+	for _, def := range presetDefinitions() {
 		// Skip SmartShrink presets if VMAF not available
-		if base.IsSmartShrink && !vmaf.IsAvailable() {
+		if def.IsSmartShrink && !vmaf.IsAvailable() {
 			continue
 		}
 
 		// Get the best available encoder for this preset's target codec
-		bestEncoder := GetBestEncoderForCodec(base.Codec)
-
-		// Add HW/SW suffix to name
-		suffix := " [SW]"
-		if bestEncoder.Accel != HWAccelNone {
-			suffix = " [HW]"
+		bestEncoder := GetBestEncoderForCodec(def.Codec)
+
+		// A user preset's quality was checked against the software encoder;
+		// hardware encoders can use a different scale
+		if def.Quality > 0 {
+			if err := ValidateQuality(bestEncoder.Accel, def.Codec, def.Quality); err != nil {
+				logger.Warn("Preset quality not supported by encoder, using encoder default",
+					"preset", def.ID, "encoder", bestEncoder.Accel, "error", err)
+				def.Quality = 0
+			}
 		}
 
-		presets[base.ID] = &Preset{
-			ID:            base.ID,
-			Name:          base.Name + suffix,
-			Description:   base.Description,
-			Encoder:       bestEncoder.Accel,
-			Codec:         base.Codec,
-			MaxHeight:     base.MaxHeight,
-			IsSmartShrink: base.IsSmartShrink,
-		}
+		presets[def.ID] = newPreset(def, bestEncoder.Accel)
 	}
 
 	return presets
@@ -760,21 +1034,13 @@ func GetPreset(id string) *Preset {
 
 // getSoftwarePreset returns a software-only preset (fallback)
 func getSoftwarePreset(id string) *Preset {
-	for _, base := range BasePresets {
-		if base.ID == id {
+	for _, def := range presetDefinitions() {
+		if def.ID == id {
 			// Skip SmartShrink presets if VMAF not available
-			if base.IsSmartShrink && !vmaf.IsAvailable() {
+			if def.IsSmartShrink && !vmaf.IsAvailable() {
 				return nil
 			}
-			return &Preset{
-				ID:            base.ID,
-				Name:          base.Name + " [SW]",
-				Description:   base.Description,
-				Encoder:       HWAccelNone,
-				Codec:         base.Codec,
-				MaxHeight:     base.MaxHeight,
-				IsSmartShrink: base.IsSmartShrink,
-			}
+			return newPreset(def, HWAccelNone)
 		}
 	}
 	return nil
@@ -817,32 +1083,25 @@ func BuildTonemapFilter(algorithm string) (filter string, requiresSoftwareDecode
 }
 
 // ListPresets returns all available presets
+// Built-in presets come first, followed by user-defined presets in file order.
 func ListPresets() []*Preset {
 	if !presetsInitialized {
 		// Return software-only presets as fallback
 		var presets []*Preset
-		for _, base := range BasePresets {
+		for _, def := range presetDefinitions() {
 			// Skip SmartShrink presets if VMAF not available
-			if base.IsSmartShrink && !vmaf.IsAvailable() {
+			if def.IsSmartShrink && !vmaf.IsAvailable() {
 				continue
 			}
-			presets = append(presets, &Preset{
-				ID:            base.ID,
-				Name:          base.Name + " [SW]",
-				Description:   base.Description,
-				Encoder:       HWAccelNone,
-				Codec:         base.Codec,
-				MaxHeight:     base.MaxHeight,
-				IsSmartShrink: base.IsSmartShrink,
-			})
+			presets = append(presets, newPreset(def, HWAccelNone))
 		}
 		return presets
 	}
 
 	// Return presets in order
 	var result []*Preset
-	for _, base := range BasePresets {
-		if preset, ok := generatedPresets[base.ID]; ok {
+	for _, def := range presetDefinitions() {
+		if preset, ok := generatedPresets[def.ID]; ok {
 			result = append(result, preset)
 		}
 	}
@@ -1,6 +1,7 @@
 package ffmpeg
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -18,7 +19,7 @@ func TestBuildPresetArgsDynamicBitrate(t *testing.T) {
 		Codec:   CodecHEVC,
 	}
 
-	inputArgs, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0, 0, false, "mkv", nil, nil)
+	inputArgs, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 
 	// Should have hwaccel input args
 	if len(inputArgs) == 0 {
@@ -59,7 +60,7 @@ func TestBuildPresetArgsDynamicBitrateAV1(t *testing.T) {
 		Codec:   CodecAV1,
 	}
 
-	inputArgs, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0, 0, false, "mkv", nil, nil)
+	inputArgs, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 
 	// Should have hwaccel input args
 	if len(inputArgs) == 0 {
@@ -91,7 +92,7 @@ func TestBuildPresetArgsQualityModOverride(t *testing.T) {
 	}
 
 	// With qualityMod=0.5, target should be 10000 * 0.5 = 5000k
-	_, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0, 0.5, false, "mkv", nil, nil)
+	_, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0.5, false, "mkv", nil, nil, nil, nil, "")
 
 	for i, arg := range outputArgs {
 		if arg == "-b:v" && i+1 < len(outputArgs) {
@@ -118,7 +119,7 @@ func TestBuildPresetArgsQualityModIgnoredForCRFEncoders(t *testing.T) {
 	}
 
 	// qualityMod should be ignored for NVENC (CRF-based)
-	_, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0, 0.5, false, "mkv", nil, nil)
+	_, outputArgs := BuildPresetArgs(preset, sourceBitrate, 0, 0, 0, 0.5, false, "mkv", nil, nil, nil, nil, "")
 
 	// Should use -cq (constant quality) not -b:v
 	for i, arg := range outputArgs {
@@ -131,6 +132,19 @@ func TestBuildPresetArgsQualityModIgnoredForCRFEncoders(t *testing.T) {
 	}
 }
 
+func TestBuildSampleEncodeArgsQualityAnyCodec(t *testing.T) {
+	// SmartShrink's search moves the sample quality, so every codec must honor it
+	for _, codec := range []Codec{CodecHEVC, CodecAV1, CodecH264, CodecVP9} {
+		preset := &Preset{ID: "test-sample", Encoder: HWAccelNone, Codec: codec, Quality: 30}
+		for _, quality := range []int{22, 34} {
+			_, outputArgs := BuildSampleEncodeArgs(preset, 1920, 1080, quality, 0, true)
+			if !strings.Contains(strings.Join(outputArgs, " "), fmt.Sprintf("-crf %d", quality)) {
+				t.Errorf("%s: expected -crf %d, got %v", codec, quality, outputArgs)
+			}
+		}
+	}
+}
+
 func TestBuildPresetArgsBitrateConstraints(t *testing.T) {
 	// Test min/max bitrate constraints
 
@@ -143,7 +157,7 @@ func TestBuildPresetArgsBitrateConstraints(t *testing.T) {
 		Codec:   CodecHEVC,
 	}
 
-	_, outputArgs := BuildPresetArgs(presetLow, lowBitrate, 0, 0, 0, 0, 0, false, "mkv", nil, nil)
+	_, outputArgs := BuildPresetArgs(presetLow, lowBitrate, 0, 0, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 	for i, arg := range outputArgs {
 		if arg == "-b:v" && i+1 < len(outputArgs) {
 			bitrate := outputArgs[i+1]
@@ -164,7 +178,7 @@ func TestBuildPresetArgsBitrateConstraints(t *testing.T) {
 		Codec:   CodecHEVC,
 	}
 
-	_, outputArgs = BuildPresetArgs(presetHigh, highBitrate, 0, 0, 0, 0, 0, false, "mkv", nil, nil)
+	_, outputArgs = BuildPresetArgs(presetHigh, highBitrate, 0, 0, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 	for i, arg := range outputArgs {
 		if arg == "-b:v" && i+1 < len(outputArgs) {
 			bitrate := outputArgs[i+1]
@@ -187,7 +201,7 @@ func TestBuildPresetArgsNonBitrateEncoder(t *testing.T) {
 		Codec:   CodecHEVC,
 	}
 
-	inputArgs, outputArgs := BuildPresetArgs(presetSoftware, sourceBitrate, 0, 0, 0, 0, 0, false, "mkv", nil, nil)
+	inputArgs, outputArgs := BuildPresetArgs(presetSoftware, sourceBitrate, 0, 0, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 
 	// Software encoder should have no hwaccel input args
 	if len(inputArgs) != 0 {
@@ -228,7 +242,7 @@ func TestBuildPresetArgsZeroBitrate(t *testing.T) {
 		Codec:   CodecHEVC,
 	}
 
-	inputArgs, outputArgs := BuildPresetArgs(presetVT, 0, 0, 0, 0, 0, 0, false, "mkv", nil, nil)
+	inputArgs, outputArgs := BuildPresetArgs(presetVT, 0, 0, 0, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 
 	// Should still have hwaccel input args
 	if len(inputArgs) == 0 {
@@ -275,6 +289,34 @@ func TestGetEncoderDefaults(t *testing.T) {
 	}
 }
 
+func TestValidateQuality(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder HWAccel
+		codec   Codec
+		quality int
+		wantErr bool
+	}{
+		{"x265 in range", HWAccelNone, CodecHEVC, 26, false},
+		{"x265 above range", HWAccelNone, CodecHEVC, 52, true},
+		{"svt-av1 in range", HWAccelNone, CodecAV1, 45, false},
+		{"nvenc av1 above range", HWAccelNVENC, CodecAV1, 45, true},
+		{"videotoolbox uses software range", HWAccelVideoToolbox, CodecHEVC, 30, false},
+		{"vaapi vp9 quantizer scale", HWAccelVAAPI, CodecVP9, 120, false},
+		{"vaapi vp9 crf value", HWAccelVAAPI, CodecVP9, 30, true},
+		{"unknown codec", HWAccelNone, "mpeg2", 20, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateQuality(tt.encoder, tt.codec, tt.quality)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateQuality(%s, %s, %d) = %v, wantErr %v", tt.encoder, tt.codec, tt.quality, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestQSVPresetFilterChain(t *testing.T) {
 	// Test that QSV presets have the correct filter chain for software decode fallback
 	// The filter chain must use "format=nv12|qsv" to accept either CPU or GPU frames
@@ -294,7 +336,7 @@ func TestQSVPresetFilterChain(t *testing.T) {
 				Codec:   tt.codec,
 			}
 
-			_, outputArgs := BuildPresetArgs(preset, 1000000, 1920, 1080, 0, 0, 0, false, "mkv", nil, nil)
+			_, outputArgs := BuildPresetArgs(preset, 1000000, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 
 			// Find -vf argument
 			for i, arg := range outputArgs {
@@ -324,10 +366,10 @@ func TestBuildPresetArgsSoftwareDecode(t *testing.T) {
 	}
 
 	// Hardware decode (softwareDecode=false)
-	inputArgsHW, _ := BuildPresetArgs(preset, 1000000, 1920, 1080, 0, 0, 0, false, "mkv", nil, nil)
+	inputArgsHW, _ := BuildPresetArgs(preset, 1000000, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, nil, "")
 
 	// Software decode (softwareDecode=true)
-	inputArgsSW, outputArgsSW := BuildPresetArgs(preset, 1000000, 1920, 1080, 0, 0, 0, true, "mkv", nil, nil)
+	inputArgsSW, outputArgsSW := BuildPresetArgs(preset, 1000000, 1920, 1080, 0, 0, true, "mkv", nil, nil, nil, nil, "")
 
 	// Hardware decode should have -hwaccel
 	hasHwaccelHW := false
@@ -438,7 +480,7 @@ func TestBuildPresetArgsHDRPermutations(t *testing.T) {
 						}
 					}
 
-					_, outputArgs := BuildPresetArgs(preset, 10000000, 1920, 1080, 0, 0, 0, false, "mkv", tonemap, nil)
+					_, outputArgs := BuildPresetArgs(preset, 10000000, 1920, 1080, 0, 0, false, "mkv", tonemap, nil, nil, nil, "")
 
 					outputStr := strings.Join(outputArgs, " ")
 
@@ -548,7 +590,7 @@ func TestBuildPresetArgsHDRFilters(t *testing.T) {
 				Algorithm:     "hable",
 			}
 
-			inputArgs, outputArgs := BuildPresetArgs(preset, 10000000, 1920, 1080, 0, 0, 0, false, "mkv", tonemap, nil)
+			inputArgs, outputArgs := BuildPresetArgs(preset, 10000000, 1920, 1080, 0, 0, false, "mkv", tonemap, nil, nil, nil, "")
 			allArgs := strings.Join(append(inputArgs, outputArgs...), " ")
 
 			// Note: Filter availability depends on system, so we just log
@@ -759,17 +801,38 @@ func TestBuildPresetArgsSubtitleMapping(t *testing.T) {
 			wantNotContains: []string{"0:s?"},
 		},
 		{
-			name:            "MP4 always strips subtitles regardless of indices",
+			name:            "MP4 converts text subtitles to mov_text",
 			subtitleIndices: []int{2, 4},
 			outputFormat:    "mp4",
+			wantContains:    []string{"0:2?", "0:4?", "-c:s mov_text"},
+			wantNotContains: []string{"-sn", "0:s?"},
+		},
+		{
+			name:            "MP4 strips subtitles when none are compatible",
+			subtitleIndices: []int{},
+			outputFormat:    "mp4",
 			wantContains:    []string{"-sn"},
-			wantNotContains: []string{"0:2", "0:4", "-c:s"},
+			wantNotContains: []string{"-c:s"},
+		},
+		{
+			name:            "MP4 strips subtitles when not probed",
+			subtitleIndices: nil,
+			outputFormat:    "mp4",
+			wantContains:    []string{"-sn"},
+			wantNotContains: []string{"-c:s", "0:s?"},
+		},
+		{
+			name:            "WebM converts text subtitles to WebVTT and audio to Opus",
+			subtitleIndices: []int{3},
+			outputFormat:    "webm",
+			wantContains:    []string{"0:3?", "-c:s webvtt", "-c:a libopus -b:a 128k -ac 2"},
+			wantNotContains: []string{"-sn", "mov_text", "-c:a copy"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, 0, false, tt.outputFormat, nil, tt.subtitleIndices)
+			_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, tt.outputFormat, nil, tt.subtitleIndices, nil, nil, "")
 			argsStr := strings.Join(outputArgs, " ")
 
 			for _, want := range tt.wantContains {
@@ -15,8 +15,13 @@ import (
 // SubtitleStream contains metadata about a subtitle stream.
 // Index is the absolute stream index (used with -map 0:N), not subtitle-relative.
 type SubtitleStream struct {
-	Index     int    // Absolute stream index in the file (for -map 0:N)
-	CodecName string // e.g., "mov_text", "subrip", "hdmv_pgs_subtitle"
+	Index           int    // Absolute stream index in the file (for -map 0:N)
+	CodecName       string // e.g., "mov_text", "subrip", "hdmv_pgs_subtitle"
+	Language        string // ISO 639-2 tag, e.g., "eng"
+	Title           string // Track title from container tags
+	Default         bool   // Default disposition
+	Forced          bool   // Forced disposition or "forced" in title
+	HearingImpaired bool   // SDH: hearing_impaired disposition or "SDH" in title
 }
 
 // ProbeResult contains metadata about a video file
@@ -24,6 +29,7 @@ type ProbeResult struct {
 	Path        string        `json:"path"`
 	Size        int64         `json:"size"`
 	Inode       uint64        `json:"-"` // Cache validation signature (not serialized to API)
+	ModTime     time.Time     `json:"-"` // With Size, identifies the file in the processed-file ledger
 	Duration    time.Duration `json:"duration"`
 	Format      string        `json:"format"`
 	VideoCodec  string        `json:"video_codec"`
@@ -38,16 +44,24 @@ type ProbeResult struct {
 	PixelFormat string        `json:"pix_fmt"`     // e.g., "yuv420p", "yuv420p10le"
 	BitDepth    int           `json:"bit_depth"`   // 8, 10, 12
 	// HDR metadata
-	ColorTransfer  string `json:"color_transfer"`  // e.g., "smpte2084" (HDR10), "arib-std-b67" (HLG), "bt709"
-	ColorPrimaries string `json:"color_primaries"` // e.g., "bt2020", "bt709"
-	ColorSpace     string `json:"color_space"`     // e.g., "bt2020nc", "bt709"
-	IsHDR          bool   `json:"is_hdr"`          // true if HDR content detected
+	ColorTransfer  string       `json:"color_transfer"`       // e.g., "smpte2084" (HDR10), "arib-std-b67" (HLG), "bt709"
+	ColorPrimaries string       `json:"color_primaries"`      // e.g., "bt2020", "bt709"
+	ColorSpace     string       `json:"color_space"`          // e.g., "bt2020nc", "bt709"
+	IsHDR          bool         `json:"is_hdr"`               // true if HDR content detected
+	HDRFormat      string       `json:"hdr_format,omitempty"` // "HDR10", "HLG", "HDR10+", "Dolby Vision"
+	HDR            *HDRMetadata `json:"hdr,omitempty"`        // Side data (DV profile, HDR10+, mastering display); nil for SDR
+	// Scan type (interlacing)
+	FieldOrder string `json:"field_order,omitempty"` // e.g., "progressive", "tt", "bb" (container/codec flag)
+	Interlaced bool   `json:"interlaced"`            // true if field_order marks the stream as interlaced
+	// Per-stream audio metadata (drives AudioPolicy)
+	AudioStreams []AudioStream `json:"audio_streams,omitempty"`
 }
 
 // ffprobeOutput represents the JSON output from ffprobe
 type ffprobeOutput struct {
 	Format  ffprobeFormat   `json:"format"`
 	Streams []ffprobeStream `json:"streams"`
+	Frames  []ffprobeFrame  `json:"frames"` // Only with -show_frames (HDR side data)
 }
 
 type ffprobeFormat struct {
@@ -69,10 +83,33 @@ type ffprobeStream struct {
 	Profile          string `json:"profile"`
 	PixelFormat      string `json:"pix_fmt"`
 	BitsPerRawSample string `json:"bits_per_raw_sample"`
+	FieldOrder       string `json:"field_order"`
 	// Color metadata for HDR detection
 	ColorTransfer  string `json:"color_transfer"`
 	ColorPrimaries string `json:"color_primaries"`
 	ColorSpace     string `json:"color_space"`
+	// Stream side data (Dolby Vision configuration record, mastering display)
+	SideDataList []ffprobeSideData `json:"side_data_list"`
+	// Audio metadata
+	Channels      int    `json:"channels"`
+	ChannelLayout string `json:"channel_layout"`
+	BitRate       string `json:"bit_rate"`
+	// Container tags and dispositions (language, title, default/forced/etc.)
+	Tags        ffprobeTags        `json:"tags"`
+	Disposition ffprobeDisposition `json:"disposition"`
+}
+
+type ffprobeTags struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+}
+
+type ffprobeDisposition struct {
+	Default         int `json:"default"`
+	Forced          int `json:"forced"`
+	Comment         int `json:"comment"`
+	HearingImpaired int `json:"hearing_impaired"`
+	VisualImpaired  int `json:"visual_impaired"`
 }
 
 // Prober wraps ffprobe functionality
@@ -126,6 +163,7 @@ func (p *Prober) Probe(ctx context.Context, path string) (*ProbeResult, error) {
 	}
 
 	// Parse stream-level metadata
+	hdr := &HDRMetadata{}
 	for i := range probeOutput.Streams {
 		stream := &probeOutput.Streams[i]
 		switch stream.CodecType {
@@ -154,19 +192,42 @@ func (p *Prober) Probe(ctx context.Context, path string) (*ProbeResult, error) {
 				result.ColorPrimaries = stream.ColorPrimaries
 				result.ColorSpace = stream.ColorSpace
 				result.IsHDR = detectHDR(stream.ColorTransfer, stream.ColorPrimaries, result.BitDepth)
+				// Dolby Vision profile 5 often has no usable color tags
+				hdr.applySideData(stream.SideDataList)
+				if hdr.DolbyVisionProfile > 0 {
+					result.IsHDR = true
+				}
+				// Interlacing flag (confirmed with idet before encoding)
+				result.FieldOrder = stream.FieldOrder
+				result.Interlaced = IsInterlacedFieldOrder(stream.FieldOrder)
 			}
 		case "audio":
 			if result.AudioCodec == "" { // Take first audio stream
 				result.AudioCodec = stream.CodecName
 			}
+			result.AudioStreams = append(result.AudioStreams, newAudioStream(stream))
 		}
 	}
 
-	// Capture inode for cache validation (detects file replacement)
-	// We use inode + size instead of mtime because mtime is deliberately
-	// preserved after transcoding (via os.Chtimes)
+	// HDR sources: HDR10+ and frame-level static metadata are only in frame
+	// side data, so read the first frame (ProbeHDR, a one-frame pass). SDR
+	// files never pay for it, and callers cache probe results (the browse
+	// cache and library index), so it runs once per file. If it fails, the
+	// stream side data (Dolby Vision, mastering display) is still reported.
+	if result.IsHDR {
+		result.HDR = hdr
+		if meta, err := p.ProbeHDR(ctx, path); err == nil {
+			result.HDR = meta
+		}
+		result.HDRFormat = HDRFormat(result.IsHDR, result.ColorTransfer, result.HDR)
+	}
+
+	// Capture inode and mtime for cache validation (detects file replacement)
+	// Inode + size matter most: mtime alone isn't enough because it is
+	// deliberately preserved after transcoding (via os.Chtimes)
 	if info, err := os.Stat(path); err == nil {
 		result.Size = info.Size() // Use stat size (more reliable than ffprobe)
+		result.ModTime = info.ModTime()
 		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
 			result.Inode = stat.Ino
 		}
@@ -203,15 +264,76 @@ func (p *Prober) ProbeSubtitles(ctx context.Context, path string) ([]SubtitleStr
 	// No need to filter by CodecType (which could be empty in some ffprobe versions).
 	var subtitles []SubtitleStream
 	for i := range probeOutput.Streams {
-		subtitles = append(subtitles, SubtitleStream{
-			Index:     probeOutput.Streams[i].Index,
-			CodecName: probeOutput.Streams[i].CodecName,
-		})
+		subtitles = append(subtitles, newSubtitleStream(&probeOutput.Streams[i]))
 	}
 
 	return subtitles, nil
 }
 
+// newSubtitleStream converts an ffprobe stream into SubtitleStream metadata
+func newSubtitleStream(stream *ffprobeStream) SubtitleStream {
+	return SubtitleStream{
+		Index:           stream.Index,
+		CodecName:       stream.CodecName,
+		Language:        stream.Tags.Language,
+		Title:           stream.Tags.Title,
+		Default:         stream.Disposition.Default == 1,
+		Forced:          stream.Disposition.Forced == 1 || isForcedTitle(stream.Tags.Title),
+		HearingImpaired: stream.Disposition.HearingImpaired == 1 || isSDHTitle(stream.Tags.Title),
+	}
+}
+
+// ProbeAudio returns audio stream info for a file.
+// Returns nil slice if no audio streams exist.
+func (p *Prober) ProbeAudio(ctx context.Context, path string) ([]AudioStream, error) {
+	cmd := exec.CommandContext(ctx, p.ffprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_streams",
+		"-select_streams", "a", // Only audio streams
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("ffprobe failed: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probeOutput ffprobeOutput
+	if err := json.Unmarshal(output, &probeOutput); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	var streams []AudioStream
+	for i := range probeOutput.Streams {
+		streams = append(streams, newAudioStream(&probeOutput.Streams[i]))
+	}
+
+	return streams, nil
+}
+
+// newAudioStream converts an ffprobe stream into AudioStream metadata
+func newAudioStream(stream *ffprobeStream) AudioStream {
+	as := AudioStream{
+		Index:         stream.Index,
+		CodecName:     stream.CodecName,
+		Profile:       stream.Profile,
+		Channels:      stream.Channels,
+		ChannelLayout: stream.ChannelLayout,
+		Language:      stream.Tags.Language,
+		Title:         stream.Tags.Title,
+		Default:       stream.Disposition.Default == 1,
+		Commentary:    stream.Disposition.Comment == 1 || isCommentaryTitle(stream.Tags.Title),
+	}
+	if stream.BitRate != "" {
+		as.Bitrate, _ = strconv.ParseInt(stream.BitRate, 10, 64)
+	}
+	return as
+}
+
 // detectHDR determines if video is HDR based on color metadata.
 // Primary detection: smpte2084 (PQ) transfer = HDR10
 // Fallback heuristic: 10-bit + bt2020 primaries = likely HDR
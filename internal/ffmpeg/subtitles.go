@@ -1,6 +1,13 @@
 package ffmpeg
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
 
 // mkvCompatibleCodecs lists subtitle codecs that can be muxed to MKV.
 // Based on FFmpeg's matroska.c ff_mkv_codec_tags mapping.
@@ -57,3 +64,190 @@ func FilterMKVCompatible(streams []SubtitleStream) (compatibleIndices []int, dro
 	}
 	return compatibleIndices, droppedCodecs
 }
+
+// mp4TextCodecs lists text-based subtitle codecs that can be converted to mov_text.
+// Image-based codecs (PGS, VobSub, DVB) can't be stored in MP4 at all.
+var mp4TextCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"text":     true,
+	"mov_text": true,
+}
+
+// sidecarFormats maps image subtitle codecs to the FFmpeg muxer and file
+// extension used when extracting them as sidecar files.
+var sidecarFormats = map[string]struct{ muxer, ext string }{
+	"hdmv_pgs_subtitle": {"sup", ".sup"},
+	"dvd_subtitle":      {"matroska", ".mks"},
+	"dvb_subtitle":      {"matroska", ".mks"},
+}
+
+// IsMP4TextSubtitle returns true if the subtitle codec can be converted to mov_text.
+func IsMP4TextSubtitle(codecName string) bool {
+	return mp4TextCodecs[strings.ToLower(strings.TrimSpace(codecName))]
+}
+
+// FilterMP4Compatible partitions subtitle streams for MP4 output.
+// Returns indices of text streams (converted to mov_text) and the streams
+// that can't be stored in MP4 (image-based or unknown codecs).
+//
+// Return value semantics match FilterMKVCompatible:
+//   - nil input → nil output
+//   - non-nil input → non-nil textIndices (possibly empty)
+func FilterMP4Compatible(streams []SubtitleStream) (textIndices []int, unsupported []SubtitleStream) {
+	if streams == nil {
+		return nil, nil
+	}
+
+	textIndices = make([]int, 0, len(streams))
+	for _, s := range streams {
+		if IsMP4TextSubtitle(s.CodecName) {
+			textIndices = append(textIndices, s.Index)
+			continue
+		}
+		unsupported = append(unsupported, s)
+	}
+	return textIndices, unsupported
+}
+
+// CanExtractSubtitle returns true if the subtitle codec can be written as a sidecar file.
+func CanExtractSubtitle(codecName string) bool {
+	_, ok := sidecarFormats[strings.ToLower(strings.TrimSpace(codecName))]
+	return ok
+}
+
+// SidecarSubtitlePath returns the sidecar path for a subtitle stream, next to
+// the video at basePath (path without extension), e.g. "Movie.eng.forced.sup".
+// The stream index is added when another stream already uses the same name.
+func SidecarSubtitlePath(basePath string, s SubtitleStream, used map[string]bool) string {
+	format := sidecarFormats[strings.ToLower(s.CodecName)]
+	suffix := ""
+	if s.Language != "" {
+		suffix += "." + strings.ToLower(s.Language)
+	}
+	if s.Forced {
+		suffix += ".forced"
+	}
+	if s.HearingImpaired {
+		suffix += ".sdh"
+	}
+
+	path := basePath + suffix + format.ext
+	if used[path] {
+		path = fmt.Sprintf("%s.%d%s%s", basePath, s.Index, suffix, format.ext)
+	}
+	used[path] = true
+	return path
+}
+
+// ExtractSubtitles copies image subtitle streams from inputPath into sidecar
+// files next to it (same name, see SidecarSubtitlePath). Streams that can't be
+// extracted are skipped. On error, sidecars written so far are removed.
+func (t *Transcoder) ExtractSubtitles(ctx context.Context, inputPath string, streams []SubtitleStream) ([]string, error) {
+	basePath := strings.TrimSuffix(inputPath, filepath.Ext(inputPath))
+	used := make(map[string]bool)
+
+	var written []string
+	for _, s := range streams {
+		if !CanExtractSubtitle(s.CodecName) {
+			continue
+		}
+		outputPath := SidecarSubtitlePath(basePath, s, used)
+		format := sidecarFormats[strings.ToLower(s.CodecName)]
+
+		cmd := exec.CommandContext(ctx, t.ffmpegPath,
+			"-y",
+			"-v", "error",
+			"-i", inputPath,
+			"-map", fmt.Sprintf("0:%d", s.Index),
+			"-c:s", "copy",
+			"-f", format.muxer,
+			outputPath,
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			os.Remove(outputPath)
+			for _, path := range written {
+				os.Remove(path)
+			}
+			return nil, fmt.Errorf("failed to extract subtitle stream %d: %w: %s", s.Index, err, strings.TrimSpace(string(output)))
+		}
+		written = append(written, outputPath)
+	}
+	return written, nil
+}
+
+// SubtitlePolicy defines per-preset subtitle stream rules.
+// Applied before container compatibility filtering. A nil policy keeps all streams.
+type SubtitlePolicy struct {
+	// Languages to keep (ISO 639-2, e.g. "eng", "jpn"). Empty = keep all.
+	// Untagged streams ("und" or no language) are always kept.
+	Languages []string `yaml:"languages" json:"languages,omitempty"`
+
+	// KeepForced keeps forced subtitles regardless of language
+	KeepForced bool `yaml:"keep_forced" json:"keep_forced,omitempty"`
+
+	// DropSDH removes SDH / hearing-impaired subtitles (forced streams are never dropped as SDH)
+	DropSDH bool `yaml:"drop_sdh" json:"drop_sdh,omitempty"`
+}
+
+// isSDHTitle returns true if a track title marks it as SDH / hearing-impaired
+func isSDHTitle(title string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == '[' || r == ']' || r == '-' || r == ','
+	}) {
+		if word == "sdh" || word == "cc" {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeSubtitleStream returns a short description for logs and job notes,
+// e.g. "#3 hdmv_pgs_subtitle (eng)"
+func DescribeSubtitleStream(s SubtitleStream) string {
+	desc := fmt.Sprintf("#%d %s", s.Index, s.CodecName)
+	if s.Language != "" {
+		desc += " (" + s.Language + ")"
+	}
+	return desc
+}
+
+// isForcedTitle returns true if a track title marks it as forced
+func isForcedTitle(title string) bool {
+	return strings.Contains(strings.ToLower(title), "forced")
+}
+
+// FilterSubtitles applies a subtitle policy to the source streams.
+// Returns the kept streams and descriptions of the dropped ones (for logging).
+//
+// Return value semantics match FilterMKVCompatible:
+//   - nil policy or nil input → input returned unchanged
+//   - otherwise → non-nil output (possibly empty slice if all were dropped)
+func FilterSubtitles(streams []SubtitleStream, policy *SubtitlePolicy) (kept []SubtitleStream, dropped []string) {
+	if policy == nil || streams == nil {
+		return streams, nil
+	}
+
+	kept = make([]SubtitleStream, 0, len(streams))
+	for _, s := range streams {
+		var reason string
+		switch {
+		case s.Forced && policy.KeepForced:
+			// Forced subtitles (foreign dialogue, signs) are always kept
+		case policy.DropSDH && s.HearingImpaired && !s.Forced:
+			reason = "SDH"
+		case !matchesLanguages(policy.Languages, s.Language):
+			reason = "language not kept"
+		}
+
+		if reason != "" {
+			dropped = append(dropped, DescribeSubtitleStream(s)+": "+reason)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept, dropped
+}
@@ -1,6 +1,7 @@
 package ffmpeg
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -178,3 +179,140 @@ func TestFilterMKVCompatible_DroppedCodecContents(t *testing.T) {
 		t.Errorf("dropped[1] = %q, want %q", dropped[1], "eia_608")
 	}
 }
+
+func TestFilterSubtitles(t *testing.T) {
+	streams := []SubtitleStream{
+		{Index: 3, CodecName: "subrip", Language: "eng"},
+		{Index: 4, CodecName: "subrip", Language: "eng", Title: "English SDH", HearingImpaired: true},
+		{Index: 5, CodecName: "subrip", Language: "jpn"},
+		{Index: 6, CodecName: "hdmv_pgs_subtitle", Language: "fre"},
+		{Index: 7, CodecName: "subrip", Language: "fre", Forced: true},
+		{Index: 8, CodecName: "subrip"},
+	}
+
+	tests := []struct {
+		name        string
+		policy      *SubtitlePolicy
+		wantIndices []int
+	}{
+		{"nil policy keeps all", nil, []int{3, 4, 5, 6, 7, 8}},
+		{"languages", &SubtitlePolicy{Languages: []string{"eng", "jpn"}}, []int{3, 4, 5, 8}},
+		{"languages plus forced", &SubtitlePolicy{Languages: []string{"eng", "jpn"}, KeepForced: true}, []int{3, 4, 5, 7, 8}},
+		{"drop SDH", &SubtitlePolicy{DropSDH: true}, []int{3, 5, 6, 7, 8}},
+		{"combined", &SubtitlePolicy{Languages: []string{"eng", "jpn"}, KeepForced: true, DropSDH: true}, []int{3, 5, 7, 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := FilterSubtitles(streams, tt.policy)
+			var got []int
+			for _, s := range kept {
+				got = append(got, s.Index)
+			}
+			if len(got) != len(tt.wantIndices) {
+				t.Fatalf("got %v, want %v", got, tt.wantIndices)
+			}
+			for i := range got {
+				if got[i] != tt.wantIndices[i] {
+					t.Errorf("got %v, want %v", got, tt.wantIndices)
+					break
+				}
+			}
+			if len(dropped) != len(streams)-len(kept) {
+				t.Errorf("got %d dropped, want %d: %v", len(dropped), len(streams)-len(kept), dropped)
+			}
+		})
+	}
+}
+
+func TestFilterSubtitles_NilSemantics(t *testing.T) {
+	// nil input stays nil (worker maps all)
+	if kept, _ := FilterSubtitles(nil, &SubtitlePolicy{Languages: []string{"eng"}}); kept != nil {
+		t.Errorf("expected nil for nil input, got %v", kept)
+	}
+
+	// All dropped returns empty, not nil (worker maps none)
+	streams := []SubtitleStream{{Index: 2, CodecName: "subrip", Language: "ger"}}
+	kept, _ := FilterSubtitles(streams, &SubtitlePolicy{Languages: []string{"eng"}})
+	if kept == nil || len(kept) != 0 {
+		t.Errorf("expected empty non-nil slice, got %v", kept)
+	}
+}
+
+func TestNewSubtitleStream(t *testing.T) {
+	data := `{"streams": [
+		{"index": 3, "codec_name": "subrip", "tags": {"language": "eng", "title": "English (SDH)"}, "disposition": {"default": 1}},
+		{"index": 4, "codec_name": "hdmv_pgs_subtitle", "tags": {"language": "eng"}, "disposition": {"forced": 1}},
+		{"index": 5, "codec_name": "subrip", "tags": {"language": "eng"}, "disposition": {"hearing_impaired": 1}}
+	]}`
+
+	var out ffprobeOutput
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	sdh := newSubtitleStream(&out.Streams[0])
+	if !sdh.HearingImpaired || !sdh.Default || sdh.Forced || sdh.Language != "eng" {
+		t.Errorf("unexpected stream: %+v", sdh)
+	}
+	forced := newSubtitleStream(&out.Streams[1])
+	if !forced.Forced || forced.HearingImpaired {
+		t.Errorf("unexpected stream: %+v", forced)
+	}
+	hi := newSubtitleStream(&out.Streams[2])
+	if !hi.HearingImpaired {
+		t.Errorf("unexpected stream: %+v", hi)
+	}
+}
+
+func TestFilterMP4Compatible(t *testing.T) {
+	if indices, unsupported := FilterMP4Compatible(nil); indices != nil || unsupported != nil {
+		t.Errorf("expected nil output for nil input, got %v, %v", indices, unsupported)
+	}
+
+	streams := []SubtitleStream{
+		{Index: 2, CodecName: "subrip"},
+		{Index: 3, CodecName: "hdmv_pgs_subtitle"},
+		{Index: 4, CodecName: "ass"},
+		{Index: 5, CodecName: "dvd_subtitle"},
+	}
+	indices, unsupported := FilterMP4Compatible(streams)
+	if len(indices) != 2 || indices[0] != 2 || indices[1] != 4 {
+		t.Errorf("text indices: got %v, want [2 4]", indices)
+	}
+	if len(unsupported) != 2 || unsupported[0].Index != 3 || unsupported[1].Index != 5 {
+		t.Errorf("unsupported: got %+v", unsupported)
+	}
+
+	// All image-based: empty (not nil) indices so the caller maps none
+	indices, _ = FilterMP4Compatible([]SubtitleStream{{Index: 3, CodecName: "hdmv_pgs_subtitle"}})
+	if indices == nil || len(indices) != 0 {
+		t.Errorf("expected empty non-nil indices, got %v", indices)
+	}
+}
+
+func TestSidecarSubtitlePath(t *testing.T) {
+	used := make(map[string]bool)
+	base := "/media/Movie (2020)"
+
+	tests := []struct {
+		stream SubtitleStream
+		want   string
+	}{
+		{SubtitleStream{Index: 3, CodecName: "hdmv_pgs_subtitle", Language: "eng"}, base + ".eng.sup"},
+		{SubtitleStream{Index: 4, CodecName: "hdmv_pgs_subtitle", Language: "eng", Forced: true}, base + ".eng.forced.sup"},
+		{SubtitleStream{Index: 5, CodecName: "hdmv_pgs_subtitle", Language: "eng"}, base + ".5.eng.sup"},
+		{SubtitleStream{Index: 6, CodecName: "dvd_subtitle", Language: "FRE", HearingImpaired: true}, base + ".fre.sdh.mks"},
+		{SubtitleStream{Index: 7, CodecName: "hdmv_pgs_subtitle"}, base + ".sup"},
+	}
+
+	for _, tt := range tests {
+		if got := SidecarSubtitlePath(base, tt.stream, used); got != tt.want {
+			t.Errorf("stream %d: got %q, want %q", tt.stream.Index, got, tt.want)
+		}
+	}
+
+	if CanExtractSubtitle("subrip") || !CanExtractSubtitle("hdmv_pgs_subtitle") {
+		t.Error("unexpected CanExtractSubtitle result")
+	}
+}
@@ -0,0 +1,159 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// targetOverhead is the share of a target file size reserved for the
+// container (headers, index, subtitles) rather than audio and video
+const targetOverhead = 0.02
+
+// minTargetVideoBitrate is the lowest video bitrate a target may leave
+// after the audio budget (bits/s). Below this the output is unwatchable,
+// so the job fails instead of producing it.
+const minTargetVideoBitrate = 100_000
+
+// HasTarget returns true if the preset encodes to a target file size or
+// average bitrate instead of a quality value
+func (p *Preset) HasTarget() bool {
+	return p.TargetSize > 0 || p.TargetBitrate > 0
+}
+
+// TargetVideoBitrate returns the video bitrate in bits/s that meets the preset's
+// target size and/or bitrate once audioBitrate is accounted for.
+// If both targets are set, the lower resulting bitrate wins.
+func TargetVideoBitrate(preset *Preset, duration time.Duration, audioBitrate int64) (int64, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("target size/bitrate needs the video duration")
+	}
+
+	var total int64 // Overall (audio + video) bits/s
+	if preset.TargetSize > 0 {
+		total = int64(float64(preset.TargetSize*8) * (1 - targetOverhead) / duration.Seconds())
+	}
+	if preset.TargetBitrate > 0 && (total == 0 || preset.TargetBitrate < total) {
+		total = preset.TargetBitrate
+	}
+
+	video := total - audioBitrate
+	if video < minTargetVideoBitrate {
+		return 0, fmt.Errorf("target leaves %d kbps for video after %d kbps of audio (minimum %d kbps)",
+			video/1000, audioBitrate/1000, minTargetVideoBitrate/1000)
+	}
+	return video, nil
+}
+
+// usesTwoPass returns true if the preset's encoder supports two-pass encoding.
+// Hardware encoders have no stats pass and use single-pass VBR instead, as
+// does libsvtav1 (FFmpeg's wrapper doesn't read or write pass stats).
+func usesTwoPass(preset *Preset) bool {
+	switch presetEncoderConfig(preset).encoder {
+	case "libx265", "libx264", "libvpx-vp9":
+		return true
+	}
+	return false
+}
+
+// presetEncoderConfig returns the encoder settings for a preset, falling back
+// to the software encoder for the codec like BuildPresetArgs does
+func presetEncoderConfig(preset *Preset) encoderSettings {
+	if config, ok := encoderConfigs[EncoderKey{preset.Encoder, preset.Codec}]; ok {
+		return config
+	}
+	return encoderConfigs[EncoderKey{HWAccelNone, preset.Codec}]
+}
+
+// targetRateArgs replaces the quality flag in outputArgs with bitrate control.
+// Single-pass encoders get a VBR peak of twice the average so complex scenes
+// can borrow bits from simple ones, as the second pass does for two-pass ones.
+func targetRateArgs(preset *Preset, outputArgs []string, videoBitrate int64) []string {
+	config := presetEncoderConfig(preset)
+	rate := fmt.Sprintf("%dk", videoBitrate/1000)
+	peak := fmt.Sprintf("%dk", 2*videoBitrate/1000)
+
+	var rateArgs []string
+	switch preset.Encoder {
+	case HWAccelNone:
+		rateArgs = []string{"-b:v", rate}
+		if !usesTwoPass(preset) {
+			rateArgs = append(rateArgs, "-maxrate", peak)
+		}
+	case HWAccelVideoToolbox:
+		rateArgs = []string{"-b:v", rate}
+	case HWAccelVAAPI:
+		rateArgs = []string{"-rc_mode", "VBR", "-b:v", rate, "-maxrate", peak}
+	default:
+		rateArgs = []string{"-b:v", rate, "-maxrate", peak, "-bufsize", peak}
+	}
+
+	args := make([]string, 0, len(outputArgs)+len(rateArgs))
+	replaced := false
+	for i := 0; i < len(outputArgs); i++ {
+		if outputArgs[i] == config.qualityFlag && i+1 < len(outputArgs) && !replaced {
+			args = append(args, rateArgs...)
+			replaced = true
+			i++ // Skip the quality value
+			continue
+		}
+		if outputArgs[i] == "-b:v" && i+1 < len(outputArgs) {
+			i++ // Drop other bitrate args (libvpx's -b:v 0 constant quality marker)
+			continue
+		}
+		args = append(args, outputArgs[i])
+	}
+	return args
+}
+
+// twoPassArgs returns a copy of outputArgs set up for the given pass (1 or 2).
+// libx265 keeps its stats via x265-params (merged with any HDR/user params);
+// other encoders use FFmpeg's generic pass options.
+func twoPassArgs(preset *Preset, outputArgs []string, pass int, passLog string) []string {
+	args := slices.Clone(outputArgs)
+	if presetEncoderConfig(preset).encoder == "libx265" {
+		return mergeEncoderParams(args, "-x265-params", fmt.Sprintf("pass=%d:stats=%s", pass, escapeParamValue(passLog)))
+	}
+	return append(args, "-pass", strconv.Itoa(pass), "-passlogfile", passLog)
+}
+
+// paramEscaper escapes the characters FFmpeg splits x265-params on
+// ("key=value:key=value"), plus its own escape and quote characters
+var paramEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, ":", `\:`, "=", `\=`)
+
+// escapeParamValue escapes a value (e.g. a file path taken from the source
+// name, like "Movie: Part 2") for use inside x265-params
+func escapeParamValue(value string) string {
+	return paramEscaper.Replace(value)
+}
+
+// removePassLogs deletes the stats files written by a two-pass encode
+// (x265 writes passLog and passLog.cutree, FFmpeg writes passLog-0.log)
+func removePassLogs(passLog string) {
+	for _, path := range []string{passLog, passLog + ".cutree", passLog + "-0.log", passLog + ".temp", passLog + ".cutree.temp"} {
+		os.Remove(path)
+	}
+}
+
+// ParseBitrate parses an FFmpeg-style bitrate ("3M", "2500k", "640000") into bits/s
+func ParseBitrate(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "k"), strings.HasSuffix(value, "K"):
+		multiplier = 1e3
+		value = value[:len(value)-1]
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1e6
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", s)
+	}
+	return int64(n * multiplier), nil
+}
@@ -0,0 +1,144 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTargetVideoBitrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		preset  *Preset
+		audio   int64
+		want    int64
+		wantErr bool
+	}{
+		// 4 GiB over 2h = 4.77 Mbps, 4.68 Mbps after 2% overhead, minus 640k audio
+		{"target size", &Preset{TargetSize: 4 << 30}, 640_000, 4_036_742, false},
+		{"target bitrate", &Preset{TargetBitrate: 3_000_000}, 640_000, 2_360_000, false},
+		{"lower target wins", &Preset{TargetSize: 4 << 30, TargetBitrate: 3_000_000}, 0, 3_000_000, false},
+		{"audio leaves no room", &Preset{TargetBitrate: 1_000_000}, 4_000_000, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TargetVideoBitrate(tt.preset, 2*time.Hour, tt.audio)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := TargetVideoBitrate(&Preset{TargetSize: 1 << 30}, 0, 0); err == nil {
+		t.Error("expected error without duration")
+	}
+}
+
+func TestTargetRateArgs(t *testing.T) {
+	tests := []struct {
+		encoder   HWAccel
+		want      string
+		unwanted  string
+		twoPasses bool
+	}{
+		{HWAccelNone, "-c:v libx265 -b:v 3000k -preset medium", "-crf", true},
+		{HWAccelNVENC, "-b:v 3000k -maxrate 6000k -bufsize 6000k", "-cq", false},
+		{HWAccelQSV, "-b:v 3000k -maxrate 6000k -bufsize 6000k", "-global_quality", false},
+		{HWAccelVAAPI, "-rc_mode VBR -b:v 3000k -maxrate 6000k", "-qp", false},
+		{HWAccelVideoToolbox, "-b:v 3000k", "-b:v 0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.encoder), func(t *testing.T) {
+			preset := &Preset{ID: "test", Encoder: tt.encoder, Codec: CodecHEVC, TargetBitrate: 3_000_000}
+			_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, nil, "")
+			args := strings.Join(targetRateArgs(preset, outputArgs, 3_000_000), " ")
+
+			if !strings.Contains(args, tt.want) {
+				t.Errorf("expected %q in args: %s", tt.want, args)
+			}
+			if strings.Contains(args, tt.unwanted+" ") {
+				t.Errorf("unexpected %q in args: %s", tt.unwanted, args)
+			}
+			if usesTwoPass(preset) != tt.twoPasses {
+				t.Errorf("usesTwoPass = %v, want %v", !tt.twoPasses, tt.twoPasses)
+			}
+		})
+	}
+}
+
+func TestTargetRateArgsVP9(t *testing.T) {
+	// libvpx-vp9 uses -b:v 0 to enable constant quality; the target replaces it
+	preset := &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecVP9, TargetBitrate: 3_000_000}
+	_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "webm", nil, nil, nil, nil, "")
+	args := strings.Join(targetRateArgs(preset, outputArgs, 3_000_000), " ")
+
+	if !strings.Contains(args, "-c:v libvpx-vp9 -b:v 3000k") {
+		t.Errorf("expected target bitrate in args: %s", args)
+	}
+	if strings.Contains(args, "-crf") || strings.Contains(args, "-b:v 0") {
+		t.Errorf("unexpected constant quality args: %s", args)
+	}
+	if !usesTwoPass(preset) {
+		t.Error("expected libvpx-vp9 to use two-pass")
+	}
+}
+
+func TestTargetRateArgsSVTAV1(t *testing.T) {
+	// libsvtav1 has no pass stats in FFmpeg, so it gets single-pass VBR
+	preset := &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecAV1, TargetBitrate: 3_000_000}
+	_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, nil, "")
+	args := strings.Join(targetRateArgs(preset, outputArgs, 3_000_000), " ")
+
+	if !strings.Contains(args, "-c:v libsvtav1 -b:v 3000k -maxrate 6000k") {
+		t.Errorf("expected single-pass VBR args: %s", args)
+	}
+	if usesTwoPass(preset) {
+		t.Error("expected libsvtav1 to use a single pass")
+	}
+}
+
+func TestTwoPassArgs(t *testing.T) {
+	// libx265 merges the stats options into existing x265-params
+	preset := &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecHEVC}
+	outputArgs := []string{"-c:v", "libx265", "-x265-params", "hdr-opt=1"}
+	args := strings.Join(twoPassArgs(preset, outputArgs, 1, "/tmp/job.passlog"), " ")
+	if !strings.Contains(args, "-x265-params hdr-opt=1:pass=1:stats=/tmp/job.passlog") {
+		t.Errorf("unexpected x265 pass args: %s", args)
+	}
+	if outputArgs[3] != "hdr-opt=1" {
+		t.Errorf("twoPassArgs modified its input: %v", outputArgs)
+	}
+
+	// Separators in the stats path (taken from the source name) are escaped
+	passLog := BuildTempPath("/media/Movie: Part 2 a=b.mkv", "/tmp", "mkv") + ".passlog"
+	args = strings.Join(twoPassArgs(preset, outputArgs, 2, passLog), " ")
+	if !strings.Contains(args, `-x265-params hdr-opt=1:pass=2:stats=/tmp/Movie\: Part 2 a\=b.shrinkray.tmp.mkv.passlog`) {
+		t.Errorf("expected escaped stats path: %s", args)
+	}
+
+	// libx264 uses FFmpeg's generic pass options
+	preset = &Preset{ID: "test", Encoder: HWAccelNone, Codec: CodecH264}
+	args = strings.Join(twoPassArgs(preset, []string{"-c:v", "libx264"}, 2, "/tmp/job.passlog"), " ")
+	if !strings.HasSuffix(args, "-pass 2 -passlogfile /tmp/job.passlog") {
+		t.Errorf("unexpected x264 pass args: %s", args)
+	}
+}
+
+func TestParseBitrate(t *testing.T) {
+	tests := map[string]int64{"3M": 3_000_000, "2500k": 2_500_000, "1.5M": 1_500_000, "640000": 640_000}
+	for input, want := range tests {
+		if got, err := ParseBitrate(input); err != nil || got != want {
+			t.Errorf("ParseBitrate(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "fast", "-1M"} {
+		if _, err := ParseBitrate(input); err == nil {
+			t.Errorf("ParseBitrate(%q) expected error", input)
+		}
+	}
+}
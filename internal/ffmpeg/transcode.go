@@ -68,13 +68,17 @@ func NewTranscoder(ffmpegPath string) *Transcoder {
 // It sends progress updates to the progress channel and returns the result
 // sourceBitrate is the source video bitrate in bits/second (for dynamic bitrate calculation)
 // sourceWidth/sourceHeight are source dimensions (for calculating scaled output)
-// qualityHEVC/qualityAV1 are CRF values to use (0 = use preset defaults)
+// quality is the CRF to use for the preset's codec (0 = use preset defaults)
 // qualityMod is a bitrate modifier for VideoToolbox (0 = use preset defaults)
 // totalFrames is the expected total frame count (for progress fallback when time-based stats unavailable)
 // softwareDecode: if true, use software decode with hardware encode (fallback for hw decode failures)
-// outputFormat: "mkv" or "mp4" - affects audio/subtitle handling
+// outputFormat: "mkv", "mp4" or "webm" - affects audio/subtitle handling
 // tonemap: optional HDR to SDR tonemapping parameters (nil = no tonemapping)
 // subtitleIndices: nil=map all, empty=none, populated=specific indices (for MKV compatibility filtering)
+// audio: optional audio plan from PlanAudio (nil = legacy audio handling)
+// crop: optional black-bar crop from DetectCrop (nil = no crop)
+// scan: scan type from DetectInterlace (interlaced/telecined sources are deinterlaced)
+// audioBitrate: expected output audio bitrate in bits/s from AudioBitrate (target size/bitrate presets only)
 func (t *Transcoder) Transcode(
 	ctx context.Context,
 	inputPath string,
@@ -83,7 +87,7 @@ func (t *Transcoder) Transcode(
 	duration time.Duration,
 	sourceBitrate int64,
 	sourceWidth, sourceHeight int,
-	qualityHEVC, qualityAV1 int,
+	quality int,
 	qualityMod float64,
 	totalFrames int64,
 	progressCh chan<- Progress,
@@ -91,6 +95,10 @@ func (t *Transcoder) Transcode(
 	outputFormat string,
 	tonemap *TonemapParams,
 	subtitleIndices []int,
+	audio *AudioPlan,
+	crop *CropRect,
+	scan ScanType,
+	audioBitrate int64,
 ) (*TranscodeResult, error) {
 	startTime := time.Now()
 
@@ -111,7 +119,7 @@ func (t *Transcoder) Transcode(
 
 	// Build preset args with source bitrate for dynamic calculation
 	// inputArgs go before -i (hwaccel), outputArgs go after
-	inputArgs, outputArgs := BuildPresetArgs(preset, sourceBitrate, sourceWidth, sourceHeight, qualityHEVC, qualityAV1, qualityMod, softwareDecode, outputFormat, tonemap, subtitleIndices)
+	inputArgs, outputArgs := BuildPresetArgs(preset, sourceBitrate, sourceWidth, sourceHeight, quality, qualityMod, softwareDecode, outputFormat, tonemap, subtitleIndices, audio, crop, scan)
 
 	// Check if hardware decode is actually being used (presence of -hwaccel flag).
 	// This determines whether we need the first-frame watchdog to catch HW decode hangs.
@@ -125,17 +133,78 @@ func (t *Transcoder) Transcode(
 
 	// Build ffmpeg command
 	// Structure: ffmpeg [inputArgs] -i input [outputArgs] output
-	args := []string{}
-	args = append(args, inputArgs...)
-	args = append(args,
-		"-i", inputPath,
-		"-y",                   // Overwrite output without asking
-		"-progress", "pipe:1", // Output progress to stdout
-		"-nostats",            // Disable default stats output
-	)
-	args = append(args, outputArgs...)
-	args = append(args, outputPath)
+	buildArgs := func(outputArgs []string, outputPath string) []string {
+		args := []string{}
+		args = append(args, inputArgs...)
+		args = append(args,
+			"-i", inputPath,
+			"-y",                  // Overwrite output without asking
+			"-progress", "pipe:1", // Output progress to stdout
+			"-nostats",            // Disable default stats output
+		)
+		args = append(args, outputArgs...)
+		return append(args, outputPath)
+	}
+
+	// Target size/bitrate presets encode at a computed average bitrate instead of CRF.
+	// Software encoders run two passes (analysis, then encode); hardware encoders
+	// use single-pass VBR.
+	passes := 1
+	if preset.HasTarget() {
+		videoBitrate, err := TargetVideoBitrate(preset, duration, audioBitrate)
+		if err != nil {
+			return nil, err
+		}
+		outputArgs = targetRateArgs(preset, outputArgs, videoBitrate)
+		if usesTwoPass(preset) {
+			passes = 2
+		}
+		logger.Info("Encoding to target bitrate",
+			"video_kbps", videoBitrate/1000,
+			"audio_kbps", audioBitrate/1000,
+			"passes", passes)
+	}
+
+	if passes == 2 {
+		passLog := outputPath + ".passlog"
+		defer removePassLogs(passLog)
+
+		// First pass only analyzes the video - no audio/subtitles, output discarded
+		firstPassArgs := append(twoPassArgs(preset, outputArgs, 1, passLog), "-an", "-sn", "-f", "null")
+		if err := t.runPass(ctx, buildArgs(firstPassArgs, os.DevNull), hasHwDecode, duration, totalFrames, progressCh, 0, passes); err != nil {
+			return nil, err
+		}
+		outputArgs = twoPassArgs(preset, outputArgs, 2, passLog)
+	}
+
+	if err := t.runPass(ctx, buildArgs(outputArgs, outputPath), hasHwDecode, duration, totalFrames, progressCh, passes-1, passes); err != nil {
+		// Clean up partial output file
+		os.Remove(outputPath)
+		return nil, err
+	}
+
+	// Get output file size
+	outputInfo, err := os.Stat(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	outputSize := outputInfo.Size()
 
+	return &TranscodeResult{
+		InputPath:  inputPath,
+		OutputPath: outputPath,
+		InputSize:  inputSize,
+		OutputSize: outputSize,
+		SpaceSaved: inputSize - outputSize,
+		Duration:   time.Since(startTime),
+	}, nil
+}
+
+// runPass runs one ffmpeg invocation and reports its progress.
+// pass/passes map the pass's progress onto the whole job (pass is 0-based),
+// so a two-pass encode goes 0-50% in the first pass and 50-100% in the second.
+func (t *Transcoder) runPass(ctx context.Context, args []string, hasHwDecode bool, duration time.Duration, totalFrames int64, progressCh chan<- Progress, pass, passes int) error {
+	startTime := time.Now()
 	cmd := exec.CommandContext(ctx, t.ffmpegPath, args...)
 
 	// Log the command at debug level
@@ -144,7 +213,7 @@ func (t *Transcoder) Transcode(
 	// Capture stdout for progress
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
 	// Capture stderr for error messages
@@ -153,7 +222,7 @@ func (t *Transcoder) Transcode(
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
 
 	// Track last frame count for error reporting
@@ -193,8 +262,9 @@ func (t *Transcoder) Transcode(
 	}
 
 	// Parse progress from stdout
+	parseDone := make(chan struct{})
 	go func() {
-		defer closeProgress()
+		defer close(parseDone)
 		scanner := bufio.NewScanner(stdout)
 		var currentProgress Progress
 
@@ -251,6 +321,8 @@ func (t *Transcoder) Transcode(
 						if currentProgress.Percent > 100 {
 							currentProgress.Percent = 100
 						}
+						// Multi-pass: each pass covers an equal share of the job
+						currentProgress.Percent = (float64(pass)*100 + currentProgress.Percent) / float64(passes)
 
 						// Calculate ETA - use FFmpeg speed if available, otherwise calculate from frames
 						if currentProgress.Speed > 0 && duration > 0 {
@@ -267,6 +339,10 @@ func (t *Transcoder) Transcode(
 							// Calculate ETA based on elapsed time and remaining frames
 							currentProgress.ETA = time.Duration(float64(elapsed) * float64(framesRemaining) / float64(currentProgress.Frame))
 						}
+						// Add the estimated time of the passes still to come
+						if remaining := passes - pass - 1; remaining > 0 && currentProgress.Speed > 0 {
+							currentProgress.ETA += time.Duration(float64(duration) / currentProgress.Speed * float64(remaining))
+						}
 
 						// Log progress values for debugging
 						logger.Debug("FFmpeg progress",
@@ -286,10 +362,9 @@ func (t *Transcoder) Transcode(
 		}
 	}()
 
-	// Wait for ffmpeg to complete
+	// Wait for the progress parser to drain stdout, then for ffmpeg to exit
+	<-parseDone
 	if err := cmd.Wait(); err != nil {
-		// Clean up partial output file
-		os.Remove(outputPath)
 		// Capture full stderr for retry detection
 		stderrOutput := stderr.String()
 		if stderrOutput != "" {
@@ -302,59 +377,72 @@ func (t *Transcoder) Transcode(
 			logger.Error("FFmpeg failed", "error", err, "stderr", strings.Join(lastLines, " | "))
 		}
 		// Return TranscodeError with full stderr and frame count for retry decisions
-		return nil, &TranscodeError{
+		return &TranscodeError{
 			Err:    fmt.Errorf("ffmpeg failed: %w", err),
 			Stderr: stderrOutput,
 			Frames: lastFrameCount,
 		}
 	}
+	return nil
+}
 
-	// Get output file size
-	outputInfo, err := os.Stat(outputPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat output file: %w", err)
+// webmVideoCodecs are the target codecs WebM can hold
+var webmVideoCodecs = map[Codec]bool{
+	CodecVP9: true,
+	CodecAV1: true,
+}
+
+// OutputFormatFor returns the container to use for a codec. WebM only holds
+// VP9 and AV1, so other codecs fall back to MKV.
+func OutputFormatFor(format string, codec Codec) string {
+	if format == "webm" && !webmVideoCodecs[codec] {
+		return "mkv"
 	}
-	outputSize := outputInfo.Size()
+	return format
+}
 
-	return &TranscodeResult{
-		InputPath:  inputPath,
-		OutputPath: outputPath,
-		InputSize:  inputSize,
-		OutputSize: outputSize,
-		SpaceSaved: inputSize - outputSize,
-		Duration:   time.Since(startTime),
-	}, nil
+// outputExtension returns the file extension (without dot) for an output format
+func outputExtension(format string) string {
+	switch format {
+	case "mp4", "webm":
+		return format
+	default:
+		return "mkv"
+	}
 }
 
 // BuildTempPath generates a temporary output path for transcoding
-// format should be "mkv" or "mp4"
+// format should be "mkv", "mp4" or "webm"
 func BuildTempPath(inputPath, tempDir, format string) string {
 	base := filepath.Base(inputPath)
 	ext := filepath.Ext(base)
 	name := strings.TrimSuffix(base, ext)
-	outExt := "mkv"
-	if format == "mp4" {
-		outExt = "mp4"
-	}
-	tempName := fmt.Sprintf("%s.shrinkray.tmp.%s", name, outExt)
+	tempName := fmt.Sprintf("%s.shrinkray.tmp.%s", name, outputExtension(format))
 	return filepath.Join(tempDir, tempName)
 }
 
+// IsTempPath reports whether path is a temporary output from BuildTempPath
+func IsTempPath(path string) bool {
+	return strings.Contains(filepath.Base(path), ".shrinkray.tmp.")
+}
+
+// KeptOriginalPath returns where FinalizeTranscode keeps the original when
+// replace=false (a .old sibling of the input)
+func KeptOriginalPath(inputPath string) string {
+	return inputPath + ".old"
+}
+
 // FinalizeTranscode handles the original file based on the configured behavior
 // If replace=true, deletes original and copies temp to final location
 // If replace=false (keep), renames original to .old and copies temp to final location
 // Uses copy-then-delete instead of rename to support cross-filesystem moves.
-// format should be "mkv" or "mp4"
+// format should be "mkv", "mp4" or "webm"
 func FinalizeTranscode(inputPath, tempPath, format string, replace bool) (finalPath string, err error) {
 	dir := filepath.Dir(inputPath)
 	base := filepath.Base(inputPath)
 	ext := filepath.Ext(base)
 	name := strings.TrimSuffix(base, ext)
-	outExt := ".mkv"
-	if format == "mp4" {
-		outExt = ".mp4"
-	}
-	finalPath = filepath.Join(dir, name+outExt)
+	finalPath = filepath.Join(dir, name+"."+outputExtension(format))
 
 	// Capture original modification time to preserve it on the output file
 	inputInfo, err := os.Stat(inputPath)
@@ -381,7 +469,7 @@ func FinalizeTranscode(inputPath, tempPath, format string, replace bool) (finalP
 	}
 
 	// Keep mode: rename original to .old, copy temp to final location
-	oldPath := inputPath + ".old"
+	oldPath := KeptOriginalPath(inputPath)
 	if err := os.Rename(inputPath, oldPath); err != nil {
 		return "", fmt.Errorf("failed to rename original to .old: %w", err)
 	}
@@ -398,3 +486,33 @@ func FinalizeTranscode(inputPath, tempPath, format string, replace bool) (finalP
 	os.Remove(tempPath)
 	return finalPath, nil
 }
+
+// RevertTranscode undoes FinalizeTranscode for a kept original: the output is
+// moved aside, restoreOriginal puts the original back at inputPath, and the
+// output is deleted. If restoreOriginal fails the output is put back, so the
+// file is never left without either version.
+func RevertTranscode(inputPath, outputPath string, restoreOriginal func() error) error {
+	// Move the output aside first - it has the same path as the original
+	// when the container didn't change (mkv → mkv)
+	asidePath := outputPath + ".revert"
+	movedAside := false
+	if err := os.Rename(outputPath, asidePath); err == nil {
+		movedAside = true
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move output aside: %w", err)
+	}
+
+	if err := restoreOriginal(); err != nil {
+		if movedAside {
+			_ = os.Rename(asidePath, outputPath)
+		}
+		return fmt.Errorf("failed to restore original to %s: %w", inputPath, err)
+	}
+
+	if movedAside {
+		if err := os.Remove(asidePath); err != nil {
+			return fmt.Errorf("original restored but failed to remove output: %w", err)
+		}
+	}
+	return nil
+}
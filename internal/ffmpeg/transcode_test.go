@@ -33,6 +33,12 @@ func TestBuildTempPath(t *testing.T) {
 			"mp4",
 			"/data/video.shrinkray.tmp.mp4",
 		},
+		{
+			"/data/video.mkv",
+			"/data",
+			"webm",
+			"/data/video.shrinkray.tmp.webm",
+		},
 	}
 
 	for _, tt := range tests {
@@ -44,6 +50,27 @@ func TestBuildTempPath(t *testing.T) {
 	}
 }
 
+func TestOutputFormatFor(t *testing.T) {
+	tests := []struct {
+		format string
+		codec  Codec
+		want   string
+	}{
+		{"webm", CodecVP9, "webm"},
+		{"webm", CodecAV1, "webm"},
+		{"webm", CodecHEVC, "mkv"},
+		{"webm", CodecH264, "mkv"},
+		{"mp4", CodecH264, "mp4"},
+		{"mkv", CodecVP9, "mkv"},
+	}
+
+	for _, tt := range tests {
+		if got := OutputFormatFor(tt.format, tt.codec); got != tt.want {
+			t.Errorf("OutputFormatFor(%s, %s) = %s, want %s", tt.format, tt.codec, got, tt.want)
+		}
+	}
+}
+
 func TestTranscode(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping transcode test in short mode")
@@ -88,7 +115,7 @@ func TestTranscode(t *testing.T) {
 	}()
 
 	totalFrames := int64(probeResult.Duration.Seconds() * probeResult.FrameRate)
-	result, err := transcoder.Transcode(ctx, testFile, outputPath, preset, probeResult.Duration, probeResult.Bitrate, probeResult.Width, probeResult.Height, 0, 0, 0, totalFrames, progressCh, false, "mkv", nil, nil)
+	result, err := transcoder.Transcode(ctx, testFile, outputPath, preset, probeResult.Duration, probeResult.Bitrate, probeResult.Width, probeResult.Height, 0, 0, totalFrames, progressCh, false, "mkv", nil, nil, nil, nil, "", 0)
 	<-done
 
 	if err != nil {
@@ -276,6 +303,48 @@ func TestFinalizeTranscodeKeep(t *testing.T) {
 	t.Logf("Keep mode: original→%s, final=%s", oldPath, finalPath)
 }
 
+func TestRevertTranscode(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// mkv → mkv in keep mode: output at the original path, original as .old
+	originalPath := filepath.Join(tmpDir, "video.mkv")
+	if err := os.WriteFile(originalPath, []byte("original content"), 0644); err != nil {
+		t.Fatalf("failed to create original: %v", err)
+	}
+	tempPath := filepath.Join(tmpDir, "video.shrinkray.tmp.mkv")
+	if err := os.WriteFile(tempPath, []byte("transcoded content"), 0644); err != nil {
+		t.Fatalf("failed to create temp: %v", err)
+	}
+	finalPath, err := FinalizeTranscode(originalPath, tempPath, "mkv", false)
+	if err != nil {
+		t.Fatalf("FinalizeTranscode failed: %v", err)
+	}
+	oldPath := KeptOriginalPath(originalPath)
+
+	// A failed restore puts the output back
+	err = RevertTranscode(originalPath, finalPath, func() error { return os.ErrPermission })
+	if err == nil {
+		t.Fatal("expected error from failed restore")
+	}
+	if content, _ := os.ReadFile(finalPath); string(content) != "transcoded content" {
+		t.Errorf("expected output back in place after failed restore, got %q", content)
+	}
+
+	err = RevertTranscode(originalPath, finalPath, func() error { return os.Rename(oldPath, originalPath) })
+	if err != nil {
+		t.Fatalf("RevertTranscode failed: %v", err)
+	}
+	if content, _ := os.ReadFile(originalPath); string(content) != "original content" {
+		t.Errorf("expected original restored, got %q", content)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Error(".old file still exists after revert")
+	}
+	if _, err := os.Stat(finalPath + ".revert"); !os.IsNotExist(err) {
+		t.Error("output still exists after revert")
+	}
+}
+
 func TestTranscode_ClosesChannelOnEarlyError(t *testing.T) {
 	transcoder := NewTranscoder("ffmpeg")
 	progressCh := make(chan Progress, 10)
@@ -290,13 +359,17 @@ func TestTranscode_ClosesChannelOnEarlyError(t *testing.T) {
 		time.Minute,
 		0,
 		1920, 1080,
-		0, 0, 0,
+		0, 0,
 		1000,
 		progressCh,
 		false,
 		"mkv",
 		nil,
 		nil,
+		nil,
+		nil,
+		ScanUnknown,
+		0,
 	)
 
 	// Should error
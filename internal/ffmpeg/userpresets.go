@@ -0,0 +1,220 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gwlsn/shrinkray/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// UserPresetsFile is the name of the user preset file in the config directory
+const UserPresetsFile = "presets.yaml"
+
+// UserPreset is a preset declared in presets.yaml.
+// Encoder selection works the same as for built-in presets: the best
+// available encoder for Codec is used, with software as the fallback.
+type UserPreset struct {
+	ID          string   `yaml:"id"`
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Codec       Codec    `yaml:"codec"`       // hevc, av1, h264 or vp9
+	MaxHeight   int      `yaml:"max_height"`  // 0 = no scaling
+	Quality     int      `yaml:"quality"`     // CRF (0 = use config/encoder default)
+	ExtraArgs   []string `yaml:"extra_args"`  // Appended after the encoder's built-in args
+	SmartShrink bool     `yaml:"smartshrink"` // Pick quality with VMAF analysis
+	AutoCrop    bool     `yaml:"auto_crop"`   // Detect and crop black bars (letterboxing)
+
+	TargetSize    string `yaml:"target_size"`    // Max output size, e.g. "4G" (replaces quality)
+	TargetBitrate string `yaml:"target_bitrate"` // Average overall bitrate, e.g. "3M" (replaces quality)
+
+	Audio     *AudioPolicy    `yaml:"audio"`     // Audio stream rules (nil = default audio handling)
+	Subtitles *SubtitlePolicy `yaml:"subtitles"` // Subtitle stream rules (nil = keep all)
+}
+
+// audioBitratePattern matches FFmpeg bitrate values like "640k" or "384000"
+var audioBitratePattern = regexp.MustCompile(`^[0-9]+[kK]?$`)
+
+// userPresetsFile is the on-disk layout of presets.yaml
+type userPresetsFile struct {
+	Presets []UserPreset `yaml:"presets"`
+}
+
+// userPresets holds validated user presets - set once at startup via SetUserPresets
+var userPresets []UserPreset
+
+// reservedPresetArgs are FFmpeg options Shrinkray manages itself.
+// Allowing them in extra_args would break stream mapping or the filter chain.
+var reservedPresetArgs = map[string]bool{
+	"-i":              true,
+	"-y":              true,
+	"-map":            true,
+	"-c:v":            true,
+	"-vcodec":         true,
+	"-vf":             true,
+	"-filter:v":       true,
+	"-filter_complex": true,
+	"-progress":       true,
+}
+
+// definition converts a user preset into a preset definition
+func (up UserPreset) definition() presetDefinition {
+	name := up.Name
+	if name == "" {
+		name = up.ID
+	}
+	// Targets were checked by ValidateUserPresets
+	targetSize, _ := util.ParseBytes(up.TargetSize)
+	targetBitrate, _ := ParseBitrate(up.TargetBitrate)
+
+	return presetDefinition{
+		ID:            up.ID,
+		Name:          name,
+		Description:   up.Description,
+		Codec:         up.Codec,
+		MaxHeight:     up.MaxHeight,
+		IsSmartShrink: up.SmartShrink,
+		Quality:       up.Quality,
+		ExtraArgs:     up.ExtraArgs,
+		IsCustom:      true,
+		Audio:         up.Audio,
+		Subtitles:     up.Subtitles,
+		AutoCrop:      up.AutoCrop,
+		TargetSize:    targetSize,
+		TargetBitrate: targetBitrate,
+	}
+}
+
+// LoadUserPresets reads and validates user presets from path.
+// A missing file is not an error and returns no presets.
+func LoadUserPresets(path string) ([]UserPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var file userPresetsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if err := ValidateUserPresets(file.Presets); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return file.Presets, nil
+}
+
+// ValidateUserPresets checks user presets for missing fields, duplicate IDs,
+// collisions with built-in presets, unknown codecs and reserved FFmpeg args.
+func ValidateUserPresets(presets []UserPreset) error {
+	seen := make(map[string]bool)
+	for _, base := range BasePresets {
+		seen[base.ID] = true
+	}
+
+	for i, up := range presets {
+		if up.ID == "" {
+			return fmt.Errorf("preset %d: id is required", i+1)
+		}
+		if seen[up.ID] {
+			return fmt.Errorf("preset %q: id is already in use", up.ID)
+		}
+		seen[up.ID] = true
+
+		if _, ok := encoderConfigs[EncoderKey{HWAccelNone, up.Codec}]; !ok {
+			return fmt.Errorf("preset %q: unknown codec %q", up.ID, up.Codec)
+		}
+		if up.MaxHeight < 0 {
+			return fmt.Errorf("preset %q: max_height must not be negative", up.ID)
+		}
+		if up.Quality < 0 {
+			return fmt.Errorf("preset %q: quality must not be negative", up.ID)
+		}
+		if up.Quality > 0 {
+			// Encoders aren't detected yet: check the software encoder's range here,
+			// the selected encoder's range is checked by GeneratePresets
+			if err := ValidateQuality(HWAccelNone, up.Codec, up.Quality); err != nil {
+				return fmt.Errorf("preset %q: %w", up.ID, err)
+			}
+		}
+		if up.SmartShrink && up.Quality > 0 {
+			return fmt.Errorf("preset %q: quality cannot be set on a SmartShrink preset", up.ID)
+		}
+		if err := validateTargets(up); err != nil {
+			return fmt.Errorf("preset %q: %w", up.ID, err)
+		}
+
+		for _, arg := range up.ExtraArgs {
+			if strings.TrimSpace(arg) == "" {
+				return fmt.Errorf("preset %q: extra_args must not contain empty values", up.ID)
+			}
+			if reservedPresetArgs[arg] {
+				return fmt.Errorf("preset %q: extra_args may not contain %s", up.ID, arg)
+			}
+		}
+
+		if up.Audio != nil {
+			if err := validateAudioPolicy(up.Audio); err != nil {
+				return fmt.Errorf("preset %q: audio: %w", up.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateTargets checks target size/bitrate values and that they aren't
+// combined with another way of choosing quality
+func validateTargets(up UserPreset) error {
+	if up.TargetSize == "" && up.TargetBitrate == "" {
+		return nil
+	}
+	if up.TargetSize != "" {
+		if size, err := util.ParseBytes(up.TargetSize); err != nil || size == 0 {
+			return fmt.Errorf("invalid target_size %q (expected e.g. \"4G\")", up.TargetSize)
+		}
+	}
+	if up.TargetBitrate != "" {
+		if _, err := ParseBitrate(up.TargetBitrate); err != nil {
+			return fmt.Errorf("invalid target_bitrate %q (expected e.g. \"3M\")", up.TargetBitrate)
+		}
+	}
+	if up.Quality > 0 {
+		return fmt.Errorf("quality cannot be combined with target_size/target_bitrate")
+	}
+	if up.SmartShrink {
+		return fmt.Errorf("target_size/target_bitrate cannot be set on a SmartShrink preset")
+	}
+	return nil
+}
+
+// validateAudioPolicy checks target codecs and bitrates in an audio policy
+func validateAudioPolicy(policy *AudioPolicy) error {
+	if policy.TranscodeTo != "" && !IsValidAudioCodec(policy.TranscodeTo) {
+		return fmt.Errorf("unknown transcode_to codec %q", policy.TranscodeTo)
+	}
+	if len(policy.TranscodeCodecs) > 0 && policy.TranscodeTo == "" {
+		return fmt.Errorf("transcode_codecs requires transcode_to")
+	}
+	if policy.CompatCodec != "" && !IsValidAudioCodec(policy.CompatCodec) {
+		return fmt.Errorf("unknown compat_codec %q", policy.CompatCodec)
+	}
+	for _, bitrate := range []string{policy.TranscodeBitrate, policy.CompatBitrate} {
+		if bitrate != "" && !audioBitratePattern.MatchString(bitrate) {
+			return fmt.Errorf("invalid bitrate %q (expected e.g. \"640k\")", bitrate)
+		}
+	}
+	return nil
+}
+
+// SetUserPresets registers user presets so they are merged into
+// ListPresets and GetPreset. Must be called before InitPresets.
+func SetUserPresets(presets []UserPreset) {
+	userPresets = presets
+}
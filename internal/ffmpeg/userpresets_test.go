@@ -0,0 +1,159 @@
+package ffmpeg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadUserPresets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, UserPresetsFile)
+
+	data := `presets:
+  - id: anime-1080p-av1
+    name: Anime 1080p (AV1)
+    description: Flat animation, 1080p max
+    codec: av1
+    max_height: 1080
+    quality: 30
+    extra_args: ["-svtav1-params", "tune=0"]
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	presets, err := LoadUserPresets(path)
+	if err != nil {
+		t.Fatalf("LoadUserPresets failed: %v", err)
+	}
+	if len(presets) != 1 {
+		t.Fatalf("expected 1 preset, got %d", len(presets))
+	}
+
+	p := presets[0]
+	if p.ID != "anime-1080p-av1" || p.Codec != CodecAV1 || p.MaxHeight != 1080 || p.Quality != 30 {
+		t.Errorf("unexpected preset: %+v", p)
+	}
+	if len(p.ExtraArgs) != 2 || p.ExtraArgs[0] != "-svtav1-params" {
+		t.Errorf("unexpected extra args: %v", p.ExtraArgs)
+	}
+}
+
+func TestLoadUserPresetsMissingFile(t *testing.T) {
+	presets, err := LoadUserPresets(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error for missing file, got %v", err)
+	}
+	if presets != nil {
+		t.Errorf("expected nil presets, got %v", presets)
+	}
+}
+
+func TestValidateUserPresets(t *testing.T) {
+	tests := []struct {
+		name    string
+		preset  UserPreset
+		wantErr string
+	}{
+		{"valid", UserPreset{ID: "custom", Codec: CodecHEVC, Quality: 22}, ""},
+		{"valid smartshrink", UserPreset{ID: "custom", Codec: CodecAV1, SmartShrink: true}, ""},
+		{"missing id", UserPreset{Codec: CodecHEVC}, "id is required"},
+		{"base preset collision", UserPreset{ID: "compress-hevc", Codec: CodecHEVC}, "already in use"},
+		{"unknown codec", UserPreset{ID: "custom", Codec: "mpeg2"}, "unknown codec"},
+		{"negative height", UserPreset{ID: "custom", Codec: CodecHEVC, MaxHeight: -1}, "max_height"},
+		{"quality too high", UserPreset{ID: "custom", Codec: CodecHEVC, Quality: 64}, "quality must be"},
+		{"quality outside codec range", UserPreset{ID: "custom", Codec: CodecH264, Quality: 34}, "between 16 and 30 for libx264"},
+		{"av1 quality", UserPreset{ID: "custom", Codec: CodecAV1, Quality: 40}, ""},
+		{"negative quality", UserPreset{ID: "custom", Codec: CodecHEVC, Quality: -1}, "negative"},
+		{"smartshrink with quality", UserPreset{ID: "custom", Codec: CodecHEVC, Quality: 24, SmartShrink: true}, "SmartShrink"},
+		{"reserved arg", UserPreset{ID: "custom", Codec: CodecHEVC, ExtraArgs: []string{"-map", "0"}}, "-map"},
+		{"empty arg", UserPreset{ID: "custom", Codec: CodecHEVC, ExtraArgs: []string{" "}}, "empty"},
+		{"valid target", UserPreset{ID: "custom", Codec: CodecHEVC, TargetSize: "4G", TargetBitrate: "3M"}, ""},
+		{"invalid target size", UserPreset{ID: "custom", Codec: CodecHEVC, TargetSize: "big"}, "target_size"},
+		{"invalid target bitrate", UserPreset{ID: "custom", Codec: CodecHEVC, TargetBitrate: "fast"}, "target_bitrate"},
+		{"target with quality", UserPreset{ID: "custom", Codec: CodecHEVC, Quality: 22, TargetSize: "4G"}, "cannot be combined"},
+		{"target with smartshrink", UserPreset{ID: "custom", Codec: CodecAV1, SmartShrink: true, TargetBitrate: "3M"}, "SmartShrink"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUserPresets([]UserPreset{tt.preset})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error: got %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Duplicate IDs within the file
+	dup := []UserPreset{
+		{ID: "custom", Codec: CodecHEVC},
+		{ID: "custom", Codec: CodecAV1},
+	}
+	if err := ValidateUserPresets(dup); err == nil {
+		t.Error("expected error for duplicate preset IDs")
+	}
+}
+
+func TestUserPresetsMerged(t *testing.T) {
+	SetUserPresets([]UserPreset{
+		{ID: "anime-1080p-av1", Name: "Anime 1080p (AV1)", Codec: CodecAV1, MaxHeight: 1080, Quality: 30},
+	})
+	defer SetUserPresets(nil)
+
+	// Presets are not initialized in tests, so the software fallback path is used
+	preset := GetPreset("anime-1080p-av1")
+	if preset == nil {
+		t.Fatal("expected user preset from GetPreset")
+	}
+	if !preset.IsCustom || preset.Quality != 30 || preset.MaxHeight != 1080 {
+		t.Errorf("unexpected preset: %+v", preset)
+	}
+	if preset.Name != "Anime 1080p (AV1) [SW]" {
+		t.Errorf("name: got %q, want %q", preset.Name, "Anime 1080p (AV1) [SW]")
+	}
+
+	list := ListPresets()
+	if len(list) == 0 || list[len(list)-1].ID != "anime-1080p-av1" {
+		t.Errorf("expected user preset last in ListPresets")
+	}
+
+	meta := GetBasePresetMeta("anime-1080p-av1")
+	if meta == nil || meta.Codec != CodecAV1 || meta.MaxHeight != 1080 {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+}
+
+func TestBuildPresetArgsUserPreset(t *testing.T) {
+	preset := &Preset{
+		ID:        "custom",
+		Encoder:   HWAccelNone,
+		Codec:     CodecAV1,
+		Quality:   30,
+		ExtraArgs: []string{"-preset", "4"},
+	}
+
+	_, outputArgs := BuildPresetArgs(preset, 0, 1920, 1080, 0, 0, false, "mkv", nil, nil, nil, nil, "")
+	args := strings.Join(outputArgs, " ")
+
+	if !strings.Contains(args, "-crf 30") {
+		t.Errorf("expected preset quality -crf 30, got: %s", args)
+	}
+	// User args must come after the built-in "-preset 6" so they take effect
+	if strings.LastIndex(args, "-preset 4") < strings.Index(args, "-preset 6") {
+		t.Errorf("expected user extra args after built-in args, got: %s", args)
+	}
+
+	// An explicit override still wins over the preset quality
+	_, outputArgs = BuildPresetArgs(preset, 0, 1920, 1080, 25, 0, false, "mkv", nil, nil, nil, nil, "")
+	if !strings.Contains(strings.Join(outputArgs, " "), "-crf 25") {
+		t.Errorf("expected override -crf 25, got: %v", outputArgs)
+	}
+}
@@ -0,0 +1,176 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamCounts is the number of streams of each type in a file
+type StreamCounts struct {
+	Video    int
+	Audio    int
+	Subtitle int
+}
+
+// OutputExpectation describes what a finished transcode must contain
+// before it is allowed to replace the original
+type OutputExpectation struct {
+	Duration time.Duration // Source duration (0 = unknown, not checked)
+	Streams  StreamCounts
+}
+
+// Duration tolerance for output verification.
+// Containers round differently and decimation (inverse telecine) can trim the
+// last frame, so small differences are expected; truncated encodes are not.
+const (
+	minDurationTolerance = 2 * time.Second
+	durationTolerancePct = 0.01 // 1% of the source duration
+)
+
+// ExpectedStreams returns the stream counts a transcode produces from a source
+// with the given streams, mirroring the mapping in BuildPresetArgs
+func ExpectedStreams(source StreamCounts, audio *AudioPlan, subtitleIndices []int, outputFormat string) StreamCounts {
+	want := StreamCounts{
+		Video: min(source.Video, 1), // First video stream only (-map 0:v:0)
+		Audio: source.Audio,         // All audio streams (-map 0:a?)
+	}
+	if audio != nil {
+		want.Audio = len(audio.Tracks)
+	}
+
+	switch {
+	case subtitleIndices != nil:
+		want.Subtitle = len(subtitleIndices)
+	case outputFormat != "mp4" && outputFormat != "webm":
+		want.Subtitle = source.Subtitle // MKV maps all subtitles (-map 0:s?)
+	}
+	return want
+}
+
+// ProbeStreams returns the stream counts and container duration of a file
+func (p *Prober) ProbeStreams(ctx context.Context, path string) (StreamCounts, time.Duration, error) {
+	cmd := exec.CommandContext(ctx, p.ffprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return StreamCounts{}, 0, fmt.Errorf("ffprobe failed: %s", string(exitErr.Stderr))
+		}
+		return StreamCounts{}, 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probeOutput ffprobeOutput
+	if err := json.Unmarshal(output, &probeOutput); err != nil {
+		return StreamCounts{}, 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	var counts StreamCounts
+	for _, stream := range probeOutput.Streams {
+		switch stream.CodecType {
+		case "video":
+			counts.Video++
+		case "audio":
+			counts.Audio++
+		case "subtitle":
+			counts.Subtitle++
+		}
+	}
+
+	var duration time.Duration
+	if secs, err := strconv.ParseFloat(probeOutput.Format.Duration, 64); err == nil {
+		duration = time.Duration(secs * float64(time.Second))
+	}
+
+	return counts, duration, nil
+}
+
+// VerifyOutput re-probes a transcoded file and checks it against the expectation.
+// Returns an error describing the first problem found.
+func (p *Prober) VerifyOutput(ctx context.Context, path string, want OutputExpectation) error {
+	got, duration, err := p.ProbeStreams(ctx, path)
+	if err != nil {
+		return fmt.Errorf("output can't be probed: %w", err)
+	}
+	return checkOutput(got, duration, want)
+}
+
+// checkOutput compares probed stream counts and duration with the expectation
+func checkOutput(got StreamCounts, duration time.Duration, want OutputExpectation) error {
+	if want.Duration > 0 {
+		tolerance := max(minDurationTolerance, time.Duration(float64(want.Duration)*durationTolerancePct))
+		diff := duration - want.Duration
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			return fmt.Errorf("output duration %s doesn't match source %s",
+				duration.Round(time.Second), want.Duration.Round(time.Second))
+		}
+	}
+
+	if got.Video != want.Streams.Video {
+		return fmt.Errorf("output has %d video stream(s), expected %d", got.Video, want.Streams.Video)
+	}
+	if got.Audio != want.Streams.Audio {
+		return fmt.Errorf("output has %d audio stream(s), expected %d", got.Audio, want.Streams.Audio)
+	}
+	if got.Subtitle != want.Streams.Subtitle {
+		return fmt.Errorf("output has %d subtitle stream(s), expected %d", got.Subtitle, want.Streams.Subtitle)
+	}
+	return nil
+}
+
+// VerifyDecode decodes the whole file without writing output, to catch
+// truncated or corrupt streams that still probe correctly.
+// Only a non-zero exit fails the check: -xerror makes FFmpeg exit on decode
+// errors, while benign messages (e.g. a damaged first packet the decoder
+// recovers from) are still logged at this level. Returns the first message
+// FFmpeg reported with the failure.
+func (t *Transcoder) VerifyDecode(ctx context.Context, path string) error {
+	cmd := exec.CommandContext(ctx, t.ffmpegPath,
+		"-hide_banner",
+		"-nostats",
+		"-v", "error",
+		"-xerror", // Stop at the first decode error
+		"-i", path,
+		"-map", "0:v",
+		"-map", "0:a?", // Subtitles have no null encoder and rarely truncate
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == nil {
+		return nil
+	}
+	if msg := firstLine(stderr.String()); msg != "" {
+		return fmt.Errorf("decode error: %s", msg)
+	}
+	return fmt.Errorf("decode failed: %w", err)
+}
+
+// firstLine returns the first non-empty line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
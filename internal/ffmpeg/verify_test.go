@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpectedStreams(t *testing.T) {
+	source := StreamCounts{Video: 2, Audio: 3, Subtitle: 4} // Cover art counts as video
+
+	tests := []struct {
+		name            string
+		audio           *AudioPlan
+		subtitleIndices []int
+		outputFormat    string
+		want            StreamCounts
+	}{
+		{"mkv maps everything", nil, nil, "mkv", StreamCounts{1, 3, 4}},
+		{"mp4 strips unprobed subtitles", nil, nil, "mp4", StreamCounts{1, 3, 0}},
+		{"selected subtitles", nil, []int{5, 7}, "mp4", StreamCounts{1, 3, 2}},
+		{"no compatible subtitles", nil, []int{}, "mkv", StreamCounts{1, 3, 0}},
+		{"audio plan", &AudioPlan{Tracks: []AudioTrack{{SourceIndex: 1, Codec: "copy"}}}, nil, "webm", StreamCounts{1, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpectedStreams(source, tt.audio, tt.subtitleIndices, tt.outputFormat); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	want := OutputExpectation{Duration: time.Hour, Streams: StreamCounts{Video: 1, Audio: 2, Subtitle: 1}}
+
+	tests := []struct {
+		name     string
+		got      StreamCounts
+		duration time.Duration
+		wantErr  string // "" = passes
+	}{
+		{"exact match", StreamCounts{1, 2, 1}, time.Hour, ""},
+		{"within tolerance", StreamCounts{1, 2, 1}, time.Hour - 30*time.Second, ""},
+		{"truncated", StreamCounts{1, 2, 1}, 40 * time.Minute, "duration"},
+		{"missing audio", StreamCounts{1, 1, 1}, time.Hour, "audio"},
+		{"missing subtitles", StreamCounts{1, 2, 0}, time.Hour, "subtitle"},
+		{"no video", StreamCounts{0, 2, 1}, time.Hour, "video"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutput(tt.got, tt.duration, want)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected %s error, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Short files use the minimum tolerance; unknown source duration isn't checked
+	short := OutputExpectation{Duration: 10 * time.Second, Streams: StreamCounts{Video: 1}}
+	if err := checkOutput(StreamCounts{Video: 1}, 11500*time.Millisecond, short); err != nil {
+		t.Errorf("expected 1.5s difference to pass on a short file: %v", err)
+	}
+	unknown := OutputExpectation{Streams: StreamCounts{Video: 1}}
+	if err := checkOutput(StreamCounts{Video: 1}, 0, unknown); err != nil {
+		t.Errorf("expected unknown duration to pass: %v", err)
+	}
+}
+
+func TestVerifyDecode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as ffmpeg")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{"clean", "exit 0", ""},
+		{"benign message", "echo 'Invalid NAL unit size, skipping' >&2; exit 0", ""},
+		{"decode error", "echo 'Error while decoding stream #0:0' >&2; exit 1", "decode error: Error while decoding stream #0:0"},
+		{"silent failure", "exit 1", "decode failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffmpegPath := filepath.Join(t.TempDir(), "ffmpeg")
+			if err := os.WriteFile(ffmpegPath, []byte("#!/bin/sh\n"+tt.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := NewTranscoder(ffmpegPath).VerifyDecode(context.Background(), "/media/movie.mkv")
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -92,7 +92,7 @@ func (a *Analyzer) Analyze(ctx context.Context, inputPath string, videoDuration
 		logger.Info("Binary search complete - no acceptable quality found", "duration", searchDuration.String())
 		return &AnalysisResult{
 			ShouldSkip: true,
-			SkipReason: "Already optimized",
+			SkipReason: SkipReasonAlreadyOptimized,
 		}, nil
 	}
 
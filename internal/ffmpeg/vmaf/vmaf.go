@@ -1,5 +1,9 @@
 package vmaf
 
+// SkipReasonAlreadyOptimized is the skip reason when no quality in range
+// meets the VMAF target, meaning the source can't be usefully shrunk
+const SkipReasonAlreadyOptimized = "Already optimized"
+
 // AnalysisResult holds the results of VMAF analysis
 type AnalysisResult struct {
 	OptimalCRF  int     // CRF/CQ/QP value (0 if bitrate-based)
@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
+)
+
+// spaceRetryInterval is how long GetNext passes over a job waiting for disk
+// space before a worker checks it again
+const spaceRetryInterval = time.Minute
+
+// spaceMargin is added to output size estimates to cover container overhead
+// and encoders overshooting their target
+const spaceMargin = 0.1
+
+// spaceClaim is the disk space a started job's output is expected to use.
+// Claims stop concurrent workers from counting the same free space twice.
+type spaceClaim struct {
+	need     int64  // Estimated output size
+	tempPath string // What is already written there no longer needs claiming
+	tempDev  uint64 // Filesystem of the temp file
+	destDev  uint64 // Filesystem the output is moved to (same as tempDev without a temp_path)
+}
+
+// outstanding returns how much of the claim is still unused on a filesystem
+func (c spaceClaim) outstanding(dev uint64) int64 {
+	switch dev {
+	case c.tempDev:
+		var written int64
+		if info, err := os.Stat(c.tempPath); err == nil {
+			written = info.Size()
+		}
+		return max(c.need-written, 0)
+	case c.destDev:
+		// The output is only copied across when the job finishes
+		return c.need
+	}
+	return 0
+}
+
+// estimateOutputSize estimates the disk space a job's output needs. Presets
+// with a target size or bitrate are bounded by it; otherwise the output is
+// assumed to be no larger than the input.
+func estimateOutputSize(job *Job, preset *ffmpeg.Preset) int64 {
+	size := job.InputSize
+	if preset.TargetSize > 0 {
+		size = preset.TargetSize
+	}
+	if preset.TargetBitrate > 0 && job.Duration > 0 {
+		size = min(size, preset.TargetBitrate/8*job.Duration/1000)
+	}
+	return size + int64(float64(size)*spaceMargin)
+}
+
+// freeSpace returns the bytes available on the filesystem holding path and
+// the filesystem's device ID. A path that doesn't exist yet (e.g. a temp
+// directory created on first use) is checked through its nearest parent.
+func freeSpace(path string) (int64, uint64, error) {
+	for {
+		var fs syscall.Statfs_t
+		err := syscall.Statfs(path, &fs)
+		if os.IsNotExist(err) && filepath.Dir(path) != path {
+			path = filepath.Dir(path)
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var st syscall.Stat_t
+		if err := syscall.Stat(path, &st); err != nil {
+			return 0, 0, err
+		}
+		return int64(fs.Bavail) * int64(fs.Bsize), uint64(st.Dev), nil
+	}
+}
+
+// claimSpace checks that a job's output fits on its temp and destination
+// filesystems, after the disk_space_reserve and the space claimed by jobs
+// already running. If it fits the space is claimed until releaseSpace and
+// claimed is true; otherwise it returns why the job can't start. Jobs that
+// can't be checked are let through unclaimed. If another worker already holds
+// the job's claim (both got it from GetNext), busy is true and the job is
+// left to that worker.
+func (p *WorkerPool) claimSpace(job *Job, preset *ffmpeg.Preset, tempDir, tempPath string) (claimed, busy bool, reason string) {
+	if p.cfg.DiskSpaceAction == "off" {
+		return false, false, ""
+	}
+
+	var reserve int64
+	if p.cfg.DiskSpaceReserve != "" {
+		reserve, _ = util.ParseBytes(p.cfg.DiskSpaceReserve)
+	}
+	claim := spaceClaim{need: estimateOutputSize(job, preset), tempPath: tempPath}
+	destDir := filepath.Dir(job.InputPath)
+
+	p.spaceMu.Lock()
+	defer p.spaceMu.Unlock()
+
+	if _, ok := p.spaceClaims[job.ID]; ok {
+		return false, true, ""
+	}
+
+	for i, dir := range []string{tempDir, destDir} {
+		free, dev, err := freeSpace(dir)
+		if err != nil {
+			logger.Warn("Failed to check free disk space", "job_id", job.ID, "dir", dir, "error", err)
+			return false, false, ""
+		}
+		if i == 0 {
+			claim.tempDev = dev
+		} else {
+			claim.destDev = dev
+			if dev == claim.tempDev {
+				break // Already checked
+			}
+		}
+
+		for _, other := range p.spaceClaims {
+			free -= other.outstanding(dev)
+		}
+		if free-reserve < claim.need {
+			return false, false, fmt.Sprintf("Not enough disk space in %s: needs %s, %s free",
+				dir, util.FormatBytes(claim.need+reserve), util.FormatBytes(free))
+		}
+	}
+
+	if p.spaceClaims == nil {
+		p.spaceClaims = make(map[string]spaceClaim)
+	}
+	p.spaceClaims[job.ID] = claim
+	return true, false, ""
+}
+
+// releaseSpace drops a job's space claim once it has finished
+func (p *WorkerPool) releaseSpace(jobID string) {
+	p.spaceMu.Lock()
+	defer p.spaceMu.Unlock()
+	delete(p.spaceClaims, jobID)
+}
@@ -10,6 +10,8 @@ import (
 var (
 	ErrJobNotFound   = errors.New("job not found")
 	ErrJobNotRunning = errors.New("job is not running")
+	ErrJobNotPending = errors.New("job is not pending")
+	ErrJobTerminal   = errors.New("job already in terminal state")
 )
 
 // jobNotFoundError returns a wrapped error for a missing job.
@@ -21,3 +23,8 @@ func jobNotFoundError(id string) error {
 func jobNotRunningError(id string, status Status) error {
 	return fmt.Errorf("%w (status: %s): %s", ErrJobNotRunning, status, id)
 }
+
+// jobNotPendingError returns a wrapped error for a job that has already started.
+func jobNotPendingError(id string, status Status) error {
+	return fmt.Errorf("%w (status: %s): %s", ErrJobNotPending, status, id)
+}
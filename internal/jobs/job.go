@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"strings"
 	"time"
 )
 
@@ -14,15 +15,17 @@ const (
 	StatusFailed    Status = "failed"
 	StatusCancelled Status = "cancelled"
 	StatusSkipped   Status = "skipped"
+	StatusReverted  Status = "reverted" // Completed, then the original was restored and the output removed
 )
 
-// Phase represents the current phase of a SmartShrink job
+// Phase represents the current phase of a running job (SmartShrink analysis, output verification)
 type Phase string
 
 const (
 	PhaseNone      Phase = ""          // Regular presets or not yet started
 	PhaseAnalyzing Phase = "analyzing" // SmartShrink: sample extraction + binary search
 	PhaseEncoding  Phase = "encoding"  // SmartShrink: full transcode
+	PhaseVerifying Phase = "verifying" // Checking the output before it replaces the original
 )
 
 // Job represents a transcoding job
@@ -59,14 +62,40 @@ type Job struct {
 	QualityMod  float64 `json:"quality_mod,omitempty"`   // Bitrate modifier for VideoToolbox (0.0-1.0)
 	SkipReason         string `json:"skip_reason,omitempty"`          // Reason for skip status
 	SmartShrinkQuality string `json:"smartshrink_quality,omitempty"` // Quality tier: acceptable, good, excellent
+	SubtitleNote       string `json:"subtitle_note,omitempty"`       // Subtitle streams dropped or extracted (e.g. image subs in MP4)
+	Crop               string `json:"crop,omitempty"`                // Detected black-bar crop (w:h:x:y), empty if not cropped
+	FieldOrder         string `json:"field_order,omitempty"`         // Source field_order flag (progressive, tt, bb, etc.)
+	ScanType           string `json:"scan_type,omitempty"`           // Detected scan type (progressive, interlaced, telecined)
+	OriginalPath       string `json:"original_path,omitempty"`       // Where the original was kept (.old sibling or trash), empty if deleted
+	Sidecars           string `json:"sidecars,omitempty"`            // Subtitle sidecar files written next to the output, one path per line
+	SpaceWait          string `json:"space_wait,omitempty"`          // Why a pending job is waiting for disk space (not persisted)
+	Priority           int    `json:"priority,omitempty"`            // Higher runs first; equal priorities run in queue order
+	Overrides                 // Per-job settings fixed when the job was queued
 	CreatedAt          time.Time `json:"created_at"`
 	StartedAt   time.Time `json:"started_at,omitempty"`
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 }
 
+// Overrides are per-job settings that take precedence over the config.
+// Zero values follow the config (and the media root) at encode time.
+type Overrides struct {
+	Quality          int    `json:"quality,omitempty"`           // CRF/CQ for the preset's encoder
+	OutputFormat     string `json:"output_format,omitempty"`     // mkv, mp4 or webm
+	Tonemap          string `json:"tonemap,omitempty"`           // "on" or "off" to tonemap HDR sources to SDR
+	OriginalHandling string `json:"original_handling,omitempty"` // replace, keep or trash
+}
+
 // IsTerminal returns true if the job is in a terminal state
 func (j *Job) IsTerminal() bool {
-	return j.Status == StatusComplete || j.Status == StatusFailed || j.Status == StatusCancelled || j.Status == StatusSkipped
+	return j.Status == StatusComplete || j.Status == StatusFailed || j.Status == StatusCancelled || j.Status == StatusSkipped || j.Status == StatusReverted
+}
+
+// SidecarPaths returns the subtitle sidecar files the job wrote
+func (j *Job) SidecarPaths() []string {
+	if j.Sidecars == "" {
+		return nil
+	}
+	return strings.Split(j.Sidecars, "\n")
 }
 
 // Copy returns a shallow copy of the job (safe since Job has no pointer/slice fields)
@@ -77,7 +106,7 @@ func (j *Job) Copy() *Job {
 
 // JobEvent represents an event for SSE streaming
 type JobEvent struct {
-	Type   string `json:"type"`            // "added", "jobs_added", "discovery_progress", "complete", "failed", "skipped", "cancelled", "progress"
+	Type   string `json:"type"`            // "added", "jobs_added", "discovery_progress", "complete", "failed", "skipped", "cancelled", "reverted", "waiting", "updated", "reordered", "progress"
 	Job    *Job   `json:"job,omitempty"`   // Single job for most events
 	Count  int    `json:"count,omitempty"` // Number of jobs for batch events (jobs_added)
 	Probed int    `json:"probed,omitempty"` // Files probed so far (discovery_progress)
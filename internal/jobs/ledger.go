@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/logger"
+)
+
+// Outcomes recorded in the processed-file ledger
+const (
+	ProcessedTranscoded = "transcoded" // Output of a completed job
+	ProcessedOptimized  = "optimized"  // SmartShrink found it already optimized
+)
+
+// ProcessedFile is a ledger record of a file Shrinkray has already handled.
+// It only applies while the file's size and modification time are unchanged,
+// so a replaced file (e.g. a new release at the same path) is processed again.
+type ProcessedFile struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Outcome     string    `json:"outcome"`
+	PresetID    string    `json:"preset_id"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// Matches returns true if the record describes the probed file as it is now
+func (f *ProcessedFile) Matches(probe *ffmpeg.ProbeResult) bool {
+	return f.Size == probe.Size && f.ModTime.Equal(probe.ModTime)
+}
+
+// SkipReason returns the skip reason for a file found in the ledger
+func (f *ProcessedFile) SkipReason() string {
+	reason := fmt.Sprintf("Previously processed on %s", f.ProcessedAt.Format("2006-01-02"))
+	if f.Outcome == ProcessedOptimized {
+		reason += " (already optimized)"
+	}
+	return reason
+}
+
+// StoreWithLedger extends Store with a ledger of processed files, which
+// outlives the jobs that produced them. Queues backed by such a store skip
+// files that were already transcoded or found to be already optimized.
+type StoreWithLedger interface {
+	Store
+
+	// GetProcessedFile returns the ledger record for a path, or nil if there is none.
+	GetProcessedFile(path string) (*ProcessedFile, error)
+
+	// SaveProcessedFile records a processed file, replacing any record for the same path.
+	SaveProcessedFile(file *ProcessedFile) error
+
+	// DeleteProcessedFile removes the record for a path (no error if there is none).
+	DeleteProcessedFile(path string) error
+}
+
+// ledger returns the store's ledger, or nil if it doesn't keep one
+func (q *Queue) ledger() StoreWithLedger {
+	l, _ := q.store.(StoreWithLedger)
+	return l
+}
+
+// processedRecords looks up the ledger records that still match the probed
+// files, keyed by path. Called without the queue lock held.
+func (q *Queue) processedRecords(probes []*ffmpeg.ProbeResult) map[string]*ProcessedFile {
+	l := q.ledger()
+	if l == nil {
+		return nil
+	}
+
+	records := make(map[string]*ProcessedFile)
+	for _, probe := range probes {
+		f, err := l.GetProcessedFile(probe.Path)
+		if err != nil {
+			logger.Warn("Failed to read processed-file ledger", "path", probe.Path, "error", err)
+			continue
+		}
+		if f != nil && f.Matches(probe) {
+			records[probe.Path] = f
+		}
+	}
+	return records
+}
+
+// recordProcessed adds a file to the ledger as it is now on disk.
+// Called with lock held.
+func (q *Queue) recordProcessed(path, outcome, presetID string) {
+	l := q.ledger()
+	if l == nil || path == "" {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.Debug("Not recording processed file", "path", path, "error", err)
+		return
+	}
+
+	file := &ProcessedFile{
+		Path:        path,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Outcome:     outcome,
+		PresetID:    presetID,
+		ProcessedAt: time.Now(),
+	}
+	if err := l.SaveProcessedFile(file); err != nil {
+		logger.Warn("Failed to record processed file", "path", path, "error", err)
+	}
+}
+
+// GetProcessedFile returns the ledger record for a path, or nil if the file
+// hasn't been processed or the store keeps no ledger
+func (q *Queue) GetProcessedFile(path string) (*ProcessedFile, error) {
+	l := q.ledger()
+	if l == nil {
+		return nil, nil
+	}
+	return l.GetProcessedFile(path)
+}
+
+// ForgetProcessedFile removes a file from the ledger so it can be queued again
+func (q *Queue) ForgetProcessedFile(path string) error {
+	l := q.ledger()
+	if l == nil {
+		return nil
+	}
+	return l.DeleteProcessedFile(path)
+}
@@ -2,10 +2,14 @@ package jobs
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/gwlsn/shrinkray/internal/config"
 	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
 	"github.com/gwlsn/shrinkray/internal/logger"
 )
 
@@ -24,6 +28,7 @@ type Store interface {
 	SetOrder(order []string) error
 	ResetRunningJobs() (int, error)
 	AddToLifetimeSaved(bytes int64) error
+	SubtractFromLifetimeSaved(bytes int64) error
 	Close() error
 }
 
@@ -46,7 +51,11 @@ type Queue struct {
 	subscribers map[chan JobEvent]struct{}
 
 	// Config options
-	allowSameCodec bool // Allow transcoding files already in target codec
+	allowSameCodec bool           // Allow transcoding files already in target codec
+	cfg            *config.Config // Settings resolved onto new jobs (nil = read by the worker)
+
+	// When jobs waiting for disk space are next checked, keyed by job ID
+	spaceRetry map[string]time.Time
 }
 
 // NewQueue creates a new in-memory job queue (for testing).
@@ -92,6 +101,55 @@ func (q *Queue) SetAllowSameCodec(allow bool) {
 	q.allowSameCodec = allow
 }
 
+// SetConfig sets the config that new jobs' settings are resolved from.
+// Without it, settings a job doesn't override are read when it runs.
+func (q *Queue) SetConfig(cfg *config.Config) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+}
+
+// resolveOverrides fills in the settings a job doesn't override from its
+// media root and the config, so changing the config doesn't affect jobs
+// already queued. Called with lock held.
+func (q *Queue) resolveOverrides(inputPath string, preset *ffmpeg.Preset, o Overrides) Overrides {
+	if q.cfg == nil {
+		return o
+	}
+
+	// SmartShrink and target presets choose their own rate control
+	if o.Quality == 0 && preset != nil && !preset.IsSmartShrink && !preset.HasTarget() {
+		o.Quality = preset.Quality
+		hevcDefault, av1Default := ffmpeg.GetEncoderDefaults(preset.Encoder)
+		switch {
+		case o.Quality > 0:
+		case preset.Codec == ffmpeg.CodecHEVC:
+			o.Quality = q.cfg.QualityHEVC
+			if o.Quality == 0 {
+				o.Quality = hevcDefault // 0 in the config means the encoder default
+			}
+		case preset.Codec == ffmpeg.CodecAV1:
+			o.Quality = q.cfg.QualityAV1
+			if o.Quality == 0 {
+				o.Quality = av1Default
+			}
+		}
+	}
+	if o.OutputFormat == "" {
+		o.OutputFormat = q.cfg.OutputFormatFor(inputPath)
+	}
+	if o.Tonemap == "" {
+		o.Tonemap = "off"
+		if q.cfg.TonemapHDR {
+			o.Tonemap = "on"
+		}
+	}
+	if o.OriginalHandling == "" {
+		o.OriginalHandling = q.cfg.OriginalHandlingFor(inputPath)
+	}
+	return o
+}
+
 // persist saves a job to the store (if configured).
 // Called with lock held.
 func (q *Queue) persist(job *Job) {
@@ -114,6 +172,17 @@ func (q *Queue) persistOrder(id string) {
 	}
 }
 
+// persistFullOrder saves the whole job order to the store (if configured).
+// Called with lock held.
+func (q *Queue) persistFullOrder() {
+	if q.store == nil {
+		return
+	}
+	if err := q.store.SetOrder(q.order); err != nil {
+		logger.Warn("Failed to persist job order", "error", err)
+	}
+}
+
 // persistDelete removes a job from the store (if configured).
 // Called with lock held.
 func (q *Queue) persistDelete(id string) {
@@ -127,6 +196,15 @@ func (q *Queue) persistDelete(id string) {
 
 // Add adds a new job to the queue
 func (q *Queue) Add(inputPath string, presetID string, probe *ffmpeg.ProbeResult, smartShrinkQuality string) (*Job, error) {
+	return q.AddWithOverrides(inputPath, presetID, probe, smartShrinkQuality, Overrides{})
+}
+
+// AddWithOverrides adds a new job whose overrides take precedence over the config.
+// Settings it doesn't override are resolved from the config now (see SetConfig).
+func (q *Queue) AddWithOverrides(inputPath string, presetID string, probe *ffmpeg.ProbeResult, smartShrinkQuality string, overrides Overrides) (*Job, error) {
+	// Consult the ledger before taking the lock (it reads from the store)
+	processed := q.processedRecords([]*ffmpeg.ProbeResult{probe})
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -140,10 +218,7 @@ func (q *Queue) Add(inputPath string, presetID string, probe *ffmpeg.ProbeResult
 	}
 
 	// Check if file should be skipped - get metadata from preset or base definitions
-	var skipReason string
-	if meta := getPresetMeta(preset, presetID); meta != nil {
-		skipReason = checkSkipReason(probe, meta, q.allowSameCodec)
-	}
+	skipReason := q.skipReason(probe, getPresetMeta(preset, presetID), processed)
 
 	status := StatusPending
 	if skipReason != "" {
@@ -171,6 +246,8 @@ func (q *Queue) Add(inputPath string, presetID string, probe *ffmpeg.ProbeResult
 		BitDepth:           probe.BitDepth,
 		IsHDR:              probe.IsHDR,
 		ColorTransfer:      probe.ColorTransfer,
+		FieldOrder:         probe.FieldOrder,
+		Overrides:          q.resolveOverrides(inputPath, preset, overrides),
 		CreatedAt:          time.Now(),
 	}
 
@@ -193,6 +270,14 @@ func (q *Queue) Add(inputPath string, presetID string, probe *ffmpeg.ProbeResult
 
 // AddMultiple adds multiple jobs at once with batched persistence and SSE
 func (q *Queue) AddMultiple(probes []*ffmpeg.ProbeResult, presetID string, smartShrinkQuality string) ([]*Job, error) {
+	return q.AddMultipleWithOverrides(probes, presetID, smartShrinkQuality, Overrides{})
+}
+
+// AddMultipleWithOverrides adds multiple jobs that share the same overrides
+func (q *Queue) AddMultipleWithOverrides(probes []*ffmpeg.ProbeResult, presetID string, smartShrinkQuality string, overrides Overrides) ([]*Job, error) {
+	// Consult the ledger before taking the lock (it reads from the store)
+	processed := q.processedRecords(probes)
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -214,10 +299,7 @@ func (q *Queue) AddMultiple(probes []*ffmpeg.ProbeResult, presetID string, smart
 
 	for _, probe := range probes {
 		// Check if file should be skipped
-		var skipReason string
-		if meta != nil {
-			skipReason = checkSkipReason(probe, meta, q.allowSameCodec)
-		}
+		skipReason := q.skipReason(probe, meta, processed)
 
 		status := StatusPending
 		if skipReason != "" {
@@ -248,6 +330,8 @@ func (q *Queue) AddMultiple(probes []*ffmpeg.ProbeResult, presetID string, smart
 			BitDepth:           probe.BitDepth,
 			IsHDR:              probe.IsHDR,
 			ColorTransfer:      probe.ColorTransfer,
+			FieldOrder:         probe.FieldOrder,
+			Overrides:          q.resolveOverrides(probe.Path, preset, overrides),
 			CreatedAt:          time.Now(),
 		}
 
@@ -275,6 +359,59 @@ func (q *Queue) AddMultiple(probes []*ffmpeg.ProbeResult, presetID string, smart
 	return jobList, nil
 }
 
+// SkipReason returns why a file would be skipped with the given preset,
+// or an empty string if it would be transcoded
+func (q *Queue) SkipReason(probe *ffmpeg.ProbeResult, presetID string) string {
+	processed := q.processedRecords([]*ffmpeg.ProbeResult{probe})
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.skipReason(probe, getPresetMeta(ffmpeg.GetPreset(presetID), presetID), processed)
+}
+
+// skipReason returns why a file should be skipped, or an empty string.
+// Files already processed with any preset (per the ledger records in
+// processed) are skipped even when allowSameCodec is set; forgetting the
+// file clears its record.
+// Called with lock held.
+func (q *Queue) skipReason(probe *ffmpeg.ProbeResult, meta *ffmpeg.BasePresetMeta, processed map[string]*ProcessedFile) string {
+	if f := processed[probe.Path]; f != nil {
+		return f.SkipReason()
+	}
+	if meta == nil {
+		return ""
+	}
+	return checkSkipReason(probe, meta, q.allowSameCodec)
+}
+
+// HasActiveJob returns true if a pending or running job exists for the input path
+func (q *Queue) HasActiveJob(inputPath string) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for _, job := range q.jobs {
+		if job.InputPath == inputPath && (job.Status == StatusPending || job.Status == StatusRunning) {
+			return true
+		}
+	}
+	return false
+}
+
+// LastJob returns a copy of the most recently created job for the input path,
+// or nil if there is none
+func (q *Queue) LastJob(inputPath string) *Job {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for i := len(q.order) - 1; i >= 0; i-- {
+		if job := q.jobs[q.order[i]]; job != nil && job.InputPath == inputPath {
+			return job.Copy()
+		}
+	}
+	return nil
+}
+
 // Get returns a job by ID
 func (q *Queue) Get(id string) *Job {
 	q.mu.RLock()
@@ -296,16 +433,56 @@ func (q *Queue) GetAll() []*Job {
 	return jobs
 }
 
-// GetNext returns the next pending job (for workers to pick up)
+// GetNext returns the next pending job (for workers to pick up): the one
+// with the highest priority, first in queue order among equals.
+// Jobs waiting for disk space are passed over until their next check.
 func (q *Queue) GetNext() *Job {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 
+	var next *Job
+	now := time.Now()
 	for _, id := range q.order {
-		if job, ok := q.jobs[id]; ok && job.Status == StatusPending {
-			return job
+		job, ok := q.jobs[id]
+		if !ok || job.Status != StatusPending {
+			continue
+		}
+		if retry, waiting := q.spaceRetry[id]; waiting && now.Before(retry) {
+			continue
 		}
+		if next == nil || job.Priority > next.Priority {
+			next = job
+		}
+	}
+	return next
+}
+
+// WaitForSpace holds a pending job that doesn't fit on disk. It stays
+// pending with the reason in SpaceWait, and is checked again after
+// spaceRetryInterval. Not persisted: jobs are rechecked after a restart.
+func (q *Queue) WaitForSpace(id, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
 	}
+
+	if job.Status != StatusPending {
+		return fmt.Errorf("job not pending: %s", job.Status)
+	}
+
+	if q.spaceRetry == nil {
+		q.spaceRetry = make(map[string]time.Time)
+	}
+	q.spaceRetry[id] = time.Now().Add(spaceRetryInterval)
+
+	if job.SpaceWait != reason {
+		job.SpaceWait = reason
+		q.broadcast(JobEvent{Type: "waiting", Job: job.Copy()})
+	}
+
 	return nil
 }
 
@@ -326,6 +503,8 @@ func (q *Queue) StartJob(id string, tempPath string) error {
 	job.Status = StatusRunning
 	job.TempPath = tempPath
 	job.StartedAt = time.Now()
+	job.SpaceWait = ""
+	delete(q.spaceRetry, id)
 
 	q.persist(job)
 	q.broadcast(JobEvent{Type: "started", Job: job.Copy()})
@@ -373,6 +552,7 @@ func (q *Queue) CompleteJob(id string, outputPath string, outputSize int64) erro
 	job.TempPath = "" // Clear temp path
 
 	q.persist(job)
+	q.recordProcessed(outputPath, ProcessedTranscoded, job.PresetID)
 
 	// Update session/lifetime saved counters
 	if q.store != nil && job.SpaceSaved > 0 {
@@ -386,6 +566,45 @@ func (q *Queue) CompleteJob(id string, outputPath string, outputSize int64) erro
 	return nil
 }
 
+// RevertJob marks a completed job as reverted once its original has been
+// restored, and takes its space saved back out of the session/lifetime counters
+func (q *Queue) RevertJob(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.Status != StatusComplete {
+		return fmt.Errorf("can only revert completed jobs, got: %s", job.Status)
+	}
+
+	job.Status = StatusReverted
+	job.OriginalPath = "" // The original is back at InputPath
+	job.Sidecars = ""     // Removed with the output
+
+	q.persist(job)
+
+	// The output is gone, so the original may be transcoded again
+	if l := q.ledger(); l != nil {
+		if err := l.DeleteProcessedFile(job.OutputPath); err != nil {
+			logger.Warn("Failed to update processed-file ledger", "path", job.OutputPath, "error", err)
+		}
+	}
+
+	if q.store != nil && job.SpaceSaved > 0 {
+		if err := q.store.SubtractFromLifetimeSaved(job.SpaceSaved); err != nil {
+			logger.Warn("Failed to update saved stats", "error", err)
+		}
+	}
+
+	q.broadcast(JobEvent{Type: "reverted", Job: job.Copy()})
+
+	return nil
+}
+
 // FailJob marks a job as failed
 func (q *Queue) FailJob(id string, errMsg string) error {
 	q.mu.Lock()
@@ -437,6 +656,9 @@ func (q *Queue) SkipJob(id, reason string) error {
 
 	// Use persist helper (handles nil store)
 	q.persist(job)
+	if reason == vmaf.SkipReasonAlreadyOptimized {
+		q.recordProcessed(job.InputPath, ProcessedOptimized, job.PresetID)
+	}
 
 	q.broadcast(JobEvent{Type: "skipped", Job: job.Copy()})
 
@@ -492,6 +714,113 @@ func (q *Queue) UpdateJobVMAFResult(id string, vmafScore float64, selectedCRF in
 	return nil
 }
 
+// UpdateJobSubtitleNote records which subtitle streams were dropped or
+// extracted for a running job
+func (q *Queue) UpdateJobSubtitleNote(id, note string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.Status != StatusRunning {
+		return jobNotRunningError(id, job.Status)
+	}
+
+	job.SubtitleNote = note
+
+	q.persist(job)
+
+	return nil
+}
+
+// UpdateJobSidecars records the subtitle sidecar files written for a running job
+func (q *Queue) UpdateJobSidecars(id string, paths []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.Status != StatusRunning {
+		return jobNotRunningError(id, job.Status)
+	}
+
+	job.Sidecars = strings.Join(paths, "\n")
+
+	q.persist(job)
+
+	return nil
+}
+
+// UpdateJobCrop records the detected black-bar crop (w:h:x:y) for a running job
+func (q *Queue) UpdateJobCrop(id, crop string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.Status != StatusRunning {
+		return jobNotRunningError(id, job.Status)
+	}
+
+	job.Crop = crop
+
+	q.persist(job)
+
+	return nil
+}
+
+// UpdateJobScanType records the detected scan type (interlaced, telecined) for a running job
+func (q *Queue) UpdateJobScanType(id, scanType string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.Status != StatusRunning {
+		return jobNotRunningError(id, job.Status)
+	}
+
+	job.ScanType = scanType
+
+	q.persist(job)
+
+	return nil
+}
+
+// UpdateJobOriginalPath records where the original was kept (.old sibling
+// or trash) for a running job, just before it completes
+func (q *Queue) UpdateJobOriginalPath(id, path string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.Status != StatusRunning {
+		return jobNotRunningError(id, job.Status)
+	}
+
+	job.OriginalPath = path
+
+	q.persist(job)
+
+	return nil
+}
+
 // CancelJob cancels a job
 func (q *Queue) CancelJob(id string) error {
 	q.mu.Lock()
@@ -503,11 +832,13 @@ func (q *Queue) CancelJob(id string) error {
 	}
 
 	if job.IsTerminal() {
-		return fmt.Errorf("job already in terminal state: %s", job.Status)
+		return fmt.Errorf("%w: %s", ErrJobTerminal, job.Status)
 	}
 
 	job.Status = StatusCancelled
 	job.CompletedAt = time.Now()
+	job.SpaceWait = ""
+	delete(q.spaceRetry, id)
 
 	q.persist(job)
 	q.broadcast(JobEvent{Type: "cancelled", Job: job.Copy()})
@@ -537,6 +868,7 @@ func (q *Queue) Requeue(id string) error {
 	job.ETA = ""
 	job.TempPath = ""
 	job.StartedAt = time.Time{}
+	job.Phase = PhaseNone
 
 	// Move to front of order (in memory)
 	newOrder := []string{id}
@@ -549,13 +881,125 @@ func (q *Queue) Requeue(id string) error {
 
 	// Persist job and order
 	q.persist(job)
-	if q.store != nil {
-		if err := q.store.SetOrder(q.order); err != nil {
-			logger.Warn("Failed to persist job order", "error", err)
+	q.persistFullOrder()
+
+	q.broadcast(JobEvent{Type: "requeued", Job: job.Copy()})
+
+	return nil
+}
+
+// Reorder puts the given jobs in the given order. They take the queue
+// positions they held between them, so unlisted jobs don't move: passing
+// every pending job reorders the pending part of the queue.
+func (q *Queue) Reorder(ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := q.jobs[id]; !ok {
+			return jobNotFoundError(id)
+		}
+		if listed[id] {
+			return fmt.Errorf("job listed twice: %s", id)
 		}
+		listed[id] = true
 	}
 
-	q.broadcast(JobEvent{Type: "requeued", Job: job.Copy()})
+	next := 0
+	for i, id := range q.order {
+		if listed[id] {
+			q.order[i] = ids[next]
+			next++
+		}
+	}
+
+	q.persistFullOrder()
+	q.broadcast(JobEvent{Type: "reordered"})
+
+	return nil
+}
+
+// MoveJob moves a pending job to just before the job with targetID, or
+// just after it if after is set
+func (q *Queue) MoveJob(id, targetID string, after bool) error {
+	if id == targetID {
+		return fmt.Errorf("can't move a job relative to itself: %s", id)
+	}
+	return q.move(id, func(order []string) (int, error) {
+		for i, oid := range order {
+			if oid == targetID {
+				if after {
+					return i + 1, nil
+				}
+				return i, nil
+			}
+		}
+		return 0, jobNotFoundError(targetID)
+	})
+}
+
+// MoveToTop moves a pending job to the front of the queue
+func (q *Queue) MoveToTop(id string) error {
+	return q.move(id, func([]string) (int, error) { return 0, nil })
+}
+
+// MoveToBottom moves a pending job to the back of the queue
+func (q *Queue) MoveToBottom(id string) error {
+	return q.move(id, func(order []string) (int, error) { return len(order), nil })
+}
+
+// move takes a pending job out of the order and reinserts it at the index
+// position returns for the remaining order
+func (q *Queue) move(id string, position func(order []string) (int, error)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+	if job.Status != StatusPending {
+		return jobNotPendingError(id, job.Status)
+	}
+
+	order := make([]string, 0, len(q.order))
+	for _, oid := range q.order {
+		if oid != id {
+			order = append(order, oid)
+		}
+	}
+	i, err := position(order)
+	if err != nil {
+		return err
+	}
+	q.order = slices.Insert(order, i, id)
+
+	q.persistFullOrder()
+	q.broadcast(JobEvent{Type: "reordered"})
+
+	return nil
+}
+
+// SetPriority sets the priority of a job that hasn't finished. GetNext picks
+// the highest priority pending job; equal priorities run in queue order.
+func (q *Queue) SetPriority(id string, priority int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return jobNotFoundError(id)
+	}
+
+	if job.IsTerminal() {
+		return fmt.Errorf("%w: %s", ErrJobTerminal, job.Status)
+	}
+
+	job.Priority = priority
+
+	q.persist(job)
+	q.broadcast(JobEvent{Type: "updated", Job: job.Copy()})
 
 	return nil
 }
@@ -587,6 +1031,7 @@ func (q *Queue) Clear(filterStatus Status) int {
 		// Clear this job
 		q.persistDelete(id)
 		delete(q.jobs, id)
+		delete(q.spaceRetry, id)
 		count++
 	}
 	q.order = newOrder
@@ -601,6 +1046,7 @@ func (q *Queue) Remove(id string) {
 
 	q.persistDelete(id)
 	delete(q.jobs, id)
+	delete(q.spaceRetry, id)
 
 	// Remove from order slice
 	newOrder := make([]string, 0, len(q.order))
@@ -658,18 +1104,26 @@ func (q *Queue) BroadcastProgress(probed, total int) {
 	})
 }
 
+// BroadcastNotifySent tells subscribers that the queue-drained summary was
+// sent. The web UI ignores it; it is part of the SSE API for other clients.
+func (q *Queue) BroadcastNotifySent() {
+	q.broadcast(JobEvent{Type: "notify_sent"})
+}
+
 // Stats returns queue statistics
 type Stats struct {
-	Pending       int   `json:"pending"`
-	Running       int   `json:"running"`
-	Complete      int   `json:"complete"`
-	Failed        int   `json:"failed"`
-	Cancelled     int   `json:"cancelled"`
-	Skipped       int   `json:"skipped"`
-	Total         int   `json:"total"`
-	TotalSaved    int64 `json:"total_saved"`    // For API compatibility (= session_saved)
-	SessionSaved  int64 `json:"session_saved"`  // Bytes saved this session
-	LifetimeSaved int64 `json:"lifetime_saved"` // All-time bytes saved
+	Pending         int   `json:"pending"`
+	Running         int   `json:"running"`
+	Complete        int   `json:"complete"`
+	Failed          int   `json:"failed"`
+	Cancelled       int   `json:"cancelled"`
+	Skipped         int   `json:"skipped"`
+	Reverted        int   `json:"reverted"`
+	WaitingForSpace int   `json:"waiting_for_space"` // Pending jobs held until disk space frees up
+	Total           int   `json:"total"`
+	TotalSaved      int64 `json:"total_saved"`    // For API compatibility (= session_saved)
+	SessionSaved    int64 `json:"session_saved"`  // Bytes saved this session
+	LifetimeSaved   int64 `json:"lifetime_saved"` // All-time bytes saved
 }
 
 func (q *Queue) Stats() Stats {
@@ -682,6 +1136,9 @@ func (q *Queue) Stats() Stats {
 		switch job.Status {
 		case StatusPending:
 			stats.Pending++
+			if job.SpaceWait != "" {
+				stats.WaitingForSpace++
+			}
 		case StatusRunning:
 			stats.Running++
 		case StatusComplete:
@@ -693,6 +1150,8 @@ func (q *Queue) Stats() Stats {
 			stats.Cancelled++
 		case StatusSkipped:
 			stats.Skipped++
+		case StatusReverted:
+			stats.Reverted++
 		}
 	}
 
@@ -744,6 +1203,12 @@ func checkSkipReason(probe *ffmpeg.ProbeResult, meta *ffmpeg.BasePresetMeta, all
 	case ffmpeg.CodecAV1:
 		isAlreadyTarget = probe.IsAV1
 		codecName = "AV1"
+	case ffmpeg.CodecH264:
+		isAlreadyTarget = probe.VideoCodec == "h264"
+		codecName = "H.264"
+	case ffmpeg.CodecVP9:
+		isAlreadyTarget = probe.VideoCodec == "vp9"
+		codecName = "VP9"
 	}
 
 	if isAlreadyTarget && !allowSameCodec {
@@ -1,11 +1,17 @@
 package jobs_test
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gwlsn/shrinkray/internal/config"
 	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
 	"github.com/gwlsn/shrinkray/internal/jobs"
 	"github.com/gwlsn/shrinkray/internal/store"
 )
@@ -166,6 +172,91 @@ func TestQueuePersistence(t *testing.T) {
 	t.Log("Queue persisted and loaded successfully")
 }
 
+func TestQueueOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbFile := filepath.Join(tmpDir, "test.db")
+
+	store1, err := store.NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	queue1, err := jobs.NewQueueWithStore(store1)
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+
+	probes := []*ffmpeg.ProbeResult{
+		{Path: "/media/a.mkv", Size: 1000000, Duration: 10 * time.Second},
+		{Path: "/media/b.mkv", Size: 1000000, Duration: 10 * time.Second},
+	}
+	mp4 := jobs.Overrides{Quality: 24, OutputFormat: "mp4", Tonemap: "on", OriginalHandling: "keep"}
+	batch, _ := queue1.AddMultipleWithOverrides(probes, "compress", "", mp4)
+	plain, _ := queue1.Add("/media/c.mkv", "compress", &ffmpeg.ProbeResult{Path: "/media/c.mkv", Size: 1000000, Duration: 10 * time.Second}, "")
+	store1.Close()
+
+	// Overrides survive a restart; jobs without them follow the config
+	store2, err := store.NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store2.Close()
+	queue2, err := jobs.NewQueueWithStore(store2)
+	if err != nil {
+		t.Fatalf("failed to load queue: %v", err)
+	}
+
+	for _, job := range batch {
+		if got := queue2.Get(job.ID); got == nil || got.Overrides != mp4 {
+			t.Errorf("expected overrides %+v, got %+v", mp4, got)
+		}
+	}
+	if got := queue2.Get(plain.ID); got == nil || got.Overrides != (jobs.Overrides{}) {
+		t.Errorf("expected no overrides, got %+v", got)
+	}
+}
+
+func TestQueueResolvesSettings(t *testing.T) {
+	cfg := &config.Config{
+		QualityHEVC:      24,
+		OutputFormat:     "mkv",
+		OriginalHandling: "replace",
+		MediaRoots: []config.MediaRoot{
+			{Name: "Movies", Path: "/media/movies"},
+			{Name: "TV", Path: "/media/tv", OutputFormat: "mp4", OriginalHandling: "keep"},
+		},
+	}
+	queue := jobs.NewQueue()
+	queue.SetConfig(cfg)
+
+	probe := func(path string) *ffmpeg.ProbeResult {
+		return &ffmpeg.ProbeResult{Path: path, Size: 1000000, Duration: 10 * time.Second}
+	}
+	movie, _ := queue.Add("/media/movies/a.mkv", "compress-hevc", probe("/media/movies/a.mkv"), "")
+	batch, _ := queue.AddMultipleWithOverrides([]*ffmpeg.ProbeResult{probe("/media/tv/b.mkv")}, "compress-hevc", "", jobs.Overrides{Quality: 30})
+
+	// Settings are fixed when queued, from the job's media root and the config
+	cfg.QualityHEVC = 28
+	cfg.OutputFormat = "mp4"
+	cfg.TonemapHDR = true
+
+	if want := (jobs.Overrides{Quality: 24, OutputFormat: "mkv", Tonemap: "off", OriginalHandling: "replace"}); movie.Overrides != want {
+		t.Errorf("expected settings %+v, got %+v", want, movie.Overrides)
+	}
+	if want := (jobs.Overrides{Quality: 30, OutputFormat: "mp4", Tonemap: "off", OriginalHandling: "keep"}); batch[0].Overrides != want {
+		t.Errorf("expected settings %+v, got %+v", want, batch[0].Overrides)
+	}
+
+	// The encoder default stands in for an unset config quality
+	cfg.QualityAV1 = 0
+	av1, _ := queue.Add("/media/movies/c.mkv", "compress-av1", probe("/media/movies/c.mkv"), "")
+	if _, want := ffmpeg.GetEncoderDefaults(ffmpeg.GetPreset("compress-av1").Encoder); av1.Quality != want {
+		t.Errorf("expected quality %d, got %d", want, av1.Quality)
+	}
+	if av1.Tonemap != "on" {
+		t.Errorf("expected tonemap on, got %q", av1.Tonemap)
+	}
+}
+
 func TestQueueRunningJobsResetOnLoad(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbFile := filepath.Join(tmpDir, "test.db")
@@ -367,6 +458,155 @@ func TestQueueGetNext(t *testing.T) {
 	}
 }
 
+func TestQueueWaitForSpace(t *testing.T) {
+	queue := jobs.NewQueue()
+
+	probe := &ffmpeg.ProbeResult{
+		Path:     "/media/video.mkv",
+		Size:     1000000,
+		Duration: 10 * time.Second,
+	}
+	job1, _ := queue.Add("/media/video1.mkv", "compress", probe, "")
+	job2, _ := queue.Add("/media/video2.mkv", "compress", probe, "")
+
+	if err := queue.WaitForSpace(job1.ID, "Not enough disk space"); err != nil {
+		t.Fatalf("WaitForSpace failed: %v", err)
+	}
+
+	// The waiting job stays pending but is passed over
+	got := queue.Get(job1.ID)
+	if got.Status != jobs.StatusPending || got.SpaceWait != "Not enough disk space" {
+		t.Errorf("expected pending job waiting for space, got %s %q", got.Status, got.SpaceWait)
+	}
+	if next := queue.GetNext(); next == nil || next.ID != job2.ID {
+		t.Errorf("expected job2, got %+v", next)
+	}
+
+	stats := queue.Stats()
+	if stats.Pending != 2 || stats.WaitingForSpace != 1 {
+		t.Errorf("expected 2 pending with 1 waiting, got %d and %d", stats.Pending, stats.WaitingForSpace)
+	}
+
+	// Starting it clears the wait
+	if err := queue.StartJob(job1.ID, "/tmp/temp.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if got := queue.Get(job1.ID); got.SpaceWait != "" {
+		t.Errorf("expected wait cleared, got %q", got.SpaceWait)
+	}
+	if err := queue.WaitForSpace(job1.ID, "Not enough disk space"); err == nil {
+		t.Error("expected error holding a running job")
+	}
+	if stats := queue.Stats(); stats.WaitingForSpace != 0 {
+		t.Errorf("expected no jobs waiting, got %d", stats.WaitingForSpace)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	queue := jobs.NewQueue()
+
+	probe := &ffmpeg.ProbeResult{
+		Path:     "/media/video.mkv",
+		Size:     1000000,
+		Duration: 10 * time.Second,
+	}
+	job1, _ := queue.Add("/media/video1.mkv", "compress", probe, "")
+	job2, _ := queue.Add("/media/video2.mkv", "compress", probe, "")
+	job3, _ := queue.Add("/media/video3.mkv", "compress", probe, "")
+
+	// The highest priority runs first, then queue order among equals
+	if err := queue.SetPriority(job3.ID, 5); err != nil {
+		t.Fatalf("SetPriority failed: %v", err)
+	}
+	if next := queue.GetNext(); next == nil || next.ID != job3.ID {
+		t.Errorf("expected job3, got %+v", next)
+	}
+	queue.StartJob(job3.ID, "/tmp/temp.mkv")
+	if next := queue.GetNext(); next == nil || next.ID != job1.ID {
+		t.Errorf("expected job1, got %+v", next)
+	}
+
+	if err := queue.SetPriority(job2.ID, -1); err != nil {
+		t.Fatal(err)
+	}
+	if next := queue.GetNext(); next == nil || next.ID != job1.ID {
+		t.Errorf("expected job1 ahead of the deprioritized job2, got %+v", next)
+	}
+
+	queue.CancelJob(job1.ID)
+	if err := queue.SetPriority(job1.ID, 1); err == nil {
+		t.Error("expected error setting the priority of a cancelled job")
+	}
+}
+
+func TestQueueReorder(t *testing.T) {
+	queue := jobs.NewQueue()
+
+	probe := &ffmpeg.ProbeResult{
+		Path:     "/media/video.mkv",
+		Size:     1000000,
+		Duration: 10 * time.Second,
+	}
+	var ids []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		job, _ := queue.Add("/media/"+name+".mkv", "compress", probe, "")
+		ids = append(ids, job.ID)
+	}
+	a, b, c, d := ids[0], ids[1], ids[2], ids[3]
+
+	order := func() []string {
+		var got []string
+		for _, job := range queue.GetAll() {
+			got = append(got, job.ID)
+		}
+		return got
+	}
+	check := func(step string, want ...string) {
+		t.Helper()
+		if got := order(); !slices.Equal(got, want) {
+			t.Errorf("%s: got order %v, want %v", step, got, want)
+		}
+	}
+
+	// Listed jobs swap the positions they held; d stays last
+	if err := queue.Reorder([]string{c, a, b}); err != nil {
+		t.Fatalf("Reorder failed: %v", err)
+	}
+	check("reorder", c, a, b, d)
+
+	queue.MoveToTop(d)
+	check("move to top", d, c, a, b)
+
+	queue.MoveToBottom(c)
+	check("move to bottom", d, a, b, c)
+
+	queue.MoveJob(a, c, true)
+	check("move after", d, b, c, a)
+
+	queue.MoveJob(d, a, false)
+	check("move before", b, c, d, a)
+
+	if next := queue.GetNext(); next == nil || next.ID != b {
+		t.Errorf("expected GetNext to follow the new order, got %+v", next)
+	}
+
+	// Errors
+	if err := queue.Reorder([]string{a, a}); err == nil {
+		t.Error("expected error for a job listed twice")
+	}
+	if err := queue.Reorder([]string{"missing"}); !errors.Is(err, jobs.ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+	if err := queue.MoveJob(a, "missing", false); !errors.Is(err, jobs.ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound for a missing target, got %v", err)
+	}
+	queue.StartJob(b, "/tmp/temp.mkv")
+	if err := queue.MoveToTop(b); !errors.Is(err, jobs.ErrJobNotPending) {
+		t.Errorf("expected ErrJobNotPending, got %v", err)
+	}
+	check("after errors", b, c, d, a)
+}
+
 func TestQueueCancel(t *testing.T) {
 	queue := jobs.NewQueue()
 
@@ -608,6 +848,51 @@ func TestQueueSkipJob(t *testing.T) {
 	}
 }
 
+func TestQueueRevertJob(t *testing.T) {
+	queue := jobs.NewQueue()
+
+	probe := &ffmpeg.ProbeResult{
+		Path:     "/test/video.mkv",
+		Size:     1000000,
+		Duration: 10 * time.Second,
+	}
+
+	job, _ := queue.Add(probe.Path, "compress", probe, "")
+
+	// Only completed jobs can be reverted
+	if err := queue.RevertJob(job.ID); err == nil {
+		t.Error("expected error when reverting a pending job")
+	}
+
+	queue.StartJob(job.ID, "/tmp/test.tmp.mkv")
+	queue.UpdateJobOriginalPath(job.ID, "/test/video.mkv.old")
+	queue.CompleteJob(job.ID, "/test/video.mkv", 500000)
+
+	if err := queue.RevertJob(job.ID); err != nil {
+		t.Fatalf("RevertJob failed: %v", err)
+	}
+
+	got := queue.Get(job.ID)
+	if got.Status != jobs.StatusReverted {
+		t.Errorf("expected StatusReverted, got %s", got.Status)
+	}
+	if got.OriginalPath != "" {
+		t.Errorf("expected OriginalPath cleared, got %q", got.OriginalPath)
+	}
+	if !got.IsTerminal() {
+		t.Error("expected reverted job to be terminal")
+	}
+
+	stats := queue.Stats()
+	if stats.Complete != 0 || stats.Reverted != 1 {
+		t.Errorf("expected 0 complete and 1 reverted, got %d and %d", stats.Complete, stats.Reverted)
+	}
+
+	if err := queue.RevertJob("nonexistent"); !errors.Is(err, jobs.ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
 func TestQueueSkipJobTerminalState(t *testing.T) {
 	queue := jobs.NewQueue()
 
@@ -821,3 +1106,111 @@ func TestQueueAllowSameCodec(t *testing.T) {
 		}
 	})
 }
+
+func TestQueueProcessedFileLedger(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	queue, err := jobs.NewQueueWithStore(s)
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+	queue.SetAllowSameCodec(true)
+
+	// probeFile probes a file on disk the way the browser does (size + mtime)
+	probeFile := func(path string) *ffmpeg.ProbeResult {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &ffmpeg.ProbeResult{Path: path, Size: info.Size(), ModTime: info.ModTime(), Duration: 10 * time.Second}
+	}
+
+	output := filepath.Join(tmpDir, "movie.mkv")
+	if err := os.WriteFile(output, []byte("transcoded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job, _ := queue.Add(output, "compress-hevc", &ffmpeg.ProbeResult{Path: output, Size: 1000}, "")
+	_ = queue.StartJob(job.ID, "")
+	_ = queue.CompleteJob(job.ID, output, 10)
+
+	// Clearing the job doesn't forget the file, even with allow_same_codec
+	queue.Clear("")
+	again, _ := queue.Add(output, "compress-hevc", probeFile(output), "")
+	if again.Status != jobs.StatusSkipped || !strings.HasPrefix(again.SkipReason, "Previously processed on ") {
+		t.Fatalf("expected skip as previously processed, got %s %q", again.Status, again.SkipReason)
+	}
+
+	// Another preset doesn't re-encode the output either
+	other, _ := queue.Add(output, "compress-av1", probeFile(output), "")
+	if other.Status != jobs.StatusSkipped || !strings.HasPrefix(other.SkipReason, "Previously processed on ") {
+		t.Errorf("expected skip with another preset, got %s %q", other.Status, other.SkipReason)
+	}
+
+	// A changed file is processed again
+	if err := os.WriteFile(output, []byte("a different release"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := queue.Add(output, "compress-hevc", probeFile(output), "")
+	if changed.Status != jobs.StatusPending {
+		t.Errorf("expected pending for changed file, got %s %q", changed.Status, changed.SkipReason)
+	}
+
+	// SmartShrink "already optimized" skips are remembered too
+	input := filepath.Join(tmpDir, "small.mkv")
+	if err := os.WriteFile(input, []byte("small"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	job, _ = queue.Add(input, "smartshrink-hevc", probeFile(input), "good")
+	_ = queue.StartJob(job.ID, "")
+	_ = queue.SkipJob(job.ID, vmaf.SkipReasonAlreadyOptimized)
+
+	jobList, _ := queue.AddMultiple([]*ffmpeg.ProbeResult{probeFile(input)}, "smartshrink-hevc", "good")
+	if len(jobList) != 1 || !strings.HasSuffix(jobList[0].SkipReason, "(already optimized)") {
+		t.Fatalf("expected skip as already optimized, got %+v", jobList)
+	}
+
+	// Forgetting a file lets it be queued again
+	if err := queue.ForgetProcessedFile(input); err != nil {
+		t.Fatalf("ForgetProcessedFile failed: %v", err)
+	}
+	if reason := queue.SkipReason(probeFile(input), "smartshrink-hevc"); reason != "" {
+		t.Errorf("expected no skip reason after forgetting, got %q", reason)
+	}
+}
+
+func TestQueueRevertJobForgetsOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := store.NewSQLiteStore(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	queue, _ := jobs.NewQueueWithStore(s)
+
+	output := filepath.Join(tmpDir, "movie.mkv")
+	if err := os.WriteFile(output, []byte("transcoded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	job, _ := queue.Add(output, "compress-hevc", &ffmpeg.ProbeResult{Path: output, Size: 1000}, "")
+	_ = queue.StartJob(job.ID, "")
+	_ = queue.CompleteJob(job.ID, output, 10)
+
+	if f, _ := queue.GetProcessedFile(output); f == nil || f.Outcome != jobs.ProcessedTranscoded {
+		t.Fatalf("expected output in the ledger, got %+v", f)
+	}
+
+	if err := queue.RevertJob(job.ID); err != nil {
+		t.Fatalf("RevertJob failed: %v", err)
+	}
+	if f, _ := queue.GetProcessedFile(output); f != nil {
+		t.Errorf("expected ledger record removed after revert, got %+v", f)
+	}
+}
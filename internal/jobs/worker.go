@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/gwlsn/shrinkray/internal/ffmpeg"
 	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
 	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/trash"
 	"github.com/gwlsn/shrinkray/internal/util"
 )
 
@@ -40,6 +42,10 @@ type Worker struct {
 	jobDone      chan struct{} // Closed when current job finishes
 }
 
+// busyRetryDelay is how long a worker waits before asking for a job again
+// after finding another worker starting the one it got
+const busyRetryDelay = 100 * time.Millisecond
+
 // WorkerPool manages multiple workers
 type WorkerPool struct {
 	mu              sync.Mutex
@@ -61,6 +67,14 @@ type WorkerPool struct {
 	analysisMu    sync.Mutex
 	analysisCount int // Currently running analyses
 	analysisLimit int // Max concurrent analyses (from config, 1-3)
+
+	// Trash for originals when original_handling is "trash" (nil = disabled)
+	trashMu sync.RWMutex
+	trash   *trash.Trash
+
+	// Disk space claimed by started jobs, keyed by job ID (see claimSpace)
+	spaceMu     sync.Mutex
+	spaceClaims map[string]spaceClaim
 }
 
 // SmartShrink quality thresholds (hardcoded for simplicity)
@@ -253,6 +267,20 @@ func (p *WorkerPool) Resize(n int) {
 	p.cfg.Workers = n
 }
 
+// SetTrash sets the trash originals are moved to in "trash" mode
+func (p *WorkerPool) SetTrash(t *trash.Trash) {
+	p.trashMu.Lock()
+	defer p.trashMu.Unlock()
+	p.trash = t
+}
+
+// Trash returns the trash for originals, or nil if none is configured
+func (p *WorkerPool) Trash() *trash.Trash {
+	p.trashMu.RLock()
+	defer p.trashMu.RUnlock()
+	return p.trash
+}
+
 // SetAnalysisLimit updates the maximum concurrent VMAF analyses.
 // This is independent of worker count since VMAF is CPU-intensive.
 // Unlike worker resize, running analyses are NOT cancelled - they complete
@@ -441,12 +469,16 @@ func (w *Worker) tryEncoderFallbacks(
 	preset *ffmpeg.Preset,
 	tempPath string,
 	duration time.Duration,
-	qualityHEVC, qualityAV1 int,
+	quality int,
 	qualityMod float64,
 	totalFrames int64,
 	tonemapParams *ffmpeg.TonemapParams,
 	priorError error,
 	subtitleIndices []int,
+	audioPlan *ffmpeg.AudioPlan,
+	crop *ffmpeg.CropRect,
+	scan ffmpeg.ScanType,
+	audioBitrate int64,
 ) (*ffmpeg.TranscodeResult, error) {
 	currentEncoder := preset.Encoder
 	lastError := priorError
@@ -471,6 +503,12 @@ func (w *Worker) tryEncoderFallbacks(
 		// Create fallback preset
 		fallbackPreset := preset.WithEncoder(fallback.Accel)
 
+		// Hardware encoders can use a different quality scale (e.g. VAAPI VP9)
+		fallbackQuality := quality
+		if quality > 0 && ffmpeg.ValidateQuality(fallback.Accel, preset.Codec, quality) != nil {
+			fallbackQuality = 0
+		}
+
 		// Recompute whether this fallback encoder needs software decode
 		// (each encoder has different decode capabilities)
 		fallbackNeedsSWDecode := ffmpeg.RequiresSoftwareDecode(
@@ -479,7 +517,7 @@ func (w *Worker) tryEncoderFallbacks(
 		// Try with HW decode first (unless this encoder requires SW decode)
 		if !fallbackNeedsSWDecode {
 			result, err := w.attemptTranscode(jobCtx, job, fallbackPreset, tempPath,
-				duration, qualityHEVC, qualityAV1, qualityMod, totalFrames, tonemapParams, false, subtitleIndices)
+				duration, fallbackQuality, qualityMod, totalFrames, tonemapParams, false, subtitleIndices, audioPlan, crop, scan, audioBitrate)
 
 			if err == nil {
 				logger.Info("Fallback encoder succeeded", "job_id", job.ID, "encoder", fallback.Accel)
@@ -496,7 +534,7 @@ func (w *Worker) tryEncoderFallbacks(
 		// Try SW decode with fallback encoder (unless it's software encoder - no point)
 		if shouldRetryWithSoftwareDecode(fallback.Accel) {
 			result, err := w.attemptTranscode(jobCtx, job, fallbackPreset, tempPath,
-				duration, qualityHEVC, qualityAV1, qualityMod, totalFrames, tonemapParams, true, subtitleIndices)
+				duration, fallbackQuality, qualityMod, totalFrames, tonemapParams, true, subtitleIndices, audioPlan, crop, scan, audioBitrate)
 
 			if err == nil {
 				logger.Info("Fallback encoder succeeded with SW decode", "job_id", job.ID, "encoder", fallback.Accel)
@@ -523,12 +561,16 @@ func (w *Worker) attemptTranscode(
 	preset *ffmpeg.Preset,
 	tempPath string,
 	duration time.Duration,
-	qualityHEVC, qualityAV1 int,
+	quality int,
 	qualityMod float64,
 	totalFrames int64,
 	tonemapParams *ffmpeg.TonemapParams,
 	softwareDecode bool,
 	subtitleIndices []int,
+	audioPlan *ffmpeg.AudioPlan,
+	crop *ffmpeg.CropRect,
+	scan ffmpeg.ScanType,
+	audioBitrate int64,
 ) (*ffmpeg.TranscodeResult, error) {
 	// Create fresh progress channel (Transcode closes it when done)
 	progressCh := make(chan ffmpeg.Progress, 10)
@@ -541,8 +583,8 @@ func (w *Worker) attemptTranscode(
 
 	return w.transcoder.Transcode(jobCtx, job.InputPath, tempPath,
 		preset, duration, job.Bitrate, job.Width, job.Height,
-		qualityHEVC, qualityAV1, qualityMod, totalFrames, progressCh,
-		softwareDecode, w.cfg.OutputFormat, tonemapParams, subtitleIndices)
+		quality, qualityMod, totalFrames, progressCh,
+		softwareDecode, w.outputFormat(job, preset), tonemapParams, subtitleIndices, audioPlan, crop, scan, audioBitrate)
 }
 
 // processJob handles a single transcoding job
@@ -580,19 +622,71 @@ func (w *Worker) processJob(job *Job) {
 
 	// Build temp output path
 	tempDir := w.cfg.GetTempDir(job.InputPath)
-	tempPath := ffmpeg.BuildTempPath(job.InputPath, tempDir, w.cfg.OutputFormat)
+	tempPath := ffmpeg.BuildTempPath(job.InputPath, tempDir, w.outputFormat(job, preset))
+
+	// Make sure the output fits before starting, rather than failing hours in
+	claimed, busy, reason := w.pool.claimSpace(job, preset, tempDir, tempPath)
+	if busy {
+		// Another worker is starting this job. Back off until its StartJob
+		// lands, rather than getting the same pending job straight back.
+		select {
+		case <-w.ctx.Done():
+		case <-time.After(busyRetryDelay):
+		}
+		return
+	}
+	if reason != "" {
+		if w.cfg.DiskSpaceAction == "skip" {
+			if err := w.queue.StartJob(job.ID, tempPath); err == nil {
+				logger.Info("Job skipped - not enough disk space", "job_id", job.ID, "reason", reason)
+				_ = w.queue.SkipJob(job.ID, reason)
+			}
+			return
+		}
+		if job.SpaceWait == "" {
+			logger.Info("Job waiting for disk space", "job_id", job.ID, "reason", reason)
+		}
+		_ = w.queue.WaitForSpace(job.ID, reason)
+		return
+	}
 
 	// Mark job as started (first worker to call this wins)
 	if err := w.queue.StartJob(job.ID, tempPath); err != nil {
 		// Another worker claimed this job, or it was cancelled
+		if claimed {
+			w.pool.releaseSpace(job.ID)
+		}
 		return
 	}
+	if claimed {
+		defer w.pool.releaseSpace(job.ID)
+	}
 
 	logger.Info("Job started", "job_id", job.ID, "file", job.InputPath, "preset", job.PresetID)
 
+	// Read HDR side data (Dolby Vision, HDR10+, mastering display) for HDR sources.
+	// Dynamic metadata must be preserved or the job skipped - never silently dropped.
+	var hdrMetadata *ffmpeg.HDRMetadata
+	if job.IsHDR {
+		probeCtx, probeCancel := context.WithTimeout(jobCtx, 10*time.Second)
+		meta, err := w.prober.ProbeHDR(probeCtx, job.InputPath)
+		probeCancel()
+
+		if err != nil {
+			logger.Warn("Failed to probe HDR metadata, using static HDR signaling",
+				"job_id", job.ID, "error", err)
+		} else {
+			hdrMetadata = meta
+			if reason := ffmpeg.DynamicHDRSkipReason(preset, meta, w.tonemapHDR(job, preset)); reason != "" {
+				logger.Info("Job skipped - dynamic HDR metadata", "job_id", job.ID, "reason", reason)
+				_ = w.queue.SkipJob(job.ID, reason)
+				return
+			}
+		}
+	}
+
 	// Initialize quality settings (may be overridden by SmartShrink analysis)
-	qualityHEVC := w.cfg.QualityHEVC
-	qualityAV1 := w.cfg.QualityAV1
+	quality := w.quality(job, preset)
 	var qualityMod float64
 
 	// Check if this is a SmartShrink preset
@@ -636,8 +730,7 @@ func (w *Worker) processJob(job *Job) {
 
 		// Set quality overrides for transcode
 		if selectedCRF > 0 {
-			qualityHEVC = selectedCRF
-			qualityAV1 = selectedCRF
+			quality = selectedCRF
 		}
 		if qualityMod > 0 {
 			logger.Info("SmartShrink selected quality modifier",
@@ -681,48 +774,102 @@ func (w *Worker) processJob(job *Job) {
 		)
 	}
 
-	// Set up HDR tonemapping if enabled and source is HDR
+	// Set up HDR handling for HDR sources: tonemap to SDR if enabled (or the codec is SDR-only),
+	// otherwise preserve HDR signaling (transfer, static and dynamic metadata)
 	var tonemapParams *ffmpeg.TonemapParams
-	if job.IsHDR && w.cfg.TonemapHDR {
+	if job.IsHDR {
 		tonemapParams = &ffmpeg.TonemapParams{
 			IsHDR:         true,
-			EnableTonemap: true,
+			EnableTonemap: w.tonemapHDR(job, preset),
 			Algorithm:     w.cfg.TonemapAlgorithm,
+			Transfer:      job.ColorTransfer,
+			Metadata:      hdrMetadata,
+		}
+		if w.tonemapHDR(job, preset) {
+			logger.Debug("HDR tonemapping enabled",
+				"job_id", job.ID,
+				"algorithm", w.cfg.TonemapAlgorithm,
+			)
+		} else {
+			logger.Debug("Preserving HDR",
+				"job_id", job.ID,
+				"format", ffmpeg.HDRFormat(true, job.ColorTransfer, hdrMetadata),
+			)
 		}
-		logger.Debug("HDR tonemapping enabled",
-			"job_id", job.ID,
-			"algorithm", w.cfg.TonemapAlgorithm,
-		)
 	}
 
-	// For MKV output, filter incompatible subtitle codecs to avoid muxing failures.
-	// Only applies to MKV - other formats (mp4, webm, etc.) have different rules.
-	var subtitleIndices []int // nil = map all (default)
-	if w.cfg.OutputFormat == "mkv" {
-		// Use a short timeout for subtitle probing to avoid stalling the job
+	// Select subtitle streams the output container can hold
+	subtitleIndices, sidecarSubtitles := w.selectSubtitles(jobCtx, job, preset)
+
+	// Apply the preset's audio policy (if any) to the source audio streams.
+	// Target size/bitrate presets also need the audio streams to budget for them.
+	var audioPlan *ffmpeg.AudioPlan // nil = legacy audio handling
+	var audioBitrate int64          // Expected output audio bits/s (target presets only)
+	if preset.Audio != nil || preset.HasTarget() {
 		probeCtx, probeCancel := context.WithTimeout(jobCtx, 10*time.Second)
-		subtitleStreams, err := w.prober.ProbeSubtitles(probeCtx, job.InputPath)
+		audioStreams, err := w.prober.ProbeAudio(probeCtx, job.InputPath)
 		probeCancel()
 
+		if err != nil && preset.HasTarget() {
+			// Without the audio streams the video would get the whole budget and overshoot the target
+			logger.Error("Job failed - audio probe error", "job_id", job.ID, "error", err.Error())
+			_ = w.queue.FailJob(job.ID, fmt.Sprintf("failed to probe audio for the target size/bitrate: %v", err))
+			return
+		}
 		if err != nil {
-			logger.Warn("Failed to probe subtitles, using default mapping",
+			logger.Warn("Failed to probe audio, using default mapping",
 				"job_id", job.ID, "error", err)
-			// subtitleIndices stays nil = map all (fallback to prior behavior).
-			// This preserves subtitles if probe fails, but may still fail on
-			// incompatible codecs. Better than silently dropping all subtitles.
-		} else if len(subtitleStreams) > 0 {
-			compatible, dropped := ffmpeg.FilterMKVCompatible(subtitleStreams)
-			if len(dropped) > 0 {
-				logger.Warn("Dropping incompatible subtitle streams",
+		} else {
+			audioPlan = ffmpeg.PlanAudio(audioStreams, preset.Audio, w.outputFormat(job, preset))
+			if audioPlan != nil && len(audioPlan.Dropped) > 0 {
+				logger.Info("Dropping audio streams per preset policy",
 					"job_id", job.ID,
-					"dropped", dropped,
-					"reason", "not supported in MKV container")
+					"dropped", audioPlan.Dropped)
+			}
+			if preset.HasTarget() {
+				audioBitrate = ffmpeg.AudioBitrate(audioStreams, audioPlan, w.outputFormat(job, preset))
 			}
-			subtitleIndices = compatible
 		}
 	}
 
-	result, err := w.transcoder.Transcode(jobCtx, job.InputPath, tempPath, preset, duration, job.Bitrate, job.Width, job.Height, qualityHEVC, qualityAV1, qualityMod, totalFrames, progressCh, useSoftwareDecode, w.cfg.OutputFormat, tonemapParams, subtitleIndices)
+	// Detect interlaced/telecined content so it can be deinterlaced.
+	// Sources flagged interlaced, and unflagged DVD and broadcast codecs, are
+	// checked with idet since those streams are often flagged wrong (or not at all).
+	scan := ffmpeg.ScanProgressive
+	if ffmpeg.NeedsInterlaceCheck(job.FieldOrder, job.VideoCodec, job.Height) {
+		detected, err := w.transcoder.DetectInterlace(jobCtx, job.InputPath, duration)
+		if err != nil {
+			logger.Warn("Interlace detection failed, using field order flag",
+				"job_id", job.ID, "field_order", job.FieldOrder, "error", err)
+			if ffmpeg.IsInterlacedFieldOrder(job.FieldOrder) {
+				scan = ffmpeg.ScanInterlaced
+			}
+		} else if detected != ffmpeg.ScanUnknown {
+			scan = detected
+		}
+	}
+	if scan.NeedsDeinterlace() {
+		logger.Info("Detected interlaced content, deinterlacing",
+			"job_id", job.ID, "scan_type", scan)
+		_ = w.queue.UpdateJobScanType(job.ID, string(scan))
+	}
+
+	// Detect black bars (letterboxing) for presets with auto crop
+	var crop *ffmpeg.CropRect // nil = no crop
+	if preset.AutoCrop {
+		detected, err := w.transcoder.DetectCrop(jobCtx, job.InputPath, duration, job.Width, job.Height)
+		if err != nil {
+			logger.Warn("Crop detection failed, encoding without crop",
+				"job_id", job.ID, "error", err)
+		} else if detected != nil {
+			crop = detected
+			logger.Info("Detected black bars, cropping",
+				"job_id", job.ID, "crop", crop.String())
+			_ = w.queue.UpdateJobCrop(job.ID, crop.String())
+		}
+	}
+
+	result, err := w.transcoder.Transcode(jobCtx, job.InputPath, tempPath, preset, duration, job.Bitrate, job.Width, job.Height, quality, qualityMod, totalFrames, progressCh, useSoftwareDecode, w.outputFormat(job, preset), tonemapParams, subtitleIndices, audioPlan, crop, scan, audioBitrate)
 
 	// Recovery strategies for hardware encoder failures
 	if err != nil && jobCtx.Err() != context.Canceled && preset.Encoder != ffmpeg.HWAccelNone {
@@ -732,7 +879,7 @@ func (w *Worker) processJob(job *Job) {
 				"job_id", job.ID, "error", err.Error())
 
 			result, err = w.attemptTranscode(jobCtx, job, preset, tempPath,
-				duration, qualityHEVC, qualityAV1, qualityMod, totalFrames, tonemapParams, true, subtitleIndices)
+				duration, quality, qualityMod, totalFrames, tonemapParams, true, subtitleIndices, audioPlan, crop, scan, audioBitrate)
 
 			if err == nil {
 				logger.Info("Software decode fallback succeeded", "job_id", job.ID)
@@ -745,7 +892,7 @@ func (w *Worker) processJob(job *Job) {
 				"job_id", job.ID, "encoder", preset.Encoder, "error", err.Error())
 
 			result, err = w.tryEncoderFallbacks(jobCtx, job, preset, tempPath,
-				duration, qualityHEVC, qualityAV1, qualityMod, totalFrames, tonemapParams, err, subtitleIndices)
+				duration, quality, qualityMod, totalFrames, tonemapParams, err, subtitleIndices, audioPlan, crop, scan, audioBitrate)
 		}
 	}
 
@@ -781,16 +928,56 @@ func (w *Worker) processJob(job *Job) {
 		logger.Warn("Output larger than input but keeping (keep_larger_files enabled)", "job_id", job.ID, "input_size", util.FormatBytes(job.InputSize), "output_size", util.FormatBytes(result.OutputSize))
 	}
 
+	// Verify the output before anything touches the original
+	if err := w.verifyOutput(jobCtx, job, preset, tempPath, audioPlan, subtitleIndices); err != nil {
+		os.Remove(tempPath)
+		if jobCtx.Err() != nil {
+			if w.ctx.Err() == nil {
+				logger.Info("Job cancelled during verification", "job_id", job.ID)
+				_ = w.queue.CancelJob(job.ID)
+			} else {
+				logger.Info("Job interrupted by shutdown during verification", "job_id", job.ID)
+			}
+			return
+		}
+		logger.Error("Job failed - output verification", "job_id", job.ID, "error", err.Error())
+		_ = w.queue.FailJob(job.ID, fmt.Sprintf("output verification failed: %v", err))
+		return
+	}
+
+	// Extract image subtitles MP4/WebM can't hold, while the original still exists
+	var sidecars []string
+	if len(sidecarSubtitles) > 0 {
+		var err error
+		sidecars, err = w.transcoder.ExtractSubtitles(jobCtx, job.InputPath, sidecarSubtitles)
+		if err != nil {
+			os.Remove(tempPath)
+			logger.Error("Job failed - subtitle extraction error", "job_id", job.ID, "error", err.Error())
+			_ = w.queue.FailJob(job.ID, fmt.Sprintf("failed to extract subtitles: %v", err))
+			return
+		}
+		logger.Info("Extracted image subtitles to sidecar files", "job_id", job.ID, "files", sidecars)
+		_ = w.queue.UpdateJobSubtitleNote(job.ID, fmt.Sprintf("Extracted %d image subtitle stream(s) to sidecar files", len(sidecars)))
+		_ = w.queue.UpdateJobSidecars(job.ID, sidecars)
+	}
+
 	// Finalize the transcode (handle original file)
-	replace := w.cfg.OriginalHandling == "replace"
-	finalPath, err := ffmpeg.FinalizeTranscode(job.InputPath, tempPath, w.cfg.OutputFormat, replace)
+	// Trash mode keeps the original as .old first, then moves it out of the media tree
+	replace := w.originalHandling(job) == "replace"
+	finalPath, err := ffmpeg.FinalizeTranscode(job.InputPath, tempPath, w.outputFormat(job, preset), replace)
 	if err != nil {
-		// Try to clean up
+		// Try to clean up, including sidecars for an output that never appeared
 		os.Remove(tempPath)
+		for _, path := range sidecars {
+			os.Remove(path)
+		}
 		logger.Error("Job failed - finalization error", "job_id", job.ID, "error", err.Error())
 		_ = w.queue.FailJob(job.ID, fmt.Sprintf("failed to finalize: %v", err))
 		return
 	}
+	if !replace {
+		_ = w.queue.UpdateJobOriginalPath(job.ID, w.keepOriginal(job))
+	}
 
 	// Invalidate cache for the output file so browser shows updated metadata
 	if w.invalidateCache != nil {
@@ -809,6 +996,190 @@ func (w *Worker) processJob(job *Job) {
 	_ = w.queue.CompleteJob(job.ID, finalPath, result.OutputSize)
 }
 
+// keepOriginal moves a job's .old original into the trash when trash mode is
+// enabled and returns where the original ended up. If the move fails the
+// .old file is left in place, so the original is never lost.
+func (w *Worker) keepOriginal(job *Job) string {
+	oldPath := ffmpeg.KeptOriginalPath(job.InputPath)
+	t := w.pool.Trash()
+	if w.originalHandling(job) != "trash" || t == nil {
+		return oldPath
+	}
+
+	entry, err := t.Move(job.ID, oldPath, job.InputPath)
+	if err != nil {
+		logger.Warn("Failed to move original to trash, keeping .old file", "job_id", job.ID, "error", err.Error())
+		return oldPath
+	}
+	return entry.TrashPath
+}
+
+// verifyOutput checks a finished transcode before it replaces the original:
+// the duration must match the source and every mapped stream must be present.
+// With verify_decode enabled the whole output is also decoded.
+func (w *Worker) verifyOutput(ctx context.Context, job *Job, preset *ffmpeg.Preset, tempPath string, audioPlan *ffmpeg.AudioPlan, subtitleIndices []int) error {
+	_ = w.queue.UpdateJobPhase(job.ID, PhaseVerifying)
+
+	probeCtx, probeCancel := context.WithTimeout(ctx, 30*time.Second)
+	source, _, err := w.prober.ProbeStreams(probeCtx, job.InputPath)
+	probeCancel()
+	if err != nil {
+		return fmt.Errorf("source can't be probed: %w", err)
+	}
+
+	want := ffmpeg.OutputExpectation{
+		Duration: time.Duration(job.Duration) * time.Millisecond,
+		Streams:  ffmpeg.ExpectedStreams(source, audioPlan, subtitleIndices, w.outputFormat(job, preset)),
+	}
+	probeCtx, probeCancel = context.WithTimeout(ctx, 30*time.Second)
+	err = w.prober.VerifyOutput(probeCtx, tempPath, want)
+	probeCancel()
+	if err != nil {
+		return err
+	}
+
+	if w.cfg.VerifyDecode {
+		logger.Info("Decoding output to verify it", "job_id", job.ID)
+		if err := w.transcoder.VerifyDecode(ctx, tempPath); err != nil {
+			return err
+		}
+	}
+
+	logger.Debug("Output verified", "job_id", job.ID)
+	return nil
+}
+
+// outputFormat returns the container for the job's output, from its overrides,
+// its media root or the config. WebM only holds VP9 and AV1, so other codecs
+// fall back to MKV.
+func (w *Worker) outputFormat(job *Job, preset *ffmpeg.Preset) string {
+	format := job.OutputFormat
+	if format == "" {
+		format = w.cfg.OutputFormatFor(job.InputPath)
+	}
+	return ffmpeg.OutputFormatFor(format, preset.Codec)
+}
+
+// quality returns the CRF for the job's transcode: its override, the preset's
+// quality, then the config's quality for HEVC and AV1 (0 = encoder default).
+// The queue resolves these settings onto jobs when they're added; the
+// fallbacks cover jobs queued before that.
+func (w *Worker) quality(job *Job, preset *ffmpeg.Preset) int {
+	switch {
+	case job.Quality > 0:
+		return job.Quality
+	case preset.Quality > 0:
+		return preset.Quality
+	case preset.Codec == ffmpeg.CodecHEVC:
+		return w.cfg.QualityHEVC
+	case preset.Codec == ffmpeg.CodecAV1:
+		return w.cfg.QualityAV1
+	}
+	return 0
+}
+
+// originalHandling returns what happens to the job's original after a
+// successful transcode, from its overrides, its media root or the config
+func (w *Worker) originalHandling(job *Job) string {
+	if job.OriginalHandling != "" {
+		return job.OriginalHandling
+	}
+	return w.cfg.OriginalHandlingFor(job.InputPath)
+}
+
+// tonemapHDR returns true if HDR sources are tonemapped to SDR for the preset:
+// when enabled for the job or in the config, or always for codecs without HDR
+// output (H.264)
+func (w *Worker) tonemapHDR(job *Job, preset *ffmpeg.Preset) bool {
+	if !preset.Codec.SupportsHDR() {
+		return true
+	}
+	switch job.Tonemap {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	return w.cfg.TonemapHDR
+}
+
+// selectSubtitles returns the subtitle stream indices to map for the output
+// container, plus image subtitle streams to extract as sidecar files (MP4/WebM only).
+// nil indices keep the container default (MKV: map all, MP4/WebM: strip).
+// Dropped streams are recorded on the job's subtitle note.
+func (w *Worker) selectSubtitles(ctx context.Context, job *Job, preset *ffmpeg.Preset) ([]int, []ffmpeg.SubtitleStream) {
+	// Only MKV, MP4 and WebM have subtitle rules - other formats keep the default mapping
+	outputFormat := w.outputFormat(job, preset)
+	if outputFormat != "mkv" && outputFormat != "mp4" && outputFormat != "webm" {
+		return nil, nil
+	}
+
+	// Use a short timeout for subtitle probing to avoid stalling the job
+	probeCtx, probeCancel := context.WithTimeout(ctx, 10*time.Second)
+	subtitleStreams, err := w.prober.ProbeSubtitles(probeCtx, job.InputPath)
+	probeCancel()
+
+	if err != nil {
+		logger.Warn("Failed to probe subtitles, using default mapping",
+			"job_id", job.ID, "error", err)
+		// nil = container default (fallback to prior behavior).
+		// For MKV this preserves subtitles if probe fails, but may still fail on
+		// incompatible codecs. Better than silently dropping all subtitles.
+		return nil, nil
+	}
+	if len(subtitleStreams) == 0 {
+		return nil, nil
+	}
+
+	// Apply the preset's subtitle rules (language, forced, SDH) first
+	subtitleStreams, droppedByPolicy := ffmpeg.FilterSubtitles(subtitleStreams, preset.Subtitles)
+	if len(droppedByPolicy) > 0 {
+		logger.Info("Dropping subtitle streams per preset policy",
+			"job_id", job.ID,
+			"dropped", droppedByPolicy)
+	}
+
+	if outputFormat == "mkv" {
+		compatible, dropped := ffmpeg.FilterMKVCompatible(subtitleStreams)
+		if len(dropped) > 0 {
+			logger.Warn("Dropping incompatible subtitle streams",
+				"job_id", job.ID,
+				"dropped", dropped,
+				"reason", "not supported in MKV container")
+			_ = w.queue.UpdateJobSubtitleNote(job.ID, fmt.Sprintf("Dropped subtitles not supported in MKV: %s", strings.Join(dropped, ", ")))
+		}
+		return compatible, nil
+	}
+
+	// MP4/WebM: text subtitles are converted to mov_text/WebVTT, image subtitles can't be stored
+	container := "MP4"
+	if outputFormat == "webm" {
+		container = "WebM"
+	}
+	textIndices, unsupported := ffmpeg.FilterMP4Compatible(subtitleStreams)
+	if len(unsupported) == 0 {
+		return textIndices, nil
+	}
+
+	var sidecars []ffmpeg.SubtitleStream
+	var dropped []string
+	for _, s := range unsupported {
+		if w.cfg.MP4ImageSubtitles == "extract" && ffmpeg.CanExtractSubtitle(s.CodecName) {
+			sidecars = append(sidecars, s)
+			continue
+		}
+		dropped = append(dropped, ffmpeg.DescribeSubtitleStream(s))
+	}
+	if len(dropped) > 0 {
+		logger.Warn("Dropping image subtitle streams",
+			"job_id", job.ID,
+			"dropped", dropped,
+			"reason", "not supported in "+container+" container")
+		_ = w.queue.UpdateJobSubtitleNote(job.ID, fmt.Sprintf("Dropped image subtitles not supported in %s: %s", container, strings.Join(dropped, ", ")))
+	}
+	return textIndices, sidecars
+}
+
 // CancelCurrentJob cancels the job if it matches the given ID.
 // Returns a channel that will be closed when the job finishes, or nil if job not found.
 func (w *Worker) CancelCurrentJob(jobID string) <-chan struct{} {
@@ -248,3 +248,153 @@ func TestWorkerPoolResizeDown(t *testing.T) {
 
 	t.Log("Resize down is immediate")
 }
+
+func TestEstimateOutputSize(t *testing.T) {
+	job := &Job{InputSize: 10_000_000_000, Duration: 3_600_000} // 10 GB, 1 hour
+
+	tests := []struct {
+		name   string
+		preset *ffmpeg.Preset
+		want   int64
+	}{
+		{"quality preset", &ffmpeg.Preset{}, 11_000_000_000},
+		{"target size", &ffmpeg.Preset{TargetSize: 4_000_000_000}, 4_400_000_000},
+		{"target bitrate", &ffmpeg.Preset{TargetBitrate: 8_000_000}, 3_960_000_000},
+		{"smaller of both", &ffmpeg.Preset{TargetSize: 2_000_000_000, TargetBitrate: 8_000_000}, 2_200_000_000},
+	}
+	for _, tt := range tests {
+		if got := estimateOutputSize(job, tt.preset); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClaimSpace(t *testing.T) {
+	dir := t.TempDir()
+	free, _, err := freeSpace(dir)
+	if err != nil {
+		t.Skipf("statfs unavailable: %v", err)
+	}
+
+	cfg := &config.Config{Workers: 1, FFmpegPath: "ffmpeg", FFprobePath: "ffprobe"}
+	pool := NewWorkerPool(NewQueue(), cfg, nil)
+	preset := &ffmpeg.Preset{}
+	newJob := func(id string, size int64) *Job {
+		return &Job{ID: id, InputPath: filepath.Join(dir, id+".mkv"), InputSize: size}
+	}
+	// A temp dir that doesn't exist yet is checked through its parent
+	tempDir := filepath.Join(dir, "cache", "shrinkray")
+
+	// Each of these fits on its own, but not both at once
+	first := newJob("first", free/2)
+	second := newJob("second", free/2)
+	if claimed, _, reason := pool.claimSpace(first, preset, tempDir, filepath.Join(tempDir, "first.tmp.mkv")); !claimed {
+		t.Fatalf("expected first job to fit, got %q", reason)
+	}
+	if _, _, reason := pool.claimSpace(second, preset, tempDir, filepath.Join(tempDir, "second.tmp.mkv")); reason == "" {
+		t.Error("expected second job not to fit alongside the first")
+	}
+
+	// A second worker that got the same job leaves it (and its claim) alone
+	if claimed, busy, _ := pool.claimSpace(first, preset, tempDir, filepath.Join(tempDir, "first.tmp.mkv")); claimed || !busy {
+		t.Errorf("expected the first job's claim to be held by its worker, got claimed=%v busy=%v", claimed, busy)
+	}
+
+	pool.releaseSpace(first.ID)
+	if claimed, _, reason := pool.claimSpace(second, preset, tempDir, filepath.Join(tempDir, "second.tmp.mkv")); !claimed {
+		t.Errorf("expected second job to fit once the first finished, got %q", reason)
+	}
+	pool.releaseSpace(second.ID)
+
+	// The reserve is left untouched
+	cfg.DiskSpaceReserve = util.FormatBytes(2 * free)
+	if _, _, reason := pool.claimSpace(newJob("small", 1000), preset, dir, ""); reason == "" {
+		t.Error("expected job not to fit within the reserve")
+	}
+
+	cfg.DiskSpaceAction = "off"
+	if claimed, _, reason := pool.claimSpace(newJob("small", 1000), preset, dir, ""); claimed || reason != "" {
+		t.Errorf("expected no check when off, got claimed=%v %q", claimed, reason)
+	}
+}
+
+func TestProcessJobBusyBacksOff(t *testing.T) {
+	dir := t.TempDir()
+	if _, _, err := freeSpace(dir); err != nil {
+		t.Skipf("statfs unavailable: %v", err)
+	}
+
+	cfg := &config.Config{Workers: 1, FFmpegPath: "ffmpeg", FFprobePath: "ffprobe", TempPath: dir}
+	queue := NewQueue()
+	pool := NewWorkerPool(queue, cfg, nil)
+	probe := &ffmpeg.ProbeResult{Path: filepath.Join(dir, "movie.mkv"), Size: 1000, Duration: 10 * time.Second}
+	job, err := queue.Add(probe.Path, "compress-hevc", probe, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another worker holds the job's claim and hasn't called StartJob yet
+	preset := ffmpeg.GetPreset(job.PresetID)
+	if claimed, _, reason := pool.claimSpace(job, preset, dir, filepath.Join(dir, "movie.tmp.mkv")); !claimed {
+		t.Fatalf("expected claim, got %q", reason)
+	}
+
+	w := &Worker{pool: pool, queue: queue, cfg: cfg, ctx: context.Background()}
+	start := time.Now()
+	w.processJob(job)
+	if elapsed := time.Since(start); elapsed < busyRetryDelay {
+		t.Errorf("expected the worker to back off for %v, returned after %v", busyRetryDelay, elapsed)
+	}
+	if got := queue.Get(job.ID); got.Status != StatusPending {
+		t.Errorf("expected the job left pending for the other worker, got %s", got.Status)
+	}
+}
+
+func TestJobOverrides(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.OutputFormat = "mkv"
+	cfg.OriginalHandling = "keep"
+	cfg.TonemapHDR = true
+	w := &Worker{cfg: cfg}
+	hevc := &ffmpeg.Preset{Codec: ffmpeg.CodecHEVC}
+	h264 := &ffmpeg.Preset{Codec: ffmpeg.CodecH264}
+
+	// Without overrides the job follows the config
+	job := &Job{InputPath: "/media/movie.mkv"}
+	if got := w.outputFormat(job, hevc); got != "mkv" {
+		t.Errorf("outputFormat: got %s, want mkv", got)
+	}
+	if got := w.originalHandling(job); got != "keep" {
+		t.Errorf("originalHandling: got %s, want keep", got)
+	}
+	if !w.tonemapHDR(job, hevc) {
+		t.Error("expected tonemapping from the config")
+	}
+
+	job.Overrides = Overrides{OutputFormat: "mp4", Tonemap: "off", OriginalHandling: "replace"}
+	if got := w.outputFormat(job, hevc); got != "mp4" {
+		t.Errorf("outputFormat: got %s, want mp4", got)
+	}
+	if got := w.originalHandling(job); got != "replace" {
+		t.Errorf("originalHandling: got %s, want replace", got)
+	}
+	if w.tonemapHDR(job, hevc) {
+		t.Error("expected tonemapping turned off for the job")
+	}
+	if !w.tonemapHDR(job, h264) {
+		t.Error("expected H.264 to always tonemap")
+	}
+
+	// A later config change doesn't affect the job
+	cfg.TonemapHDR = false
+	job.Tonemap = "on"
+	if !w.tonemapHDR(job, hevc) {
+		t.Error("expected tonemapping turned on for the job")
+	}
+
+	// WebM still falls back to MKV for codecs it can't hold
+	job.OutputFormat = "webm"
+	if got := w.outputFormat(job, hevc); got != "mkv" {
+		t.Errorf("outputFormat: got %s, want mkv for HEVC in WebM", got)
+	}
+}
@@ -0,0 +1,423 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg/vmaf"
+	"github.com/gwlsn/shrinkray/internal/jobs"
+	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
+)
+
+// tickInterval is how often the digest schedule and quiet hours are checked
+const tickInterval = time.Minute
+
+// maxDigestFailures caps how many failed files a digest lists by name
+const maxDigestFailures = 5
+
+// SendFunc delivers a notification with the given title and message
+type SendFunc func(title, message string) error
+
+// message is a notification waiting to be sent
+type message struct {
+	title   string
+	body    string
+	drained bool          // Queue-drained summary: clients are told once it goes out
+	flushed chan struct{} // Not a notification: closed when the sender reaches it (see flush)
+}
+
+// tally counts finished jobs over a period
+type tally struct {
+	complete int
+	failed   int
+	skipped  int
+	finished int // Terminal events of any kind, including cancellations
+	saved    int64
+	failures []string // Names of failed files, for the digest
+}
+
+func (t *tally) add(event jobs.JobEvent) {
+	switch event.Type {
+	case "complete":
+		t.complete++
+		t.saved += event.Job.SpaceSaved
+	case "failed":
+		t.failed++
+		t.failures = append(t.failures, filepath.Base(event.Job.InputPath))
+	case "skipped":
+		t.skipped++
+	case "cancelled":
+	default:
+		return
+	}
+	t.finished++
+}
+
+// Dispatcher turns queue events into notifications according to the rules
+// in the config. It runs from a single server-side subscription, so each
+// notification goes out exactly once whether zero or many browser tabs are
+// connected to the SSE stream.
+//
+// Rules:
+//   - notify_on_complete: a summary each time the queue drains
+//   - notify_on_failure: every failed job, with its file and error
+//   - notify_on_skip: SmartShrink "already optimized" skips
+//   - notify_digest: a daily or weekly summary of space saved and failures
+//
+// During quiet hours notifications are held and sent together when quiet hours end.
+//
+// Notifications are delivered by a separate sender goroutine, so a slow
+// backend never stalls the event loop and the subscription never fills up
+// and drops events.
+type Dispatcher struct {
+	queue *jobs.Queue
+	cfg   *config.Config
+	send  SendFunc
+	now   func() time.Time
+
+	events  chan jobs.JobEvent
+	flushes chan chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	outMu  sync.Mutex // Guards outbox
+	outbox []message  // Waiting for the sender goroutine
+	wake   chan struct{}
+
+	mu         sync.Mutex // Guards the state below
+	run        tally      // Since the last queue-drained summary
+	digest     tally      // Since the last digest
+	lastDigest time.Time
+	held       []message // Held during quiet hours
+}
+
+// NewDispatcher creates a dispatcher that sends to every backend configured
+// in cfg (rules and backends can change at runtime via the API)
+func NewDispatcher(queue *jobs.Queue, cfg *config.Config) *Dispatcher {
+	d := &Dispatcher{
+		queue:   queue,
+		cfg:     cfg,
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+		flushes: make(chan chan struct{}),
+	}
+	d.send = func(title, message string) error {
+		sent, err := SendAll(d.cfg, title, message)
+		if sent > 0 && err != nil {
+			// Delivered somewhere: report the failing backends but don't fail the send
+			logger.Warn("Some notification backends failed", "error", err)
+			return nil
+		}
+		return err
+	}
+	return d
+}
+
+// SetSender replaces how notifications are delivered (used by tests)
+func (d *Dispatcher) SetSender(send SendFunc) {
+	d.send = send
+}
+
+// Start subscribes to the queue and processes events in the background.
+// The subscription is made before Start returns, so no event after it is missed.
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.events = d.queue.Subscribe()
+	d.lastDigest = d.now()
+
+	d.wg.Add(2)
+	go d.loop(ctx)
+	go d.sender(ctx)
+}
+
+// Stop unsubscribes from the queue and waits for the event loop and the
+// sender to exit. Notifications not yet sent are dropped.
+func (d *Dispatcher) Stop() {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) loop(ctx context.Context) {
+	defer d.wg.Done()
+	defer d.queue.Unsubscribe(d.events)
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-d.events:
+			if !ok {
+				return
+			}
+			d.handle(event)
+		case done := <-d.flushes:
+			d.handlePending()
+			d.enqueue(message{flushed: done})
+		case <-ticker.C:
+			d.tick()
+		}
+	}
+}
+
+// flush waits until every event broadcast before the call has been handled
+// and the notifications it queued have been delivered (used by tests)
+func (d *Dispatcher) flush() {
+	done := make(chan struct{})
+	d.flushes <- done
+	<-done
+}
+
+// handlePending handles the events already waiting in the subscription
+func (d *Dispatcher) handlePending() {
+	for {
+		select {
+		case event, ok := <-d.events:
+			if !ok {
+				return
+			}
+			d.handle(event)
+		default:
+			return
+		}
+	}
+}
+
+// handle applies the notification rules to an event
+func (d *Dispatcher) handle(event jobs.JobEvent) {
+	if event.Job == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.run.add(event)
+	d.digest.add(event)
+
+	switch event.Type {
+	case "failed":
+		if d.cfg.NotifyOnFailure {
+			d.notify(message{title: "Shrinkray: Job Failed",
+				body: fmt.Sprintf("%s\n%s", filepath.Base(event.Job.InputPath), event.Job.Error)})
+		}
+	case "skipped":
+		if d.cfg.NotifyOnSkip && event.Job.SkipReason == vmaf.SkipReasonAlreadyOptimized {
+			d.notify(message{title: "Shrinkray: Already Optimized",
+				body: fmt.Sprintf("%s\nSmartShrink couldn't make it smaller at the target quality", filepath.Base(event.Job.InputPath))})
+		}
+	}
+
+	switch event.Type {
+	case "complete", "failed", "cancelled", "skipped":
+		d.checkQueueDrained()
+	}
+}
+
+// checkQueueDrained sends the queue-drained summary once no jobs are pending
+// or running. It covers the jobs finished since the previous summary, so
+// each drain is reported once.
+func (d *Dispatcher) checkQueueDrained() {
+	stats := d.queue.Stats()
+	if stats.Pending > 0 || stats.Running > 0 || d.run.finished == 0 {
+		return
+	}
+
+	run := d.run
+	d.run = tally{}
+	if !d.cfg.NotifyOnComplete {
+		return
+	}
+
+	d.notify(message{
+		title: "Shrinkray Complete",
+		body: fmt.Sprintf("%d jobs complete, %d failed\nSaved %s",
+			run.complete, run.failed, util.FormatBytes(run.saved)),
+		drained: true,
+	})
+}
+
+// tick sends a due digest and flushes held notifications once quiet hours end
+func (d *Dispatcher) tick() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if d.cfg.NotifyDigest == "daily" || d.cfg.NotifyDigest == "weekly" {
+		if !now.Before(nextDigest(d.lastDigest, d.cfg.NotifyDigest, d.cfg.NotifyDigestHour)) {
+			d.sendDigest()
+			d.lastDigest = now
+		}
+	}
+
+	if len(d.held) > 0 && !d.inQuietHours(now) {
+		d.flushHeld()
+	}
+}
+
+// sendDigest sends a summary of the jobs finished since the last digest,
+// with the all-time total from the store
+func (d *Dispatcher) sendDigest() {
+	period := d.digest
+	d.digest = tally{}
+
+	title := "Shrinkray Daily Digest"
+	if d.cfg.NotifyDigest == "weekly" {
+		title = "Shrinkray Weekly Digest"
+	}
+
+	if period.finished == 0 {
+		d.notify(message{title: title, body: "No jobs finished"})
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d jobs complete, %d failed, %d skipped\n", period.complete, period.failed, period.skipped)
+	fmt.Fprintf(&b, "Saved %s", util.FormatBytes(period.saved))
+	if lifetime := d.queue.Stats().LifetimeSaved; lifetime > 0 {
+		fmt.Fprintf(&b, " (%s all time)", util.FormatBytes(lifetime))
+	}
+	if len(period.failures) > 0 {
+		b.WriteString("\n\nFailed:")
+		for i, name := range period.failures {
+			if i == maxDigestFailures {
+				fmt.Fprintf(&b, "\n...and %d more", len(period.failures)-maxDigestFailures)
+				break
+			}
+			b.WriteString("\n- " + name)
+		}
+	}
+	d.notify(message{title: title, body: b.String()})
+}
+
+// notify queues a notification for the sender, or holds it during quiet hours
+func (d *Dispatcher) notify(m message) {
+	if len(Configured(d.cfg)) == 0 {
+		return
+	}
+	if d.inQuietHours(d.now()) {
+		d.held = append(d.held, message{title: m.title, body: m.body})
+		return
+	}
+	d.enqueue(m)
+}
+
+// enqueue adds a message to the outbox and wakes the sender
+func (d *Dispatcher) enqueue(m message) {
+	d.outMu.Lock()
+	d.outbox = append(d.outbox, m)
+	d.outMu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sender delivers queued notifications in order until ctx is cancelled
+func (d *Dispatcher) sender(ctx context.Context) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		}
+
+		for ctx.Err() == nil {
+			d.outMu.Lock()
+			if len(d.outbox) == 0 {
+				d.outMu.Unlock()
+				break
+			}
+			m := d.outbox[0]
+			d.outbox = d.outbox[1:]
+			d.outMu.Unlock()
+
+			d.deliver(m)
+		}
+	}
+}
+
+// deliver sends one notification
+func (d *Dispatcher) deliver(m message) {
+	if m.flushed != nil {
+		close(m.flushed)
+		return
+	}
+	if err := d.send(m.title, m.body); err != nil {
+		logger.Warn("Failed to send notification", "title", m.title, "error", err)
+		return
+	}
+	if m.drained {
+		// Only tell clients once the notification actually went out
+		d.queue.BroadcastNotifySent()
+	}
+}
+
+// flushHeld sends the notifications held during quiet hours, combined into one
+func (d *Dispatcher) flushHeld() {
+	held := d.held
+	d.held = nil
+
+	if len(held) == 1 {
+		d.notify(held[0])
+		return
+	}
+
+	parts := make([]string, len(held))
+	for i, m := range held {
+		parts[i] = m.title + "\n" + m.body
+	}
+	d.notify(message{
+		title: fmt.Sprintf("Shrinkray: %d notifications during quiet hours", len(held)),
+		body:  strings.Join(parts, "\n\n"),
+	})
+}
+
+// inQuietHours checks if t is within the configured quiet hours
+func (d *Dispatcher) inQuietHours(t time.Time) bool {
+	if !d.cfg.QuietHoursEnabled {
+		return false
+	}
+
+	hour := t.Hour()
+	start := d.cfg.QuietHoursStart
+	end := d.cfg.QuietHoursEnd
+
+	// Handle overnight windows (e.g., 22:00 to 07:00)
+	if start > end {
+		return hour >= start || hour < end
+	}
+
+	// Handle daytime windows (e.g., 09:00 to 17:00)
+	return hour >= start && hour < end
+}
+
+// nextDigest returns when the first digest after last is due: the next
+// occurrence of hour, on a Monday for weekly digests
+func nextDigest(last time.Time, mode string, hour int) time.Time {
+	next := time.Date(last.Year(), last.Month(), last.Day(), hour, 0, 0, 0, last.Location())
+	if !next.After(last) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if mode == "weekly" {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
@@ -0,0 +1,335 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/jobs"
+)
+
+// recorder is a SendFunc that records sent notifications
+type recorder struct {
+	mu       sync.Mutex
+	titles   []string
+	messages []string
+}
+
+func (r *recorder) send(title, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.titles = append(r.titles, title)
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messages)
+}
+
+func (r *recorder) get(i int) (title, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.titles[i], r.messages[i]
+}
+
+// clock is a settable time source
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// monday is a Monday at the given hour
+func monday(hour int) time.Time {
+	return time.Date(2026, 3, 2, hour, 0, 0, 0, time.UTC)
+}
+
+func newTestDispatcher(t *testing.T, cfg *config.Config) (*Dispatcher, *jobs.Queue, *clock, *recorder) {
+	t.Helper()
+	cfg.PushoverUserKey = "user"
+	cfg.PushoverAppToken = "token"
+
+	queue := jobs.NewQueue()
+	clk := &clock{now: monday(12)}
+	rec := &recorder{}
+	d := NewDispatcher(queue, cfg)
+	d.now = clk.Now
+	d.SetSender(rec.send)
+	d.Start()
+	t.Cleanup(d.Stop)
+	return d, queue, clk, rec
+}
+
+func addJob(t *testing.T, queue *jobs.Queue, path string) *jobs.Job {
+	t.Helper()
+	probe := &ffmpeg.ProbeResult{Path: path, Size: 1000000, Duration: 10 * time.Second}
+	job, err := queue.Add(path, "compress", probe, "")
+	if err != nil {
+		t.Fatalf("failed to add job: %v", err)
+	}
+	return job
+}
+
+// waitFor polls cond until it is true or the timeout expires
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcherQueueDrained(t *testing.T) {
+	d, queue, _, rec := newTestDispatcher(t, &config.Config{NotifyOnComplete: true})
+
+	// Watch for notify_sent like a browser would
+	events := queue.Subscribe()
+	defer queue.Unsubscribe(events)
+
+	job1 := addJob(t, queue, "/media/a.mkv")
+	job2 := addJob(t, queue, "/media/b.mkv")
+
+	queue.StartJob(job1.ID, "/tmp/a.tmp.mkv")
+	queue.CompleteJob(job1.ID, "/media/a.mkv", 400000)
+
+	// job2 still pending: nothing sent yet
+	d.flush()
+	if rec.count() != 0 {
+		t.Fatalf("expected no notification while jobs are pending, got %d", rec.count())
+	}
+
+	queue.StartJob(job2.ID, "/tmp/b.tmp.mkv")
+	queue.FailJob(job2.ID, "encoder error")
+
+	waitFor(t, func() bool { return rec.count() == 1 })
+	if _, msg := rec.get(0); msg != "1 jobs complete, 1 failed\nSaved 585.9 KB" {
+		t.Errorf("unexpected message: %q", msg)
+	}
+
+	// notify_sent is broadcast after the send
+	waitFor(t, func() bool {
+		for {
+			select {
+			case event := <-events:
+				if event.Type == "notify_sent" {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+
+	// The rule stays on: the next drain is reported, covering only the new jobs
+	job3 := addJob(t, queue, "/media/c.mkv")
+	queue.CancelJob(job3.ID)
+	waitFor(t, func() bool { return rec.count() == 2 })
+	if _, msg := rec.get(1); msg != "0 jobs complete, 0 failed\nSaved 0 B" {
+		t.Errorf("unexpected second summary: %q", msg)
+	}
+}
+
+func TestDispatcherFailureAndSkipRules(t *testing.T) {
+	d, queue, _, rec := newTestDispatcher(t, &config.Config{NotifyOnFailure: true, NotifyOnSkip: true})
+
+	// Keep a job pending so no drained summary is involved
+	addJob(t, queue, "/media/pending.mkv")
+
+	failed := addJob(t, queue, "/media/Movies/broken.mkv")
+	queue.StartJob(failed.ID, "/tmp/broken.tmp.mkv")
+	queue.FailJob(failed.ID, "exit status 1")
+
+	waitFor(t, func() bool { return rec.count() == 1 })
+	if title, msg := rec.get(0); title != "Shrinkray: Job Failed" || msg != "broken.mkv\nexit status 1" {
+		t.Errorf("unexpected failure notification: %q %q", title, msg)
+	}
+
+	// Only SmartShrink "already optimized" skips are notified
+	other := addJob(t, queue, "/media/hdr.mkv")
+	queue.StartJob(other.ID, "")
+	queue.SkipJob(other.ID, "Dolby Vision can't be preserved")
+
+	optimized := addJob(t, queue, "/media/small.mkv")
+	queue.StartJob(optimized.ID, "")
+	queue.SkipJob(optimized.ID, "Already optimized")
+
+	waitFor(t, func() bool { return rec.count() == 2 })
+	d.flush()
+	if rec.count() != 2 {
+		t.Fatalf("expected 2 notifications, got %d", rec.count())
+	}
+	if title, msg := rec.get(1); title != "Shrinkray: Already Optimized" || !strings.HasPrefix(msg, "small.mkv\n") {
+		t.Errorf("unexpected skip notification: %q %q", title, msg)
+	}
+}
+
+func TestDispatcherQuietHours(t *testing.T) {
+	d, queue, clk, rec := newTestDispatcher(t, &config.Config{
+		NotifyOnFailure:   true,
+		QuietHoursEnabled: true,
+		QuietHoursStart:   22,
+		QuietHoursEnd:     7,
+	})
+	clk.Set(monday(23))
+
+	addJob(t, queue, "/media/pending.mkv")
+	for _, path := range []string{"/media/a.mkv", "/media/b.mkv"} {
+		job := addJob(t, queue, path)
+		queue.StartJob(job.ID, "")
+		queue.FailJob(job.ID, "encoder error")
+	}
+
+	d.flush()
+	d.tick()
+	d.flush()
+	if rec.count() != 0 {
+		t.Fatalf("expected notifications held during quiet hours, got %d", rec.count())
+	}
+
+	// Held notifications go out together when quiet hours end
+	clk.Set(monday(23).Add(8 * time.Hour))
+	d.tick()
+	waitFor(t, func() bool { return rec.count() == 1 })
+	title, msg := rec.get(0)
+	if title != "Shrinkray: 2 notifications during quiet hours" {
+		t.Errorf("unexpected title: %q", title)
+	}
+	if !strings.Contains(msg, "a.mkv") || !strings.Contains(msg, "b.mkv") {
+		t.Errorf("expected both failures in message: %q", msg)
+	}
+}
+
+func TestDispatcherDigest(t *testing.T) {
+	d, queue, clk, rec := newTestDispatcher(t, &config.Config{NotifyDigest: "daily", NotifyDigestHour: 9})
+
+	job := addJob(t, queue, "/media/a.mkv")
+	queue.StartJob(job.ID, "")
+	queue.CompleteJob(job.ID, "/media/a.mkv", 400000)
+	job = addJob(t, queue, "/media/b.mkv")
+	queue.StartJob(job.ID, "")
+	queue.FailJob(job.ID, "encoder error")
+	d.flush()
+
+	// Started Monday noon: the first daily digest is due Tuesday 9 AM
+	clk.Set(monday(12).Add(20 * time.Hour))
+	d.tick()
+	d.flush()
+	if rec.count() != 0 {
+		t.Fatalf("expected no digest before it is due, got %d", rec.count())
+	}
+
+	clk.Set(monday(12).Add(21 * time.Hour))
+	d.tick()
+	waitFor(t, func() bool { return rec.count() == 1 })
+	title, msg := rec.get(0)
+	if title != "Shrinkray Daily Digest" {
+		t.Errorf("unexpected title: %q", title)
+	}
+	if msg != "1 jobs complete, 1 failed, 0 skipped\nSaved 585.9 KB\n\nFailed:\n- b.mkv" {
+		t.Errorf("unexpected digest: %q", msg)
+	}
+
+	// Not sent again until the next day
+	clk.Set(monday(12).Add(22 * time.Hour))
+	d.tick()
+	d.flush()
+	if rec.count() != 1 {
+		t.Errorf("expected one digest per day, got %d", rec.count())
+	}
+}
+
+func TestDispatcherSlowSender(t *testing.T) {
+	d, queue, _, rec := newTestDispatcher(t, &config.Config{NotifyOnFailure: true})
+
+	// Hold the first send so later events arrive while a backend is stuck
+	release := make(chan struct{})
+	var once sync.Once
+	d.SetSender(func(title, message string) error {
+		once.Do(func() { <-release })
+		return rec.send(title, message)
+	})
+
+	const n = 20
+	addJob(t, queue, "/media/pending.mkv")
+	for i := 0; i < n; i++ {
+		job := addJob(t, queue, fmt.Sprintf("/media/%d.mkv", i))
+		queue.StartJob(job.ID, "")
+		queue.FailJob(job.ID, "encoder error")
+	}
+
+	// Events keep being handled while the send is stuck
+	waitFor(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.digest.failed == n
+	})
+	if rec.count() != 0 {
+		t.Fatalf("expected the first send to still be blocked, got %d sent", rec.count())
+	}
+
+	close(release)
+	waitFor(t, func() bool { return rec.count() == n })
+}
+
+func TestNextDigest(t *testing.T) {
+	tests := []struct {
+		name string
+		last time.Time
+		mode string
+		want time.Time
+	}{
+		{"daily before hour", monday(8), "daily", monday(9)},
+		{"daily at hour", monday(9), "daily", monday(9).AddDate(0, 0, 1)},
+		{"daily after hour", monday(12), "daily", monday(9).AddDate(0, 0, 1)},
+		{"weekly before hour on monday", monday(8), "weekly", monday(9)},
+		{"weekly after hour on monday", monday(12), "weekly", monday(9).AddDate(0, 0, 7)},
+		{"weekly midweek", monday(12).AddDate(0, 0, 3), "weekly", monday(9).AddDate(0, 0, 7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextDigest(tt.last, tt.mode, 9); !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherNotConfigured(t *testing.T) {
+	queue := jobs.NewQueue()
+	rec := &recorder{}
+	d := NewDispatcher(queue, &config.Config{NotifyOnComplete: true, NotifyOnFailure: true})
+	d.SetSender(rec.send)
+	d.Start()
+	defer d.Stop()
+
+	job := addJob(t, queue, "/media/a.mkv")
+	queue.StartJob(job.ID, "")
+	queue.FailJob(job.ID, "encoder error")
+
+	d.flush()
+	if rec.count() != 0 {
+		t.Errorf("expected no notification without a configured backend, got %d", rec.count())
+	}
+}
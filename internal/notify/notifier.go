@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/pushover"
+)
+
+// Sentinel errors for notifier lookups.
+// These can be checked with errors.Is().
+var (
+	ErrUnknownBackend = errors.New("unknown notification backend")
+	ErrNotConfigured  = errors.New("notification backend not configured")
+)
+
+// Notifier is a notification channel
+type Notifier interface {
+	// Name returns the backend ID used in the API, e.g. "discord"
+	Name() string
+
+	// IsConfigured returns true if the backend has everything it needs to send
+	IsConfigured() bool
+
+	// Send delivers a notification with the given title and message
+	Send(title, message string) error
+}
+
+// httpClient is shared by the webhook-style backends
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Backends returns every notifier built from the current config, whether
+// configured or not. Notifiers are rebuilt on each call so settings changed
+// through the API take effect immediately.
+func Backends(cfg *config.Config) []Notifier {
+	return []Notifier{
+		pushover.NewClient(cfg.PushoverUserKey, cfg.PushoverAppToken),
+		NewDiscord(cfg.DiscordWebhookURL),
+		NewSlack(cfg.SlackWebhookURL),
+		NewNtfy(cfg.NtfyURL, cfg.NtfyToken),
+		NewGotify(cfg.GotifyURL, cfg.GotifyToken),
+		NewWebhook(cfg.WebhookURL),
+		NewEmail(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPTo),
+	}
+}
+
+// Configured returns the notifiers that are ready to send
+func Configured(cfg *config.Config) []Notifier {
+	var configured []Notifier
+	for _, n := range Backends(cfg) {
+		if n.IsConfigured() {
+			configured = append(configured, n)
+		}
+	}
+	return configured
+}
+
+// Backend returns the named notifier, or ErrUnknownBackend
+func Backend(cfg *config.Config, name string) (Notifier, error) {
+	for _, n := range Backends(cfg) {
+		if n.Name() == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+}
+
+// Test sends a test notification through n
+func Test(n Notifier) error {
+	if !n.IsConfigured() {
+		return fmt.Errorf("%w: %s", ErrNotConfigured, n.Name())
+	}
+	return n.Send("Shrinkray", fmt.Sprintf("Test notification - %s is configured correctly!", n.Name()))
+}
+
+// SendAll sends to every configured notifier. It returns the number of
+// backends that accepted the notification and the errors from the rest.
+func SendAll(cfg *config.Config, title, message string) (int, error) {
+	sent := 0
+	var errs []error
+	for _, n := range Configured(cfg) {
+		if err := n.Send(title, message); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// postJSON posts payload as JSON to url and fails on any non-2xx response
+func postJSON(url string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(url, "application/json", bytes.NewReader(body), headers)
+}
+
+func post(url, contentType string, body io.Reader, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if msg := strings.TrimSpace(string(detail)); msg != "" {
+			return fmt.Errorf("returned status %d: %s", resp.StatusCode, msg)
+		}
+		return fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	return nil
+}
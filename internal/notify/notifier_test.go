@@ -0,0 +1,267 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/config"
+)
+
+// captured is a request received by a stand-in server
+type captured struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+// newStandIn starts an HTTP server that records requests and replies with status
+func newStandIn(t *testing.T, status int) (*httptest.Server, chan captured) {
+	t.Helper()
+	requests := make(chan captured, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- captured{path: r.URL.Path, headers: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func decode(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", body, err)
+	}
+	return payload
+}
+
+func TestWebhookBackends(t *testing.T) {
+	srv, requests := newStandIn(t, http.StatusNoContent)
+
+	tests := []struct {
+		notifier Notifier
+		check    func(t *testing.T, req captured)
+	}{
+		{NewDiscord(srv.URL + "/discord"), func(t *testing.T, req captured) {
+			if got := decode(t, req.body)["content"]; got != "**Title**\nBody" {
+				t.Errorf("discord content: got %q", got)
+			}
+		}},
+		{NewSlack(srv.URL + "/slack"), func(t *testing.T, req captured) {
+			if got := decode(t, req.body)["text"]; got != "*Title*\nBody" {
+				t.Errorf("slack text: got %q", got)
+			}
+		}},
+		{NewNtfy(srv.URL+"/shrinkray", "tk_secret"), func(t *testing.T, req captured) {
+			if req.body != "Body" || req.headers.Get("Title") != "Title" {
+				t.Errorf("ntfy: got body %q title %q", req.body, req.headers.Get("Title"))
+			}
+			if req.headers.Get("Authorization") != "Bearer tk_secret" {
+				t.Errorf("ntfy auth: got %q", req.headers.Get("Authorization"))
+			}
+		}},
+		{NewGotify(srv.URL+"/", "app-token"), func(t *testing.T, req captured) {
+			if req.path != "/message" || req.headers.Get("X-Gotify-Key") != "app-token" {
+				t.Errorf("gotify: got path %q key %q", req.path, req.headers.Get("X-Gotify-Key"))
+			}
+			payload := decode(t, req.body)
+			if payload["title"] != "Title" || payload["message"] != "Body" {
+				t.Errorf("gotify payload: %v", payload)
+			}
+		}},
+		{NewWebhook(srv.URL + "/hook"), func(t *testing.T, req captured) {
+			payload := decode(t, req.body)
+			if payload["title"] != "Title" || payload["message"] != "Body" || payload["timestamp"] == "" {
+				t.Errorf("webhook payload: %v", payload)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.notifier.Name(), func(t *testing.T) {
+			if !tt.notifier.IsConfigured() {
+				t.Fatal("expected notifier to be configured")
+			}
+			if err := tt.notifier.Send("Title", "Body"); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			tt.check(t, <-requests)
+		})
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv, _ := newStandIn(t, http.StatusUnauthorized)
+
+	err := NewSlack(srv.URL).Send("Title", "Body")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected status 401 error, got %v", err)
+	}
+}
+
+// smtpStandIn is a minimal SMTP server that accepts one message
+func smtpStandIn(t *testing.T) (host string, port int, messages chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages = make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+func TestEmailSend(t *testing.T) {
+	host, port, messages := smtpStandIn(t)
+
+	email := NewEmail(host, port, "", "", "shrinkray@example.com", "a@example.com, b@example.com")
+	if len(email.To) != 2 {
+		t.Fatalf("expected 2 recipients, got %v", email.To)
+	}
+	if err := email.Send("Shrinkray Complete", "3 jobs complete\nSaved 1 GB"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	msg := <-messages
+	for _, want := range []string{
+		"From: shrinkray@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: Shrinkray Complete\r\n",
+		"3 jobs complete\r\nSaved 1 GB",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	// A server that accepts the connection but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+	}()
+
+	old := smtpTimeout
+	smtpTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { smtpTimeout = old })
+
+	addr := ln.Addr().(*net.TCPAddr)
+	email := NewEmail(addr.IP.String(), addr.Port, "", "", "shrinkray@example.com", "a@example.com")
+
+	start := time.Now()
+	if err := email.Send("Shrinkray Complete", "done"); err == nil {
+		t.Fatal("expected an error from an unresponsive server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %v, expected it to time out", elapsed)
+	}
+}
+
+func TestBackendLookup(t *testing.T) {
+	srv, requests := newStandIn(t, http.StatusOK)
+	cfg := &config.Config{DiscordWebhookURL: srv.URL}
+
+	configured := Configured(cfg)
+	if len(configured) != 1 || configured[0].Name() != "discord" {
+		t.Fatalf("expected only discord configured, got %v", configured)
+	}
+
+	n, err := Backend(cfg, "discord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Test(n); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	<-requests
+
+	if _, err := Backend(cfg, "carrier-pigeon"); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("expected ErrUnknownBackend, got %v", err)
+	}
+
+	n, _ = Backend(cfg, "gotify")
+	if err := Test(n); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestSendAllPartialFailure(t *testing.T) {
+	ok, requests := newStandIn(t, http.StatusOK)
+	failing, _ := newStandIn(t, http.StatusInternalServerError)
+	cfg := &config.Config{
+		DiscordWebhookURL: ok.URL,
+		SlackWebhookURL:   failing.URL,
+	}
+
+	sent, err := SendAll(cfg, "Title", "Body")
+	<-requests
+	if sent != 1 {
+		t.Errorf("expected 1 backend to succeed, got %d", sent)
+	}
+	if err == nil || !strings.Contains(err.Error(), "slack") {
+		t.Errorf("expected slack error, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"strings"
+	"time"
+)
+
+// Discord sends notifications to a Discord channel webhook
+type Discord struct {
+	WebhookURL string
+}
+
+// NewDiscord creates a Discord notifier
+func NewDiscord(webhookURL string) *Discord {
+	return &Discord{WebhookURL: webhookURL}
+}
+
+func (d *Discord) Name() string       { return "discord" }
+func (d *Discord) IsConfigured() bool { return d.WebhookURL != "" }
+
+// Send posts the title in bold followed by the message
+func (d *Discord) Send(title, message string) error {
+	return postJSON(d.WebhookURL, map[string]string{
+		"username": "Shrinkray",
+		"content":  "**" + title + "**\n" + message,
+	}, nil)
+}
+
+// Slack sends notifications to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+}
+
+// NewSlack creates a Slack notifier
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{WebhookURL: webhookURL}
+}
+
+func (s *Slack) Name() string       { return "slack" }
+func (s *Slack) IsConfigured() bool { return s.WebhookURL != "" }
+
+// Send posts the title in bold followed by the message
+func (s *Slack) Send(title, message string) error {
+	return postJSON(s.WebhookURL, map[string]string{
+		"text": "*" + title + "*\n" + message,
+	}, nil)
+}
+
+// Ntfy publishes notifications to an ntfy topic
+type Ntfy struct {
+	TopicURL string // Full topic URL, e.g. https://ntfy.sh/shrinkray
+	Token    string // Access token for protected topics (optional)
+}
+
+// NewNtfy creates an ntfy notifier
+func NewNtfy(topicURL, token string) *Ntfy {
+	return &Ntfy{TopicURL: topicURL, Token: token}
+}
+
+func (n *Ntfy) Name() string       { return "ntfy" }
+func (n *Ntfy) IsConfigured() bool { return n.TopicURL != "" }
+
+// Send publishes the message as the body with the title in the Title header
+func (n *Ntfy) Send(title, message string) error {
+	headers := map[string]string{"Title": title}
+	if n.Token != "" {
+		headers["Authorization"] = "Bearer " + n.Token
+	}
+	return post(n.TopicURL, "text/plain; charset=utf-8", strings.NewReader(message), headers)
+}
+
+// Gotify sends notifications to a Gotify server
+type Gotify struct {
+	ServerURL string // Base URL of the server, e.g. https://gotify.example.com
+	AppToken  string
+}
+
+// NewGotify creates a Gotify notifier
+func NewGotify(serverURL, appToken string) *Gotify {
+	return &Gotify{ServerURL: serverURL, AppToken: appToken}
+}
+
+func (g *Gotify) Name() string       { return "gotify" }
+func (g *Gotify) IsConfigured() bool { return g.ServerURL != "" && g.AppToken != "" }
+
+// Send creates a message through the Gotify message API
+func (g *Gotify) Send(title, message string) error {
+	return postJSON(strings.TrimRight(g.ServerURL, "/")+"/message", map[string]interface{}{
+		"title":    title,
+		"message":  message,
+		"priority": 5,
+	}, map[string]string{"X-Gotify-Key": g.AppToken})
+}
+
+// Webhook posts notifications as JSON to an arbitrary URL
+type Webhook struct {
+	URL string
+}
+
+// WebhookPayload is the body posted by the generic webhook backend
+type WebhookPayload struct {
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewWebhook creates a generic JSON webhook notifier
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url}
+}
+
+func (w *Webhook) Name() string       { return "webhook" }
+func (w *Webhook) IsConfigured() bool { return w.URL != "" }
+
+// Send posts a WebhookPayload
+func (w *Webhook) Send(title, message string) error {
+	return postJSON(w.URL, WebhookPayload{
+		Title:     title,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	}, nil)
+}
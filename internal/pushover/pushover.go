@@ -24,6 +24,11 @@ func NewClient(userKey, appToken string) *Client {
 	}
 }
 
+// Name returns the notification backend ID
+func (c *Client) Name() string {
+	return "pushover"
+}
+
 // IsConfigured returns true if both credentials are set
 func (c *Client) IsConfigured() bool {
 	return c.UserKey != "" && c.AppToken != ""
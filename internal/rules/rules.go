@@ -0,0 +1,296 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/browse"
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/jobs"
+	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
+)
+
+// Rule is a validated queue rule, ready to match probe results
+type Rule struct {
+	config.QueueRule
+
+	paths      []*regexp.Regexp // Any of; nil = any path
+	minBitrate int64
+	minSize    int64
+	maxSize    int64
+}
+
+// Compile validates queue rules and prepares them for matching.
+// Relative rule paths are resolved against each of mediaPaths. Rules without
+// a name are named after their position. Presets must be initialized first.
+func Compile(queueRules []config.QueueRule, mediaPaths []string) ([]*Rule, error) {
+	compiled := make([]*Rule, 0, len(queueRules))
+	for i, qr := range queueRules {
+		if qr.Name == "" {
+			qr.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		r, err := compile(qr, mediaPaths)
+		if err != nil {
+			return nil, fmt.Errorf("queue rule %q: %w", qr.Name, err)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+func compile(qr config.QueueRule, mediaPaths []string) (*Rule, error) {
+	r := &Rule{QueueRule: qr}
+
+	if ffmpeg.GetPreset(qr.PresetID) == nil {
+		return nil, fmt.Errorf("unknown preset %q", qr.PresetID)
+	}
+	if qr.SmartShrinkQuality != "" && !jobs.IsValidSmartShrinkQuality(qr.SmartShrinkQuality) {
+		return nil, fmt.Errorf("smartshrink_quality must be 'acceptable', 'good', or 'excellent'")
+	}
+	if qr.MaxHeight > 0 && qr.MinHeight > qr.MaxHeight {
+		return nil, fmt.Errorf("min_height is greater than max_height")
+	}
+
+	if filepath.IsAbs(qr.Path) {
+		r.paths = []*regexp.Regexp{globRegexp(filepath.Clean(qr.Path))}
+	} else if qr.Path != "" {
+		for _, mediaPath := range mediaPaths {
+			r.paths = append(r.paths, globRegexp(filepath.Join(mediaPath, qr.Path)))
+		}
+	}
+
+	var err error
+	if qr.MinBitrate != "" {
+		if r.minBitrate, err = ffmpeg.ParseBitrate(qr.MinBitrate); err != nil {
+			return nil, fmt.Errorf("invalid min_bitrate %q (expected e.g. \"8M\")", qr.MinBitrate)
+		}
+	}
+	if qr.MinSize != "" {
+		if r.minSize, err = util.ParseBytes(qr.MinSize); err != nil {
+			return nil, fmt.Errorf("invalid min_size %q (expected e.g. \"2G\")", qr.MinSize)
+		}
+	}
+	if qr.MaxSize != "" {
+		if r.maxSize, err = util.ParseBytes(qr.MaxSize); err != nil {
+			return nil, fmt.Errorf("invalid max_size %q (expected e.g. \"20G\")", qr.MaxSize)
+		}
+	}
+	return r, nil
+}
+
+// globRegexp converts a path glob to an anchored regular expression.
+// "*" and "?" don't match "/", "**" does. A pattern without wildcards
+// matches the path itself and everything under it.
+func globRegexp(pattern string) *regexp.Regexp {
+	if !strings.ContainsAny(pattern, "*?") {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "(/.*)?$")
+	}
+
+	return regexp.MustCompile("^" + util.GlobRegexp(pattern, false) + "$")
+}
+
+// Matches returns true if the probed file meets every condition of the rule
+func (r *Rule) Matches(probe *ffmpeg.ProbeResult) bool {
+	switch {
+	case r.paths != nil && !slices.ContainsFunc(r.paths, func(re *regexp.Regexp) bool { return re.MatchString(probe.Path) }):
+		return false
+	case len(r.Codecs) > 0 && !slices.ContainsFunc(r.Codecs, func(c string) bool { return strings.EqualFold(c, probe.VideoCodec) }):
+		return false
+	case r.minBitrate > 0 && probe.Bitrate < r.minBitrate:
+		return false
+	case r.MinHeight > 0 && probe.Height < r.MinHeight:
+		return false
+	case r.MaxHeight > 0 && probe.Height > r.MaxHeight:
+		return false
+	case r.HDR != nil && probe.IsHDR != *r.HDR:
+		return false
+	case r.BitDepth > 0 && probe.BitDepth != r.BitDepth:
+		return false
+	case r.minSize > 0 && probe.Size < r.minSize:
+		return false
+	case r.maxSize > 0 && probe.Size > r.maxSize:
+		return false
+	}
+	return true
+}
+
+// Match is a file a rule would queue
+type Match struct {
+	Path               string `json:"path"`
+	Size               int64  `json:"size"`
+	Rule               string `json:"rule"`
+	PresetID           string `json:"preset_id"`
+	SmartShrinkQuality string `json:"smartshrink_quality,omitempty"`
+
+	probe *ffmpeg.ProbeResult
+}
+
+// Prober finds and probes video files under a set of paths.
+// Implemented by browse.Browser.
+type Prober interface {
+	GetVideoFilesWithProgress(ctx context.Context, paths []string, onProgress browse.ProgressCallback) ([]*ffmpeg.ProbeResult, error)
+}
+
+// Runner evaluates queue rules over the media library
+type Runner struct {
+	queue  *jobs.Queue
+	prober Prober
+	roots  []string
+	rules  []*Rule
+
+	runMu sync.Mutex // One evaluation at a time, so files aren't queued twice
+
+	mu      sync.Mutex // Guards lastRun
+	lastRun time.Time
+}
+
+// New creates a runner that evaluates rules over every video file under roots
+func New(queue *jobs.Queue, prober Prober, roots []string, rules []*Rule) *Runner {
+	return &Runner{
+		queue:  queue,
+		prober: prober,
+		roots:  roots,
+		rules:  rules,
+	}
+}
+
+// Rules returns the compiled rules
+func (r *Runner) Rules() []*Rule {
+	return r.rules
+}
+
+// LastRun returns when rules last queued files (zero if they haven't run yet)
+func (r *Runner) LastRun() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastRun
+}
+
+// Plan returns the files the rules would queue, without queueing them (a dry run)
+func (r *Runner) Plan(ctx context.Context) ([]Match, error) {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	return r.plan(ctx)
+}
+
+// Apply queues the files matched by the rules and returns them
+func (r *Runner) Apply(ctx context.Context) ([]Match, error) {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	matches, err := r.plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// One batch per preset and quality, in rule order
+	type batchKey struct{ presetID, quality string }
+	var keys []batchKey
+	batches := make(map[batchKey][]*ffmpeg.ProbeResult)
+	for _, m := range matches {
+		key := batchKey{m.PresetID, m.SmartShrinkQuality}
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], m.probe)
+	}
+	for _, key := range keys {
+		if _, err := r.queue.AddMultiple(batches[key], key.presetID, key.quality); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	r.lastRun = time.Now()
+	r.mu.Unlock()
+
+	if len(matches) > 0 {
+		logger.Info("Queued files from queue rules", "count", len(matches))
+	}
+	return matches, nil
+}
+
+// plan probes the library and matches each file against the rules.
+// Files with an active job, whose last job didn't complete, or that the
+// matched rule's preset would skip, are left out. Called with runMu held.
+func (r *Runner) plan(ctx context.Context) ([]Match, error) {
+	if len(r.rules) == 0 {
+		return nil, nil
+	}
+
+	probes, err := r.prober.GetVideoFilesWithProgress(ctx, r.roots, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for _, probe := range probes {
+		rule := r.match(probe)
+		if rule == nil || r.queue.HasActiveJob(probe.Path) || r.unfinished(probe) || r.queue.SkipReason(probe, rule.PresetID) != "" {
+			continue
+		}
+		matches = append(matches, Match{
+			Path:               probe.Path,
+			Size:               probe.Size,
+			Rule:               rule.Name,
+			PresetID:           rule.PresetID,
+			SmartShrinkQuality: rule.SmartShrinkQuality,
+			probe:              probe,
+		})
+	}
+	return matches, nil
+}
+
+// unfinished reports whether the file's last job failed, was skipped (e.g.
+// output larger than the original) or was cancelled, and the file hasn't
+// changed since. Queueing it on every run would end the same way each time;
+// retrying or queueing it by hand still works.
+func (r *Runner) unfinished(probe *ffmpeg.ProbeResult) bool {
+	job := r.queue.LastJob(probe.Path)
+	if job == nil || job.InputSize != probe.Size {
+		return false
+	}
+	switch job.Status {
+	case jobs.StatusFailed, jobs.StatusSkipped, jobs.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// match returns the first rule the file matches, or nil
+func (r *Runner) match(probe *ffmpeg.ProbeResult) *Rule {
+	for _, rule := range r.rules {
+		if rule.Matches(probe) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Run applies the rules every interval until ctx is cancelled
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Apply(ctx); err != nil {
+				logger.Warn("Queue rules failed", "error", err.Error())
+			}
+		}
+	}
+}
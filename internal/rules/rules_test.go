@@ -0,0 +1,287 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/browse"
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/jobs"
+)
+
+// fakeProber returns fixed probe results
+type fakeProber struct {
+	probes []*ffmpeg.ProbeResult
+}
+
+func (p *fakeProber) GetVideoFilesWithProgress(ctx context.Context, paths []string, onProgress browse.ProgressCallback) ([]*ffmpeg.ProbeResult, error) {
+	return p.probes, nil
+}
+
+func mustCompile(t *testing.T, queueRules ...config.QueueRule) []*Rule {
+	t.Helper()
+	compiled, err := Compile(queueRules, []string{"/media"})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return compiled
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.QueueRule
+		want string
+	}{
+		{"unknown preset", config.QueueRule{PresetID: "nope"}, "unknown preset"},
+		{"bad quality", config.QueueRule{PresetID: "compress-hevc", SmartShrinkQuality: "best"}, "smartshrink_quality"},
+		{"bad bitrate", config.QueueRule{PresetID: "compress-hevc", MinBitrate: "fast"}, "min_bitrate"},
+		{"bad size", config.QueueRule{PresetID: "compress-hevc", MinSize: "big"}, "min_size"},
+		{"heights reversed", config.QueueRule{PresetID: "compress-hevc", MinHeight: 2160, MaxHeight: 1080}, "min_height"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]config.QueueRule{tt.rule}, []string{"/media"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), `"rule 1"`) {
+				t.Errorf("expected unnamed rule to be named by position: %v", err)
+			}
+		})
+	}
+}
+
+func TestRulePathGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"tv", "/media/tv/Show/S01E01.mkv", true},
+		{"/media/tv", "/media/tv/Show/S01E01.mkv", true},
+		{"/media/tv", "/media/tv2/Show/S01E01.mkv", false},
+		{"tv/*", "/media/tv/episode.mkv", true},
+		{"tv/*", "/media/tv/Show/S01E01.mkv", false},
+		{"tv/**", "/media/tv/Show/S01E01.mkv", true},
+		{"tv/**/*.avi", "/media/tv/Show/Season 1/S01E01.avi", true},
+		{"tv/**/*.avi", "/media/tv/S01E01.avi", true},
+		{"tv/**/*.avi", "/media/tv/Show/S01E01.mkv", false},
+		{"movies/*(19??)*/*", "/media/movies/Film (1999)/Film.mkv", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			rule := mustCompile(t, config.QueueRule{Path: tt.pattern, PresetID: "compress-hevc"})[0]
+			if got := rule.Matches(&ffmpeg.ProbeResult{Path: tt.path}); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulePathSeveralRoots(t *testing.T) {
+	compiled, err := Compile([]config.QueueRule{{Path: "4K/**", PresetID: "1080p"}}, []string{"/mnt/movies", "/mnt/tv"})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	rule := compiled[0]
+	for path, want := range map[string]bool{
+		"/mnt/movies/4K/Film.mkv": true,
+		"/mnt/tv/4K/Show/E01.mkv": true,
+		"/mnt/other/4K/Film.mkv":  false,
+		"/mnt/movies/HD/Film.mkv": false,
+	} {
+		if got := rule.Matches(&ffmpeg.ProbeResult{Path: path}); got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestRuleConditions(t *testing.T) {
+	hdr := true
+	rule := mustCompile(t, config.QueueRule{
+		Codecs:     []string{"H264", "mpeg2video"},
+		MinBitrate: "8M",
+		MinHeight:  720,
+		MaxHeight:  1080,
+		HDR:        &hdr,
+		BitDepth:   10,
+		MinSize:    "1G",
+		MaxSize:    "10G",
+		PresetID:   "compress-hevc",
+	})[0]
+
+	match := func() *ffmpeg.ProbeResult {
+		return &ffmpeg.ProbeResult{
+			Path:       "/media/movie.mkv",
+			VideoCodec: "h264",
+			Bitrate:    10_000_000,
+			Height:     1080,
+			IsHDR:      true,
+			BitDepth:   10,
+			Size:       2 << 30,
+		}
+	}
+	if !rule.Matches(match()) {
+		t.Fatal("expected file meeting every condition to match")
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *ffmpeg.ProbeResult)
+	}{
+		{"codec", func(p *ffmpeg.ProbeResult) { p.VideoCodec = "hevc" }},
+		{"bitrate", func(p *ffmpeg.ProbeResult) { p.Bitrate = 5_000_000 }},
+		{"too small", func(p *ffmpeg.ProbeResult) { p.Height = 480 }},
+		{"too tall", func(p *ffmpeg.ProbeResult) { p.Height = 2160 }},
+		{"sdr", func(p *ffmpeg.ProbeResult) { p.IsHDR = false }},
+		{"bit depth", func(p *ffmpeg.ProbeResult) { p.BitDepth = 8 }},
+		{"min size", func(p *ffmpeg.ProbeResult) { p.Size = 100 << 20 }},
+		{"max size", func(p *ffmpeg.ProbeResult) { p.Size = 20 << 30 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := match()
+			tt.modify(probe)
+			if rule.Matches(probe) {
+				t.Errorf("expected no match when %s differs", tt.name)
+			}
+		})
+	}
+}
+
+func TestRunnerPlanAndApply(t *testing.T) {
+	queue := jobs.NewQueue()
+	prober := &fakeProber{probes: []*ffmpeg.ProbeResult{
+		{Path: "/media/tv/Show/S01E01.mkv", VideoCodec: "h264", Bitrate: 12_000_000, Height: 1080},
+		{Path: "/media/tv/Show/S01E02.mkv", VideoCodec: "h264", Bitrate: 4_000_000, Height: 1080},
+		{Path: "/media/tv/Show/S01E03.mkv", VideoCodec: "hevc", IsHEVC: true, Bitrate: 12_000_000, Height: 1080},
+		{Path: "/media/movies/Film.mkv", VideoCodec: "hevc", IsHEVC: true, Height: 2160},
+		{Path: "/media/movies/Small.mkv", VideoCodec: "h264", Height: 720},
+		{Path: "/media/movies/Queued.mkv", VideoCodec: "h264", Height: 2160},
+	}}
+
+	runner := New(queue, prober, []string{"/media"}, mustCompile(t,
+		config.QueueRule{Name: "tv", Path: "tv", Codecs: []string{"h264"}, MinBitrate: "8M", PresetID: "compress-hevc"},
+		config.QueueRule{Name: "4k", Path: "movies", MinHeight: 2160, PresetID: "1080p"},
+		// Catch-all: S01E03 matches it but would be skipped (already HEVC)
+		config.QueueRule{Name: "rest", Path: "tv", PresetID: "compress-hevc"},
+	))
+
+	// Already queued by hand
+	if _, err := queue.Add("/media/movies/Queued.mkv", "1080p", prober.probes[5], ""); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := runner.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	got := make(map[string]string)
+	for _, m := range matches {
+		got[m.Path] = m.Rule
+	}
+	want := map[string]string{
+		"/media/tv/Show/S01E01.mkv": "tv",
+		"/media/tv/Show/S01E02.mkv": "rest",
+		"/media/movies/Film.mkv":    "4k",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d matches, got %+v", len(want), matches)
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Errorf("%s: expected rule %q, got %q", path, rule, got[path])
+		}
+	}
+
+	// A dry run queues nothing
+	if n := len(queue.GetAll()); n != 1 {
+		t.Fatalf("expected dry run to leave the queue alone, got %d jobs", n)
+	}
+	if !runner.LastRun().IsZero() {
+		t.Error("expected no last run after a dry run")
+	}
+
+	if _, err := runner.Apply(context.Background()); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	presets := make(map[string]string)
+	for _, job := range queue.GetAll() {
+		if job.Status != jobs.StatusPending {
+			t.Errorf("%s: expected pending, got %s", job.InputPath, job.Status)
+		}
+		presets[job.InputPath] = job.PresetID
+	}
+	if len(presets) != 4 || presets["/media/movies/Film.mkv"] != "1080p" || presets["/media/tv/Show/S01E01.mkv"] != "compress-hevc" {
+		t.Errorf("unexpected jobs: %+v", presets)
+	}
+
+	// Queued files aren't matched again
+	matches, _ = runner.Plan(context.Background())
+	if len(matches) != 0 {
+		t.Errorf("expected no matches after applying, got %+v", matches)
+	}
+}
+
+func TestRunnerSkipsUnfinishedJobs(t *testing.T) {
+	queue := jobs.NewQueue()
+	prober := &fakeProber{probes: []*ffmpeg.ProbeResult{
+		{Path: "/media/tv/Larger.mkv", Size: 1000, VideoCodec: "h264"},
+		{Path: "/media/tv/Failed.mkv", Size: 1000, VideoCodec: "h264"},
+		{Path: "/media/tv/Changed.mkv", Size: 1000, VideoCodec: "h264"},
+	}}
+	runner := New(queue, prober, []string{"/media"}, mustCompile(t,
+		config.QueueRule{Name: "tv", Path: "tv", PresetID: "compress-hevc"},
+	))
+
+	if _, err := runner.Apply(context.Background()); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	for _, job := range queue.GetAll() {
+		_ = queue.StartJob(job.ID, "")
+		switch job.InputPath {
+		case "/media/tv/Larger.mkv":
+			_ = queue.SkipJob(job.ID, "Output larger than original (1.1 KB > 1000 B)")
+		default:
+			_ = queue.FailJob(job.ID, "encoder error")
+		}
+	}
+	// A replaced file is queued again
+	prober.probes[2] = &ffmpeg.ProbeResult{Path: "/media/tv/Changed.mkv", Size: 2000, VideoCodec: "h264"}
+
+	// Scheduled runs must not re-queue files whose last job would end the same way
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+	last, runs := runner.LastRun(), 0
+	for deadline := time.Now().Add(5 * time.Second); runs < 3 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if run := runner.LastRun(); run.After(last) {
+			last = run
+			runs++
+		}
+	}
+	cancel()
+	<-done
+	if runs < 3 {
+		t.Fatalf("expected 3 scheduled runs, got %d", runs)
+	}
+
+	var pending []string
+	for _, job := range queue.GetAll() {
+		if job.Status == jobs.StatusPending {
+			pending = append(pending, job.InputPath)
+		}
+	}
+	if len(pending) != 1 || pending[0] != "/media/tv/Changed.mkv" {
+		t.Errorf("expected only the changed file queued again, got %v", pending)
+	}
+}
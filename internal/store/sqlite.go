@@ -2,18 +2,24 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/gwlsn/shrinkray/internal/browse"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
 	"github.com/gwlsn/shrinkray/internal/jobs"
+	"github.com/gwlsn/shrinkray/internal/trash"
+	"github.com/gwlsn/shrinkray/internal/watch"
 	_ "modernc.org/sqlite"
 )
 
-const schemaVersion = 6
+const schemaVersion = 13
 
 const schema = `
 CREATE TABLE IF NOT EXISTS jobs (
@@ -49,6 +55,17 @@ CREATE TABLE IF NOT EXISTS jobs (
 	quality_mod REAL DEFAULT 0,
 	skip_reason TEXT DEFAULT '',
 	smartshrink_quality TEXT DEFAULT '',
+	subtitle_note TEXT DEFAULT '',
+	crop TEXT DEFAULT '',
+	field_order TEXT DEFAULT '',
+	scan_type TEXT DEFAULT '',
+	original_path TEXT DEFAULT '',
+	priority INTEGER DEFAULT 0,
+	quality INTEGER DEFAULT 0,
+	output_format TEXT DEFAULT '',
+	tonemap TEXT DEFAULT '',
+	original_handling TEXT DEFAULT '',
+	sidecars TEXT DEFAULT '',
 	created_at TEXT NOT NULL,
 	started_at TEXT,
 	completed_at TEXT
@@ -70,9 +87,53 @@ CREATE TABLE IF NOT EXISTS stats_metadata (
 	updated_at TEXT DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS trash (
+	job_id TEXT PRIMARY KEY,
+	original_path TEXT NOT NULL,
+	trash_path TEXT NOT NULL,
+	size INTEGER NOT NULL DEFAULT 0,
+	trashed_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS watch_files (
+	path TEXT PRIMARY KEY,
+	size INTEGER NOT NULL DEFAULT 0,
+	mod_time INTEGER NOT NULL DEFAULT 0,
+	outcome TEXT NOT NULL,
+	seen_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS processed_files (
+	path TEXT PRIMARY KEY,
+	size INTEGER NOT NULL DEFAULT 0,
+	mod_time INTEGER NOT NULL DEFAULT 0,
+	outcome TEXT NOT NULL,
+	preset_id TEXT NOT NULL DEFAULT '',
+	processed_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS probes (
+	path TEXT PRIMARY KEY,
+	size INTEGER NOT NULL DEFAULT 0,
+	mod_time INTEGER NOT NULL DEFAULT 0,
+	inode INTEGER NOT NULL DEFAULT 0,
+	container TEXT NOT NULL DEFAULT '',
+	video_codec TEXT NOT NULL DEFAULT '',
+	width INTEGER NOT NULL DEFAULT 0,
+	height INTEGER NOT NULL DEFAULT 0,
+	resolution TEXT NOT NULL DEFAULT '',
+	bitrate INTEGER NOT NULL DEFAULT 0,
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	is_hdr INTEGER NOT NULL DEFAULT 0,
+	bit_depth INTEGER NOT NULL DEFAULT 0,
+	data TEXT NOT NULL,
+	probed_at TEXT NOT NULL
+);
+
 CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
 CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at);
 CREATE INDEX IF NOT EXISTS idx_jobs_status_created ON jobs(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_probes_codec_height ON probes(video_codec, height);
 `
 
 // SQLiteStore implements Store using SQLite.
@@ -212,6 +273,90 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 				}
 			}
 		}
+		if version < 7 {
+			// Migrate v6 -> v7: Add subtitle_note for subtitle streams dropped or extracted during transcode
+			migrations := []string{
+				`ALTER TABLE jobs ADD COLUMN subtitle_note TEXT DEFAULT ''`,
+			}
+			for _, m := range migrations {
+				if _, err := db.Exec(m); err != nil {
+					db.Close()
+					return nil, fmt.Errorf("migration v6->v7 failed: %w", err)
+				}
+			}
+		}
+		if version < 8 {
+			// Migrate v7 -> v8: Add crop for detected black-bar crop
+			migrations := []string{
+				`ALTER TABLE jobs ADD COLUMN crop TEXT DEFAULT ''`,
+			}
+			for _, m := range migrations {
+				if _, err := db.Exec(m); err != nil {
+					db.Close()
+					return nil, fmt.Errorf("migration v7->v8 failed: %w", err)
+				}
+			}
+		}
+		if version < 9 {
+			// Migrate v8 -> v9: Add field_order and scan_type for interlace detection
+			migrations := []string{
+				`ALTER TABLE jobs ADD COLUMN field_order TEXT DEFAULT ''`,
+				`ALTER TABLE jobs ADD COLUMN scan_type TEXT DEFAULT ''`,
+			}
+			for _, m := range migrations {
+				if _, err := db.Exec(m); err != nil {
+					db.Close()
+					return nil, fmt.Errorf("migration v8->v9 failed: %w", err)
+				}
+			}
+		}
+		if version < 10 {
+			// Migrate v9 -> v10: Add original_path for originals kept as .old or in the trash.
+			// The trash table itself is created by the schema (CREATE TABLE IF NOT EXISTS).
+			migrations := []string{
+				`ALTER TABLE jobs ADD COLUMN original_path TEXT DEFAULT ''`,
+			}
+			for _, m := range migrations {
+				if _, err := db.Exec(m); err != nil {
+					db.Close()
+					return nil, fmt.Errorf("migration v9->v10 failed: %w", err)
+				}
+			}
+		}
+		if version < 11 {
+			// Migrate v10 -> v11: Add priority (higher runs first)
+			migrations := []string{
+				`ALTER TABLE jobs ADD COLUMN priority INTEGER DEFAULT 0`,
+			}
+			for _, m := range migrations {
+				if _, err := db.Exec(m); err != nil {
+					db.Close()
+					return nil, fmt.Errorf("migration v10->v11 failed: %w", err)
+				}
+			}
+		}
+		if version < 12 {
+			// Migrate v11 -> v12: Add per-job overrides of the config
+			migrations := []string{
+				`ALTER TABLE jobs ADD COLUMN quality INTEGER DEFAULT 0`,
+				`ALTER TABLE jobs ADD COLUMN output_format TEXT DEFAULT ''`,
+				`ALTER TABLE jobs ADD COLUMN tonemap TEXT DEFAULT ''`,
+				`ALTER TABLE jobs ADD COLUMN original_handling TEXT DEFAULT ''`,
+			}
+			for _, m := range migrations {
+				if _, err := db.Exec(m); err != nil {
+					db.Close()
+					return nil, fmt.Errorf("migration v11->v12 failed: %w", err)
+				}
+			}
+		}
+		if version < 13 {
+			// Migrate v12 -> v13: Add sidecar subtitle files written by a job
+			if _, err := db.Exec(`ALTER TABLE jobs ADD COLUMN sidecars TEXT DEFAULT ''`); err != nil {
+				db.Close()
+				return nil, fmt.Errorf("migration v12->v13 failed: %w", err)
+			}
+		}
 		// Update version
 		_, err = db.Exec("INSERT INTO schema_version (version) VALUES (?)", schemaVersion)
 		if err != nil {
@@ -238,8 +383,11 @@ func (s *SQLiteStore) saveJobLocked(job *jobs.Job) error {
 			status, progress, speed, eta, error, input_size, output_size, space_saved,
 			duration_ms, bitrate, width, height, frame_rate, video_codec, profile, bit_depth,
 			is_hdr, color_transfer, transcode_secs, phase, vmaf_score, selected_crf, quality_mod, skip_reason,
-			smartshrink_quality, created_at, started_at, completed_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			smartshrink_quality, subtitle_note, crop,
+			field_order, scan_type, original_path, priority,
+			quality, output_format, tonemap, original_handling, sidecars,
+			created_at, started_at, completed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		job.ID, job.InputPath, nullString(job.OutputPath), nullString(job.TempPath),
 		job.PresetID, job.Encoder, boolToInt(job.IsHardware),
@@ -249,7 +397,10 @@ func (s *SQLiteStore) saveJobLocked(job *jobs.Job) error {
 		nullFloat64(job.FrameRate), nullString(job.VideoCodec), nullString(job.Profile), nullInt(job.BitDepth),
 		boolToInt(job.IsHDR), nullString(job.ColorTransfer), nullInt64(job.TranscodeTime),
 		string(job.Phase), nullFloat64(job.VMafScore), nullInt(job.SelectedCRF), nullFloat64(job.QualityMod), nullString(job.SkipReason),
-		nullString(job.SmartShrinkQuality), formatTime(job.CreatedAt), formatTimePtr(job.StartedAt), formatTimePtr(job.CompletedAt),
+		nullString(job.SmartShrinkQuality), nullString(job.SubtitleNote), nullString(job.Crop),
+		nullString(job.FieldOrder), nullString(job.ScanType), nullString(job.OriginalPath), job.Priority,
+		job.Quality, job.OutputFormat, job.Tonemap, job.OriginalHandling, nullString(job.Sidecars),
+		formatTime(job.CreatedAt), formatTimePtr(job.StartedAt), formatTimePtr(job.CompletedAt),
 	)
 	return err
 }
@@ -268,7 +419,10 @@ func (s *SQLiteStore) getJobLocked(id string) (*jobs.Job, error) {
 			status, progress, speed, eta, error, input_size, output_size, space_saved,
 			duration_ms, bitrate, width, height, frame_rate, video_codec, profile, bit_depth,
 			is_hdr, color_transfer, transcode_secs, phase, vmaf_score, selected_crf, quality_mod, skip_reason,
-			smartshrink_quality, created_at, started_at, completed_at
+			smartshrink_quality, subtitle_note, crop,
+			field_order, scan_type, original_path, priority,
+			quality, output_format, tonemap, original_handling, sidecars,
+			created_at, started_at, completed_at
 		FROM jobs WHERE id = ?
 	`, id)
 
@@ -302,8 +456,11 @@ func (s *SQLiteStore) SaveJobs(jobList []*jobs.Job) error {
 			status, progress, speed, eta, error, input_size, output_size, space_saved,
 			duration_ms, bitrate, width, height, frame_rate, video_codec, profile, bit_depth,
 			is_hdr, color_transfer, transcode_secs, phase, vmaf_score, selected_crf, quality_mod, skip_reason,
-			smartshrink_quality, created_at, started_at, completed_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			smartshrink_quality, subtitle_note, crop,
+			field_order, scan_type, original_path, priority,
+			quality, output_format, tonemap, original_handling, sidecars,
+			created_at, started_at, completed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return err
@@ -320,7 +477,10 @@ func (s *SQLiteStore) SaveJobs(jobList []*jobs.Job) error {
 			nullFloat64(job.FrameRate), nullString(job.VideoCodec), nullString(job.Profile), nullInt(job.BitDepth),
 			boolToInt(job.IsHDR), nullString(job.ColorTransfer), nullInt64(job.TranscodeTime),
 			string(job.Phase), nullFloat64(job.VMafScore), nullInt(job.SelectedCRF), nullFloat64(job.QualityMod), nullString(job.SkipReason),
-			nullString(job.SmartShrinkQuality), formatTime(job.CreatedAt), formatTimePtr(job.StartedAt), formatTimePtr(job.CompletedAt),
+			nullString(job.SmartShrinkQuality), nullString(job.SubtitleNote), nullString(job.Crop),
+			nullString(job.FieldOrder), nullString(job.ScanType), nullString(job.OriginalPath), job.Priority,
+			job.Quality, job.OutputFormat, job.Tonemap, job.OriginalHandling, nullString(job.Sidecars),
+			formatTime(job.CreatedAt), formatTimePtr(job.StartedAt), formatTimePtr(job.CompletedAt),
 		)
 		if err != nil {
 			return err
@@ -341,7 +501,10 @@ func (s *SQLiteStore) GetAllJobs() ([]*jobs.Job, []string, error) {
 			j.status, j.progress, j.speed, j.eta, j.error, j.input_size, j.output_size, j.space_saved,
 			j.duration_ms, j.bitrate, j.width, j.height, j.frame_rate, j.video_codec, j.profile, j.bit_depth,
 			j.is_hdr, j.color_transfer, j.transcode_secs, j.phase, j.vmaf_score, j.selected_crf, j.quality_mod, j.skip_reason,
-			j.smartshrink_quality, j.created_at, j.started_at, j.completed_at
+			j.smartshrink_quality, j.subtitle_note, j.crop,
+			j.field_order, j.scan_type, j.original_path, j.priority,
+			j.quality, j.output_format, j.tonemap, j.original_handling, j.sidecars,
+			j.created_at, j.started_at, j.completed_at
 		FROM jobs j
 		LEFT JOIN job_order o ON j.id = o.job_id
 		ORDER BY o.position ASC, j.created_at ASC
@@ -376,7 +539,10 @@ func (s *SQLiteStore) GetJobsByStatus(status jobs.Status) ([]*jobs.Job, error) {
 			j.status, j.progress, j.speed, j.eta, j.error, j.input_size, j.output_size, j.space_saved,
 			j.duration_ms, j.bitrate, j.width, j.height, j.frame_rate, j.video_codec, j.profile, j.bit_depth,
 			j.is_hdr, j.color_transfer, j.transcode_secs, j.phase, j.vmaf_score, j.selected_crf, j.quality_mod, j.skip_reason,
-			j.smartshrink_quality, j.created_at, j.started_at, j.completed_at
+			j.smartshrink_quality, j.subtitle_note, j.crop,
+			j.field_order, j.scan_type, j.original_path, j.priority,
+			j.quality, j.output_format, j.tonemap, j.original_handling, j.sidecars,
+			j.created_at, j.started_at, j.completed_at
 		FROM jobs j
 		LEFT JOIN job_order o ON j.id = o.job_id
 		WHERE j.status = ?
@@ -399,7 +565,7 @@ func (s *SQLiteStore) GetJobsByStatus(status jobs.Status) ([]*jobs.Job, error) {
 	return jobList, rows.Err()
 }
 
-// GetNextPendingJob returns the first pending job in queue order.
+// GetNextPendingJob returns the highest priority pending job, first in queue order among equals.
 func (s *SQLiteStore) GetNextPendingJob() (*jobs.Job, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -409,11 +575,14 @@ func (s *SQLiteStore) GetNextPendingJob() (*jobs.Job, error) {
 			j.status, j.progress, j.speed, j.eta, j.error, j.input_size, j.output_size, j.space_saved,
 			j.duration_ms, j.bitrate, j.width, j.height, j.frame_rate, j.video_codec, j.profile, j.bit_depth,
 			j.is_hdr, j.color_transfer, j.transcode_secs, j.phase, j.vmaf_score, j.selected_crf, j.quality_mod, j.skip_reason,
-			j.smartshrink_quality, j.created_at, j.started_at, j.completed_at
+			j.smartshrink_quality, j.subtitle_note, j.crop,
+			j.field_order, j.scan_type, j.original_path, j.priority,
+			j.quality, j.output_format, j.tonemap, j.original_handling, j.sidecars,
+			j.created_at, j.started_at, j.completed_at
 		FROM jobs j
 		LEFT JOIN job_order o ON j.id = o.job_id
 		WHERE j.status = 'pending'
-		ORDER BY o.position ASC, j.created_at ASC
+		ORDER BY j.priority DESC, o.position ASC, j.created_at ASC
 		LIMIT 1
 	`)
 
@@ -516,12 +685,13 @@ func (s *SQLiteStore) Stats() (jobs.Stats, error) {
 			SUM(CASE WHEN status = 'complete' THEN 1 ELSE 0 END) as complete,
 			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) as failed,
 			SUM(CASE WHEN status = 'cancelled' THEN 1 ELSE 0 END) as cancelled,
-			SUM(CASE WHEN status = 'skipped' THEN 1 ELSE 0 END) as skipped
+			SUM(CASE WHEN status = 'skipped' THEN 1 ELSE 0 END) as skipped,
+			SUM(CASE WHEN status = 'reverted' THEN 1 ELSE 0 END) as reverted
 		FROM jobs
 	`)
 
 	err = row.Scan(&stats.Total, &stats.Pending, &stats.Running, &stats.Complete,
-		&stats.Failed, &stats.Cancelled, &stats.Skipped)
+		&stats.Failed, &stats.Cancelled, &stats.Skipped, &stats.Reverted)
 	if err != nil {
 		return stats, err
 	}
@@ -561,6 +731,22 @@ func (s *SQLiteStore) AddToLifetimeSaved(bytes int64) error {
 	return err
 }
 
+// SubtractFromLifetimeSaved decrements both session and lifetime saved counters.
+// Counters are clamped at 0, since the session may have been reset after the
+// bytes were counted. Call this when a completed job is reverted.
+func (s *SQLiteStore) SubtractFromLifetimeSaved(bytes int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		UPDATE stats_metadata
+		SET value = CAST(MAX(CAST(value AS INTEGER) - ?, 0) AS TEXT),
+		    updated_at = datetime('now')
+		WHERE key IN ('session_saved', 'lifetime_saved')
+	`, bytes)
+	return err
+}
+
 // SessionLifetimeStats returns the session and lifetime saved bytes.
 // This implements the jobs.StoreWithStats interface.
 func (s *SQLiteStore) SessionLifetimeStats() (sessionSaved, lifetimeSaved int64, err error) {
@@ -584,6 +770,430 @@ func (s *SQLiteStore) SessionLifetimeStats() (sessionSaved, lifetimeSaved int64,
 	return sessionSaved, lifetimeSaved, nil
 }
 
+// SaveTrashEntry records an original moved to the trash, replacing any
+// existing entry for the same job.
+// This implements the trash.Store interface.
+func (s *SQLiteStore) SaveTrashEntry(entry *trash.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO trash (job_id, original_path, trash_path, size, trashed_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, entry.JobID, entry.OriginalPath, entry.TrashPath, entry.Size, formatTime(entry.TrashedAt))
+	if err != nil {
+		return fmt.Errorf("save trash entry: %w", err)
+	}
+	return nil
+}
+
+// GetTrashEntries returns all trash entries, oldest first.
+func (s *SQLiteStore) GetTrashEntries() ([]*trash.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT job_id, original_path, trash_path, size, trashed_at
+		FROM trash ORDER BY trashed_at, job_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query trash: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*trash.Entry
+	for rows.Next() {
+		entry, err := scanTrashEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetTrashEntry returns the trash entry for a job. Returns nil if not found.
+func (s *SQLiteStore) GetTrashEntry(jobID string) (*trash.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRow(`
+		SELECT job_id, original_path, trash_path, size, trashed_at
+		FROM trash WHERE job_id = ?
+	`, jobID)
+	entry, err := scanTrashEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// DeleteTrashEntry removes the trash entry for a job.
+// Returns nil if the entry doesn't exist.
+func (s *SQLiteStore) DeleteTrashEntry(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM trash WHERE job_id = ?`, jobID); err != nil {
+		return fmt.Errorf("delete trash entry: %w", err)
+	}
+	return nil
+}
+
+// GetWatchedFiles returns all files handled by the watch folder scanner.
+// This implements the watch.Store interface.
+func (s *SQLiteStore) GetWatchedFiles() ([]*watch.File, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT path, size, mod_time, outcome, seen_at FROM watch_files`)
+	if err != nil {
+		return nil, fmt.Errorf("query watched files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*watch.File
+	for rows.Next() {
+		var f watch.File
+		var modTime int64
+		var seenAt string
+		if err := rows.Scan(&f.Path, &f.Size, &modTime, &f.Outcome, &seenAt); err != nil {
+			return nil, err
+		}
+		f.ModTime = time.Unix(0, modTime)
+		f.SeenAt = parseTime(seenAt)
+		files = append(files, &f)
+	}
+	return files, rows.Err()
+}
+
+// SaveWatchedFiles records handled files in a single transaction, replacing
+// existing records for the same paths.
+// Modification times are stored in nanoseconds so they compare exactly.
+func (s *SQLiteStore) SaveWatchedFiles(files []*watch.File) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO watch_files (path, size, mod_time, outcome, seen_at)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, f := range files {
+		if _, err := stmt.Exec(f.Path, f.Size, f.ModTime.UnixNano(), f.Outcome, formatTime(f.SeenAt)); err != nil {
+			return fmt.Errorf("save watched file %s: %w", f.Path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetProcessedFile returns the processed-file ledger record for a path.
+// Returns nil if the path has no record.
+// This implements the jobs.StoreWithLedger interface.
+func (s *SQLiteStore) GetProcessedFile(path string) (*jobs.ProcessedFile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var f jobs.ProcessedFile
+	var modTime int64
+	var processedAt string
+	err := s.db.QueryRow(`
+		SELECT path, size, mod_time, outcome, preset_id, processed_at
+		FROM processed_files WHERE path = ?
+	`, path).Scan(&f.Path, &f.Size, &modTime, &f.Outcome, &f.PresetID, &processedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get processed file: %w", err)
+	}
+	f.ModTime = time.Unix(0, modTime)
+	f.ProcessedAt = parseTime(processedAt)
+	return &f, nil
+}
+
+// SaveProcessedFile records a processed file, replacing any record for the same path.
+// Modification times are stored in nanoseconds so they compare exactly.
+func (s *SQLiteStore) SaveProcessedFile(f *jobs.ProcessedFile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO processed_files (path, size, mod_time, outcome, preset_id, processed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, f.Path, f.Size, f.ModTime.UnixNano(), f.Outcome, f.PresetID, formatTime(f.ProcessedAt))
+	if err != nil {
+		return fmt.Errorf("save processed file: %w", err)
+	}
+	return nil
+}
+
+// DeleteProcessedFile removes the ledger record for a path.
+// Returns nil if the path has no record.
+func (s *SQLiteStore) DeleteProcessedFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM processed_files WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("delete processed file: %w", err)
+	}
+	return nil
+}
+
+// SaveProbes records probe results in the library index in a single
+// transaction, replacing existing entries for the same paths.
+// This implements the browse.LibraryIndex interface.
+func (s *SQLiteStore) SaveProbes(probes []*ffmpeg.ProbeResult) error {
+	if len(probes) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO probes (
+			path, size, mod_time, inode, container, video_codec, width, height,
+			resolution, bitrate, duration_ms, is_hdr, bit_depth, data, probed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := formatTime(time.Now())
+	for _, p := range probes {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("encode probe %s: %w", p.Path, err)
+		}
+		_, err = stmt.Exec(
+			p.Path, p.Size, p.ModTime.UnixNano(), int64(p.Inode), browse.Container(p.Path),
+			strings.ToLower(p.VideoCodec), p.Width, p.Height, browse.ResolutionClass(p.Width, p.Height),
+			p.Bitrate, p.Duration.Milliseconds(), boolToInt(p.IsHDR), p.BitDepth, string(data), now,
+		)
+		if err != nil {
+			return fmt.Errorf("save probe %s: %w", p.Path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetProbe returns the library index entry for a path.
+// Returns nil if the path has no entry.
+func (s *SQLiteStore) GetProbe(path string) (*ffmpeg.ProbeResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var data string
+	var modTime, inode int64
+	err := s.db.QueryRow(`SELECT data, mod_time, inode FROM probes WHERE path = ?`, path).
+		Scan(&data, &modTime, &inode)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get probe: %w", err)
+	}
+	return decodeProbe(data, modTime, inode)
+}
+
+// DeleteProbes removes library index entries for paths.
+// Paths without an entry are ignored.
+func (s *SQLiteStore) DeleteProbes(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, path := range paths {
+		if _, err := tx.Exec(`DELETE FROM probes WHERE path = ?`, path); err != nil {
+			return fmt.Errorf("delete probe %s: %w", path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ProbePaths returns the paths of all library index entries under root.
+func (s *SQLiteStore) ProbePaths(root string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Range scan over the primary key: "/media/" <= path < "/media0"
+	// ('0' is the byte after '/')
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	rows, err := s.db.Query(`SELECT path FROM probes WHERE path >= ? AND path < ?`,
+		prefix, prefix[:len(prefix)-1]+"0")
+	if err != nil {
+		return nil, fmt.Errorf("query probe paths: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// probeSortColumns maps library search sort fields to columns
+var probeSortColumns = map[string]string{
+	"path":     "path",
+	"size":     "size",
+	"bitrate":  "bitrate",
+	"height":   "height",
+	"duration": "duration_ms",
+	"codec":    "video_codec",
+}
+
+// SearchProbes returns the library index entries matching a query.
+// This implements the browse.LibraryIndex interface.
+func (s *SQLiteStore) SearchProbes(q browse.SearchQuery) (*browse.SearchResult, error) {
+	where, args := probeFilter(q)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := &browse.SearchResult{Files: []*ffmpeg.ProbeResult{}}
+	err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM probes`+where, args...).
+		Scan(&result.Total, &result.TotalSize)
+	if err != nil {
+		return nil, fmt.Errorf("count probes: %w", err)
+	}
+
+	order, ok := probeSortColumns[q.Sort]
+	if !ok {
+		order = "path"
+	}
+	if q.Desc {
+		order += " DESC"
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+
+	rows, err := s.db.Query(`SELECT data, mod_time, inode FROM probes`+where+
+		` ORDER BY `+order+`, path LIMIT ? OFFSET ?`, append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("search probes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		var modTime, inode int64
+		if err := rows.Scan(&data, &modTime, &inode); err != nil {
+			return nil, err
+		}
+		p, err := decodeProbe(data, modTime, inode)
+		if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, p)
+	}
+	return result, rows.Err()
+}
+
+// decodeProbe restores a probe result from its JSON encoding and the file
+// signature columns (which the JSON encoding leaves out)
+func decodeProbe(data string, modTime, inode int64) (*ffmpeg.ProbeResult, error) {
+	var p ffmpeg.ProbeResult
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return nil, fmt.Errorf("decode probe: %w", err)
+	}
+	p.ModTime = time.Unix(0, modTime)
+	p.Inode = uint64(inode)
+	return &p, nil
+}
+
+// probeFilter builds the WHERE clause for a library search query
+func probeFilter(q browse.SearchQuery) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	in := func(column string, values []string, normalize func(string) string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = "?"
+			args = append(args, normalize(v))
+		}
+		conds = append(conds, column+" IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	cond := func(c string, arg interface{}) {
+		conds = append(conds, c)
+		args = append(args, arg)
+	}
+
+	in("video_codec", q.Codecs, strings.ToLower)
+	in("container", q.Containers, func(c string) string { return strings.ToLower(strings.TrimPrefix(c, ".")) })
+	in("resolution", q.Resolutions, strings.ToLower)
+	if q.MinHeight > 0 {
+		cond("height >= ?", q.MinHeight)
+	}
+	if q.MaxHeight > 0 {
+		cond("height <= ?", q.MaxHeight)
+	}
+	if q.MinBitrate > 0 {
+		cond("bitrate >= ?", q.MinBitrate)
+	}
+	if q.MaxBitrate > 0 {
+		cond("bitrate <= ?", q.MaxBitrate)
+	}
+	if q.HDR != nil {
+		cond("is_hdr = ?", boolToInt(*q.HDR))
+	}
+	if q.BitDepth > 0 {
+		cond("bit_depth = ?", q.BitDepth)
+	}
+	if q.MinSize > 0 {
+		cond("size >= ?", q.MinSize)
+	}
+	if q.MaxSize > 0 {
+		cond("size <= ?", q.MaxSize)
+	}
+	if q.Path != "" {
+		cond("instr(lower(path), lower(?)) > 0", q.Path)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
 // Close closes the database connection.
 func (s *SQLiteStore) Close() error {
 	return s.db.Close()
@@ -613,6 +1223,15 @@ func scanJob(row rowScanner) (*jobs.Job, error) {
 	var frameRate, vmafScore, qualityMod sql.NullFloat64
 	var isHardware int
 	var status string
+	var subtitleNote sql.NullString
+	var crop sql.NullString
+	var fieldOrder sql.NullString
+	var scanType sql.NullString
+	var originalPath sql.NullString
+	var priority sql.NullInt64
+	var quality sql.NullInt64
+	var outputFormat, tonemap, originalHandling sql.NullString
+	var sidecars sql.NullString
 	var createdAt, startedAt, completedAt sql.NullString
 
 	err := row.Scan(
@@ -624,7 +1243,10 @@ func scanJob(row rowScanner) (*jobs.Job, error) {
 		&videoCodec, &profile, &bitDepth,
 		&isHDR, &colorTransfer, &transcodeTime,
 		&phase, &vmafScore, &selectedCRF, &qualityMod, &skipReason,
-		&smartShrinkQuality, &createdAt, &startedAt, &completedAt,
+		&smartShrinkQuality, &subtitleNote, &crop,
+		&fieldOrder, &scanType, &originalPath, &priority,
+		&quality, &outputFormat, &tonemap, &originalHandling, &sidecars,
+		&createdAt, &startedAt, &completedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -655,6 +1277,17 @@ func scanJob(row rowScanner) (*jobs.Job, error) {
 	job.QualityMod = qualityMod.Float64
 	job.SkipReason = skipReason.String
 	job.SmartShrinkQuality = smartShrinkQuality.String
+	job.SubtitleNote = subtitleNote.String
+	job.Crop = crop.String
+	job.FieldOrder = fieldOrder.String
+	job.ScanType = scanType.String
+	job.OriginalPath = originalPath.String
+	job.Priority = int(priority.Int64)
+	job.Quality = int(quality.Int64)
+	job.OutputFormat = outputFormat.String
+	job.Tonemap = tonemap.String
+	job.OriginalHandling = originalHandling.String
+	job.Sidecars = sidecars.String
 	job.CreatedAt = parseTime(createdAt.String)
 	job.StartedAt = parseTime(startedAt.String)
 	job.CompletedAt = parseTime(completedAt.String)
@@ -668,6 +1301,16 @@ func scanJobRows(rows *sql.Rows) (*jobs.Job, error) {
 
 // Helper functions for SQL values
 
+func scanTrashEntry(row rowScanner) (*trash.Entry, error) {
+	var entry trash.Entry
+	var trashedAt string
+	if err := row.Scan(&entry.JobID, &entry.OriginalPath, &entry.TrashPath, &entry.Size, &trashedAt); err != nil {
+		return nil, err
+	}
+	entry.TrashedAt = parseTime(trashedAt)
+	return &entry, nil
+}
+
 func nullString(s string) interface{} {
 	if s == "" {
 		return nil
@@ -6,7 +6,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gwlsn/shrinkray/internal/browse"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
 	"github.com/gwlsn/shrinkray/internal/jobs"
+	"github.com/gwlsn/shrinkray/internal/trash"
+	"github.com/gwlsn/shrinkray/internal/watch"
 	_ "modernc.org/sqlite"
 )
 
@@ -328,6 +332,34 @@ func TestSQLiteStore_GetNextPendingJob(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_GetNextPendingJob_HonorsPriority(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for _, id := range []string{"first", "second", "urgent"} {
+		job := createTestJob(id)
+		if id == "urgent" {
+			job.Priority = 10
+		}
+		store.SaveJob(job)
+		store.AppendToOrder(id)
+	}
+
+	next, err := store.GetNextPendingJob()
+	if err != nil {
+		t.Fatalf("failed to get next pending: %v", err)
+	}
+	if next == nil || next.ID != "urgent" || next.Priority != 10 {
+		t.Errorf("expected the urgent job, got %v", next)
+	}
+}
+
 func TestSQLiteStore_GetNextPendingJob_SkipsRunning(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -486,6 +518,37 @@ func TestSQLiteStore_Stats(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_SubtractFromLifetimeSaved(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.AddToLifetimeSaved(300000)
+	store.ResetSession()
+	store.AddToLifetimeSaved(100000)
+
+	// Reverting a job counted before the session reset can't take the session below 0
+	if err := store.SubtractFromLifetimeSaved(200000); err != nil {
+		t.Fatalf("SubtractFromLifetimeSaved failed: %v", err)
+	}
+
+	sessionSaved, lifetimeSaved, err := store.SessionLifetimeStats()
+	if err != nil {
+		t.Fatalf("SessionLifetimeStats failed: %v", err)
+	}
+	if sessionSaved != 0 {
+		t.Errorf("expected SessionSaved 0, got %d", sessionSaved)
+	}
+	if lifetimeSaved != 200000 {
+		t.Errorf("expected LifetimeSaved 200000, got %d", lifetimeSaved)
+	}
+}
+
 func TestSQLiteStore_AllFieldsRoundTrip(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -998,3 +1061,409 @@ func TestSaveJobColorTransferAndSmartShrinkQuality(t *testing.T) {
 		t.Errorf("SmartShrinkQuality via GetAllJobs mismatch: got %q, want %q", allJobs[0].SmartShrinkQuality, "excellent")
 	}
 }
+
+func TestSaveJobSubtitleNote(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	note := "Dropped image subtitles not supported in MP4: #3 hdmv_pgs_subtitle (eng)"
+	job := &jobs.Job{
+		ID:           "test-subtitle-note",
+		InputPath:    "/test/video.mkv",
+		PresetID:     "compress-hevc",
+		Encoder:      "none",
+		Status:       jobs.StatusComplete,
+		SubtitleNote: note,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := store.SaveJob(job); err != nil {
+		t.Fatalf("SaveJob failed: %v", err)
+	}
+
+	loaded, err := store.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if loaded.SubtitleNote != note {
+		t.Errorf("SubtitleNote mismatch: got %q, want %q", loaded.SubtitleNote, note)
+	}
+}
+
+func TestSaveJobCrop(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	job := &jobs.Job{
+		ID:        "test-crop",
+		InputPath: "/test/letterboxed.mkv",
+		PresetID:  "compress-hevc",
+		Encoder:   "none",
+		Status:    jobs.StatusComplete,
+		Crop:      "1920:800:0:140",
+		CreatedAt: time.Now(),
+	}
+
+	if err := store.SaveJob(job); err != nil {
+		t.Fatalf("SaveJob failed: %v", err)
+	}
+
+	allJobs, _, err := store.GetAllJobs()
+	if err != nil {
+		t.Fatalf("GetAllJobs failed: %v", err)
+	}
+	if len(allJobs) != 1 || allJobs[0].Crop != "1920:800:0:140" {
+		t.Errorf("Crop mismatch: got %+v", allJobs)
+	}
+}
+
+func TestSaveJobScanType(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	job := &jobs.Job{
+		ID:         "test-interlaced",
+		InputPath:  "/test/dvd.mkv",
+		PresetID:   "compress-hevc",
+		Encoder:    "none",
+		Status:     jobs.StatusComplete,
+		FieldOrder: "tt",
+		ScanType:   "interlaced",
+		CreatedAt:  time.Now(),
+	}
+
+	if err := store.SaveJob(job); err != nil {
+		t.Fatalf("SaveJob failed: %v", err)
+	}
+
+	allJobs, _, err := store.GetAllJobs()
+	if err != nil {
+		t.Fatalf("GetAllJobs failed: %v", err)
+	}
+	if len(allJobs) != 1 || allJobs[0].FieldOrder != "tt" || allJobs[0].ScanType != "interlaced" {
+		t.Errorf("scan type mismatch: got %+v", allJobs)
+	}
+}
+
+func TestSaveJobOriginalPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	job := createTestJob("test-kept")
+	job.Status = jobs.StatusComplete
+	job.OriginalPath = "/trash/video_test-kept.mkv"
+	job.Sidecars = "/media/video.eng.sup\n/media/video.jpn.sup"
+
+	if err := store.SaveJob(job); err != nil {
+		t.Fatalf("SaveJob failed: %v", err)
+	}
+
+	got, err := store.GetJob("test-kept")
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if got.OriginalPath != job.OriginalPath {
+		t.Errorf("OriginalPath: got %q, want %q", got.OriginalPath, job.OriginalPath)
+	}
+	if len(got.SidecarPaths()) != 2 {
+		t.Errorf("Sidecars: got %q, want %q", got.Sidecars, job.Sidecars)
+	}
+}
+
+func TestSQLiteStore_TrashEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().Truncate(time.Second)
+	newer := &trash.Entry{JobID: "job-2", OriginalPath: "/media/b.mkv", TrashPath: "/trash/b.mkv", Size: 200, TrashedAt: now}
+	older := &trash.Entry{JobID: "job-1", OriginalPath: "/media/a.mkv", TrashPath: "/trash/a.mkv", Size: 100, TrashedAt: now.Add(-time.Hour)}
+	for _, entry := range []*trash.Entry{newer, older} {
+		if err := store.SaveTrashEntry(entry); err != nil {
+			t.Fatalf("SaveTrashEntry failed: %v", err)
+		}
+	}
+
+	entries, err := store.GetTrashEntries()
+	if err != nil {
+		t.Fatalf("GetTrashEntries failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].JobID != "job-1" || entries[1].JobID != "job-2" {
+		t.Fatalf("expected entries oldest first, got %+v", entries)
+	}
+	if !entries[0].TrashedAt.Equal(older.TrashedAt) || entries[0].Size != 100 || entries[0].TrashPath != "/trash/a.mkv" {
+		t.Errorf("entry mismatch: got %+v, want %+v", entries[0], older)
+	}
+
+	if err := store.DeleteTrashEntry("job-1"); err != nil {
+		t.Fatalf("DeleteTrashEntry failed: %v", err)
+	}
+	if entry, err := store.GetTrashEntry("job-1"); err != nil || entry != nil {
+		t.Errorf("expected nil after delete, got %+v (%v)", entry, err)
+	}
+	if entry, err := store.GetTrashEntry("job-2"); err != nil || entry == nil {
+		t.Errorf("expected job-2 entry, got %+v (%v)", entry, err)
+	}
+}
+
+func TestSQLiteStore_WatchedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	modTime := time.Date(2026, 3, 2, 12, 0, 0, 123456789, time.UTC)
+	seenAt := time.Now().Truncate(time.Second)
+	files := []*watch.File{
+		{Path: "/media/a.mkv", Size: 100, ModTime: modTime, Outcome: watch.OutcomeQueued, SeenAt: seenAt},
+		{Path: "/media/b.mkv", Size: 200, ModTime: modTime, Outcome: watch.OutcomeSkipped, SeenAt: seenAt},
+	}
+	if err := store.SaveWatchedFiles(files); err != nil {
+		t.Fatalf("SaveWatchedFiles failed: %v", err)
+	}
+
+	// Replacing a record keeps one row per path
+	replaced := &watch.File{Path: "/media/a.mkv", Size: 50, ModTime: modTime, Outcome: watch.OutcomeOutput, SeenAt: seenAt}
+	if err := store.SaveWatchedFiles([]*watch.File{replaced}); err != nil {
+		t.Fatalf("SaveWatchedFiles failed: %v", err)
+	}
+
+	got, err := store.GetWatchedFiles()
+	if err != nil {
+		t.Fatalf("GetWatchedFiles failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(got))
+	}
+	byPath := make(map[string]*watch.File)
+	for _, f := range got {
+		byPath[f.Path] = f
+	}
+	a := byPath["/media/a.mkv"]
+	if a == nil || a.Size != 50 || a.Outcome != watch.OutcomeOutput {
+		t.Errorf("expected replaced record, got %+v", a)
+	}
+	if a != nil && !a.ModTime.Equal(modTime) {
+		t.Errorf("mod time not preserved exactly: got %v, want %v", a.ModTime, modTime)
+	}
+	if a != nil && !a.SeenAt.Equal(seenAt) {
+		t.Errorf("seen at: got %v, want %v", a.SeenAt, seenAt)
+	}
+}
+
+func TestSQLiteStore_ProcessedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if f, err := store.GetProcessedFile("/media/a.mkv"); err != nil || f != nil {
+		t.Fatalf("expected no record, got %+v (%v)", f, err)
+	}
+
+	modTime := time.Date(2026, 3, 2, 12, 0, 0, 123456789, time.UTC)
+	processedAt := time.Now().Truncate(time.Second)
+	file := &jobs.ProcessedFile{
+		Path:        "/media/a.mkv",
+		Size:        100,
+		ModTime:     modTime,
+		Outcome:     jobs.ProcessedTranscoded,
+		PresetID:    "compress-hevc",
+		ProcessedAt: processedAt,
+	}
+	if err := store.SaveProcessedFile(file); err != nil {
+		t.Fatalf("SaveProcessedFile failed: %v", err)
+	}
+
+	got, err := store.GetProcessedFile("/media/a.mkv")
+	if err != nil || got == nil {
+		t.Fatalf("GetProcessedFile failed: %+v (%v)", got, err)
+	}
+	if got.Size != 100 || !got.ModTime.Equal(modTime) || got.Outcome != jobs.ProcessedTranscoded ||
+		got.PresetID != "compress-hevc" || !got.ProcessedAt.Equal(processedAt) {
+		t.Errorf("record mismatch: got %+v, want %+v", got, file)
+	}
+
+	if err := store.DeleteProcessedFile("/media/a.mkv"); err != nil {
+		t.Fatalf("DeleteProcessedFile failed: %v", err)
+	}
+	if f, _ := store.GetProcessedFile("/media/a.mkv"); f != nil {
+		t.Errorf("expected nil after delete, got %+v", f)
+	}
+}
+
+func TestSQLiteStore_ProbeIndex(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const gb = int64(1) << 30
+	modTime := time.Date(2026, 3, 2, 12, 0, 0, 123456789, time.UTC)
+	probes := []*ffmpeg.ProbeResult{
+		{Path: "/media/movies/Big.mkv", Size: 12 * gb, Inode: 42, ModTime: modTime, VideoCodec: "h264", Width: 1920, Height: 1080, Bitrate: 20_000_000, Duration: 2 * time.Hour, BitDepth: 8},
+		{Path: "/media/movies/Scope.MP4", Size: 11 * gb, VideoCodec: "h264", Width: 1920, Height: 800, Bitrate: 15_000_000, BitDepth: 8},
+		{Path: "/media/movies/Small.mkv", Size: 2 * gb, VideoCodec: "h264", Width: 1920, Height: 1080, Bitrate: 4_000_000, BitDepth: 8},
+		{Path: "/media/movies/HDR.mkv", Size: 30 * gb, VideoCodec: "hevc", Width: 3840, Height: 2160, Bitrate: 50_000_000, IsHDR: true, HDRFormat: "HDR10", BitDepth: 10},
+		{Path: "/media/tv/Show/S01E01.avi", Size: gb / 2, VideoCodec: "mpeg4", Width: 720, Height: 480, Bitrate: 1_500_000, BitDepth: 8},
+		{Path: "/media2/Other.mkv", Size: 12 * gb, VideoCodec: "h264", Width: 1920, Height: 1080},
+	}
+	if err := store.SaveProbes(probes); err != nil {
+		t.Fatalf("SaveProbes failed: %v", err)
+	}
+
+	search := func(q browse.SearchQuery) []string {
+		t.Helper()
+		result, err := store.SearchProbes(q)
+		if err != nil {
+			t.Fatalf("SearchProbes failed: %v", err)
+		}
+		paths := make([]string, len(result.Files))
+		for i, f := range result.Files {
+			paths[i] = f.Path
+		}
+		return paths
+	}
+	hdr := true
+	tests := []struct {
+		name string
+		q    browse.SearchQuery
+		want []string
+	}{
+		{"h264 1080p over 10G",
+			browse.SearchQuery{Codecs: []string{"H264"}, Resolutions: []string{"1080p"}, MinSize: 10 * gb, Path: "/MEDIA/"},
+			[]string{"/media/movies/Big.mkv", "/media/movies/Scope.MP4"}},
+		{"container", browse.SearchQuery{Containers: []string{".mp4", "avi"}}, []string{"/media/movies/Scope.MP4", "/media/tv/Show/S01E01.avi"}},
+		{"bitrate range", browse.SearchQuery{MinBitrate: 10_000_000, MaxBitrate: 30_000_000}, []string{"/media/movies/Big.mkv", "/media/movies/Scope.MP4"}},
+		{"height", browse.SearchQuery{MinHeight: 1000, MaxHeight: 1080, Path: "movies"}, []string{"/media/movies/Big.mkv", "/media/movies/Small.mkv"}},
+		{"hdr and bit depth", browse.SearchQuery{HDR: &hdr, BitDepth: 10}, []string{"/media/movies/HDR.mkv"}},
+		{"max size", browse.SearchQuery{MaxSize: gb}, []string{"/media/tv/Show/S01E01.avi"}},
+		{"sort and page", browse.SearchQuery{Path: "/media/", Sort: "size", Desc: true, Limit: 2, Offset: 1}, []string{"/media/movies/Big.mkv", "/media/movies/Scope.MP4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := search(tt.q)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	// Totals cover every page
+	result, _ := store.SearchProbes(browse.SearchQuery{Codecs: []string{"hevc", "mpeg4"}, Limit: 1})
+	if result.Total != 2 || result.TotalSize != 30*gb+gb/2 || len(result.Files) != 1 {
+		t.Errorf("unexpected totals: total=%d size=%d files=%d", result.Total, result.TotalSize, len(result.Files))
+	}
+
+	// Full probe results round-trip, including the fields the API doesn't expose
+	result, _ = store.SearchProbes(browse.SearchQuery{Path: "Big.mkv"})
+	got := result.Files[0]
+	if got.Inode != 42 || !got.ModTime.Equal(modTime) || got.Duration != 2*time.Hour || got.Bitrate != 20_000_000 {
+		t.Errorf("probe mismatch: %+v", got)
+	}
+
+	paths, err := store.ProbePaths("/media")
+	if err != nil {
+		t.Fatalf("ProbePaths failed: %v", err)
+	}
+	if len(paths) != 5 {
+		t.Errorf("expected 5 paths under /media (not /media2), got %v", paths)
+	}
+
+	got, err = store.GetProbe("/media/movies/Big.mkv")
+	if err != nil || got == nil || got.Inode != 42 || !got.ModTime.Equal(modTime) || got.VideoCodec != "h264" {
+		t.Errorf("GetProbe mismatch: %+v (%v)", got, err)
+	}
+	if got, err := store.GetProbe("/media/missing.mkv"); err != nil || got != nil {
+		t.Errorf("expected no entry, got %+v (%v)", got, err)
+	}
+
+	if err := store.DeleteProbes([]string{"/media/movies/Big.mkv", "/media/missing.mkv"}); err != nil {
+		t.Fatalf("DeleteProbes failed: %v", err)
+	}
+	if got := search(browse.SearchQuery{Path: "Big"}); len(got) != 0 {
+		t.Errorf("expected deleted entry to be gone, got %v", got)
+	}
+}
+
+func TestSaveJobOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	job := createTestJob("test-overrides")
+	job.Overrides = jobs.Overrides{Quality: 24, OutputFormat: "mp4", Tonemap: "off", OriginalHandling: "keep"}
+
+	if err := store.SaveJobs([]*jobs.Job{job}); err != nil {
+		t.Fatalf("SaveJobs failed: %v", err)
+	}
+	store.AppendToOrder(job.ID)
+
+	got, err := store.GetJob("test-overrides")
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if got.Overrides != job.Overrides {
+		t.Errorf("Overrides: got %+v, want %+v", got.Overrides, job.Overrides)
+	}
+
+	next, err := store.GetNextPendingJob()
+	if err != nil || next == nil {
+		t.Fatalf("GetNextPendingJob failed: %v", err)
+	}
+	if next.Overrides != job.Overrides {
+		t.Errorf("Overrides from GetNextPendingJob: got %+v, want %+v", next.Overrides, job.Overrides)
+	}
+}
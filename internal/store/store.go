@@ -65,6 +65,10 @@ type Store interface {
 	// Call this when a job completes successfully.
 	AddToLifetimeSaved(bytes int64) error
 
+	// SubtractFromLifetimeSaved decrements the saved counters (never below 0).
+	// Call this when a completed job is reverted.
+	SubtractFromLifetimeSaved(bytes int64) error
+
 	// Close closes the store and releases resources.
 	Close() error
 }
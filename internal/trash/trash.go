@@ -0,0 +1,294 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
+)
+
+// Sentinel errors for trash operations.
+// These can be checked with errors.Is().
+var (
+	ErrNotFound          = errors.New("no trashed original for job")
+	ErrDestinationExists = errors.New("a file already exists at the original path")
+)
+
+// Entry is an original file moved to the trash after a successful transcode
+type Entry struct {
+	JobID        string    `json:"job_id"`
+	OriginalPath string    `json:"original_path"` // Where the file lived in the media tree
+	TrashPath    string    `json:"trash_path"`    // Where the file lives now
+	Size         int64     `json:"size"`
+	TrashedAt    time.Time `json:"trashed_at"`
+}
+
+// ExpiresAt returns when the purger deletes the entry (zero = never)
+func (e *Entry) ExpiresAt(retention time.Duration) time.Time {
+	if retention <= 0 {
+		return time.Time{}
+	}
+	return e.TrashedAt.Add(retention)
+}
+
+// Store persists trash entries.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// SaveTrashEntry records an entry, replacing any existing entry for the job.
+	SaveTrashEntry(entry *Entry) error
+
+	// GetTrashEntries returns all entries, oldest first.
+	GetTrashEntries() ([]*Entry, error)
+
+	// GetTrashEntry returns the entry for a job. Returns nil if not found.
+	GetTrashEntry(jobID string) (*Entry, error)
+
+	// DeleteTrashEntry removes the entry for a job.
+	// Returns nil if the entry doesn't exist.
+	DeleteTrashEntry(jobID string) error
+}
+
+// Trash moves replaced originals into a directory outside the media tree
+// and deletes them once they are older than the retention period or the
+// trash grows past its size cap
+type Trash struct {
+	dir        string
+	mediaRoots []string
+	retention  time.Duration // 0 = keep until the size cap is hit
+	maxSize    int64         // 0 = no cap
+	store      Store
+
+	mu sync.Mutex // Serializes moves, restores and purges
+}
+
+// New creates a Trash rooted at dir. Paths under a media root keep their
+// relative location inside the trash so same-named files don't collide.
+func New(dir string, mediaRoots []string, retention time.Duration, maxSize int64, store Store) *Trash {
+	return &Trash{
+		dir:        dir,
+		mediaRoots: mediaRoots,
+		retention:  retention,
+		maxSize:    maxSize,
+		store:      store,
+	}
+}
+
+// Dir returns the trash directory
+func (t *Trash) Dir() string {
+	return t.dir
+}
+
+// Retention returns how long originals are kept (0 = no time limit)
+func (t *Trash) Retention() time.Duration {
+	return t.retention
+}
+
+// Move moves the file at path into the trash on behalf of a job.
+// originalPath is where the file lived before the transcode (path may be a
+// renamed copy such as a .old sibling); it determines the location inside
+// the trash and where Restore puts the file back.
+func (t *Trash) Move(jobID, path, originalPath string) (*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat original: %w", err)
+	}
+
+	trashPath := t.trashPath(jobID, originalPath)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := moveFile(path, trashPath); err != nil {
+		return nil, fmt.Errorf("failed to move original to trash: %w", err)
+	}
+
+	entry := &Entry{
+		JobID:        jobID,
+		OriginalPath: originalPath,
+		TrashPath:    trashPath,
+		Size:         info.Size(),
+		TrashedAt:    time.Now(),
+	}
+	if err := t.store.SaveTrashEntry(entry); err != nil {
+		// Put the file back rather than leave it untracked in the trash
+		if restoreErr := moveFile(trashPath, path); restoreErr != nil {
+			logger.Error("Failed to return untracked file from trash", "path", trashPath, "error", restoreErr.Error())
+		}
+		return nil, err
+	}
+
+	logger.Info("Moved original to trash", "job_id", jobID, "path", originalPath, "trash_path", trashPath)
+	return entry, nil
+}
+
+// List returns all trashed originals, oldest first
+func (t *Trash) List() ([]*Entry, error) {
+	return t.store.GetTrashEntries()
+}
+
+// Get returns the trashed original for a job, or ErrNotFound
+func (t *Trash) Get(jobID string) (*Entry, error) {
+	entry, err := t.store.GetTrashEntry(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, jobID)
+	}
+	return entry, nil
+}
+
+// Restore moves a job's original back to where it came from.
+// Fails with ErrDestinationExists rather than overwrite a file at that path
+// (such as a transcoded output with the same extension).
+func (t *Trash) Restore(jobID string) (*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, err := t.Get(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(entry.OriginalPath); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDestinationExists, entry.OriginalPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(entry.OriginalPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create original directory: %w", err)
+	}
+	if err := moveFile(entry.TrashPath, entry.OriginalPath); err != nil {
+		return nil, fmt.Errorf("failed to restore original: %w", err)
+	}
+
+	if err := t.store.DeleteTrashEntry(jobID); err != nil {
+		logger.Warn("Failed to remove trash entry after restore", "job_id", jobID, "error", err.Error())
+	}
+	t.removeEmptyDirs(filepath.Dir(entry.TrashPath))
+
+	logger.Info("Restored original from trash", "job_id", jobID, "path", entry.OriginalPath)
+	return entry, nil
+}
+
+// Purge deletes originals older than the retention period, then the oldest
+// remaining originals until the trash fits under the size cap.
+// Returns the number of originals deleted.
+func (t *Trash) Purge() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries, err := t.store.GetTrashEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	now := time.Now()
+	purged := 0
+	for _, entry := range entries {
+		expired := t.retention > 0 && now.After(entry.ExpiresAt(t.retention))
+		overCap := t.maxSize > 0 && total > t.maxSize
+		if !expired && !overCap {
+			continue
+		}
+
+		if err := os.Remove(entry.TrashPath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to purge original from trash", "path", entry.TrashPath, "error", err.Error())
+			continue
+		}
+		if err := t.store.DeleteTrashEntry(entry.JobID); err != nil {
+			return purged, err
+		}
+		t.removeEmptyDirs(filepath.Dir(entry.TrashPath))
+
+		total -= entry.Size
+		purged++
+		logger.Info("Purged original from trash", "job_id", entry.JobID, "path", entry.OriginalPath)
+	}
+	return purged, nil
+}
+
+// Run purges the trash every interval until ctx is cancelled
+func (t *Trash) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := t.Purge(); err != nil {
+			logger.Warn("Trash purge failed", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// trashPath returns where a job's original goes inside the trash.
+// Files under a media root keep their relative path, under the root's
+// directory name when there are several roots; anything else is filed by name.
+// If the path is taken (the same file transcoded twice), the job ID is added
+// before the extension.
+func (t *Trash) trashPath(jobID, originalPath string) string {
+	rel := filepath.Base(originalPath)
+	for _, root := range t.mediaRoots {
+		if util.IsWithin(originalPath, root) {
+			rel, _ = filepath.Rel(root, originalPath)
+			if len(t.mediaRoots) > 1 {
+				rel = filepath.Join(filepath.Base(root), rel)
+			}
+			break
+		}
+	}
+
+	path := filepath.Join(t.dir, rel)
+	if _, err := os.Stat(path); err == nil {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "." + jobID + ext
+	}
+	return path
+}
+
+// removeEmptyDirs removes empty directories from dir up to (not including)
+// the trash root, so restored and purged files don't leave a skeleton tree
+func (t *Trash) removeEmptyDirs(dir string) {
+	root := filepath.Clean(t.dir)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return // Not empty (or already gone)
+		}
+	}
+}
+
+// moveFile renames src to dst, falling back to copy-then-delete when they
+// are on different filesystems (the trash usually lives outside the media mount)
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := util.CopyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	_ = os.Chtimes(dst, info.ModTime(), info.ModTime())
+	return os.Remove(src)
+}
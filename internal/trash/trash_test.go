@@ -0,0 +1,259 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory Store for tests
+type memStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+func newMemStore() *memStore {
+	return &memStore{entries: make(map[string]*Entry)}
+}
+
+func (s *memStore) SaveTrashEntry(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := *entry
+	s.entries[entry.JobID] = &e
+	return nil
+}
+
+func (s *memStore) GetTrashEntries() ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []*Entry
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].TrashedAt.Before(entries[j].TrashedAt) })
+	return entries, nil
+}
+
+func (s *memStore) GetTrashEntry(jobID string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[jobID], nil
+}
+
+func (s *memStore) DeleteTrashEntry(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, jobID)
+	return nil
+}
+
+// setup creates a media tree with one file and an empty trash directory
+func setup(t *testing.T) (mediaRoot, trashDir, original string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	mediaRoot = filepath.Join(tmpDir, "media")
+	trashDir = filepath.Join(tmpDir, "trash")
+
+	original = filepath.Join(mediaRoot, "Movies", "Film (2020)", "Film.mkv")
+	if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(original, []byte("original content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mediaRoot, trashDir, original
+}
+
+func TestMoveAndRestore(t *testing.T) {
+	mediaRoot, trashDir, original := setup(t)
+	store := newMemStore()
+	tr := New(trashDir, []string{mediaRoot}, 0, 0, store)
+
+	// Move the renamed .old copy, as the worker does after finalizing
+	oldPath := original + ".old"
+	if err := os.Rename(original, oldPath); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := tr.Move("job-1", oldPath, original)
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	wantTrashPath := filepath.Join(trashDir, "Movies", "Film (2020)", "Film.mkv")
+	if entry.TrashPath != wantTrashPath {
+		t.Errorf("trash path: got %s, want %s", entry.TrashPath, wantTrashPath)
+	}
+	if entry.Size != int64(len("original content")) {
+		t.Errorf("size: got %d", entry.Size)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Error("expected .old file to be moved out of the media tree")
+	}
+
+	entries, _ := tr.List()
+	if len(entries) != 1 || entries[0].JobID != "job-1" {
+		t.Fatalf("expected one entry, got %+v", entries)
+	}
+
+	if _, err := tr.Restore("job-1"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	data, err := os.ReadFile(original)
+	if err != nil || string(data) != "original content" {
+		t.Errorf("expected original restored, got %q (%v)", data, err)
+	}
+	if _, err := tr.Get("job-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected entry removed after restore, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, "Movies")); !os.IsNotExist(err) {
+		t.Error("expected empty trash directories to be removed")
+	}
+}
+
+func TestRestoreRefusesToOverwrite(t *testing.T) {
+	mediaRoot, trashDir, original := setup(t)
+	tr := New(trashDir, []string{mediaRoot}, 0, 0, newMemStore())
+
+	if _, err := tr.Move("job-1", original, original); err != nil {
+		t.Fatal(err)
+	}
+	// A same-extension output now sits at the original path
+	if err := os.WriteFile(original, []byte("transcoded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tr.Restore("job-1"); !errors.Is(err, ErrDestinationExists) {
+		t.Errorf("expected ErrDestinationExists, got %v", err)
+	}
+	if _, err := tr.Get("job-1"); err != nil {
+		t.Errorf("expected entry kept after failed restore: %v", err)
+	}
+}
+
+func TestMoveNameCollision(t *testing.T) {
+	mediaRoot, trashDir, original := setup(t)
+	tr := New(trashDir, []string{mediaRoot}, 0, 0, newMemStore())
+
+	first, err := tr.Move("job-1", original, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file transcoded again later
+	if err := os.WriteFile(original, []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := tr.Move("job-2", original, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.TrashPath == second.TrashPath {
+		t.Fatalf("expected distinct trash paths, both %s", first.TrashPath)
+	}
+	if filepath.Base(second.TrashPath) != "Film.job-2.mkv" {
+		t.Errorf("unexpected collision name: %s", second.TrashPath)
+	}
+}
+
+func TestMoveOutsideMediaRoot(t *testing.T) {
+	_, trashDir, original := setup(t)
+	tr := New(trashDir, []string{"/elsewhere"}, 0, 0, newMemStore())
+
+	entry, err := tr.Move("job-1", original, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.TrashPath != filepath.Join(trashDir, "Film.mkv") {
+		t.Errorf("expected file filed by name, got %s", entry.TrashPath)
+	}
+}
+
+func TestMoveDotDotName(t *testing.T) {
+	mediaRoot, trashDir, _ := setup(t)
+	tr := New(trashDir, []string{mediaRoot}, 0, 0, newMemStore())
+
+	// A name starting with ".." is still inside the media root
+	original := filepath.Join(mediaRoot, "..Extras", "Film.mkv")
+	if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(original, []byte("original content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := tr.Move("job-1", original, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(trashDir, "..Extras", "Film.mkv")
+	if entry.TrashPath != want {
+		t.Errorf("expected %s, got %s", want, entry.TrashPath)
+	}
+}
+
+func TestMoveWithSeveralMediaRoots(t *testing.T) {
+	mediaRoot, trashDir, original := setup(t)
+	tr := New(trashDir, []string{"/elsewhere", mediaRoot}, 0, 0, newMemStore())
+
+	entry, err := tr.Move("job-1", original, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(trashDir, "media", "Movies", "Film (2020)", "Film.mkv")
+	if entry.TrashPath != want {
+		t.Errorf("expected %s, got %s", want, entry.TrashPath)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	trashDir := t.TempDir()
+	store := newMemStore()
+	now := time.Now()
+
+	add := func(jobID string, size int, age time.Duration) string {
+		path := filepath.Join(trashDir, jobID+".mkv")
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_ = store.SaveTrashEntry(&Entry{JobID: jobID, TrashPath: path, Size: int64(size), TrashedAt: now.Add(-age)})
+		return path
+	}
+
+	expired := add("expired", 10, 40*24*time.Hour)
+	oldest := add("oldest", 100, 20*24*time.Hour)
+	newer := add("newer", 100, 10*24*time.Hour)
+	newest := add("newest", 100, time.Hour)
+
+	// 30 day retention removes "expired"; the 250 byte cap then removes "oldest"
+	tr := New(trashDir, nil, 30*24*time.Hour, 250, store)
+	purged, err := tr.Purge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("expected 2 purged, got %d", purged)
+	}
+
+	for _, path := range []string{expired, oldest} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be purged", filepath.Base(path))
+		}
+	}
+	for _, path := range []string{newer, newest} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %v", filepath.Base(path), err)
+		}
+	}
+
+	entries, _ := store.GetTrashEntries()
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries left, got %d", len(entries))
+	}
+}
@@ -3,6 +3,8 @@ package util
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,6 +26,28 @@ func FormatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// ParseBytes parses a human-readable size (e.g., "4G", "700 MB", "1.5GB") into bytes.
+// Uses binary units like FormatBytes; a plain number is a byte count.
+func ParseBytes(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "B")
+	if value == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	multiplier := int64(1)
+	if i := strings.IndexByte("KMGTPE", value[len(value)-1]); i >= 0 {
+		multiplier = int64(1) << (10 * (i + 1))
+		value = strings.TrimSpace(value[:len(value)-1])
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
 // FormatDuration formats a duration as a human-readable string (e.g., "1h 30m").
 // Returns empty string for negative durations.
 func FormatDuration(d time.Duration) string {
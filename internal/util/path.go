@@ -0,0 +1,52 @@
+package util
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IsWithin reports whether path is dir or inside it. It compares whole path
+// elements, so /mnt/mediastuff isn't within /mnt/media while a name that
+// merely starts with ".." (e.g. "..Extras") still is.
+func IsWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// GlobRegexp converts a path glob to an unanchored regular expression.
+// "*" and "?" don't match "/", "**" does, and "**/" also matches no
+// directory at all. With gitignore set, "\x" escapes a character and
+// "[...]" (or "[!...]") is a character class, as in .gitignore files;
+// otherwise both are literal.
+func GlobRegexp(glob string, gitignore bool) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case gitignore && c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case gitignore && c == '[' && strings.IndexByte(glob[i+1:], ']') > 0:
+			end := i + 1 + strings.IndexByte(glob[i+1:], ']')
+			class := glob[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,375 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/browse"
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/jobs"
+	"github.com/gwlsn/shrinkray/internal/logger"
+	"github.com/gwlsn/shrinkray/internal/util"
+)
+
+// Outcomes recorded for handled files
+const (
+	OutcomeQueued   = "queued"   // Added to the queue
+	OutcomeSkipped  = "skipped"  // Already meets the preset (e.g. already HEVC), not queued
+	OutcomeOutput   = "output"   // Written by a completed job
+	OutcomeExisting = "existing" // Already there when the folder was first scanned, not queued
+	OutcomeFolder   = "folder"   // A watch folder whose existing files were recorded
+)
+
+// File is a media file the watcher has handled. A file is handled again
+// only if its size or modification time changes.
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Outcome string    `json:"outcome"`
+	SeenAt  time.Time `json:"seen_at"`
+}
+
+// Store persists handled files so they aren't re-queued after a restart.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// GetWatchedFiles returns all handled files.
+	GetWatchedFiles() ([]*File, error)
+
+	// SaveWatchedFiles records handled files, replacing existing records for the same paths.
+	SaveWatchedFiles(files []*File) error
+}
+
+// Prober finds and probes video files under a set of paths.
+// Implemented by browse.Browser.
+type Prober interface {
+	GetVideoFilesWithProgress(ctx context.Context, paths []string, onProgress browse.ProgressCallback) ([]*ffmpeg.ProbeResult, error)
+}
+
+// observation is a file's size and modification time at the last scan
+type observation struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher polls watch folders and queues new media once it has stopped changing.
+// Polling (rather than inotify) works on network shares and in containers.
+type Watcher struct {
+	queue  *jobs.Queue
+	prober Prober
+	store  Store // nil = remember handled files in memory only
+	stable time.Duration
+	now    func() time.Time
+
+	scanMu sync.Mutex // One scan at a time
+
+	mu       sync.Mutex // Guards the state below
+	folders  []config.WatchFolder
+	handled  map[string]*File
+	loaded   bool
+	observed map[string]observation // Unhandled files seen at the last scan
+	lastScan time.Time
+}
+
+// New creates a watcher for folders. New files are queued once they have
+// been unchanged for at least stable.
+func New(queue *jobs.Queue, prober Prober, store Store, folders []config.WatchFolder, stable time.Duration) *Watcher {
+	return &Watcher{
+		queue:    queue,
+		prober:   prober,
+		store:    store,
+		stable:   stable,
+		now:      time.Now,
+		folders:  folders,
+		handled:  make(map[string]*File),
+		observed: make(map[string]observation),
+	}
+}
+
+// Folders returns the watch folders
+func (w *Watcher) Folders() []config.WatchFolder {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]config.WatchFolder(nil), w.folders...)
+}
+
+// SetFolders replaces the watch folders (takes effect at the next scan)
+func (w *Watcher) SetFolders(folders []config.WatchFolder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.folders = append([]config.WatchFolder(nil), folders...)
+}
+
+// LastScan returns when the last scan finished (zero if none yet)
+func (w *Watcher) LastScan() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastScan
+}
+
+// Run scans every interval until ctx is cancelled. It also records the
+// outputs of completed jobs so transcoded files aren't picked up as new.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	events := w.queue.Subscribe()
+	defer w.queue.Unsubscribe(events)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.scanLogged(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == "complete" && event.Job != nil {
+				w.recordOutput(event.Job.OutputPath)
+			}
+		case <-ticker.C:
+			w.scanLogged(ctx)
+		}
+	}
+}
+
+func (w *Watcher) scanLogged(ctx context.Context) {
+	if _, err := w.Scan(ctx); err != nil {
+		logger.Warn("Watch folder scan failed", "error", err.Error())
+	}
+}
+
+// Scan checks every watch folder once and queues files that are new and
+// stable. A file is stable once it has the same size and modification time
+// as at the previous scan and was last modified at least the stable period ago.
+// The first scan of a folder only records the files already in it, unless
+// the folder is set to queue existing files.
+// Returns the number of jobs queued.
+func (w *Watcher) Scan(ctx context.Context) (int, error) {
+	w.scanMu.Lock()
+	defer w.scanMu.Unlock()
+
+	if err := w.load(); err != nil {
+		return 0, err
+	}
+
+	now := w.now()
+	seen := make(map[string]bool)
+	queued := 0
+	for _, folder := range w.Folders() {
+		if !folder.QueueExisting && !w.baselined(folder.Path) {
+			w.baseline(folder.Path, now, seen)
+			continue
+		}
+
+		ready := w.findReady(folder.Path, now, seen)
+		if len(ready) == 0 {
+			continue
+		}
+
+		n, err := w.enqueue(ctx, folder, ready, now)
+		if err != nil {
+			return queued, err
+		}
+		queued += n
+	}
+
+	w.mu.Lock()
+	// Forget observations of files that have gone away
+	for path := range w.observed {
+		if !seen[path] {
+			delete(w.observed, path)
+		}
+	}
+	w.lastScan = now
+	w.mu.Unlock()
+
+	return queued, nil
+}
+
+// load reads handled files from the store on first use
+func (w *Watcher) load() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.loaded || w.store == nil {
+		return nil
+	}
+	files, err := w.store.GetWatchedFiles()
+	if err != nil {
+		return fmt.Errorf("load watched files: %w", err)
+	}
+	for _, f := range files {
+		w.handled[f.Path] = f
+	}
+	w.loaded = true
+	return nil
+}
+
+// baselined reports whether the files already in a folder were recorded.
+// Folders watched before baselines were recorded count once they have any
+// handled file.
+func (w *Watcher) baselined(root string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if f, ok := w.handled[root]; ok && f.Outcome == OutcomeFolder {
+		return true
+	}
+	for path := range w.handled {
+		if util.IsWithin(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseline records the files already in a newly watched folder as handled,
+// so only files added from now on are queued
+func (w *Watcher) baseline(root string, now time.Time, seen map[string]bool) {
+	// An unavailable folder (e.g. an unmounted share) is baselined once it's back
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return
+	}
+
+	w.mu.Lock()
+	var files []*File
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !ffmpeg.IsVideoFile(path) || ffmpeg.IsTempPath(path) || seen[path] {
+			return nil
+		}
+		if _, ok := w.handled[path]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		seen[path] = true
+		files = append(files, &File{Path: path, Size: info.Size(), ModTime: info.ModTime(), Outcome: OutcomeExisting, SeenAt: now})
+		return nil
+	})
+	w.mu.Unlock()
+
+	files = append(files, &File{Path: root, Outcome: OutcomeFolder, SeenAt: now})
+	w.record(files)
+	logger.Info("Watching new folder, existing files not queued", "folder", root, "existing", len(files)-1)
+}
+
+// findReady walks a folder and returns the unhandled files that are stable
+func (w *Watcher) findReady(root string, now time.Time, seen map[string]bool) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var ready []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !ffmpeg.IsVideoFile(path) || ffmpeg.IsTempPath(path) || seen[path] {
+			return nil // seen: already checked via an overlapping folder this scan
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		current := observation{size: info.Size(), modTime: info.ModTime()}
+		if f, ok := w.handled[path]; ok && f.Size == current.size && f.ModTime.Equal(current.modTime) {
+			return nil
+		}
+		if w.queue.HasActiveJob(path) {
+			return nil // Queued by hand
+		}
+
+		seen[path] = true
+		previous, observed := w.observed[path]
+		w.observed[path] = current
+		if !observed || previous != current || now.Sub(current.modTime) < w.stable {
+			return nil // New or still changing
+		}
+		ready = append(ready, path)
+		return nil
+	})
+	return ready
+}
+
+// enqueue probes ready files and queues those the folder's preset would transcode.
+// Files that fail to probe are left unhandled and retried at the next scan.
+func (w *Watcher) enqueue(ctx context.Context, folder config.WatchFolder, paths []string, now time.Time) (int, error) {
+	probes, err := w.prober.GetVideoFilesWithProgress(ctx, paths, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var toQueue []*ffmpeg.ProbeResult
+	files := make([]*File, 0, len(probes))
+	w.mu.Lock()
+	for _, probe := range probes {
+		obs := w.observed[probe.Path]
+		file := &File{Path: probe.Path, Size: obs.size, ModTime: obs.modTime, Outcome: OutcomeQueued, SeenAt: now}
+		if reason := w.queue.SkipReason(probe, folder.PresetID); reason != "" {
+			file.Outcome = OutcomeSkipped
+		} else {
+			toQueue = append(toQueue, probe)
+		}
+		files = append(files, file)
+	}
+	w.mu.Unlock()
+
+	if len(toQueue) > 0 {
+		if _, err := w.queue.AddMultiple(toQueue, folder.PresetID, folder.SmartShrinkQuality); err != nil {
+			return 0, err
+		}
+		logger.Info("Queued new files from watch folder", "folder", folder.Path, "count", len(toQueue), "preset", folder.PresetID)
+	}
+
+	w.record(files)
+	return len(toQueue), nil
+}
+
+// recordOutput marks a completed job's output as handled if it is in a watch folder
+func (w *Watcher) recordOutput(path string) {
+	if path == "" || !w.inFolder(path) {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	w.record([]*File{{Path: path, Size: info.Size(), ModTime: info.ModTime(), Outcome: OutcomeOutput, SeenAt: w.now()}})
+}
+
+// inFolder reports whether path is inside one of the watch folders
+func (w *Watcher) inFolder(path string) bool {
+	for _, folder := range w.Folders() {
+		if util.IsWithin(path, folder.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// record remembers handled files and persists them
+func (w *Watcher) record(files []*File) {
+	if len(files) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	for _, f := range files {
+		w.handled[f.Path] = f
+		delete(w.observed, f.Path)
+	}
+	w.mu.Unlock()
+
+	if w.store != nil {
+		if err := w.store.SaveWatchedFiles(files); err != nil {
+			logger.Warn("Failed to save watched files", "error", err.Error())
+		}
+	}
+}
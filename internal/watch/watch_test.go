@@ -0,0 +1,300 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gwlsn/shrinkray/internal/browse"
+	"github.com/gwlsn/shrinkray/internal/config"
+	"github.com/gwlsn/shrinkray/internal/ffmpeg"
+	"github.com/gwlsn/shrinkray/internal/jobs"
+)
+
+// fakeProber probes files without ffprobe. Files with "hevc" in the name
+// are reported as already HEVC.
+type fakeProber struct {
+	mu     sync.Mutex
+	probed []string
+}
+
+func (p *fakeProber) GetVideoFilesWithProgress(ctx context.Context, paths []string, onProgress browse.ProgressCallback) ([]*ffmpeg.ProbeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var results []*ffmpeg.ProbeResult
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		p.probed = append(p.probed, path)
+		isHEVC := strings.Contains(filepath.Base(path), "hevc")
+		codec := "h264"
+		if isHEVC {
+			codec = "hevc"
+		}
+		results = append(results, &ffmpeg.ProbeResult{
+			Path:       path,
+			Size:       info.Size(),
+			Duration:   10 * time.Second,
+			VideoCodec: codec,
+			IsHEVC:     isHEVC,
+		})
+	}
+	return results, nil
+}
+
+// memStore is an in-memory Store for tests
+type memStore struct {
+	mu    sync.Mutex
+	files map[string]*File
+}
+
+func (s *memStore) GetWatchedFiles() ([]*File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var files []*File
+	for _, f := range s.files {
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func (s *memStore) SaveWatchedFiles(files []*File) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range files {
+		s.files[f.Path] = f
+	}
+	return nil
+}
+
+// setup creates an empty watch folder and a watcher for it
+func setup(t *testing.T, stable time.Duration) (dir string, w *Watcher, queue *jobs.Queue, store *memStore) {
+	t.Helper()
+	dir = t.TempDir()
+	queue = jobs.NewQueue()
+	store = &memStore{files: make(map[string]*File)}
+	folders := []config.WatchFolder{{Path: dir, PresetID: "compress-hevc"}}
+	w = New(queue, &fakeProber{}, store, folders, stable)
+	scan(t, w) // Baseline of the empty folder
+	return dir, w, queue, store
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func scan(t *testing.T, w *Watcher) int {
+	t.Helper()
+	n, err := w.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	return n
+}
+
+func TestScanQueuesStableFiles(t *testing.T) {
+	dir, w, queue, store := setup(t, 0)
+	path := filepath.Join(dir, "movie.mkv")
+	writeFile(t, path, "video")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not a video")
+	writeFile(t, filepath.Join(dir, "other.shrinkray.tmp.mkv"), "in progress")
+
+	// The first sighting only records the file
+	if n := scan(t, w); n != 0 {
+		t.Fatalf("expected nothing queued on first sighting, got %d", n)
+	}
+
+	// Unchanged since the last scan: queued
+	if n := scan(t, w); n != 1 {
+		t.Fatalf("expected 1 queued, got %d", n)
+	}
+	all := queue.GetAll()
+	if len(all) != 1 || all[0].InputPath != path || all[0].PresetID != "compress-hevc" {
+		t.Fatalf("unexpected jobs: %+v", all)
+	}
+	if f := store.files[path]; f == nil || f.Outcome != OutcomeQueued {
+		t.Errorf("expected file recorded as queued, got %+v", f)
+	}
+	if w.LastScan().IsZero() {
+		t.Error("expected last scan time to be set")
+	}
+
+	// Not queued again, even once the job is gone
+	queue.Clear("")
+	if n := scan(t, w); n != 0 {
+		t.Errorf("expected handled file not to be re-queued, got %d", n)
+	}
+
+	// A restarted watcher remembers handled files via the store
+	w2 := New(queue, &fakeProber{}, store, w.Folders(), 0)
+	scan(t, w2)
+	if n := scan(t, w2); n != 0 {
+		t.Errorf("expected handled file not to be re-queued after restart, got %d", n)
+	}
+}
+
+func TestScanBaseline(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "old.mkv")
+	writeFile(t, existing, "video")
+
+	queue := jobs.NewQueue()
+	store := &memStore{files: make(map[string]*File)}
+	w := New(queue, &fakeProber{}, store, []config.WatchFolder{{Path: dir, PresetID: "compress-hevc"}}, 0)
+
+	// Files already in a new watch folder are recorded, not queued
+	scan(t, w)
+	if n := scan(t, w); n != 0 {
+		t.Fatalf("expected existing files not to be queued, got %d", n)
+	}
+	if f := store.files[existing]; f == nil || f.Outcome != OutcomeExisting {
+		t.Errorf("expected file recorded as existing, got %+v", f)
+	}
+
+	// Files added afterwards are queued, also after a restart
+	added := filepath.Join(dir, "new.mkv")
+	writeFile(t, added, "video")
+	w2 := New(queue, &fakeProber{}, store, w.Folders(), 0)
+	scan(t, w2)
+	if n := scan(t, w2); n != 1 {
+		t.Fatalf("expected the new file queued, got %d", n)
+	}
+	if all := queue.GetAll(); len(all) != 1 || all[0].InputPath != added {
+		t.Errorf("unexpected jobs: %+v", all)
+	}
+}
+
+func TestScanQueueExisting(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "old.mkv"), "video")
+
+	queue := jobs.NewQueue()
+	folders := []config.WatchFolder{{Path: dir, PresetID: "compress-hevc", QueueExisting: true}}
+	w := New(queue, &fakeProber{}, nil, folders, 0)
+
+	scan(t, w)
+	if n := scan(t, w); n != 1 {
+		t.Errorf("expected the existing file queued, got %d", n)
+	}
+}
+
+func TestScanWaitsForChangingFiles(t *testing.T) {
+	dir, w, queue, _ := setup(t, 0)
+	path := filepath.Join(dir, "copying.mkv")
+
+	writeFile(t, path, "part")
+	scan(t, w)
+	writeFile(t, path, "partial copy")
+	if n := scan(t, w); n != 0 {
+		t.Fatalf("expected a growing file not to be queued, got %d", n)
+	}
+
+	if n := scan(t, w); n != 1 {
+		t.Fatalf("expected file queued once it stopped changing, got %d", n)
+	}
+	if len(queue.GetAll()) != 1 {
+		t.Errorf("expected 1 job, got %d", len(queue.GetAll()))
+	}
+}
+
+func TestScanStablePeriod(t *testing.T) {
+	dir, w, _, _ := setup(t, 10*time.Minute)
+	path := filepath.Join(dir, "movie.mkv")
+	writeFile(t, path, "video")
+
+	modTime := time.Now()
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	w.now = func() time.Time { return modTime.Add(5 * time.Minute) }
+	scan(t, w)
+	if n := scan(t, w); n != 0 {
+		t.Fatalf("expected no queue before the stable period, got %d", n)
+	}
+
+	w.now = func() time.Time { return modTime.Add(10 * time.Minute) }
+	if n := scan(t, w); n != 1 {
+		t.Errorf("expected file queued after the stable period, got %d", n)
+	}
+}
+
+func TestScanSkipsFilesAlreadyAtTarget(t *testing.T) {
+	dir, w, queue, store := setup(t, 0)
+	path := filepath.Join(dir, "movie.hevc.mkv")
+	writeFile(t, path, "video")
+
+	scan(t, w)
+	if n := scan(t, w); n != 0 {
+		t.Fatalf("expected already-HEVC file not to be queued, got %d", n)
+	}
+	if len(queue.GetAll()) != 0 {
+		t.Errorf("expected no jobs, got %d", len(queue.GetAll()))
+	}
+	if f := store.files[path]; f == nil || f.Outcome != OutcomeSkipped {
+		t.Errorf("expected file recorded as skipped, got %+v", f)
+	}
+
+	// Not probed again on later scans
+	prober := w.prober.(*fakeProber)
+	scan(t, w)
+	if len(prober.probed) != 1 {
+		t.Errorf("expected file probed once, got %d", len(prober.probed))
+	}
+}
+
+func TestScanRequeuesReplacedFiles(t *testing.T) {
+	dir, w, queue, _ := setup(t, 0)
+	path := filepath.Join(dir, "movie.mkv")
+	writeFile(t, path, "video")
+
+	scan(t, w)
+	scan(t, w)
+	queue.Clear("")
+
+	// A different file at the same path is new media
+	writeFile(t, path, "a new release")
+	scan(t, w)
+	if n := scan(t, w); n != 1 {
+		t.Errorf("expected replaced file to be queued, got %d", n)
+	}
+}
+
+func TestRecordOutput(t *testing.T) {
+	dir, w, queue, store := setup(t, 0)
+	output := filepath.Join(dir, "movie [HEVC].mkv")
+	writeFile(t, output, "transcoded")
+
+	dotted := filepath.Join(dir, "..movie.mkv")
+	writeFile(t, dotted, "transcoded")
+	elsewhere := filepath.Join(t.TempDir(), "elsewhere.mkv")
+	writeFile(t, elsewhere, "transcoded")
+	w.recordOutput(output)
+	w.recordOutput(dotted)
+	w.recordOutput(elsewhere)
+
+	if f := store.files[output]; f == nil || f.Outcome != OutcomeOutput {
+		t.Fatalf("expected output recorded, got %+v", f)
+	}
+	if f := store.files[dotted]; f == nil || f.Outcome != OutcomeOutput {
+		t.Errorf("expected output named \"..movie.mkv\" recorded, got %+v", f)
+	}
+	if f := store.files[elsewhere]; f != nil {
+		t.Errorf("expected only outputs in watch folders to be recorded, got %+v", f)
+	}
+
+	scan(t, w)
+	if n := scan(t, w); n != 0 || len(queue.GetAll()) != 0 {
+		t.Errorf("expected output not to be queued, got %d", n)
+	}
+}